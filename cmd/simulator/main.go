@@ -5,75 +5,269 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log/slog"
+	"math/rand/v2"
 	"os"
 	"os/signal"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	_ "net/http/pprof"
 
 	"github.com/allthepins/iot-sensor-network-simulator/internal/aggregator"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/bridge"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/buildinfo"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/config"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/dashboard"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/estimate"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/grpc"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/ingest"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/kafka"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/lastvalue"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/livefeed"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/logging"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/memguard"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/mqtt"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/nats"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/publisher"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/replay"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/sensor"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/server"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/shutdown"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sink"
 	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/time/rate"
 )
 
 func main() {
-	// Simulation and metrics parameters
-	// TODO Set simulation params via args or config values
+	// `simulator estimate [flags]` prints the resources the simulation would need, without running it.
+	args := os.Args[1:]
+	estimateOnly := len(args) > 0 && args[0] == "estimate"
+	if estimateOnly {
+		args = args[1:]
+	}
+
+	// Settings configurable from a YAML file (-config=sim.yaml) and the command line,
+	// e.g. `simulator -sensors=1000 -interval=50ms -duration=2m`.
+	cfg, err := config.Parse(os.Args[0], args, os.Stderr)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if errors.Is(err, config.ErrVersion) {
+		fmt.Println("simulator", buildinfo.Get())
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+		os.Exit(2)
+	}
+
+	// Simulation parameters not (yet) configurable from the command line.
 	var (
-		sensorCount        = 5000
-		simulationDuration = 10 * time.Minute // Increased simulation duration to allow more time to monitor metrics.
-		sensorInterval     = 100 * time.Millisecond
-		metricsAddr        = ":2112"
-		pprofAddr          = ":6060"
-		enableNATS         = true // Feature flag for NATS integration. TODO Set via env var
+		timestampPrecision  = time.Millisecond // Emitted timestamps are truncated to this precision.
+		dataChBuffer        = 1000
+		depthSampleInterval = time.Second      // How often the data channel's depth is sampled into the channel depth metric.
+		sensorShutdownGrace = 5 * time.Second  // How long to wait for sensors to confirm they've stopped.
+		sensorMaxRestarts   = 0                // How many times a panicking sensor is restarted before it's given up on (0 restarts it indefinitely).
+		reconnectBufferSize = 10_000           // How many messages the publisher holds while NATS reconnects.
+		publishRetries      = 3                // How many times a failed publish is retried, with exponential backoff, before it's given up on.
+		publishTimeout      = 2 * time.Second  // How long each publish waits for the broker (e.g. its JetStream ack) before it's failed, as a timeout.
+		publishDrainTimeout = 10 * time.Second // How long the publisher keeps draining the data channel on shutdown before abandoning what's left.
+		consumerDrainGrace  = 15 * time.Second // How long shutdown waits for the aggregator and publisher to drain the data channel (beyond publishDrainTimeout).
+		sinkFlushTimeout    = 10 * time.Second // How long shutdown waits for the sinks (Kafka, bridge, CSV, Parquet, socket) and gRPC streams to finish.
+		publisherWorkers    = 1                // Concurrent publish workers. Messages are sharded by sensor ID, preserving each sensor's order.
+		publishBatchSize    = 0                // When greater than 1, readings are published as JSON arrays of up to this many, to <prefix>.batch.<worker>.
+		compressThreshold   = 0                // When positive, NATS payloads larger than this many bytes are gzip-compressed (e.g. 1024, with batching).
+		csvFlushInterval    = time.Second      // How often the CSV sink (-csv-out) flushes readings to its file.
+		sinkQueueSize       = 10_000           // How many records each sink queues before dropping, so a slow sink doesn't stall its consumer.
+		dashboardLogFile    = "simulator.log"  // Where logs are written in dashboard mode (-dashboard), unless a log file is configured.
 	)
 
+	// Device profiles, assigned to sensors round-robin by ID.
+	// A profile's Distribution (e.g. sensor.Normal or sensor.Sine) sets the values its sensors generate,
+	// to simulate a heterogeneous fleet. Without one, sensors generate uniform values in [0, 1).
+	sensorProfiles := []sensor.Profile{
+		{Name: "standard", Model: "SIM-100", FirmwareVersion: "1.4.2", Type: "temperature", Unit: "celsius"},
+		{Name: "legacy", Model: "SIM-50", FirmwareVersion: "0.9.8", Type: "humidity", Unit: "percent"},
+	}
+
+	// Sensor positions, included in their readings (e.g. for a map demo).
+	// Sensors are placed at sensorLocations[id-1] when listed, or else at random within sensorLocationBox when it's set,
+	// reproducibly for a given seed. Without either, readings carry no location.
+	sensorLocations, _ := sensor.ParseLocations(cfg.Locations) // Validated with the config.
+	var sensorLocationBox *sensor.BoundingBox
+	if cfg.LocationBox != "" {
+		bbox, _ := sensor.ParseBoundingBox(cfg.LocationBox) // Validated with the config.
+		sensorLocationBox = &bbox
+	}
+
+	// Groups of sensors sharing an underlying signal, e.g. a room's thermostats, for testing correlation-aware analytics.
+	// Each member reads its group's base value plus its own noise, overriding its profile's distribution. For example:
+	//
+	//	{Name: "room-1", Members: []int{1, 2, 3}, Base: sensor.Sine{Amplitude: 2, Period: time.Hour, Offset: 21},
+	//		Noise: sensor.Normal{StdDev: 0.1}, Hold: cfg.SensorInterval}
+	var sensorGroups []sensor.GroupConfig
+
+	// NATS is only used when it's the sink sensor data is published to.
+	if cfg.Sink != config.SinkNATS {
+		cfg.NATS.Enabled = false
+	}
+	// Replayed readings take the synthetic sensors' place.
+	if cfg.Replay != "" {
+		cfg.SensorCount = 0
+	}
+	payloadCodec, _ := codec.Parse(cfg.Codec) // Validated with the config.
+
+	if estimateOnly {
+		e := estimate.Resources(estimate.Config{
+			SensorCount:      cfg.SensorCount,
+			SensorInterval:   cfg.SensorInterval,
+			ChannelBuffer:    dataChBuffer,
+			Profiles:         len(sensorProfiles),
+			NATSEnabled:      cfg.NATS.Enabled || cfg.Sink != config.SinkNATS, // Publishing to MQTT or Kafka exports the same series.
+			BridgeEnabled:    cfg.Bridge.Enabled,
+			SubjectPrefix:    cfg.NATS.SubjectPrefix,
+			PublisherWorkers: publisherWorkers,
+			Codec:            payloadCodec,
+		})
+		if err := e.Print(os.Stdout); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// logging setup
-	logger := logging.NewJSONLogger()
+	// Logs are written to stdout, or to a rotated log file if one is configured.
+	// The dashboard owns the terminal, so in dashboard mode logs are written to a file regardless.
+	var logOutput io.Writer = os.Stdout
+	switch {
+	case cfg.Log.File != "":
+		logFile := logging.NewFileWriter(logging.FileOptions{
+			Path:       cfg.Log.File,
+			MaxSizeMB:  cfg.Log.MaxSizeMB,
+			MaxBackups: cfg.Log.MaxBackups,
+			MaxAgeDays: cfg.Log.MaxAgeDays,
+		})
+		defer logFile.Close()
+		logOutput = logFile
+	case cfg.Dashboard:
+		logFile, err := os.OpenFile(dashboardLogFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to open dashboard log file %s: %v\n", dashboardLogFile, err)
+			os.Exit(1)
+		}
+		defer logFile.Close()
+		logOutput = logFile
+	}
+	logLevel, err := logging.ParseLevel(cfg.Log.Level)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log level: %v\n", err)
+		os.Exit(2)
+	}
+	logger, err := logging.NewLogger(logging.Options{Level: logLevel, Format: cfg.Log.Format, Output: logOutput})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid log format: %v\n", err)
+		os.Exit(2)
+	}
 	slog.SetDefault(logger)
 
+	build := buildinfo.Get()
+	logger.Info("Simulator starting", "version", build.Version, "commit", build.Commit, "go_version", build.GoVersion)
+
+	deviceIDs, _ := sensor.NewIDAllocator(cfg.DeviceIDs) // Validated with the config.
+	var valueGen *sensor.ExprGenerator
+	if cfg.ValueExpression != "" {
+		valueGen, _ = sensor.NewExprGenerator(cfg.ValueExpression) // Validated with the config.
+	}
+
+	var replaySource *replay.Source
+	if cfg.Replay != "" {
+		if replaySource, err = replay.New(cfg.Replay, cfg.ReplaySpeed, logger); err != nil {
+			logger.Error("Invalid replay file", "error", err)
+			os.Exit(1)
+		}
+	}
+
 	// Metrics and Server setup
 	reg := prometheus.NewRegistry()
-	appMetrics := metrics.NewMetrics(reg)
-	metricsServer := server.NewMetricsServer(metricsAddr, reg)
+	broker := "none"
+	if cfg.NATS.Enabled || cfg.Sink != config.SinkNATS {
+		broker = cfg.Sink
+	}
+	appMetrics := metrics.NewMetrics(reg, metrics.ConfigInfo{
+		SensorCount:    cfg.SensorCount,
+		SensorInterval: cfg.SensorInterval,
+		Broker:         broker,
+		Encoding:       cfg.Codec,
+	})
+	metricsServer := server.NewMetricsServer(cfg.MetricsAddr, reg, logger)
+
+	var latestCache *lastvalue.Cache
+	if cfg.LatestCache {
+		latestCache = lastvalue.New()
+		metricsServer.Handle("/latest/", latestCache.Handler())
+	}
+	var liveFeed *livefeed.Hub
+	if cfg.LiveFeed.Enabled {
+		liveFeed = livefeed.New(cfg.LiveFeed.Queue, appMetrics, logger)
+		metricsServer.Handle("/ws", liveFeed.Handler())
+	}
 
 	// Main context that can be cancelled by an OS signal (e.g `ctrl+c`).
-	mainCtx, stopMain := context.WithCancel(context.Background())
+	// It is canceled with a cause, so components can log why they're shutting down.
+	mainCtx, stopMain := shutdown.WithCancelCause(context.Background())
 
 	// Start the metrics server in a separate goroutine.
-	go metricsServer.Serve(mainCtx)
+	// It's best-effort: losing observability shouldn't kill a running load test,
+	// so if it can't bind its address the simulation carries on without metrics exposure.
+	metricsAvailable := true
+	if err := metricsServer.Listen(); err != nil {
+		logger.Warn("METRICS UNAVAILABLE: continuing the simulation without metrics exposure", "addr", cfg.MetricsAddr, "error", err)
+		metricsAvailable = false
+	} else {
+		go func() {
+			if err := metricsServer.Serve(mainCtx); err != nil {
+				logger.Warn("METRICS UNAVAILABLE: metrics server stopped", "addr", cfg.MetricsAddr, "error", err)
+			}
+		}()
+	}
 
 	// Start the pprof server in a separate goroutine.
 	// This allows us to use go pprof tool profiling.
-	go server.StartPprofServer(mainCtx, pprofAddr)
+	go server.StartPprofServer(mainCtx, cfg.PprofAddr, logger)
 
-	// NATS setup (`enableNATS` feature flag controlled)
+	// NATS setup (`-nats` flag controlled)
 	var natsClient *nats.Client
-	var publisherWg sync.WaitGroup
+	var publisherWg, bridgeWg, grpcWg sync.WaitGroup
 
-	if enableNATS {
+	if cfg.NATS.Enabled {
+		// The NATS_URL environment variable (e.g. set by Docker Compose) takes precedence over the configured URL.
 		natsURL := os.Getenv("NATS_URL")
 		if natsURL == "" {
-			natsURL = "nats://localhost:4222"
+			natsURL = cfg.NATS.URL
 		}
 
 		natsCfg := nats.DefaultConfig()
 		natsCfg.URL = natsURL
+		natsCfg.StreamName = cfg.NATS.Stream
+		natsCfg.SubjectPrefix = cfg.NATS.SubjectPrefix
+		natsCfg.Storage, _ = nats.ParseStorage(cfg.NATS.Storage) // Validated with the config.
+		natsCfg.Replicas = cfg.NATS.Replicas
 
 		var err error
 		natsClient, err = nats.NewClient(natsCfg, logger)
 		if err != nil {
 			logger.Error("Failed to connect to NATS, continuiong without NATS", "error", err)
 			appMetrics.NATSConnectionStatus.Set(0)
-			enableNATS = false
+			cfg.NATS.Enabled = false
 		} else {
 			logger.Info("NATS client initialized", "url", natsURL)
 			appMetrics.NATSConnectionStatus.Set(1)
@@ -86,6 +280,47 @@ func main() {
 		}
 	}
 
+	// MQTT setup (`-sink=mqtt` flag controlled)
+	var mqttClient *mqtt.Client
+	if cfg.Sink == config.SinkMQTT {
+		mqttCfg := mqtt.DefaultConfig()
+		mqttCfg.URL = cfg.MQTT.URL
+		mqttCfg.ClientID = cfg.MQTT.ClientID
+		mqttCfg.TopicPrefix = cfg.MQTT.TopicPrefix
+
+		var err error
+		mqttClient, err = mqtt.NewClient(mqttCfg, logger)
+		if err != nil {
+			logger.Error("Failed to connect to MQTT, continuing without MQTT", "error", err)
+		} else {
+			logger.Info("MQTT client initialized", "url", cfg.MQTT.URL)
+
+			defer func() {
+				if err := mqttClient.Close(); err != nil {
+					logger.Error("Error closing MQTT client", "error", err)
+				}
+			}()
+		}
+	}
+
+	// Kafka setup (`-sink=kafka` flag controlled)
+	var kafkaClient *kafka.Client
+	if cfg.Sink == config.SinkKafka {
+		kafkaCfg := kafka.DefaultConfig()
+		kafkaCfg.Brokers, _ = kafka.ParseBrokers(cfg.Kafka.Brokers) // Validated with the config.
+		kafkaCfg.Topic = cfg.Kafka.Topic
+		kafkaCfg.BatchSize = cfg.Kafka.BatchSize
+		kafkaCfg.BatchTimeout = cfg.Kafka.BatchTimeout
+
+		var err error
+		kafkaClient, err = kafka.NewClient(kafkaCfg, logger)
+		if err != nil {
+			logger.Error("Failed to connect to Kafka, continuing without Kafka", "error", err)
+		} else {
+			logger.Info("Kafka client initialized", "brokers", cfg.Kafka.Brokers, "topic", cfg.Kafka.Topic)
+		}
+	}
+
 	// Channel to listen for interrupt signals.
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, os.Interrupt) // Listen for SIGINT
@@ -95,44 +330,244 @@ func main() {
 	go func() {
 		<-sigCh
 		logger.Info("Shutdown signal received, starting graceful shutdown.")
-		stopMain()
+		stopMain(shutdown.ErrSignal)
 	}()
 
 	// Create a derived context that is automatically cancelled after the simulation duration,
 	// or by the main context being cancelled by an OS interrupt.
 	// This context is the primary signal for all goroutines to begin graceful shutdown.
-	ctx, cancel := context.WithTimeout(mainCtx, simulationDuration)
+	ctx, cancel := shutdown.WithDuration(mainCtx, cfg.SimulationDuration)
 	defer cancel()
 
-	// Buffered channel sensors send data to.
-	dataCh := make(chan model.SensorData, 1000)
+	// Start the memory guard, which sheds load by having sensors drop readings the data channel has no room for
+	// while under memory pressure, rather than piling up blocked sends.
+	var shedding atomic.Bool
+	if cfg.MemoryLimit > 0 {
+		guard := memguard.New(memguard.Config{Limit: cfg.MemoryLimit}, appMetrics, logger)
+		guard.OnPressure(shedding.Store)
+		go guard.Run(ctx)
+	}
+
+	// Buffered channel the aggregator and publisher consume sensor data from.
+	dataCh := make(chan model.SensorData, dataChBuffer)
+
+	// Channel sensors send data to.
+	// With the latest-value cache, the live feed or the CSV, Parquet or socket sink enabled, readings pass through their taps on their way to dataCh.
+	sensorCh := dataCh
+	if latestCache != nil {
+		in := make(chan model.SensorData, dataChBuffer)
+		go latestCache.Tap(in, sensorCh)
+		sensorCh = in
+	}
+	if liveFeed != nil {
+		in := make(chan model.SensorData, dataChBuffer)
+		go liveFeed.Tap(in, sensorCh)
+		sensorCh = in
+	}
+	var csvWg sync.WaitGroup
+	if cfg.CSVOut != "" {
+		if csvSink, err := sink.NewCSVSink(cfg.CSVOut, csvFlushInterval, appMetrics, logger); err != nil {
+			logger.Error("Failed to open CSV sink, continuing without it", "error", err)
+		} else {
+			// The queue keeps a slow disk from holding readings back.
+			csvQueue := sink.NewAsyncSink("csv", csvSink, sinkQueueSize, appMetrics, logger)
+			in := make(chan model.SensorData, dataChBuffer)
+			csvWg.Add(1)
+			go func(out chan<- model.SensorData) {
+				defer csvWg.Done()
+				sink.Tap(in, out, csvQueue, logger)
+				if err := csvQueue.Close(); err != nil {
+					logger.Error("Error closing CSV sink", "error", err)
+				}
+			}(sensorCh)
+			sensorCh = in
+		}
+	}
+	var parquetWg sync.WaitGroup
+	if cfg.Parquet.Output != "" {
+		parquetOpts := sink.ParquetOptions{
+			RowGroupSize: cfg.Parquet.RowGroupSize,
+			RollRecords:  cfg.Parquet.RollRecords,
+			RollInterval: cfg.Parquet.RollInterval,
+		}
+		// The sink closes its current file once ctx is done, so the files are readable even if shutdown times out.
+		if parquetSink, err := sink.NewParquetSink(ctx, cfg.Parquet.Output, parquetOpts, appMetrics, logger); err != nil {
+			logger.Error("Failed to open Parquet sink, continuing without it", "error", err)
+		} else {
+			parquetQueue := sink.NewAsyncSink("parquet", parquetSink, sinkQueueSize, appMetrics, logger)
+			in := make(chan model.SensorData, dataChBuffer)
+			parquetWg.Add(1)
+			go func(out chan<- model.SensorData) {
+				defer parquetWg.Done()
+				sink.Tap(in, out, parquetQueue, logger)
+				if err := parquetQueue.Close(); err != nil {
+					logger.Error("Error closing Parquet sink", "error", err)
+				}
+			}(sensorCh)
+			sensorCh = in
+		}
+	}
+
+	var socketWg sync.WaitGroup
+	if cfg.SocketOut != "" {
+		network, addr, _ := sink.ParseSocketAddr(cfg.SocketOut) // Validated with the config.
+		if socketSink, err := sink.NewSocketSink(network, addr); err != nil {
+			logger.Error("Failed to connect socket sink, continuing without it", "error", err)
+		} else {
+			// The queue keeps a slow or unreachable collector from holding readings back.
+			socketQueue := sink.NewAsyncSink("socket", socketSink, sinkQueueSize, appMetrics, logger)
+			in := make(chan model.SensorData, dataChBuffer)
+			socketWg.Add(1)
+			go func(out chan<- model.SensorData) {
+				defer socketWg.Done()
+				sink.Tap(in, out, socketQueue, logger)
+				if err := socketQueue.Close(); err != nil {
+					logger.Error("Error closing socket sink", "error", err)
+				}
+			}(sensorCh)
+			sensorCh = in
+		}
+	}
+
+	// Accept readings pushed by external sensors at `POST /ingest` on the metrics address,
+	// feeding them into the pipeline alongside the simulated sensors' readings.
+	// The gRPC service (-grpc-addr) ingests readings through the same Ingester.
+	var ingester *ingest.Ingester
+	if cfg.Ingest || cfg.GRPCAddr != "" {
+		ingester = ingest.New(sensorCh, appMetrics, logger)
+	}
+	if cfg.Ingest {
+		metricsServer.Handle("/ingest", ingester.Handler())
+	}
+
+	// Periodically sample how many readings are buffered in the data channel
+	// (and the channel sensors send to, if it's another one), to expose backpressure.
+	// Reading a channel's length doesn't lock it, so sampling doesn't contend with the senders or receivers.
+	go func() {
+		channels := map[string]chan model.SensorData{"data": dataCh}
+		if sensorCh != dataCh {
+			channels["sensor"] = sensorCh
+		}
+		for name, ch := range channels {
+			appMetrics.ChannelCapacity.WithLabelValues(name).Set(float64(cap(ch)))
+		}
+
+		ticker := time.NewTicker(depthSampleInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				for name, ch := range channels {
+					appMetrics.ChannelDepth.WithLabelValues(name).Set(float64(len(ch)))
+				}
+			}
+		}
+	}()
 
 	// WaitGroups to coordinate a graceful shutdown.
-	// sensorsWg for the sensors.
-	// aggregatorWg for the aggregator.
-	var sensorsWg, aggregatorWg sync.WaitGroup
+	// aggregatorWg for the aggregator. Sensors are waited for by the sensor manager.
+	var aggregatorWg sync.WaitGroup
+
+	// Open the file JSON summaries are appended to.
+	summaryOutput := aggregator.SummaryOutput(cfg.Aggregator.SummaryOutput) // Validated with the config.
+	var summaryWriter io.Writer
+	if summaryOutput == aggregator.SummaryJSON {
+		f, err := os.OpenFile(cfg.Aggregator.SummaryFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			logger.Error("Failed to open summary file", "path", cfg.Aggregator.SummaryFile, "error", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		summaryWriter = f
+	}
+
+	// Instantiate the aggregator, and serve its current stats at `GET /stats` on the metrics address.
+	aggOpts := []aggregator.Option{
+		aggregator.WithSummaryOutput(summaryOutput, summaryWriter),
+		aggregator.WithStaleAfter(cfg.Aggregator.StaleAfter),
+	}
+	if cfg.Aggregator.WindowedStats {
+		aggOpts = append(aggOpts, aggregator.WithWindowedStats())
+	}
+	if cfg.Aggregator.DetectAnomalies {
+		aggOpts = append(aggOpts, aggregator.WithAnomalyDetection(aggregator.AnomalyConfig{}))
+	}
+	agg := aggregator.New(dataCh, appMetrics, logger, aggOpts...)
+	metricsServer.Handle("/stats", agg.StatsHandler())
+
+	// Serve the gRPC service, which ingests pushed readings and streams the aggregator's window summaries to subscribers.
+	// Like the metrics server, it's best-effort: the simulation carries on without it if it can't bind its address.
+	if cfg.GRPCAddr != "" {
+		grpcServer := grpc.NewServer(cfg.GRPCAddr, ingester, logger)
+		if err := grpcServer.Listen(); err != nil {
+			logger.Error("gRPC service unavailable, continuing without it", "addr", cfg.GRPCAddr, "error", err)
+		} else {
+			go grpcServer.Broadcast(agg.Windows())
+			grpcWg.Add(1)
+			go func() {
+				defer grpcWg.Done()
+				if err := grpcServer.Serve(ctx); err != nil {
+					logger.Error("gRPC server stopped", "addr", cfg.GRPCAddr, "error", err)
+				}
+			}()
+		}
+	}
 
 	// Start the aggregator.
 	aggregatorWg.Add(1)
 	go func() {
 		defer aggregatorWg.Done()
 
-		// Instantiate and run the aggregator.
+		// Run the aggregator.
 		// It should run until its context is cancelled
 		// and the data channel is drained and closed.
-		aggregator.New(dataCh, appMetrics, logger).Run(ctx)
+		agg.Run(ctx)
 	}()
 
-	// Start the NATS publisher.
-	if enableNATS && natsClient != nil {
+	// The broker the publisher publishes to, if it's connected.
+	var pubSink publisher.Sink
+	var subjectPrefix string
+	switch {
+	case cfg.NATS.Enabled && natsClient != nil:
+		pubSink, subjectPrefix = natsClient, natsClient.SubjectPrefix()
+	case mqttClient != nil:
+		pubSink, subjectPrefix = mqttClient, mqttClient.SubjectPrefix()
+	case kafkaClient != nil:
+		pubSink, subjectPrefix = kafkaClient, kafkaClient.SubjectPrefix()
+	}
+
+	// Report ready (at /readyz) while the broker is connected, or always without one.
+	metricsServer.SetReadiness(func() bool {
+		return pubSink == nil || pubSink.IsConnected()
+	})
+
+	// Start the publisher.
+	if pubSink != nil {
 		publisherWg.Add(1)
 		go func() {
 			defer publisherWg.Done()
 
-			pub := publisher.New(dataCh, natsClient, nats.DefaultSubjectPrefix, appMetrics, logger)
+			// The publisher drains dataCh until it's closed (after the sensors stop), or for up to publishDrainTimeout,
+			// so readings still buffered at shutdown are published rather than abandoned.
+			pub := publisher.New(dataCh, pubSink, subjectPrefix, publisher.Options{
+				ReconnectBufferSize: reconnectBufferSize,
+				Workers:             publisherWorkers,
+				BatchSize:           publishBatchSize,
+				RetryAttempts:       publishRetries,
+				PublishTimeout:      publishTimeout,
+				DrainTimeout:        publishDrainTimeout,
+				DeadLetterSubject:   publisher.DeadLetterSubject(subjectPrefix),
+				CompressThreshold:   compressThreshold,
+				Codec:               payloadCodec,
+			}, appMetrics, logger)
 			pub.Run(ctx)
 		}()
+	}
 
+	if cfg.NATS.Enabled && natsClient != nil {
 		// Periodically check and update NATS connection status
 		go func() {
 			ticker := time.NewTicker(5 * time.Second)
@@ -153,48 +588,267 @@ func main() {
 		}()
 	}
 
-	// Start sensors.
-	for i := 1; i <= sensorCount; i++ {
-		sensorsWg.Add(1)
+	// Start the bridge, archiving everything in the NATS stream to a file sink.
+	if cfg.Bridge.Enabled && cfg.NATS.Enabled && natsClient != nil {
+		consumerCfg := nats.DefaultConsumerConfig()
+		consumerCfg.FilterSubject = natsClient.SubjectPrefix() + ".data.>"
+		consumer, err := natsClient.NewConsumer(ctx, consumerCfg)
+		if err != nil {
+			logger.Error("Failed to create NATS consumer, continuing without bridge", "error", err)
+		} else if bridgeSink, err := sink.NewFileSink(cfg.Bridge.Output); err != nil {
+			logger.Error("Failed to open bridge sink, continuing without bridge", "error", err)
+		} else {
+			// The bridge writes and flushes each record to the file before acking it, rather than queueing it,
+			// so a record is only acked once it's archived, and NATS redelivers any that failed.
+			bridgeWg.Add(1)
+			go func() {
+				defer bridgeWg.Done()
+				defer func() {
+					if err := bridgeSink.Close(); err != nil {
+						logger.Error("Error closing bridge sink", "error", err)
+					}
+				}()
 
-		// TODO Look into refactoring `sensor.Start` such that we can directly wait for it,
-		// rather than having to wrap its invocation in another goroutine (so it can be integrated with sensorsWg).
-		go func(id int, interval time.Duration) {
-			defer sensorsWg.Done()
+				if err := bridge.New(consumer, bridgeSink, appMetrics, logger).Run(ctx); err != nil {
+					logger.Error("Bridge failed", "error", err)
+				}
+			}()
+		}
+	}
 
-			sensor.Start(ctx, id, dataCh, interval, appMetrics, logger)
-			// Wait for the shutdown signal from the context.
-			// When the context is cancelled, the sensor's internal goroutine alse receives the signal and will terminate.
-			// This ensures Done() is called only after the sensor is asked to stop,
-			<-ctx.Done()
-		}(i, sensorInterval)
+	// Open the registry live sensors record themselves in.
+	var registry *nats.Registry
+	if cfg.Registry && cfg.NATS.Enabled && natsClient != nil {
+		var err error
+		if registry, err = natsClient.NewRegistry(ctx, nats.DefaultRegistryConfig()); err != nil {
+			logger.Error("Failed to open the sensor registry, continuing without it", "error", err)
+		}
 	}
 
-	logger.Info("Simulation starting",
-		"sensor_count", sensorCount,
-		"simulation_duration", simulationDuration,
-		"nats_enabled", enableNATS,
-	)
+	// With -max-rate, the sensors share a rate limiter capping the fleet's total readings per second.
+	// Its burst of one reading keeps the cap strict, rather than letting a backlog of tokens through at once.
+	var limiter *rate.Limiter
+	if cfg.MaxRate > 0 {
+		limiter = rate.NewLimiter(rate.Limit(cfg.MaxRate), 1)
+	}
 
-	// Launch a dedicated goroutine to orchestrate the shutdown of sensors.
-	go func() {
-		// Wait for sensors to be done.
-		// (When their context is cancelled or the simulationDuration elapses).
-		sensorsWg.Wait()
+	// Start sensors, tracking them so their exit can be confirmed during shutdown.
+	sensorManager := sensor.NewManager(appMetrics, logger)
+	simulationStart := time.Now()
+	if cfg.Seed == 0 {
+		cfg.Seed = sensor.NewSeed()
+	}
+	groups, err := sensor.NewGroups(sensorGroups, cfg.Seed)
+	if err != nil {
+		logger.Error("Invalid sensor groups", "error", err)
+		os.Exit(1)
+	}
+	// The sensors consult a shared controller, so the admin server can pause them and change their interval.
+	controller := sensor.NewController(cfg.SensorInterval, appMetrics)
+	// The options of sensor i, whether started now or added later at `POST /admin/sensors/{i}`.
+	sensorOptions := func(i int) []sensor.Option {
+		opts := []sensor.Option{
+			sensor.WithController(controller),
+			sensor.WithProfile(sensorProfiles[i%len(sensorProfiles)]),
+			sensor.WithDeviceID(deviceIDs.Allocate(i)),
+			sensor.WithTimestampPrecision(timestampPrecision),
+			sensor.WithSeed(cfg.Seed),
+			sensor.WithMaxRestarts(sensorMaxRestarts),
+		}
+		switch {
+		case i <= len(sensorLocations):
+			opts = append(opts, sensor.WithLocation(sensorLocations[i-1]))
+		case sensorLocationBox != nil:
+			// Each sensor's position is drawn from its own source, so it doesn't depend on the others'.
+			r := rand.New(rand.NewPCG(uint64(cfg.Seed), uint64(i)))
+			opts = append(opts, sensor.WithLocation(sensor.RandomLocation(*sensorLocationBox, r)))
+		}
+		if valueGen != nil {
+			opts = append(opts, sensor.WithDistribution(valueGen.Distribution(i, simulationStart)))
+		}
+		if g, ok := groups[i]; ok {
+			opts = append(opts, sensor.WithDistribution(g.Distribution()))
+		}
+		if cfg.DriftRate != 0 {
+			opts = append(opts, sensor.WithDriftRate(cfg.DriftRate))
+		}
+		if cfg.Jitter != 0 {
+			opts = append(opts, sensor.WithJitter(cfg.Jitter))
+		}
+		if registry != nil {
+			opts = append(opts, sensor.WithRegistry(registry))
+		}
+		if limiter != nil {
+			opts = append(opts, sensor.WithRateLimiter(limiter))
+		}
+		if cfg.DropOnFull || cfg.MemoryLimit > 0 {
+			dropOnFull := cfg.DropOnFull
+			opts = append(opts, sensor.WithDropOnFull(func() bool {
+				return dropOnFull || shedding.Load()
+			}))
+		}
+		if cfg.Backpressure.Enabled {
+			opts = append(opts, sensor.WithBackpressure(sensor.BackpressureConfig{
+				Signal:      sensor.ChannelPressure(sensorCh),
+				MaxInterval: cfg.Backpressure.MaxInterval,
+			}))
+		}
+		return opts
+	}
+	// Sensors are tracked until they have fully stopped (across panic restarts), so shutdown can wait for them.
+	sensorManager.SetLauncher(ctx, sensorCh, cfg.SensorInterval, sensorOptions)
+	// With -ramp, the sensors come online gradually in the background, avoiding a thundering herd at startup.
+	// A shutdown during the ramp stops it promptly: sensors can't be added once ctx is done.
+	if cfg.RampDuration > 0 {
+		go sensorManager.Ramp(ctx, cfg.SensorCount, cfg.RampDuration)
+	} else {
+		sensorManager.Ramp(ctx, cfg.SensorCount, 0)
+	}
 
-		// Now safe to close the data channel.
-		close(dataCh)
-		logger.Info("All sensors shutdown. Data channel closed.")
-	}()
+	// With -replay, the recorded readings are sent in place of the sensors', ending the run once they all have been.
+	replayDone := make(chan struct{})
+	if replaySource != nil {
+		go func() {
+			defer close(replayDone)
+			n, err := replaySource.Run(ctx, sensorCh)
+			switch {
+			case err == nil:
+				stopMain(shutdown.ErrReplayFinished)
+			case ctx.Err() == nil:
+				logger.Error("Replay failed", "replayed", n, "error", err)
+				stopMain(fmt.Errorf("replay failed: %w", err))
+			}
+		}()
+	} else {
+		close(replayDone)
+	}
 
-	// Wait for the aggregator.
-	aggregatorWg.Wait()
+	// Serve the admin API, for pausing, resuming and rescaling the sensors at runtime.
+	// Like the metrics server, it's best-effort: the simulation carries on without it if it can't bind its address.
+	if cfg.AdminAddr != "" {
+		if cfg.AdminToken == "" {
+			logger.Warn("Admin server has no token configured, so anyone who can reach it can control the sensors", "addr", cfg.AdminAddr)
+		}
+		adminServer := server.NewAdminServer(cfg.AdminAddr, cfg.AdminToken, controller, sensorManager, logger)
+		if err := adminServer.Listen(); err != nil {
+			logger.Error("Admin server unavailable, continuing without it", "addr", cfg.AdminAddr, "error", err)
+		} else {
+			go func() {
+				if err := adminServer.Serve(mainCtx); err != nil {
+					logger.Error("Admin server stopped", "addr", cfg.AdminAddr, "error", err)
+				}
+			}()
+		}
+	}
 
-	// Wait for the NATS publisher.
-	if enableNATS {
-		publisherWg.Wait()
-		logger.Info("NATS publisher shutdown complete.")
+	logger.Info("Simulation starting",
+		"sensor_count", cfg.SensorCount,
+		"ramp", cfg.RampDuration,
+		"simulation_duration", cfg.SimulationDuration,
+		"seed", cfg.Seed,
+		"sink", cfg.Sink,
+		"nats_enabled", cfg.NATS.Enabled,
+		"bridge_enabled", cfg.Bridge.Enabled,
+		"replay", cfg.Replay,
+		"metrics_available", metricsAvailable,
+	)
+	if cfg.SensorCount == 0 && replaySource == nil {
+		logger.Info("No sensors configured, running as a consumer only")
+	}
+
+	// Show the terminal dashboard until the simulation ends.
+	// Quitting the dashboard (q or ctrl+c) stops the simulation, like an interrupt.
+	dashboardDone := make(chan struct{})
+	if cfg.Dashboard {
+		go func() {
+			defer close(dashboardDone)
+			quit, err := dashboard.Run(ctx, dashboard.NewSampler(reg, simulationStart))
+			if err != nil {
+				logger.Error("Dashboard failed", "error", err)
+				return
+			}
+			if quit {
+				logger.Info("Dashboard quit, starting graceful shutdown.")
+				stopMain(shutdown.ErrSignal)
+			}
+		}()
+	} else {
+		close(dashboardDone)
 	}
 
-	logger.Info("Simulation ended gracefully.")
+	// Once the context is done (it's cancelled or the simulation duration elapses), shut down in order:
+	// the data channel is only closed once nothing can send to it anymore, and only then are its consumers
+	// waited for to drain it. Every stage is bounded, so a stuck component can't hang the shutdown.
+	<-ctx.Done()
+	logger.Info("Shutting down", "cause", shutdown.Reason(ctx))
+	shutdownSequence := shutdown.NewSequence(logger,
+		shutdown.Stage{
+			// Sensors stop with the context; ingestion and replay also send to the data channel, so stop those too.
+			Name:    "stop producers",
+			Timeout: sensorShutdownGrace,
+			Run: func(ctx context.Context) error {
+				if ingester != nil {
+					ingester.Close()
+				}
+				select {
+				case <-replayDone:
+					return nil
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+			},
+		},
+		shutdown.Stage{
+			// Wait gives up on stragglers after the grace period itself; the stage's timeout is a backstop.
+			Name:    "wait for sensors",
+			Timeout: 2 * sensorShutdownGrace,
+			Run: func(context.Context) error {
+				if stragglers := sensorManager.Wait(sensorShutdownGrace); len(stragglers) > 0 {
+					// The channel is closed regardless, so its consumers can finish. A straggler that then sends to it
+					// panics, which its supervisor recovers from without restarting it, since its context is done.
+					return fmt.Errorf("%d sensors still running", len(stragglers))
+				}
+				return nil
+			},
+		},
+		shutdown.Stage{
+			// The taps, if any, close dataCh in turn.
+			Name:    "close data channel",
+			Timeout: time.Second,
+			Run: func(context.Context) error {
+				close(sensorCh)
+				return nil
+			},
+		},
+		shutdown.Stage{
+			// The publisher drains dataCh for up to publishDrainTimeout, then gives up on what's left.
+			Name:    "drain consumers",
+			Timeout: publishDrainTimeout + consumerDrainGrace,
+			Run:     shutdown.Wait(&aggregatorWg, &publisherWg),
+		},
+		shutdown.Stage{
+			// The Kafka producer flushes the records it still buffers, now that nothing more will be published.
+			// The bridge, CSV and Parquet sinks flush and close their files, the socket sink sends what it has queued,
+			// and the gRPC server's streams finish.
+			Name:    "flush sinks",
+			Timeout: sinkFlushTimeout,
+			Run: func(ctx context.Context) error {
+				var err error
+				if kafkaClient != nil {
+					if err = kafkaClient.Close(); err != nil {
+						err = fmt.Errorf("failed to close Kafka client: %w", err)
+					}
+				}
+				return errors.Join(err, shutdown.Wait(&bridgeWg, &csvWg, &parquetWg, &socketWg, &grpcWg)(ctx))
+			},
+		},
+	)
+	if err := shutdownSequence.Run(); err != nil {
+		logger.Warn("Shutdown finished with errors", "error", err)
+	}
+
+	// Wait for the dashboard to restore the terminal.
+	<-dashboardDone
+
+	logger.Info("Simulation ended gracefully.", "cause", shutdown.Reason(ctx), "seed", cfg.Seed)
 }
@@ -4,64 +4,521 @@ package aggregator
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/allthepins/iot-sensor-network-simulator/internal/clock"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/shutdown"
 )
 
+// DefaultSummaryInterval is how often the aggregator emits a summary of the messages it processed.
+const DefaultSummaryInterval = 5 * time.Second
+
+// Anomaly detection defaults (see AnomalyConfig).
+const (
+	DefaultZThreshold    = 3.0
+	DefaultAnomalyWarmup = 30
+)
+
+// windowsBuffer is how many completed windows Windows buffers for a slow consumer.
+const windowsBuffer = 16
+
+// SummaryOutput is how the aggregator emits its periodic summaries.
+type SummaryOutput string
+
+const (
+	// SummaryLog logs each summary as a structured log line. It is the default.
+	SummaryLog SummaryOutput = "log"
+	// SummaryJSON appends each summary as a JSON object (one per line) to the summary writer.
+	SummaryJSON SummaryOutput = "json"
+	// SummaryMetricsOnly emits no summaries; the aggregator's metrics are still updated.
+	SummaryMetricsOnly SummaryOutput = "metrics-only"
+)
+
+// Summary is the aggregator's record of one summary window.
+type Summary struct {
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	// Messages is the number of messages processed during the window.
+	Messages int `json:"messages"`
+	// Total is the number of messages processed since the aggregator started.
+	Total int `json:"total"`
+	// Stats are the statistics of the values received, since the aggregator started or,
+	// with WithWindowedStats (or WithWindowSize), during the window.
+	Stats Stats `json:"stats"`
+	// PercentilesSkipped is whether the window's percentiles weren't computed (and are 0 in Stats),
+	// because the aggregator was degraded, falling behind the readings.
+	PercentilesSkipped bool `json:"percentiles_skipped,omitempty"`
+}
+
+// Stats are running statistics of the values of received readings.
+// Min, Max, Mean and the percentiles are 0 while Count is 0.
+type Stats struct {
+	Count int     `json:"count"`
+	Min   float64 `json:"min"`
+	Max   float64 `json:"max"`
+	Mean  float64 `json:"mean"`
+	// P50, P95 and P99 are the median, 95th and 99th percentiles.
+	// They're estimated from a fixed-size random sample of the values, so they're exact only for small counts.
+	P50 float64 `json:"p50"`
+	P95 float64 `json:"p95"`
+	P99 float64 `json:"p99"`
+}
+
+// add accumulates v into the statistics.
+func (s *Stats) add(v float64) {
+	s.Count++
+	if s.Count == 1 {
+		s.Min, s.Max, s.Mean = v, v, v
+		return
+	}
+	s.Min = min(s.Min, v)
+	s.Max = max(s.Max, v)
+	s.Mean += (v - s.Mean) / float64(s.Count) // Incremental mean, which doesn't overflow a running sum.
+}
+
+// SensorStats are the aggregator's statistics of one sensor's readings.
+type SensorStats struct {
+	// Count is the number of readings received from the sensor.
+	Count int `json:"count"`
+	// LastValue is the value of the reading received last.
+	LastValue float64 `json:"last_value"`
+	// LastTimestamp is the latest timestamp of the sensor's readings.
+	// Out-of-order readings don't move it back.
+	LastTimestamp time.Time `json:"last_timestamp"`
+	// Mean is the mean of the sensor's values.
+	Mean float64 `json:"mean"`
+	m2   float64 // Sum of squared differences from the mean, for the variance (Welford's algorithm).
+}
+
+// add accumulates v into the sensor's count and running mean and variance.
+func (s *SensorStats) add(v float64) {
+	s.Count++
+	delta := v - s.Mean
+	s.Mean += delta / float64(s.Count)
+	s.m2 += delta * (v - s.Mean)
+}
+
+// StdDev returns the (population) standard deviation of the sensor's values.
+func (s SensorStats) StdDev() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return math.Sqrt(s.m2 / float64(s.Count))
+}
+
+// AnomalyConfig configures anomaly detection, which flags readings far from their sensor's running mean.
+type AnomalyConfig struct {
+	// ZThreshold is how many standard deviations from the sensor's mean a reading must be to be anomalous
+	// (DefaultZThreshold if 0).
+	ZThreshold float64
+	// Warmup is how many readings a sensor must have sent before its readings are checked
+	// (DefaultAnomalyWarmup if 0), so its mean and standard deviation have settled.
+	Warmup int
+}
+
+// Report is the aggregator's current state, as served by its stats handler.
+type Report struct {
+	// Total is the number of readings received, from all sensors.
+	Total int `json:"total"`
+	// Stats are the statistics of the values received (see Summary.Stats).
+	Stats Stats `json:"stats"`
+	// Sensors are the statistics of each sensor's readings, by sensor ID.
+	Sensors map[int]SensorStats `json:"sensors"`
+}
+
 // Aggregator processes sensor data.
 type Aggregator struct {
-	DataCh  <-chan model.SensorData
-	metrics *metrics.Metrics
-	logger  *slog.Logger
+	DataCh          <-chan model.SensorData
+	summaryOutput   SummaryOutput
+	summaryWriter   io.Writer
+	summaryInterval time.Duration
+	windowedStats   bool
+	staleAfter      time.Duration
+	anomaly         *AnomalyConfig
+	clock           clock.Clock
+	metrics         *metrics.Metrics
+	logger          *slog.Logger
+
+	windows     chan Summary
+	windowsRead atomic.Bool // Whether Windows has been called, i.e. whether anyone receives the windows.
+
+	mu      sync.Mutex // Guards stats, sample and sensors, which Stats and Snapshot read while Run updates them.
+	stats   Stats
+	sample  *reservoir // Sample of the values in stats, for its percentiles.
+	sensors map[int]SensorStats
+}
+
+// Option configures optional Aggregator behavior.
+type Option func(*Aggregator)
+
+// WithSummaryOutput sets how summaries are emitted. SummaryJSON writes to w, which is ignored otherwise.
+func WithSummaryOutput(out SummaryOutput, w io.Writer) Option {
+	return func(a *Aggregator) {
+		a.summaryOutput = out
+		a.summaryWriter = w
+	}
+}
+
+// WithSummaryInterval overrides how often summaries are emitted (DefaultSummaryInterval).
+func WithSummaryInterval(d time.Duration) Option {
+	return func(a *Aggregator) {
+		a.summaryInterval = d
+	}
+}
+
+// WithWindowedStats resets the value statistics at every summary, so each summary
+// (and Stats) covers only the current window, rather than everything received since the start.
+func WithWindowedStats() Option {
+	return func(a *Aggregator) {
+		a.windowedStats = true
+	}
+}
+
+// WithWindowSize aggregates in tumbling windows of size d: a summary is emitted every d,
+// covering only that window's readings (see WithSummaryInterval and WithWindowedStats).
+func WithWindowSize(d time.Duration) Option {
+	return func(a *Aggregator) {
+		a.summaryInterval = d
+		a.windowedStats = true
+	}
+}
+
+// WithStaleAfter flags sensors whose latest reading is older than d as stale, at every summary.
+// Stale sensors are logged and counted by the stale sensors metric. 0 (the default) disables it.
+func WithStaleAfter(d time.Duration) Option {
+	return func(a *Aggregator) {
+		a.staleAfter = d
+	}
+}
+
+// WithAnomalyDetection flags readings more than cfg.ZThreshold standard deviations from their sensor's running mean,
+// once the sensor has warmed up. Anomalous readings are logged, and counted by the anomalies metric.
+func WithAnomalyDetection(cfg AnomalyConfig) Option {
+	return func(a *Aggregator) {
+		if cfg.ZThreshold == 0 {
+			cfg.ZThreshold = DefaultZThreshold
+		}
+		if cfg.Warmup == 0 {
+			cfg.Warmup = DefaultAnomalyWarmup
+		}
+		a.anomaly = &cfg
+	}
+}
+
+// WithClock sets the clock the aggregator's summary windows are timed by (clock.Real by default),
+// e.g. a clock.Fake, so tests can step through windows without waiting for them.
+func WithClock(c clock.Clock) Option {
+	return func(a *Aggregator) {
+		a.clock = c
+	}
 }
 
 // New creates and returns a new Aggregator instance.
-func New(dataCh <-chan model.SensorData, m *metrics.Metrics, l *slog.Logger) *Aggregator {
+func New(dataCh <-chan model.SensorData, m *metrics.Metrics, l *slog.Logger, opts ...Option) *Aggregator {
 	if l == nil {
 		l = slog.Default() // Fallback to default logger if nil logger provided.
 	}
 
-	return &Aggregator{
-		DataCh:  dataCh,
-		metrics: m,
-		logger:  l.With("component", "aggregator"),
+	a := &Aggregator{
+		DataCh:          dataCh,
+		summaryOutput:   SummaryLog,
+		summaryInterval: DefaultSummaryInterval,
+		clock:           clock.Real,
+		windows:         make(chan Summary, windowsBuffer),
+		sample:          newReservoir(reservoirSize),
+		sensors:         make(map[int]SensorStats),
+		metrics:         m,
+		logger:          l.With("component", "aggregator"),
+	}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	if a.summaryOutput == SummaryJSON && a.summaryWriter == nil {
+		a.logger.Warn("No writer for JSON summaries, logging them instead")
+		a.summaryOutput = SummaryLog
 	}
+
+	return a
+}
+
+// Windows returns the channel every window's summary is sent to as the window completes,
+// in addition to being emitted in the configured output format.
+// Up to 16 summaries are buffered; beyond that, summaries the caller hasn't received are dropped (and logged).
+// Summaries are only sent once Windows has been called, so it should be called before Run.
+// The channel is closed once Run returns, after the final, partial window.
+func (a *Aggregator) Windows() <-chan Summary {
+	a.windowsRead.Store(true)
+	return a.windows
 }
 
 // Run starts the aggregator loop, which reads and processes SensorData.
 // It listens for data on its DataCh and processes it.
-// The loop terminates when the given context is canceled, or if DataCh is closed.
+// The loop terminates when the given context is canceled, or if DataCh is closed,
+// after summarizing the final, partial window.
 func (a *Aggregator) Run(ctx context.Context) {
 	a.logger.Info("Aggregator starting")
 	defer a.logger.Info("Aggregator stopping")
+	defer close(a.windows)
 
-	// Use a ticker and counter to help log a summary of processed messages every 5 seconds.
-	summaryTicker := time.NewTicker(5 * time.Second)
+	// Use a ticker and counters to help emit a summary of processed messages every summary interval.
+	summaryTicker := a.clock.NewTicker(a.summaryInterval)
 	defer summaryTicker.Stop()
-	count := 0
+	count, windowCount := 0, 0
+	windowStart := a.clock.Now()
+
+	// While the aggregator processes readings slower than they arrive, it degrades, computing the percentiles
+	// (which sort a sample of up to 1024 values) only every stride windows, doubling the stride each window
+	// it's still behind, and halving it again as it catches up.
+	// Whether it's behind counts the time computing the percentiles last took, spread over the stride's windows.
+	var windowLoad load
+	var percentileCost time.Duration
+	stride, skipped := 1, 0
+
+	// closeWindow summarizes the window ending at now, and starts the next one.
+	closeWindow := func(now time.Time) {
+		skipPercentiles := skipped < stride-1
+		a.mu.Lock()
+		stats := a.stats
+		if !skipPercentiles {
+			started := a.clock.Now()
+			stats = a.statsLocked()
+			percentileCost = a.clock.Now().Sub(started)
+		}
+		if a.windowedStats {
+			a.stats = Stats{}
+			a.sample.reset()
+		}
+		a.mu.Unlock()
+		if skipPercentiles {
+			skipped++
+		} else {
+			skipped = 0
+		}
+
+		sum := Summary{
+			WindowStart:        windowStart,
+			WindowEnd:          now,
+			Messages:           windowCount,
+			Total:              count,
+			Stats:              stats,
+			PercentilesSkipped: skipPercentiles,
+		}
+		a.summarize(sum)
+		if a.windowsRead.Load() {
+			select {
+			case a.windows <- sum:
+			default:
+				a.logger.Warn("Windows channel full, dropping window summary", "window_start", sum.WindowStart)
+			}
+		}
+		windowStart, windowCount = now, 0
+
+		// Degrade further if behind at the current stride, and recover only if it would keep up at half of it.
+		latency, interval, behind := windowLoad.behind(percentileCost / time.Duration(stride))
+		switch {
+		case behind && stride < maxPercentileStride:
+			stride *= 2
+			a.logger.Warn("Aggregator falling behind, degrading to computing percentiles less often",
+				"latency", latency,
+				"interval", interval,
+				"percentiles_every_windows", stride)
+		case !behind && stride > 1:
+			if _, _, behind := windowLoad.behind(percentileCost / time.Duration(stride/2)); behind {
+				break
+			}
+			stride /= 2
+			if stride == 1 {
+				a.logger.Info("Aggregator caught up, computing percentiles every window")
+			}
+		}
+		if a.metrics != nil {
+			if stride > 1 {
+				a.metrics.AggregatorDegraded.Set(1)
+			} else {
+				a.metrics.AggregatorDegraded.Set(0)
+			}
+		}
+		windowLoad = load{}
+	}
 
 	for {
 		select {
 		case <-ctx.Done():
 			// Context has been canceled, so we exit.
+			a.logger.Info("Aggregator context canceled", "cause", shutdown.Reason(ctx))
+			closeWindow(a.clock.Now())
 			return
-		case _, ok := <-a.DataCh:
+		case data, ok := <-a.DataCh:
 			// The `ok` flag is false if DataCh has been closed.
 			if !ok {
+				closeWindow(a.clock.Now())
 				return
 			}
 
-			// Instrument the message receipt.
+			// Instrument the message receipt, and how long the reading waited in the channel.
+			received := a.clock.Now()
 			if a.metrics != nil {
 				a.metrics.MessagesReceived.Inc()
+				a.metrics.MessageQueueAgeSeconds.WithLabelValues("aggregator").Observe(received.Sub(data.Timestamp).Seconds())
+			}
+
+			a.mu.Lock()
+			a.stats.add(data.Value)
+			a.sample.add(data.Value)
+			sensor, seen := a.sensors[data.ID]
+			// Score the reading against the sensor's readings before it.
+			var z float64
+			anomalous := false
+			if a.anomaly != nil && sensor.Count >= a.anomaly.Warmup {
+				if sd := sensor.StdDev(); sd > 0 {
+					z = (data.Value - sensor.Mean) / sd
+					anomalous = math.Abs(z) > a.anomaly.ZThreshold
+				}
+			}
+			sensor.add(data.Value)
+			sensor.LastValue = data.Value
+			// Compare with the latest timestamp seen from the sensor, to detect readings that go back in time.
+			outOfOrder := seen && data.Timestamp.Before(sensor.LastTimestamp)
+			if !outOfOrder {
+				sensor.LastTimestamp = data.Timestamp
+			}
+			a.sensors[data.ID] = sensor
+			a.mu.Unlock()
+
+			if anomalous {
+				a.logger.Warn("Anomalous reading", "sensor_id", data.ID, "value", data.Value, "z_score", z)
+				if a.metrics != nil {
+					a.metrics.AnomaliesDetected.WithLabelValues(strconv.Itoa(data.ID)).Inc()
+				}
+			}
+
+			if outOfOrder {
+				a.logger.Warn("Out-of-order reading",
+					"sensor_id", data.ID,
+					"timestamp", data.Timestamp,
+					"previous_timestamp", sensor.LastTimestamp)
+				if a.metrics != nil {
+					a.metrics.OutOfOrderReadings.Inc()
+				}
 			}
 
 			count++
-		case <-summaryTicker.C:
-			a.logger.Info("processed messages", "count", count)
+			windowCount++
+			windowLoad.add(data.Timestamp, a.clock.Now().Sub(received))
+		case now := <-summaryTicker.C():
+			closeWindow(now)
+
+			if a.staleAfter > 0 {
+				a.flagStale(now)
+			}
+		}
+	}
+}
+
+// summarize emits a window's summary in the configured output format.
+func (a *Aggregator) summarize(sum Summary) {
+	switch a.summaryOutput {
+	case SummaryMetricsOnly:
+		return
+	case SummaryJSON:
+		line, err := json.Marshal(sum)
+		if err != nil {
+			a.logger.Warn("Failed to marshal summary", "error", err)
+			return
 		}
+		if _, err := a.summaryWriter.Write(append(line, '\n')); err != nil {
+			a.logger.Warn("Failed to write summary", "error", err)
+		}
+	default:
+		a.logger.Info("processed messages",
+			"count", sum.Total,
+			"window_count", sum.Messages,
+			"min", sum.Stats.Min,
+			"max", sum.Stats.Max,
+			"mean", sum.Stats.Mean,
+			"p50", sum.Stats.P50,
+			"p95", sum.Stats.P95,
+			"p99", sum.Stats.P99)
+	}
+}
+
+// flagStale logs and counts the sensors whose latest reading is older than the staleness threshold at now.
+func (a *Aggregator) flagStale(now time.Time) {
+	var stale []int
+	a.mu.Lock()
+	for id, sensor := range a.sensors {
+		if now.Sub(sensor.LastTimestamp) > a.staleAfter {
+			stale = append(stale, id)
+		}
+	}
+	a.mu.Unlock()
+
+	if a.metrics != nil {
+		a.metrics.StaleSensors.Set(float64(len(stale)))
 	}
+	if len(stale) > 0 {
+		slices.Sort(stale)
+		a.logger.Warn("Stale sensors", "count", len(stale), "stale_after", a.staleAfter, "sensor_ids", stale)
+	}
+}
+
+// Stats returns the current statistics of received values.
+// It is safe to call while Run is running.
+func (a *Aggregator) Stats() Stats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.statsLocked()
+}
+
+// statsLocked returns the current statistics, with their percentiles. a.mu must be held.
+func (a *Aggregator) statsLocked() Stats {
+	stats := a.stats
+	p := a.sample.percentiles(0.5, 0.95, 0.99)
+	stats.P50, stats.P95, stats.P99 = p[0], p[1], p[2]
+	return stats
+}
+
+// Snapshot returns a copy of every sensor's statistics, by sensor ID.
+// It is safe to call while Run is running, and the copy isn't modified afterwards.
+func (a *Aggregator) Snapshot() map[int]SensorStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return maps.Clone(a.sensors)
+}
+
+// Report returns the aggregator's current state.
+// It is safe to call while Run is running. Before any data is received, its fields are zero
+// (and Sensors is empty).
+func (a *Aggregator) Report() Report {
+	stats, sensors := a.Stats(), a.Snapshot()
+
+	total := 0
+	for _, sensor := range sensors {
+		total += sensor.Count
+	}
+	return Report{Total: total, Stats: stats, Sensors: sensors}
+}
+
+// StatsHandler returns an HTTP handler serving `GET /stats`, which responds with the JSON-encoded Report.
+func (a *Aggregator) StatsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(a.Report())
+	})
+	return mux
 }
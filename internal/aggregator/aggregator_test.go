@@ -4,14 +4,26 @@ package aggregator_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
 	"github.com/allthepins/iot-sensor-network-simulator/internal/aggregator"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/clock"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/shutdown"
 )
 
 // newTestLogger returns a slog.Logger to facilitate testing function log text.
@@ -22,6 +34,22 @@ func newTestLogger(buf *bytes.Buffer) *slog.Logger {
 	return slog.New(handler)
 }
 
+// testStart is when the fake clocks of tests start.
+var testStart = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// nextWindow receives the next window summary from windows, failing the test if none is sent promptly.
+func nextWindow(t *testing.T, windows <-chan aggregator.Summary) aggregator.Summary {
+	t.Helper()
+
+	select {
+	case sum := <-windows:
+		return sum
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a window summary")
+		return aggregator.Summary{}
+	}
+}
+
 // TestNewAggregator verifies that the New function correctly initializes an Aggregator.
 func TestNewAggregator(t *testing.T) {
 	t.Parallel()
@@ -36,15 +64,18 @@ func TestNewAggregator(t *testing.T) {
 	}
 }
 
-// TestAggregator_Run_ProcesssesData verifies that the aggregator receives and logs data.
+// TestAggregator_Run_ProcesssesData verifies that the aggregator receives data, and logs a summary of it
+// once the summary interval has passed.
 func TestAggregator_Run_ProcessesData(t *testing.T) {
 	t.Parallel()
 
 	buf := &bytes.Buffer{}
 	logger := newTestLogger(buf)
 
-	dataCh := make(chan model.SensorData, 1) // Buffer channel to prevent blocking
-	agg := aggregator.New(dataCh, nil, logger)
+	clk := clock.NewFake(testStart)
+	dataCh := make(chan model.SensorData) // Unbuffered, so the reading has been received once it's sent.
+	agg := aggregator.New(dataCh, nil, logger, aggregator.WithClock(clk))
+	windows := agg.Windows()
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
@@ -59,9 +90,11 @@ func TestAggregator_Run_ProcessesData(t *testing.T) {
 	testData := model.SensorData{ID: 1, Value: 0.99}
 	dataCh <- testData
 
-	// Give the aggregator enough to process, so that the summary is logged.
-	time.Sleep(6 * time.Second)
-
+	// Pass the summary interval, so that the summary is logged (before its window is sent).
+	clk.Advance(aggregator.DefaultSummaryInterval)
+	if sum := nextWindow(t, windows); sum.Total != 1 {
+		t.Errorf("expected a window with 1 message, got %+v", sum)
+	}
 	if !strings.Contains(buf.String(), "count=1") {
 		t.Errorf("expected log to contain summary of processed data, but it didn't. Log %s", buf.String())
 	}
@@ -116,3 +149,643 @@ func TestAggregator_Run_StopsOnChannelClose(t *testing.T) {
 		t.Fatal("aggregator did not stop after channel was closed")
 	}
 }
+
+// TestAggregator_Run_DetectsOutOfOrderReadings verifies that a reading timestamped earlier
+// than the previous reading from the same sensor is counted as out-of-order.
+func TestAggregator_Run_DetectsOutOfOrderReadings(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 4)
+	agg := aggregator.New(dataCh, m, nil)
+
+	base := time.Now()
+	dataCh <- model.SensorData{ID: 1, Timestamp: base}
+	dataCh <- model.SensorData{ID: 2, Timestamp: base.Add(-time.Minute)} // Earlier, but from a different sensor.
+	dataCh <- model.SensorData{ID: 1, Timestamp: base.Add(-time.Second)} // Out of order.
+	dataCh <- model.SensorData{ID: 1, Timestamp: base.Add(time.Second)}
+	close(dataCh)
+
+	agg.Run(context.Background())
+
+	if got := testutil.ToFloat64(m.OutOfOrderReadings); got != 1 {
+		t.Errorf("expected 1 out-of-order reading, got %v", got)
+	}
+}
+
+// TestAggregator_Run_LogsCancellationCause verifies the aggregator logs why its context was canceled.
+func TestAggregator_Run_LogsCancellationCause(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	agg := aggregator.New(make(chan model.SensorData), nil, newTestLogger(buf))
+
+	ctx, cancel := shutdown.WithCancelCause(context.Background())
+	cancel(shutdown.ErrSignal)
+	agg.Run(ctx)
+
+	if !strings.Contains(buf.String(), shutdown.ErrSignal.Error()) {
+		t.Errorf("expected log to contain cause %q, got: %s", shutdown.ErrSignal, buf.String())
+	}
+}
+
+// TestAggregator_Run_ObservesQueueAge verifies the age of dequeued readings is observed,
+// measured from their timestamps.
+func TestAggregator_Run_ObservesQueueAge(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 1)
+	agg := aggregator.New(dataCh, m, nil)
+
+	const age = 30 * time.Second
+	dataCh <- model.SensorData{ID: 1, Timestamp: time.Now().Add(-age)}
+	close(dataCh)
+
+	agg.Run(context.Background())
+
+	var metric dto.Metric
+	if err := m.MessageQueueAgeSeconds.WithLabelValues("aggregator").(prometheus.Metric).Write(&metric); err != nil {
+		t.Fatalf("failed to read queue age histogram: %v", err)
+	}
+	h := metric.GetHistogram()
+	if h.GetSampleCount() != 1 {
+		t.Fatalf("expected 1 queue age observation, got %d", h.GetSampleCount())
+	}
+	if got := h.GetSampleSum(); got < age.Seconds() || got > age.Seconds()+1 {
+		t.Errorf("expected a queue age of ~%v, got %vs", age, got)
+	}
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestAggregator_Run_JSONSummaries verifies that in JSON summary mode one well-formed JSON summary
+// is appended per window, covering consecutive windows, and that summaries aren't logged.
+func TestAggregator_Run_JSONSummaries(t *testing.T) {
+	t.Parallel()
+
+	logs := &bytes.Buffer{}
+	out := &syncBuffer{}
+	clk := clock.NewFake(testStart)
+	dataCh := make(chan model.SensorData)
+	agg := aggregator.New(dataCh, nil, newTestLogger(logs),
+		aggregator.WithSummaryOutput(aggregator.SummaryJSON, out),
+		aggregator.WithSummaryInterval(time.Second),
+		aggregator.WithClock(clk))
+	windows := agg.Windows()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		agg.Run(ctx)
+	}()
+
+	const sent = 3
+	for i := 1; i <= sent; i++ {
+		dataCh <- model.SensorData{ID: i, Timestamp: clk.Now()}
+	}
+
+	// Step through three windows, then stop halfway through a fourth, which is summarized too.
+	for range 3 {
+		clk.Advance(time.Second)
+		nextWindow(t, windows)
+	}
+	clk.Advance(500 * time.Millisecond)
+	cancel()
+	wg.Wait()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 summaries, got:\n%s", out.String())
+	}
+	var prev aggregator.Summary
+	messages := 0
+	for i, line := range lines {
+		var sum aggregator.Summary
+		if err := json.Unmarshal([]byte(line), &sum); err != nil {
+			t.Fatalf("summary %d is not well-formed JSON (%q): %v", i, line, err)
+		}
+		wantStart := testStart.Add(time.Duration(i) * time.Second)
+		wantEnd := wantStart.Add(time.Second)
+		if i == len(lines)-1 {
+			wantEnd = wantStart.Add(500 * time.Millisecond)
+		}
+		if !sum.WindowStart.Equal(wantStart) || !sum.WindowEnd.Equal(wantEnd) {
+			t.Errorf("summary %d: expected the window %v to %v, got %v to %v", i, wantStart, wantEnd, sum.WindowStart, sum.WindowEnd)
+		}
+		messages += sum.Messages
+		prev = sum
+	}
+
+	if messages != sent || prev.Total != sent {
+		t.Errorf("expected %d messages across windows and in total, got %d and %d", sent, messages, prev.Total)
+	}
+	if strings.Contains(logs.String(), "processed messages") {
+		t.Errorf("expected summaries not to be logged in JSON mode, got logs:\n%s", logs.String())
+	}
+}
+
+// TestAggregator_Stats verifies the running min, max, mean and percentiles of received values.
+func TestAggregator_Stats(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 4)
+	agg := aggregator.New(dataCh, nil, nil)
+
+	if got := agg.Stats(); got != (aggregator.Stats{}) {
+		t.Errorf("expected zero stats before any reading, got %+v", got)
+	}
+
+	for _, v := range []float64{0.5, -1, 2, 2.5} {
+		dataCh <- model.SensorData{ID: 1, Value: v, Timestamp: time.Now()}
+	}
+	close(dataCh)
+	agg.Run(context.Background())
+
+	want := aggregator.Stats{Count: 4, Min: -1, Max: 2.5, Mean: 1, P50: 0.5, P95: 2.5, P99: 2.5}
+	if got := agg.Stats(); got != want {
+		t.Errorf("expected stats %+v, got %+v", want, got)
+	}
+}
+
+// TestAggregator_Stats_Percentiles verifies the percentiles of a large, known distribution
+// (uniform over [0, 1)) are estimated within a tolerance.
+func TestAggregator_Stats_Percentiles(t *testing.T) {
+	t.Parallel()
+
+	const n = 100_000
+	dataCh := make(chan model.SensorData, n)
+	agg := aggregator.New(dataCh, nil, slog.New(slog.DiscardHandler))
+
+	now := time.Now()
+	for i := range n {
+		dataCh <- model.SensorData{ID: 1, Value: float64(i) / n, Timestamp: now}
+	}
+	close(dataCh)
+	agg.Run(context.Background())
+
+	const tolerance = 0.03
+	got := agg.Stats()
+	for _, tt := range []struct {
+		name      string
+		got, want float64
+	}{
+		{"p50", got.P50, 0.5},
+		{"p95", got.P95, 0.95},
+		{"p99", got.P99, 0.99},
+	} {
+		if math.Abs(tt.got-tt.want) > tolerance {
+			t.Errorf("expected %s within %v of %v, got %v", tt.name, tolerance, tt.want, tt.got)
+		}
+	}
+}
+
+// TestAggregator_Run_WindowedStats verifies windowed stats are reset at each summary,
+// and that Stats can be read while Run processes readings.
+func TestAggregator_Run_WindowedStats(t *testing.T) {
+	t.Parallel()
+
+	out := &syncBuffer{}
+	clk := clock.NewFake(testStart)
+	dataCh := make(chan model.SensorData)
+	agg := aggregator.New(dataCh, nil, nil,
+		aggregator.WithSummaryOutput(aggregator.SummaryJSON, out),
+		aggregator.WithSummaryInterval(time.Second),
+		aggregator.WithWindowedStats(),
+		aggregator.WithClock(clk))
+	windows := agg.Windows()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		agg.Run(ctx)
+	}()
+
+	dataCh <- model.SensorData{ID: 1, Value: 10, Timestamp: time.Now()}
+	dataCh <- model.SensorData{ID: 1, Value: 20, Timestamp: time.Now()}
+	if got := agg.Stats(); got.Count > 2 {
+		t.Errorf("expected at most 2 values in the stats, got %+v", got)
+	}
+
+	// Step through the window covering the readings, and the empty one after it.
+	for range 2 {
+		clk.Advance(time.Second)
+		nextWindow(t, windows)
+	}
+	cancel()
+	wg.Wait()
+
+	if got := agg.Stats(); got != (aggregator.Stats{}) {
+		t.Errorf("expected the stats to be reset after the window, got %+v", got)
+	}
+
+	var summaries []aggregator.Summary
+	for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+		var sum aggregator.Summary
+		if err := json.Unmarshal([]byte(line), &sum); err != nil {
+			t.Fatalf("summary is not well-formed JSON (%q): %v", line, err)
+		}
+		summaries = append(summaries, sum)
+	}
+	want := aggregator.Stats{Count: 2, Min: 10, Max: 20, Mean: 15, P50: 10, P95: 20, P99: 20}
+	if summaries[0].Stats != want {
+		t.Errorf("expected the first window's stats to be %+v, got %+v", want, summaries[0].Stats)
+	}
+	if got := summaries[len(summaries)-1].Stats; got != (aggregator.Stats{}) {
+		t.Errorf("expected the last window's stats to be empty, got %+v", got)
+	}
+}
+
+// TestAggregator_Windows verifies completed windows are sent to the Windows channel,
+// including the final, partial window when the aggregator stops, after which the channel is closed.
+func TestAggregator_Windows(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 3)
+	agg := aggregator.New(dataCh, nil, slog.New(slog.DiscardHandler),
+		aggregator.WithSummaryOutput(aggregator.SummaryMetricsOnly, nil),
+		aggregator.WithWindowSize(time.Hour)) // Long enough that only the final window completes.
+	windowsCh := agg.Windows()
+
+	for _, v := range []float64{10, 20, 30} {
+		dataCh <- model.SensorData{ID: 1, Value: v, Timestamp: time.Now()}
+	}
+	close(dataCh)
+	agg.Run(context.Background())
+
+	var windows []aggregator.Summary
+	for sum := range windowsCh {
+		windows = append(windows, sum)
+	}
+	if len(windows) != 1 {
+		t.Fatalf("expected only the final window, got %+v", windows)
+	}
+	want := aggregator.Stats{Count: 3, Min: 10, Max: 30, Mean: 20, P50: 20, P95: 30, P99: 30}
+	if got := windows[0]; got.Messages != 3 || got.Stats != want || !got.WindowEnd.After(got.WindowStart) {
+		t.Errorf("expected a window of 3 messages with stats %+v, got %+v", want, got)
+	}
+}
+
+// TestAggregator_Run_DetectsAnomalies verifies readings far from their sensor's running mean are logged and counted,
+// but only once the sensor has warmed up.
+func TestAggregator_Run_DetectsAnomalies(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 100)
+	agg := aggregator.New(dataCh, m, newTestLogger(buf),
+		aggregator.WithSummaryOutput(aggregator.SummaryMetricsOnly, nil),
+		aggregator.WithAnomalyDetection(aggregator.AnomalyConfig{Warmup: 10}))
+
+	now := time.Now()
+	send := func(id int, values ...float64) {
+		for _, v := range values {
+			dataCh <- model.SensorData{ID: id, Value: v, Timestamp: now}
+		}
+	}
+	// Sensor 1 warms up around a mean of 11 with a standard deviation of 1, then stays within it before a spike.
+	for range 5 {
+		send(1, 10, 12)
+	}
+	send(1, 12.5, 30)
+	// Sensor 2 spikes before it has warmed up.
+	send(2, 10, 12, 10, 30)
+	close(dataCh)
+	agg.Run(context.Background())
+
+	if got := testutil.ToFloat64(m.AnomaliesDetected.WithLabelValues("1")); got != 1 {
+		t.Errorf("expected 1 anomaly from sensor 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.AnomaliesDetected.WithLabelValues("2")); got != 0 {
+		t.Errorf("expected no anomalies from sensor 2 before it warmed up, got %v", got)
+	}
+	if logs := buf.String(); strings.Count(logs, "Anomalous reading") != 1 || !strings.Contains(logs, "value=30") {
+		t.Errorf("expected the spike to be logged as anomalous, got logs:\n%s", logs)
+	}
+
+	stats := agg.Snapshot()[1]
+	if stats.Count != 12 || math.Abs(stats.Mean-152.5/12) > 1e-9 {
+		t.Errorf("expected 12 readings with a mean of 152.5/12 from sensor 1, got %+v", stats)
+	}
+}
+
+// TestAggregator_Snapshot verifies per-sensor statistics, and that a snapshot is a copy
+// which can be taken while Run processes readings.
+func TestAggregator_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData)
+	agg := aggregator.New(dataCh, nil, nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agg.Run(context.Background())
+	}()
+
+	base := time.Now()
+	readings := []model.SensorData{
+		{ID: 1, Value: 1, Timestamp: base},
+		{ID: 2, Value: 5, Timestamp: base},
+		{ID: 1, Value: 2, Timestamp: base.Add(time.Second)},
+		{ID: 1, Value: 3, Timestamp: base.Add(-time.Second)}, // Out of order.
+	}
+	for _, data := range readings {
+		dataCh <- data
+		agg.Snapshot() // Read concurrently with Run.
+	}
+	close(dataCh)
+	<-done
+
+	snapshot := agg.Snapshot()
+	want := map[int]aggregator.SensorStats{
+		1: {Count: 3, LastValue: 3, LastTimestamp: base.Add(time.Second)},
+		2: {Count: 1, LastValue: 5, LastTimestamp: base},
+	}
+	if len(snapshot) != len(want) {
+		t.Fatalf("expected stats for %d sensors, got %d: %+v", len(want), len(snapshot), snapshot)
+	}
+	for id, w := range want {
+		got := snapshot[id]
+		if got.Count != w.Count || got.LastValue != w.LastValue || !got.LastTimestamp.Equal(w.LastTimestamp) {
+			t.Errorf("sensor %d: expected stats %+v, got %+v", id, w, got)
+		}
+	}
+
+	delete(snapshot, 1)
+	if _, ok := agg.Snapshot()[1]; !ok {
+		t.Error("expected modifying a snapshot not to affect the aggregator")
+	}
+}
+
+// TestAggregator_Run_FlagsStaleSensors verifies sensors whose latest reading is older than the
+// staleness threshold are logged and counted at each summary, and others aren't.
+func TestAggregator_Run_FlagsStaleSensors(t *testing.T) {
+	t.Parallel()
+
+	logs := &syncBuffer{}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	clk := clock.NewFake(testStart)
+	dataCh := make(chan model.SensorData)
+	agg := aggregator.New(dataCh, m, slog.New(slog.NewTextHandler(logs, nil)),
+		aggregator.WithSummaryOutput(aggregator.SummaryMetricsOnly, nil),
+		aggregator.WithSummaryInterval(10*time.Second),
+		aggregator.WithStaleAfter(time.Minute),
+		aggregator.WithClock(clk))
+	windows := agg.Windows()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		agg.Run(ctx)
+	}()
+
+	// At the summary, 10s from now, sensor 1's reading is 2m10s old, and sensor 3's an hour and 10s.
+	now := clk.Now()
+	dataCh <- model.SensorData{ID: 1, Timestamp: now.Add(-2 * time.Minute)}
+	dataCh <- model.SensorData{ID: 2, Timestamp: now.Add(time.Hour)}
+	dataCh <- model.SensorData{ID: 3, Timestamp: now.Add(-time.Hour)}
+
+	// Stale sensors are flagged after the window is summarized, so by the time Run has returned.
+	clk.Advance(10 * time.Second)
+	nextWindow(t, windows)
+	cancel()
+	wg.Wait()
+
+	if !strings.Contains(logs.String(), "sensor_ids=\"[1 3]\"") {
+		t.Errorf("expected sensors 1 and 3 to be logged as stale, got logs:\n%s", logs.String())
+	}
+	if got := testutil.ToFloat64(m.StaleSensors); got != 2 {
+		t.Errorf("expected 2 stale sensors, got %v", got)
+	}
+}
+
+// slowClock is a fake clock on which processing takes time: every reading of the time advances it by step first,
+// as though the work since it was last read took that long.
+type slowClock struct {
+	*clock.Fake
+	step time.Duration
+}
+
+func (c slowClock) Now() time.Time {
+	c.Advance(c.step)
+	return c.Fake.Now()
+}
+
+// TestAggregator_Run_DegradesWhenFallingBehind verifies that while the aggregator processes readings slower
+// than they were taken, it computes percentiles less and less often and sets the degraded gauge,
+// and that it computes them every window again once it catches up.
+func TestAggregator_Run_DegradesWhenFallingBehind(t *testing.T) {
+	t.Parallel()
+
+	var logs bytes.Buffer
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	// Each reading takes 10ms to process, and the clock is read twice per reading, so 50 are processed per window.
+	clk := slowClock{Fake: clock.NewFake(testStart), step: 10 * time.Millisecond}
+	dataCh := make(chan model.SensorData)
+	agg := aggregator.New(dataCh, m, newTestLogger(&logs),
+		aggregator.WithSummaryOutput(aggregator.SummaryMetricsOnly, nil),
+		aggregator.WithSummaryInterval(time.Second),
+		aggregator.WithClock(clk))
+	windows := agg.Windows()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agg.Run(context.Background())
+	}()
+
+	// 8 windows of readings taken 1ms apart, 10 times faster than they're processed.
+	for i := range 400 {
+		dataCh <- model.SensorData{ID: i % 10, Value: float64(i), Timestamp: testStart.Add(time.Duration(i) * time.Millisecond)}
+	}
+	if got := testutil.ToFloat64(m.AggregatorDegraded); got != 1 {
+		t.Errorf("expected the aggregator to be degraded while falling behind, got gauge %v", got)
+	}
+	// Then 4 windows of readings taken 1s apart, which it keeps up with.
+	later := testStart.Add(time.Hour)
+	for i := range 200 {
+		dataCh <- model.SensorData{ID: i % 10, Value: float64(i), Timestamp: later.Add(time.Duration(i) * time.Second)}
+	}
+	close(dataCh)
+	<-done
+
+	var sums []aggregator.Summary
+	for sum := range windows {
+		sums = append(sums, sum)
+	}
+	if len(sums) < 12 {
+		t.Fatalf("expected at least 12 windows, got %d", len(sums))
+	}
+
+	// The stride doubles after each window behind, to every 8th window, so of the first 8 windows
+	// only the first computes percentiles.
+	for i, sum := range sums[:8] {
+		wantSkipped := i != 0
+		if sum.PercentilesSkipped != wantSkipped {
+			t.Errorf("window %d: expected percentiles skipped %t, got %t", i+1, wantSkipped, sum.PercentilesSkipped)
+		}
+		if wantSkipped && sum.Stats.P50 != 0 {
+			t.Errorf("window %d: expected no percentiles, got p50 %v", i+1, sum.Stats.P50)
+		}
+	}
+	if !strings.Contains(logs.String(), "Aggregator falling behind") {
+		t.Errorf("expected the degradation to be logged, got logs:\n%s", logs.String())
+	}
+
+	if last := sums[len(sums)-1]; last.PercentilesSkipped || last.Stats.P50 == 0 {
+		t.Errorf("expected percentiles once caught up, got skipped %t, p50 %v", last.PercentilesSkipped, last.Stats.P50)
+	}
+	if got := testutil.ToFloat64(m.AggregatorDegraded); got != 0 {
+		t.Errorf("expected the aggregator not to be degraded once caught up, got gauge %v", got)
+	}
+	if !strings.Contains(logs.String(), "Aggregator caught up") {
+		t.Errorf("expected the recovery to be logged, got logs:\n%s", logs.String())
+	}
+}
+
+// TestAggregator_Run_DegradesForPercentileCost verifies the time computing the percentiles takes at the close
+// of a window counts towards falling behind, spread over the windows between computing them.
+func TestAggregator_Run_DegradesForPercentileCost(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	// Each reading takes 10ms to process, and so does computing the percentiles, so about 50 are processed per window.
+	clk := slowClock{Fake: clock.NewFake(testStart), step: 10 * time.Millisecond}
+	dataCh := make(chan model.SensorData)
+	agg := aggregator.New(dataCh, m, slog.New(slog.DiscardHandler),
+		aggregator.WithSummaryOutput(aggregator.SummaryMetricsOnly, nil),
+		aggregator.WithSummaryInterval(time.Second),
+		aggregator.WithClock(clk))
+	windows := agg.Windows()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agg.Run(context.Background())
+	}()
+
+	// Readings taken 10.15ms apart: the aggregator keeps up with the readings alone, but not with a 10ms share
+	// of computing the percentiles every window (0.2ms a reading), only with every other window's (0.1ms).
+	for i := range 500 {
+		dataCh <- model.SensorData{ID: i % 10, Value: float64(i), Timestamp: testStart.Add(time.Duration(i) * 10150 * time.Microsecond)}
+	}
+	if got := testutil.ToFloat64(m.AggregatorDegraded); got != 1 {
+		t.Errorf("expected the aggregator to be degraded by the cost of the percentiles, got gauge %v", got)
+	}
+	close(dataCh)
+	<-done
+
+	var sums []aggregator.Summary
+	for sum := range windows {
+		sums = append(sums, sum)
+	}
+	if len(sums) < 8 {
+		t.Fatalf("expected at least 8 windows, got %d", len(sums))
+	}
+	for i, sum := range sums[:8] {
+		if wantSkipped := i%2 == 1; sum.PercentilesSkipped != wantSkipped {
+			t.Errorf("window %d: expected percentiles skipped %t, got %t", i+1, wantSkipped, sum.PercentilesSkipped)
+		}
+	}
+}
+
+// TestAggregator_StatsHandler verifies the stats handler serves a zeroed report before any data is received,
+// and the aggregator's totals and per-sensor statistics afterwards.
+func TestAggregator_StatsHandler(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData)
+	agg := aggregator.New(dataCh, nil, nil)
+	h := agg.StatsHandler()
+
+	get := func() aggregator.Report {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/stats", nil))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status 200, got %d", rec.Code)
+		}
+		if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("expected a JSON content type, got %q", ct)
+		}
+		var report aggregator.Report
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("failed to decode report %q: %v", rec.Body.String(), err)
+		}
+		return report
+	}
+
+	if report := get(); report.Total != 0 || report.Stats != (aggregator.Stats{}) || len(report.Sensors) != 0 {
+		t.Errorf("expected a zeroed report before any data, got %+v", report)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		agg.Run(context.Background())
+	}()
+	now := time.Now()
+	for _, data := range []model.SensorData{{ID: 1, Value: 1, Timestamp: now}, {ID: 2, Value: 3, Timestamp: now}, {ID: 1, Value: 2, Timestamp: now}} {
+		dataCh <- data
+	}
+	close(dataCh)
+	<-done
+
+	report := get()
+	if report.Total != 3 {
+		t.Errorf("expected a total of 3, got %d", report.Total)
+	}
+	if want := (aggregator.Stats{Count: 3, Min: 1, Max: 3, Mean: 2, P50: 2, P95: 3, P99: 3}); report.Stats != want {
+		t.Errorf("expected stats %+v, got %+v", want, report.Stats)
+	}
+	if report.Sensors[1].Count != 2 || report.Sensors[2].Count != 1 {
+		t.Errorf("expected per-sensor counts of 2 and 1, got %+v", report.Sensors)
+	}
+}
+
+// BenchmarkAggregator_Run measures how fast the aggregator drains a saturated data channel,
+// with readings from fleets of increasing size.
+func BenchmarkAggregator_Run(b *testing.B) {
+	for _, sensors := range []int{1, 100, 10_000} {
+		b.Run(fmt.Sprintf("sensors=%d", sensors), func(b *testing.B) {
+			dataCh := make(chan model.SensorData, b.N)
+			now := time.Now()
+			for i := range b.N {
+				dataCh <- model.SensorData{ID: i%sensors + 1, Value: float64(i), Timestamp: now}
+			}
+			close(dataCh)
+			agg := aggregator.New(dataCh, nil, slog.New(slog.DiscardHandler), aggregator.WithSummaryOutput(aggregator.SummaryMetricsOnly, nil))
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			agg.Run(context.Background()) // Returns once the channel is drained.
+			b.StopTimer()
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "readings/s")
+		})
+	}
+}
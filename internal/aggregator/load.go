@@ -0,0 +1,39 @@
+package aggregator
+
+import "time"
+
+// maxPercentileStride is the most windows a degraded aggregator goes between computing percentiles.
+const maxPercentileStride = 8
+
+// load measures whether the aggregator kept up with the readings of a window: how long it took to process each,
+// along with its share of the window's closing work, against how far apart the sensors took them.
+// Processing slower than readings arrive backs up the data channel.
+type load struct {
+	busy        time.Duration // Total time spent processing the readings.
+	readings    int
+	first, last time.Time // The earliest and latest of the readings' timestamps.
+}
+
+// add records a reading taken at ts, which took took to process.
+func (l *load) add(ts time.Time, took time.Duration) {
+	if l.readings == 0 || ts.Before(l.first) {
+		l.first = ts
+	}
+	if l.readings == 0 || ts.After(l.last) {
+		l.last = ts
+	}
+	l.busy += took
+	l.readings++
+}
+
+// behind returns the mean time taken to process a reading, including its share of overhead
+// (the time spent closing the window), and the mean time between the readings' timestamps,
+// and reports whether the former exceeded the latter. It needs two readings to tell.
+func (l load) behind(overhead time.Duration) (latency, interval time.Duration, behind bool) {
+	if l.readings < 2 {
+		return 0, 0, false
+	}
+	latency = (l.busy + overhead) / time.Duration(l.readings)
+	interval = l.last.Sub(l.first) / time.Duration(l.readings-1)
+	return latency, interval, latency > interval
+}
@@ -0,0 +1,63 @@
+package aggregator
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+)
+
+// reservoirSize is how many values the aggregator samples to estimate percentiles.
+// It bounds the sample's memory (8KiB) regardless of how many values are received.
+const reservoirSize = 1024
+
+// reservoir is a uniform random sample of a stream of values (Algorithm R):
+// every value received so far is in it with equal probability.
+// It holds every value until it's full, so percentiles of small streams are exact.
+type reservoir struct {
+	values []float64
+	seen   int
+	rand   *rand.Rand
+}
+
+// newReservoir returns an empty reservoir of up to size values.
+func newReservoir(size int) *reservoir {
+	return &reservoir{
+		values: make([]float64, 0, size),
+		rand:   rand.New(rand.NewPCG(1, 2)), // Fixed seed, so a run's percentiles are reproducible.
+	}
+}
+
+// add offers v to the sample.
+func (r *reservoir) add(v float64) {
+	r.seen++
+	if len(r.values) < cap(r.values) {
+		r.values = append(r.values, v)
+		return
+	}
+	if i := r.rand.IntN(r.seen); i < len(r.values) {
+		r.values[i] = v
+	}
+}
+
+// reset empties the sample.
+func (r *reservoir) reset() {
+	r.values = r.values[:0]
+	r.seen = 0
+}
+
+// percentiles returns the nearest-rank percentiles ps (each in (0, 1]) of the sampled values,
+// or zeros if there are none.
+func (r *reservoir) percentiles(ps ...float64) []float64 {
+	out := make([]float64, len(ps))
+	if len(r.values) == 0 {
+		return out
+	}
+
+	sorted := slices.Clone(r.values)
+	slices.Sort(sorted)
+	for i, p := range ps {
+		rank := int(math.Ceil(p * float64(len(sorted))))
+		out[i] = sorted[max(rank, 1)-1]
+	}
+	return out
+}
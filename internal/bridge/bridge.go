@@ -0,0 +1,83 @@
+// Package bridge consumes sensor data from a message stream and writes it to a sink.
+// It decouples durable storage from the producers, so a lightweight simulator
+// and a separate archiver can run independently.
+package bridge
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/shutdown"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sink"
+)
+
+// Source delivers consumed SensorData to a handler until its context is canceled.
+// A handler error signals that the record should be redelivered.
+// *nats.Consumer satisfies this interface.
+type Source interface {
+	Consume(ctx context.Context, handler func(model.SensorData) error) error
+}
+
+// Bridge moves records from a Source to a Sink.
+// Each record is written to the sink, and flushed if the sink buffers writes (see sink.Flusher),
+// before the handler returns and the source acks it, so a crash can't lose a record that was acked.
+// The sink must therefore write synchronously: one that only queues records (e.g. sink.AsyncSink)
+// would have them acked before they're stored.
+type Bridge struct {
+	source  Source
+	sink    sink.Sink
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+}
+
+// New creates and returns a new Bridge instance.
+func New(src Source, snk sink.Sink, m *metrics.Metrics, l *slog.Logger) *Bridge {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	return &Bridge{
+		source:  src,
+		sink:    snk,
+		metrics: m,
+		logger:  l.With("component", "bridge"),
+	}
+}
+
+// Run consumes from the source and writes every record to the sink.
+// It blocks until the context is canceled or the source fails.
+// Closing the sink is left to the caller.
+func (b *Bridge) Run(ctx context.Context) error {
+	b.logger.Info("Bridge starting")
+	defer func() {
+		b.logger.Info("Bridge stopping", "cause", shutdown.Reason(ctx))
+	}()
+
+	return b.source.Consume(ctx, func(data model.SensorData) error {
+		if err := b.store(ctx, data); err != nil {
+			b.logger.Warn("Failed to write record to sink", "sensor_id", data.ID, "error", err)
+			if b.metrics != nil {
+				b.metrics.BridgeWriteFailures.Inc()
+			}
+			return err
+		}
+
+		if b.metrics != nil {
+			b.metrics.BridgeRecordsWritten.Inc()
+		}
+		return nil
+	})
+}
+
+// store writes data to the sink, flushing it if the sink buffers writes.
+func (b *Bridge) store(ctx context.Context, data model.SensorData) error {
+	if err := b.sink.Write(ctx, data); err != nil {
+		return err
+	}
+	if f, ok := b.sink.(sink.Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+// Package bridge_test contains tests for the bridge package.
+package bridge_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/bridge"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/nats"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sink"
+)
+
+// fakeStream is an in-memory Source that redelivers records its handler rejects.
+type fakeStream struct {
+	msgs chan model.SensorData
+}
+
+func newFakeStream() *fakeStream {
+	return &fakeStream{msgs: make(chan model.SensorData, 100)}
+}
+
+func (f *fakeStream) publish(data model.SensorData) {
+	f.msgs <- data
+}
+
+func (f *fakeStream) Consume(ctx context.Context, handler func(model.SensorData) error) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case data := <-f.msgs:
+			if err := handler(data); err != nil {
+				f.msgs <- data // Redeliver.
+			}
+		}
+	}
+}
+
+// flakySink fails the first write of every record, then delegates to a MemorySink.
+type flakySink struct {
+	*sink.MemorySink
+	mu     sync.Mutex
+	failed map[int]bool
+}
+
+func (s *flakySink) Write(ctx context.Context, data model.SensorData) error {
+	s.mu.Lock()
+	first := !s.failed[data.ID]
+	s.failed[data.ID] = true
+	s.mu.Unlock()
+
+	if first {
+		return errors.New("sink unavailable")
+	}
+	return s.MemorySink.Write(ctx, data)
+}
+
+// waitForRecords polls the sink until it holds n records or the timeout elapses.
+func waitForRecords(t *testing.T, s *sink.MemorySink, n int, timeout time.Duration) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for s.Len() < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %d archived records, got %d", n, s.Len())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestBridge_Run_ArchivesAllMessages verifies every published message is written to the sink.
+func TestBridge_Run_ArchivesAllMessages(t *testing.T) {
+	t.Parallel()
+
+	stream := newFakeStream()
+	mem := sink.NewMemorySink()
+	b := bridge.New(stream, mem, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runFinished := make(chan error)
+	go func() {
+		runFinished <- b.Run(ctx)
+	}()
+
+	const n = 50
+	for i := 1; i <= n; i++ {
+		stream.publish(model.SensorData{ID: i, Value: float64(i)})
+	}
+
+	waitForRecords(t, mem, n, time.Second)
+
+	seen := make(map[int]bool)
+	for _, r := range mem.Records() {
+		seen[r.ID] = true
+	}
+	for i := 1; i <= n; i++ {
+		if !seen[i] {
+			t.Errorf("message from sensor %d was not archived", i)
+		}
+	}
+
+	cancel()
+	if err := <-runFinished; err != nil {
+		t.Errorf("expected Run to return nil, got %v", err)
+	}
+}
+
+// TestBridge_Run_RedeliversOnSinkFailure verifies records the sink rejects are redelivered, not lost.
+func TestBridge_Run_RedeliversOnSinkFailure(t *testing.T) {
+	t.Parallel()
+
+	stream := newFakeStream()
+	snk := &flakySink{MemorySink: sink.NewMemorySink(), failed: make(map[int]bool)}
+	b := bridge.New(stream, snk, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go b.Run(ctx)
+
+	const n = 5
+	for i := 1; i <= n; i++ {
+		stream.publish(model.SensorData{ID: i})
+	}
+
+	waitForRecords(t, snk.MemorySink, n, time.Second)
+}
+
+// bufferedSink is a sink.Flusher that only stores records in its MemorySink once they're flushed.
+// Its first flush fails, losing the buffered records, like a failed write to a file.
+type bufferedSink struct {
+	*sink.MemorySink
+	mu      sync.Mutex
+	pending []model.SensorData
+	flushes int
+}
+
+func (s *bufferedSink) Write(_ context.Context, data model.SensorData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = append(s.pending, data)
+	return nil
+}
+
+func (s *bufferedSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pending := s.pending
+	s.pending = nil
+	if s.flushes++; s.flushes == 1 {
+		return errors.New("disk full")
+	}
+	for _, data := range pending {
+		s.MemorySink.Write(context.Background(), data)
+	}
+	return nil
+}
+
+// sourceFunc adapts a function to the bridge.Source interface.
+type sourceFunc func(ctx context.Context, handler func(model.SensorData) error) error
+
+func (f sourceFunc) Consume(ctx context.Context, handler func(model.SensorData) error) error {
+	return f(ctx, handler)
+}
+
+// TestBridge_Run_FlushesBeforeAck verifies a record is only acked once it's flushed to a buffering sink,
+// and that a failed flush has the record redelivered rather than acked.
+func TestBridge_Run_FlushesBeforeAck(t *testing.T) {
+	t.Parallel()
+
+	snk := &bufferedSink{MemorySink: sink.NewMemorySink()}
+	const n = 5
+	var naks int
+	src := sourceFunc(func(_ context.Context, handler func(model.SensorData) error) error {
+		for i := 1; i <= n; i++ {
+			data := model.SensorData{ID: i}
+			for handler(data) != nil { // Nak'ed, so redelivered.
+				naks++
+			}
+			// Acked, so the record must be stored.
+			if got := snk.Len(); got != i {
+				t.Errorf("record %d: expected %d records stored once it was acked, got %d", i, i, got)
+			}
+		}
+		return nil
+	})
+
+	if err := bridge.New(src, snk, nil, nil).Run(context.Background()); err != nil {
+		t.Fatalf("expected Run to return nil, got %v", err)
+	}
+	if naks != 1 {
+		t.Errorf("expected the record whose flush failed to be nak'ed once, got %d naks", naks)
+	}
+}
+
+// TestBridge_Run_NATS runs the bridge against a real NATS server.
+// It is skipped unless NATS_URL points at a JetStream-enabled server.
+func TestBridge_Run_NATS(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL not set, skipping NATS integration test")
+	}
+
+	// Use a dedicated stream and prefix so the test doesn't interfere with a running simulator.
+	suffix := time.Now().UnixNano()
+	cfg := nats.DefaultConfig()
+	cfg.URL = url
+	cfg.StreamName = fmt.Sprintf("BRIDGE_TEST_%d", suffix)
+	cfg.SubjectPrefix = fmt.Sprintf("bridgetest.%d", suffix)
+
+	client, err := nats.NewClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to NATS: %v", err)
+	}
+	defer client.Close()
+	defer client.JetStream().DeleteStream(context.Background(), cfg.StreamName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	const n = 20
+	for i := 1; i <= n; i++ {
+		subject := fmt.Sprintf("%s.data.%d", cfg.SubjectPrefix, i)
+		if err := client.PublishJson(ctx, subject, model.SensorData{ID: i, Value: float64(i)}); err != nil {
+			t.Fatalf("failed to publish message %d: %v", i, err)
+		}
+	}
+
+	consumer, err := client.NewConsumer(ctx, nats.ConsumerConfig{
+		Durable:       "bridge-test",
+		FilterSubject: fmt.Sprintf("%s.data.>", cfg.SubjectPrefix),
+		AckWait:       5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to create consumer: %v", err)
+	}
+
+	mem := sink.NewMemorySink()
+	runCtx, stopRun := context.WithCancel(ctx)
+	runFinished := make(chan error)
+	go func() {
+		runFinished <- bridge.New(consumer, mem, nil, nil).Run(runCtx)
+	}()
+
+	waitForRecords(t, mem, n, 5*time.Second)
+
+	stopRun()
+	if err := <-runFinished; err != nil {
+		t.Errorf("expected Run to return nil, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+// Package buildinfo describes the running build: its version and commit, set at link time, and its Go version.
+package buildinfo
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Version and Commit identify the build. They're set at link time, e.g.
+//
+//	go build -ldflags "-X github.com/allthepins/iot-sensor-network-simulator/internal/buildinfo.Version=v1.2.0 \
+//		-X github.com/allthepins/iot-sensor-network-simulator/internal/buildinfo.Commit=$(git rev-parse --short HEAD)" ./cmd/simulator
+//
+// Without -ldflags, Version is "dev" and Commit is taken from the VCS information the go command embeds, if any.
+var (
+	Version = "dev"
+	Commit  = ""
+)
+
+// shortCommitLength is how many characters of an embedded commit hash are kept, like `git rev-parse --short`.
+const shortCommitLength = 12
+
+// Info describes the running build.
+type Info struct {
+	Version   string
+	Commit    string // "unknown" if it wasn't set or embedded.
+	GoVersion string
+}
+
+// Get returns the running build's Info.
+func Get() Info {
+	return Info{
+		Version:   Version,
+		Commit:    commit(),
+		GoVersion: runtime.Version(),
+	}
+}
+
+// String returns the build info as printed by -version, e.g. "v1.2.0 (commit 3d9dabf, go1.24.5)".
+func (i Info) String() string {
+	return fmt.Sprintf("%s (commit %s, %s)", i.Version, i.Commit, i.GoVersion)
+}
+
+// commit returns Commit, falling back to the embedded VCS revision (marked "-dirty" if the tree was modified).
+func commit() string {
+	if Commit != "" {
+		return Commit
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	var revision string
+	var modified bool
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			modified = s.Value == "true"
+		}
+	}
+	if revision == "" {
+		return "unknown"
+	}
+	if len(revision) > shortCommitLength {
+		revision = revision[:shortCommitLength]
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,35 @@
+// Package buildinfo_test contains tests for the buildinfo package.
+package buildinfo_test
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/buildinfo"
+)
+
+// TestGet verifies the build info defaults to a dev version, and always has a commit and the running Go version.
+func TestGet(t *testing.T) {
+	t.Parallel()
+
+	info := buildinfo.Get()
+	if info.Version != "dev" {
+		t.Errorf("expected version dev without -ldflags, got %q", info.Version)
+	}
+	if info.Commit == "" {
+		t.Error("expected a commit, or unknown")
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("expected Go version %q, got %q", runtime.Version(), info.GoVersion)
+	}
+}
+
+// TestInfo_String verifies the build info's printed form.
+func TestInfo_String(t *testing.T) {
+	t.Parallel()
+
+	info := buildinfo.Info{Version: "v1.2.0", Commit: "3d9dabf", GoVersion: "go1.24.5"}
+	if got, want := info.String(), "v1.2.0 (commit 3d9dabf, go1.24.5)"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
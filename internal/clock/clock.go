@@ -0,0 +1,63 @@
+// Package clock abstracts reading the time and waiting for it to pass, so that time-dependent code
+// can be tested deterministically with a Fake clock, advanced by hand rather than by sleeping.
+package clock
+
+import "time"
+
+// Clock tells the time, and creates tickers and timers firing as it passes.
+type Clock interface {
+	Now() time.Time
+	// NewTicker returns a Ticker firing every d, like time.NewTicker. It panics if d isn't positive.
+	NewTicker(d time.Duration) Ticker
+	// NewTimer returns a Timer firing once, after d, like time.NewTimer.
+	NewTimer(d time.Duration) Timer
+}
+
+// Ticker is a time.Ticker, behind an interface.
+type Ticker interface {
+	// C returns the channel the ticks are delivered on.
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// Timer is a time.Timer, behind an interface.
+type Timer interface {
+	// C returns the channel the time is delivered on once the timer fires.
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Real is the Clock of the time package: the actual time, and tickers and timers that fire as it passes.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{t: time.NewTicker(d)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{t: time.NewTimer(d)}
+}
+
+type realTicker struct {
+	t *time.Ticker
+}
+
+func (r realTicker) C() <-chan time.Time   { return r.t.C }
+func (r realTicker) Stop()                 { r.t.Stop() }
+func (r realTicker) Reset(d time.Duration) { r.t.Reset(d) }
+
+type realTimer struct {
+	t *time.Timer
+}
+
+func (r realTimer) C() <-chan time.Time        { return r.t.C }
+func (r realTimer) Stop() bool                 { return r.t.Stop() }
+func (r realTimer) Reset(d time.Duration) bool { return r.t.Reset(d) }
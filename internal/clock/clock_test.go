@@ -0,0 +1,143 @@
+// Package clock_test contains tests for the clock package.
+package clock_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/clock"
+)
+
+// start is when the tests' fake clocks start.
+var start = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// receive returns the time waiting on c, if any.
+func receive(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case now := <-c:
+		return now, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+// TestFake_Ticker verifies a fake ticker fires at each interval as the clock is advanced,
+// dropping the ticks that aren't received in time, and stops firing once stopped.
+func TestFake_Ticker(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(start)
+	ticker := clk.NewTicker(time.Second)
+
+	clk.Advance(999 * time.Millisecond)
+	if now, ok := receive(ticker.C()); ok {
+		t.Fatalf("expected no tick before the interval has passed, got %v", now)
+	}
+	clk.Advance(time.Millisecond)
+	if now, ok := receive(ticker.C()); !ok || !now.Equal(start.Add(time.Second)) {
+		t.Fatalf("expected a tick at %v, got %v (%t)", start.Add(time.Second), now, ok)
+	}
+
+	// Advancing past several intervals delivers just the first of the ticks.
+	clk.Advance(3 * time.Second)
+	if now, ok := receive(ticker.C()); !ok || !now.Equal(start.Add(2*time.Second)) {
+		t.Fatalf("expected a tick at %v, got %v (%t)", start.Add(2*time.Second), now, ok)
+	}
+	if now, ok := receive(ticker.C()); ok {
+		t.Fatalf("expected the missed ticks to be dropped, got %v", now)
+	}
+	if got := clk.Now(); !got.Equal(start.Add(4 * time.Second)) {
+		t.Errorf("expected the time to be %v, got %v", start.Add(4*time.Second), got)
+	}
+
+	ticker.Reset(time.Minute)
+	if d := clk.AdvanceToNext(); d != time.Minute {
+		t.Errorf("expected the reset ticker to be due in a minute, got %v", d)
+	}
+	if _, ok := receive(ticker.C()); !ok {
+		t.Error("expected the reset ticker to fire")
+	}
+
+	ticker.Stop()
+	clk.Advance(time.Hour)
+	if now, ok := receive(ticker.C()); ok {
+		t.Errorf("expected no tick once stopped, got %v", now)
+	}
+}
+
+// TestFake_Timer verifies a fake timer fires once, when the clock passes it, and can be stopped and reset.
+func TestFake_Timer(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(start)
+	timer := clk.NewTimer(time.Second)
+
+	clk.Advance(2 * time.Second)
+	if now, ok := receive(timer.C()); !ok || !now.Equal(start.Add(time.Second)) {
+		t.Fatalf("expected the timer to fire at %v, got %v (%t)", start.Add(time.Second), now, ok)
+	}
+	clk.Advance(time.Hour)
+	if now, ok := receive(timer.C()); ok {
+		t.Fatalf("expected the timer to fire only once, got %v", now)
+	}
+
+	if timer.Reset(time.Second) {
+		t.Error("expected Reset to report a fired timer as inactive")
+	}
+	if !timer.Stop() {
+		t.Error("expected Stop to report a waiting timer as active")
+	}
+	clk.Advance(time.Hour)
+	if now, ok := receive(timer.C()); ok {
+		t.Fatalf("expected a stopped timer not to fire, got %v", now)
+	}
+
+	// A timer that's already due fires straight away.
+	timer.Reset(0)
+	if _, ok := receive(timer.C()); !ok {
+		t.Error("expected a timer reset to 0 to fire straight away")
+	}
+}
+
+// TestFake_BlockUntil verifies BlockUntil waits for tickers and timers to be created by another goroutine,
+// and AdvanceToNext advances the clock to the earliest of them.
+func TestFake_BlockUntil(t *testing.T) {
+	t.Parallel()
+
+	clk := clock.NewFake(start)
+	fired := make(chan time.Time)
+	go func() {
+		slow := clk.NewTimer(time.Hour)
+		defer slow.Stop()
+		fired <- <-clk.NewTimer(time.Minute).C()
+	}()
+
+	clk.BlockUntil(2)
+	if d := clk.AdvanceToNext(); d != time.Minute {
+		t.Errorf("expected to advance to the earliest timer, a minute away, got %v", d)
+	}
+	if now := <-fired; !now.Equal(start.Add(time.Minute)) {
+		t.Errorf("expected the timer to fire at %v, got %v", start.Add(time.Minute), now)
+	}
+}
+
+// TestReal verifies the real clock tells the time, and its tickers and timers fire.
+func TestReal(t *testing.T) {
+	t.Parallel()
+
+	before := time.Now()
+	if now := clock.Real.Now(); now.Before(before) {
+		t.Errorf("expected the real time, got %v (before %v)", now, before)
+	}
+
+	ticker := clock.Real.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	timer := clock.Real.NewTimer(time.Millisecond)
+	for _, c := range []<-chan time.Time{ticker.C(), timer.C()} {
+		select {
+		case <-c:
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for the real clock to fire")
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package clock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Fake is a Clock whose time only passes when it's advanced, for tests.
+// Its tickers and timers fire as Advance moves the time past them, so a test can step code that waits on them
+// through any number of intervals without sleeping. It is safe for concurrent use.
+type Fake struct {
+	mu      sync.Mutex
+	waiting *sync.Cond // Broadcast whenever waiters changes, for BlockUntil.
+	now     time.Time
+	waiters []*waiter // The tickers and timers yet to fire.
+}
+
+// NewFake returns a Fake clock whose time is now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.waiting = sync.NewCond(&f.mu)
+	return f
+}
+
+// Now returns the clock's current time.
+func (f *Fake) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+// NewTicker returns a Ticker firing every d, as the clock is advanced. It panics if d isn't positive.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("clock: non-positive interval for NewTicker")
+	}
+
+	t := fakeTicker{&waiter{fake: f, c: make(chan time.Time, 1), period: d}}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(t.waiter, d)
+	return t
+}
+
+// NewTimer returns a Timer firing once the clock has been advanced by d (straight away if d isn't positive).
+func (f *Fake) NewTimer(d time.Duration) Timer {
+	t := fakeTimer{&waiter{fake: f, c: make(chan time.Time, 1)}}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.schedule(t.waiter, d)
+	return t
+}
+
+// Advance moves the clock's time forward by d, firing the tickers and timers due by then in order,
+// each at the time it was due. Like the time package's, a ticker whose last tick hasn't been received yet
+// drops the ticks it misses, so advancing past several of its intervals at once delivers just one.
+func (f *Fake) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.advanceTo(f.now.Add(d))
+}
+
+// AdvanceToNext moves the clock's time forward to when the next ticker or timer is due, firing it,
+// and returns how far it moved the time (0 if nothing is waiting to fire).
+// It lets a test measure the intervals code waits for, rather than assume them.
+func (f *Fake) AdvanceToNext() time.Duration {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if len(f.waiters) == 0 {
+		return 0
+	}
+	next := f.waiters[0].at
+	for _, w := range f.waiters[1:] {
+		if w.at.Before(next) {
+			next = w.at
+		}
+	}
+	d := next.Sub(f.now)
+	f.advanceTo(next)
+	return d
+}
+
+// advanceTo moves the clock's time forward to end, firing the tickers and timers due by then. f.mu must be held.
+func (f *Fake) advanceTo(end time.Time) {
+	for {
+		var next *waiter
+		for _, w := range f.waiters {
+			if !w.at.After(end) && (next == nil || w.at.Before(next.at)) {
+				next = w
+			}
+		}
+		if next == nil {
+			break
+		}
+
+		f.now = next.at
+		next.fire(f.now)
+		if next.period > 0 {
+			next.at = next.at.Add(next.period)
+		} else {
+			f.remove(next)
+		}
+	}
+	f.now = end
+}
+
+// BlockUntil waits until at least n tickers and timers are waiting to fire, e.g. for the code under test
+// to have created its ticker before the test advances the clock past it.
+func (f *Fake) BlockUntil(n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for len(f.waiters) < n {
+		f.waiting.Wait()
+	}
+}
+
+// schedule has w fire d from now, or fires it straight away if d isn't positive (only timers can be). f.mu must be held.
+func (f *Fake) schedule(w *waiter, d time.Duration) {
+	if d <= 0 {
+		w.fire(f.now)
+		return
+	}
+	w.at = f.now.Add(d)
+	f.waiters = append(f.waiters, w)
+	f.waiting.Broadcast()
+}
+
+// remove stops w from firing, reporting whether it was waiting to. f.mu must be held.
+func (f *Fake) remove(w *waiter) bool {
+	i := slices.Index(f.waiters, w)
+	if i < 0 {
+		return false
+	}
+	f.waiters = slices.Delete(f.waiters, i, i+1)
+	f.waiting.Broadcast()
+	return true
+}
+
+// waiter is a Fake clock's ticker (with a period) or timer (without).
+type waiter struct {
+	fake   *Fake
+	c      chan time.Time
+	at     time.Time // When it next fires.
+	period time.Duration
+}
+
+// C returns the channel the waiter fires on.
+func (w *waiter) C() <-chan time.Time {
+	return w.c
+}
+
+// fire delivers now on the waiter's channel, unless a previous time is still waiting to be received.
+func (w *waiter) fire(now time.Time) {
+	select {
+	case w.c <- now:
+	default:
+	}
+}
+
+// stop stops the waiter, discarding any time delivered but not yet received (like the time package's
+// tickers and timers), and reports whether it was waiting to fire.
+func (w *waiter) stop() bool {
+	w.fake.mu.Lock()
+	defer w.fake.mu.Unlock()
+	return w.stopLocked()
+}
+
+// stopLocked is stop, with the clock's mutex held.
+func (w *waiter) stopLocked() bool {
+	select {
+	case <-w.c:
+	default:
+	}
+	return w.fake.remove(w)
+}
+
+// reset stops the waiter, then has it fire d from now (and a ticker, every d), reporting whether it was waiting to fire.
+func (w *waiter) reset(d time.Duration) bool {
+	w.fake.mu.Lock()
+	defer w.fake.mu.Unlock()
+	active := w.stopLocked()
+	if w.period > 0 {
+		w.period = d
+	}
+	w.fake.schedule(w, d)
+	return active
+}
+
+type fakeTicker struct {
+	*waiter
+}
+
+func (t fakeTicker) Stop() {
+	t.stop()
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("clock: non-positive interval for Ticker.Reset")
+	}
+	t.reset(d)
+}
+
+type fakeTimer struct {
+	*waiter
+}
+
+func (t fakeTimer) Stop() bool {
+	return t.stop()
+}
+
+func (t fakeTimer) Reset(d time.Duration) bool {
+	return t.reset(d)
+}
@@ -0,0 +1,76 @@
+// Package codec encodes SensorData for the wire, as JSON or as Protobuf.
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+const (
+	// HeaderContentType is the NATS header naming the codec a message payload was encoded with.
+	// Messages without it carry JSON.
+	HeaderContentType = "Content-Type"
+
+	// ContentTypeJSON is the content type of JSONCodec payloads.
+	ContentTypeJSON = "application/json"
+	// ContentTypeProto is the content type of ProtoCodec payloads.
+	ContentTypeProto = "application/x-protobuf"
+)
+
+// Codec encodes and decodes SensorData payloads.
+type Codec interface {
+	Marshal(data model.SensorData) ([]byte, error)
+	Unmarshal(b []byte, data *model.SensorData) error
+	// ContentType identifies the encoding, e.g. in the HeaderContentType header.
+	ContentType() string
+}
+
+// Appender is implemented by codecs that can encode into an existing buffer,
+// so callers reusing buffers don't allocate a payload per message.
+type Appender interface {
+	// Append appends data's encoding to b and returns the extended buffer.
+	Append(b []byte, data model.SensorData) ([]byte, error)
+}
+
+// JSONCodec encodes SensorData as JSON, with encoding/json.
+type JSONCodec struct{}
+
+// Marshal returns data's JSON encoding.
+func (JSONCodec) Marshal(data model.SensorData) ([]byte, error) {
+	return json.Marshal(data)
+}
+
+// Unmarshal decodes JSON-encoded SensorData from b into data.
+func (JSONCodec) Unmarshal(b []byte, data *model.SensorData) error {
+	return json.Unmarshal(b, data)
+}
+
+// ContentType returns ContentTypeJSON.
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+// Parse returns the codec with the given name: json or proto.
+func Parse(name string) (Codec, error) {
+	switch name {
+	case "json":
+		return JSONCodec{}, nil
+	case "proto":
+		return ProtoCodec{}, nil
+	default:
+		return nil, fmt.Errorf("codec must be json or proto, got %q", name)
+	}
+}
+
+// ForContentType returns the codec of payloads with the given HeaderContentType header.
+// An empty content type is JSON.
+func ForContentType(contentType string) (Codec, error) {
+	switch contentType {
+	case "", ContentTypeJSON:
+		return JSONCodec{}, nil
+	case ContentTypeProto:
+		return ProtoCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %q", contentType)
+	}
+}
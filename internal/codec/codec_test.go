@@ -0,0 +1,191 @@
+package codec_test
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// reading returns a SensorData with every field set.
+func reading() model.SensorData {
+	return model.SensorData{
+		SchemaVersion:   model.SchemaVersion,
+		ID:              42,
+		DeviceID:        "a1b2c3",
+		Type:            "temperature",
+		Unit:            "celsius",
+		Value:           -21.375,
+		Timestamp:       time.Date(2025, 7, 1, 12, 30, 15, 123_000_000, time.UTC),
+		Model:           "SIM-100",
+		FirmwareVersion: "1.4.2",
+		Tags:            map[string]string{"site": "north", "floor": "3"},
+		Latitude:        51.5072,
+		Longitude:       -0.1276,
+	}
+}
+
+// TestCodecs_RoundTrip verifies each codec decodes what it encodes, for full and zero readings.
+func TestCodecs_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, c := range []codec.Codec{codec.JSONCodec{}, codec.ProtoCodec{}} {
+		for _, want := range []model.SensorData{reading(), {ID: 1}} {
+			b, err := c.Marshal(want)
+			if err != nil {
+				t.Fatalf("%s: failed to marshal: %v", c.ContentType(), err)
+			}
+
+			var got model.SensorData
+			if err := c.Unmarshal(b, &got); err != nil {
+				t.Fatalf("%s: failed to unmarshal: %v", c.ContentType(), err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: expected %+v, got %+v", c.ContentType(), want, got)
+			}
+		}
+	}
+}
+
+// TestProtoCodec_MatchesSchema verifies ProtoCodec's encoding is the SensorData message generated from sensordata.proto,
+// by decoding it with the protobuf runtime, and decoding the runtime's own encoding of it back.
+func TestProtoCodec_MatchesSchema(t *testing.T) {
+	t.Parallel()
+
+	want := reading()
+	b, err := codec.ProtoCodec{}.Marshal(want)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var msg codec.SensorData
+	if err := proto.Unmarshal(b, &msg); err != nil {
+		t.Fatalf("protobuf runtime failed to unmarshal: %v", err)
+	}
+	if got := msg.GetDeviceId(); got != want.DeviceID {
+		t.Errorf("expected device_id %q, got %q", want.DeviceID, got)
+	}
+	if got := msg.GetValue(); got != want.Value {
+		t.Errorf("expected value %v, got %v", want.Value, got)
+	}
+	if got := msg.GetTimestamp().AsTime(); !got.Equal(want.Timestamp) {
+		t.Errorf("expected timestamp %v, got %v", want.Timestamp, got)
+	}
+	if got := len(msg.GetTags()); got != len(want.Tags) {
+		t.Errorf("expected %d tags, got %d", len(want.Tags), got)
+	}
+
+	reencoded, err := proto.Marshal(&msg)
+	if err != nil {
+		t.Fatalf("protobuf runtime failed to marshal: %v", err)
+	}
+	var got model.SensorData
+	if err := (codec.ProtoCodec{}).Unmarshal(reencoded, &got); err != nil {
+		t.Fatalf("failed to unmarshal the runtime's encoding: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+// TestSensorData_CoversModel verifies sensordata.proto has a field for each of model.SensorData's,
+// so a field added to the model isn't silently dropped by ProtoCodec.
+func TestSensorData_CoversModel(t *testing.T) {
+	t.Parallel()
+
+	fields := (&codec.SensorData{}).ProtoReflect().Descriptor().Fields()
+	if want := reflect.TypeFor[model.SensorData]().NumField(); fields.Len() != want {
+		t.Errorf("expected %d fields in sensordata.proto, got %d", want, fields.Len())
+	}
+}
+
+// TestProtoCodec_Unmarshal_Invalid verifies truncated payloads are rejected.
+func TestProtoCodec_Unmarshal_Invalid(t *testing.T) {
+	t.Parallel()
+
+	b, err := codec.ProtoCodec{}.Marshal(reading())
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+
+	var data model.SensorData
+	if err := (codec.ProtoCodec{}).Unmarshal(b[:len(b)-3], &data); err == nil {
+		t.Error("expected an error for a truncated payload, got nil")
+	}
+}
+
+// TestForContentType verifies payloads without a content type are JSON, and unknown content types are rejected.
+func TestForContentType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		contentType string
+		want        codec.Codec
+	}{
+		{contentType: "", want: codec.JSONCodec{}},
+		{contentType: codec.ContentTypeJSON, want: codec.JSONCodec{}},
+		{contentType: codec.ContentTypeProto, want: codec.ProtoCodec{}},
+		{contentType: "application/xml"},
+	}
+
+	for _, tt := range tests {
+		got, err := codec.ForContentType(tt.contentType)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %T", tt.contentType, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.contentType, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%q: expected %T, got %T", tt.contentType, tt.want, got)
+		}
+	}
+}
+
+// BenchmarkCodec_Marshal compares encoding a reading as JSON and as Protobuf.
+func BenchmarkCodec_Marshal(b *testing.B) {
+	data := reading()
+	for _, c := range []codec.Codec{codec.JSONCodec{}, codec.ProtoCodec{}} {
+		b.Run(c.ContentType(), func(b *testing.B) {
+			b.ReportAllocs()
+			b.ResetTimer()
+			var size int
+			for range b.N {
+				payload, err := c.Marshal(data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(payload)
+			}
+			b.ReportMetric(float64(size), "payload-bytes")
+		})
+	}
+}
+
+// BenchmarkCodec_Unmarshal compares decoding a reading from JSON and from Protobuf.
+func BenchmarkCodec_Unmarshal(b *testing.B) {
+	for _, c := range []codec.Codec{codec.JSONCodec{}, codec.ProtoCodec{}} {
+		b.Run(c.ContentType(), func(b *testing.B) {
+			payload, err := c.Marshal(reading())
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				var data model.SensorData
+				if err := c.Unmarshal(payload, &data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package codec
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative internal/codec/sensordata.proto
+
+// ProtoCodec encodes SensorData as the SensorData message of sensordata.proto, through its generated type.
+// As in proto3, zero values aren't encoded, and a zero Timestamp is omitted.
+// Decoded timestamps are in UTC.
+type ProtoCodec struct{}
+
+// Marshal returns data's Protobuf encoding.
+func (ProtoCodec) Marshal(data model.SensorData) ([]byte, error) {
+	return proto.Marshal(ToProto(data))
+}
+
+// Append appends data's Protobuf encoding to b.
+func (ProtoCodec) Append(b []byte, data model.SensorData) ([]byte, error) {
+	return proto.MarshalOptions{}.MarshalAppend(b, ToProto(data))
+}
+
+// Unmarshal decodes Protobuf-encoded SensorData from b into data.
+// Unknown fields are skipped, so payloads from newer schema versions still decode.
+func (ProtoCodec) Unmarshal(b []byte, data *model.SensorData) error {
+	var msg SensorData
+	if err := proto.Unmarshal(b, &msg); err != nil {
+		return fmt.Errorf("invalid protobuf payload: %w", err)
+	}
+	*data = FromProto(&msg)
+	return nil
+}
+
+// ContentType returns ContentTypeProto.
+func (ProtoCodec) ContentType() string { return ContentTypeProto }
+
+// ToProto returns data as a SensorData message. A zero Timestamp is left unset.
+// The message shares data's Tags.
+func ToProto(data model.SensorData) *SensorData {
+	msg := &SensorData{
+		SchemaVersion:   int32(data.SchemaVersion),
+		Id:              int64(data.ID),
+		DeviceId:        data.DeviceID,
+		Type:            data.Type,
+		Unit:            data.Unit,
+		Value:           data.Value,
+		Model:           data.Model,
+		FirmwareVersion: data.FirmwareVersion,
+		Tags:            data.Tags,
+		Latitude:        data.Latitude,
+		Longitude:       data.Longitude,
+	}
+	if !data.Timestamp.IsZero() {
+		msg.Timestamp = timestamppb.New(data.Timestamp)
+	}
+	return msg
+}
+
+// FromProto returns the SensorData msg carries, with its timestamp in UTC.
+// A nil msg is a zero reading.
+func FromProto(msg *SensorData) model.SensorData {
+	data := model.SensorData{
+		SchemaVersion:   int(msg.GetSchemaVersion()),
+		ID:              int(msg.GetId()),
+		DeviceID:        msg.GetDeviceId(),
+		Type:            msg.GetType(),
+		Unit:            msg.GetUnit(),
+		Value:           msg.GetValue(),
+		Model:           msg.GetModel(),
+		FirmwareVersion: msg.GetFirmwareVersion(),
+		Tags:            msg.GetTags(),
+		Latitude:        msg.GetLatitude(),
+		Longitude:       msg.GetLongitude(),
+	}
+	if ts := msg.GetTimestamp(); ts != nil {
+		data.Timestamp = ts.AsTime()
+	}
+	return data
+}
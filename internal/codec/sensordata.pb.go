@@ -0,0 +1,236 @@
+// Protobuf schema of model.SensorData, as encoded by ProtoCodec.
+// Field numbers must never be reused: retire removed fields with `reserved`.
+// After changing it, regenerate sensordata.pb.go with `go generate ./internal/codec`.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: internal/codec/sensordata.proto
+
+package codec
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// SensorData is a single reading emitted by a simulated sensor.
+type SensorData struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	SchemaVersion   int32                  `protobuf:"varint,1,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	Id              int64                  `protobuf:"varint,2,opt,name=id,proto3" json:"id,omitempty"`
+	DeviceId        string                 `protobuf:"bytes,3,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Type            string                 `protobuf:"bytes,4,opt,name=type,proto3" json:"type,omitempty"`
+	Unit            string                 `protobuf:"bytes,5,opt,name=unit,proto3" json:"unit,omitempty"`
+	Value           float64                `protobuf:"fixed64,6,opt,name=value,proto3" json:"value,omitempty"`
+	Timestamp       *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Model           string                 `protobuf:"bytes,8,opt,name=model,proto3" json:"model,omitempty"`
+	FirmwareVersion string                 `protobuf:"bytes,9,opt,name=firmware_version,json=firmwareVersion,proto3" json:"firmware_version,omitempty"`
+	Tags            map[string]string      `protobuf:"bytes,10,rep,name=tags,proto3" json:"tags,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Latitude        float64                `protobuf:"fixed64,11,opt,name=latitude,proto3" json:"latitude,omitempty"`
+	Longitude       float64                `protobuf:"fixed64,12,opt,name=longitude,proto3" json:"longitude,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *SensorData) Reset() {
+	*x = SensorData{}
+	mi := &file_internal_codec_sensordata_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SensorData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SensorData) ProtoMessage() {}
+
+func (x *SensorData) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_codec_sensordata_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SensorData.ProtoReflect.Descriptor instead.
+func (*SensorData) Descriptor() ([]byte, []int) {
+	return file_internal_codec_sensordata_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *SensorData) GetSchemaVersion() int32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+func (x *SensorData) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *SensorData) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *SensorData) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *SensorData) GetUnit() string {
+	if x != nil {
+		return x.Unit
+	}
+	return ""
+}
+
+func (x *SensorData) GetValue() float64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *SensorData) GetTimestamp() *timestamppb.Timestamp {
+	if x != nil {
+		return x.Timestamp
+	}
+	return nil
+}
+
+func (x *SensorData) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *SensorData) GetFirmwareVersion() string {
+	if x != nil {
+		return x.FirmwareVersion
+	}
+	return ""
+}
+
+func (x *SensorData) GetTags() map[string]string {
+	if x != nil {
+		return x.Tags
+	}
+	return nil
+}
+
+func (x *SensorData) GetLatitude() float64 {
+	if x != nil {
+		return x.Latitude
+	}
+	return 0
+}
+
+func (x *SensorData) GetLongitude() float64 {
+	if x != nil {
+		return x.Longitude
+	}
+	return 0
+}
+
+var File_internal_codec_sensordata_proto protoreflect.FileDescriptor
+
+const file_internal_codec_sensordata_proto_rawDesc = "" +
+	"\n" +
+	"\x1finternal/codec/sensordata.proto\x12\x0eiot.sensors.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xc6\x03\n" +
+	"\n" +
+	"SensorData\x12%\n" +
+	"\x0eschema_version\x18\x01 \x01(\x05R\rschemaVersion\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\x03R\x02id\x12\x1b\n" +
+	"\tdevice_id\x18\x03 \x01(\tR\bdeviceId\x12\x12\n" +
+	"\x04type\x18\x04 \x01(\tR\x04type\x12\x12\n" +
+	"\x04unit\x18\x05 \x01(\tR\x04unit\x12\x14\n" +
+	"\x05value\x18\x06 \x01(\x01R\x05value\x128\n" +
+	"\ttimestamp\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\ttimestamp\x12\x14\n" +
+	"\x05model\x18\b \x01(\tR\x05model\x12)\n" +
+	"\x10firmware_version\x18\t \x01(\tR\x0ffirmwareVersion\x128\n" +
+	"\x04tags\x18\n" +
+	" \x03(\v2$.iot.sensors.v1.SensorData.TagsEntryR\x04tags\x12\x1a\n" +
+	"\blatitude\x18\v \x01(\x01R\blatitude\x12\x1c\n" +
+	"\tlongitude\x18\f \x01(\x01R\tlongitude\x1a7\n" +
+	"\tTagsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01BCZAgithub.com/allthepins/iot-sensor-network-simulator/internal/codecb\x06proto3"
+
+var (
+	file_internal_codec_sensordata_proto_rawDescOnce sync.Once
+	file_internal_codec_sensordata_proto_rawDescData []byte
+)
+
+func file_internal_codec_sensordata_proto_rawDescGZIP() []byte {
+	file_internal_codec_sensordata_proto_rawDescOnce.Do(func() {
+		file_internal_codec_sensordata_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_codec_sensordata_proto_rawDesc), len(file_internal_codec_sensordata_proto_rawDesc)))
+	})
+	return file_internal_codec_sensordata_proto_rawDescData
+}
+
+var file_internal_codec_sensordata_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_internal_codec_sensordata_proto_goTypes = []any{
+	(*SensorData)(nil),            // 0: iot.sensors.v1.SensorData
+	nil,                           // 1: iot.sensors.v1.SensorData.TagsEntry
+	(*timestamppb.Timestamp)(nil), // 2: google.protobuf.Timestamp
+}
+var file_internal_codec_sensordata_proto_depIdxs = []int32{
+	2, // 0: iot.sensors.v1.SensorData.timestamp:type_name -> google.protobuf.Timestamp
+	1, // 1: iot.sensors.v1.SensorData.tags:type_name -> iot.sensors.v1.SensorData.TagsEntry
+	2, // [2:2] is the sub-list for method output_type
+	2, // [2:2] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_internal_codec_sensordata_proto_init() }
+func file_internal_codec_sensordata_proto_init() {
+	if File_internal_codec_sensordata_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_codec_sensordata_proto_rawDesc), len(file_internal_codec_sensordata_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_internal_codec_sensordata_proto_goTypes,
+		DependencyIndexes: file_internal_codec_sensordata_proto_depIdxs,
+		MessageInfos:      file_internal_codec_sensordata_proto_msgTypes,
+	}.Build()
+	File_internal_codec_sensordata_proto = out.File
+	file_internal_codec_sensordata_proto_goTypes = nil
+	file_internal_codec_sensordata_proto_depIdxs = nil
+}
@@ -0,0 +1,516 @@
+// Package config holds the simulator's run configuration,
+// loaded from an optional YAML file and overridden by command-line flags.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/aggregator"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/kafka"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/logging"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/mqtt"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/nats"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sensor"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sink"
+)
+
+// Brokers sensor data can be published to.
+const (
+	SinkNATS  = "nats"
+	SinkMQTT  = "mqtt"
+	SinkKafka = "kafka"
+)
+
+// ErrVersion is returned by Parse when the build's version was requested with -version.
+var ErrVersion = errors.New("version requested")
+
+// Config holds the simulator settings that can be set at run time.
+// Durations are written in YAML as time.ParseDuration strings, e.g. "100ms" or "2m".
+type Config struct {
+	SensorCount        int           `yaml:"sensors"`
+	SensorInterval     time.Duration `yaml:"interval"`
+	SimulationDuration time.Duration `yaml:"duration"`
+	MetricsAddr        string        `yaml:"metrics_addr"`
+	PprofAddr          string        `yaml:"pprof_addr"`
+	// GRPCAddr, when set, is the address the gRPC ingestion and subscription service listens on.
+	GRPCAddr string `yaml:"grpc_addr"`
+	// AdminAddr, when set, is the address the admin server (pausing, resuming and rescaling the sensors) listens on.
+	AdminAddr string `yaml:"admin_addr"`
+	// AdminToken, when set, is the bearer token the admin server requires of requests that change anything.
+	// It can only be set in the config file, so it doesn't show up in the process list.
+	AdminToken string `yaml:"admin_token"`
+	// Seed is the base seed of the sensors' random sources. 0 generates one, which is logged so the run can be reproduced.
+	Seed int64 `yaml:"seed"`
+	// RampDuration staggers the sensors' starts over the given duration, so they come online gradually
+	// rather than all at once. 0 starts them all straight away.
+	RampDuration time.Duration `yaml:"ramp"`
+	// MaxRate, when positive, caps the readings the whole fleet sends per second, e.g. to simulate a constrained uplink.
+	// Sensors wait for their turn rather than dropping readings.
+	MaxRate float64 `yaml:"max_rate"`
+	// DropOnFull makes sensors drop readings the data channel has no room for, rather than block until there is room.
+	DropOnFull bool `yaml:"drop_on_full"`
+	// Replay, when set, is a file of recorded readings (e.g. from CSVOut) to replay instead of running the synthetic sensors.
+	// The run ends once every reading has been replayed.
+	Replay string `yaml:"replay"`
+	// ReplaySpeed multiplies the pace readings are replayed at, e.g. 2 replays them twice as fast as they were recorded.
+	ReplaySpeed float64 `yaml:"replay_speed"`
+	// CSVOut, when set, is the CSV file every reading is also written to, for offline analysis.
+	CSVOut string `yaml:"csv_out"`
+	// SocketOut, when set, is the "tcp://host:port" or "udp://host:port" address every reading is also sent to,
+	// as a JSON line, e.g. for a legacy collector.
+	SocketOut string `yaml:"socket_out"`
+	// Locations, when set, are the positions of the first sensors, by ID, included in their readings (e.g. for a map demo),
+	// as semicolon-separated "latitude,longitude" pairs, e.g. "51.5,-0.12;48.86,2.35".
+	Locations string `yaml:"locations"`
+	// LocationBox, when set, is the area the other sensors are placed at random within (reproducibly for a given seed),
+	// as its south-west and north-east corners, "min_lat,min_lon,max_lat,max_lon", e.g. "51.28,-0.51,51.69,0.33".
+	LocationBox string `yaml:"location_box"`
+	// DeviceIDs is how sensors' external device IDs are allocated: sequential, uuid or mac.
+	DeviceIDs string `yaml:"device_ids"`
+	// ValueExpression, when set, generates the sensors' values as an expression of `t` (seconds since the start)
+	// and `id`, e.g. "20 + 5*sin(t/3600)", instead of their profiles' distributions.
+	ValueExpression string `yaml:"value_expression"`
+	// DriftRate is how much sensors' values drift by per second, simulating degradation (0 disables drift).
+	DriftRate float64 `yaml:"drift_rate"`
+	// Jitter is the fraction sensors' intervals randomly vary by, in [0, 1), e.g. 0.1 for ±10% (0 keeps them strictly periodic).
+	Jitter float64 `yaml:"jitter"`
+	// Dashboard shows a live terminal dashboard of the simulation while it runs.
+	// Logs go to a file meanwhile (Log.File, or simulator.log), since the dashboard takes over the terminal.
+	Dashboard bool `yaml:"dashboard"`
+	// Ingest accepts readings pushed by external sensors at `POST /ingest` on the metrics address.
+	Ingest bool `yaml:"ingest"`
+	// LatestCache serves each sensor's latest reading at `GET /latest/{id}` on the metrics address.
+	LatestCache bool `yaml:"latest_cache"`
+	// Registry registers live sensors in a NATS KV bucket, for dashboards to enumerate. It requires NATS.
+	Registry bool `yaml:"registry"`
+	// MemoryLimit, when positive, is the soft heap cap in bytes above which the sensors shed load,
+	// dropping the readings the data channel has no room for.
+	MemoryLimit  uint64             `yaml:"memory_limit"`
+	Backpressure BackpressureConfig `yaml:"backpressure"`
+	Bridge       BridgeConfig       `yaml:"bridge"`
+	LiveFeed     LiveFeedConfig     `yaml:"live_feed"`
+	Parquet      ParquetConfig      `yaml:"parquet"`
+	Aggregator   AggregatorConfig   `yaml:"aggregator"`
+	// Sink is the broker sensor data is published to: SinkNATS, SinkMQTT or SinkKafka.
+	Sink string `yaml:"sink"`
+	// Codec is how readings are encoded for the broker: json or proto.
+	Codec string      `yaml:"codec"`
+	NATS  NATSConfig  `yaml:"nats"`
+	MQTT  MQTTConfig  `yaml:"mqtt"`
+	Kafka KafkaConfig `yaml:"kafka"`
+	Log   LogConfig   `yaml:"log"`
+}
+
+// NATSConfig holds the settings of the NATS integration.
+type NATSConfig struct {
+	Enabled       bool   `yaml:"enabled"`
+	URL           string `yaml:"url"`
+	Stream        string `yaml:"stream"`
+	SubjectPrefix string `yaml:"subject_prefix"`
+	// Storage is the stream's storage backend: file, or memory (e.g. for tests). Replicas is its replica count.
+	Storage  string `yaml:"storage"`
+	Replicas int    `yaml:"replicas"`
+}
+
+// MQTTConfig holds the settings of the MQTT integration, used when Sink is SinkMQTT.
+type MQTTConfig struct {
+	URL         string `yaml:"url"`
+	ClientID    string `yaml:"client_id"`
+	TopicPrefix string `yaml:"topic_prefix"`
+}
+
+// KafkaConfig holds the settings of the Kafka integration, used when Sink is SinkKafka.
+type KafkaConfig struct {
+	// Brokers is a comma-separated list of broker addresses, e.g. "kafka-1:9092,kafka-2:9092".
+	Brokers string `yaml:"brokers"`
+	Topic   string `yaml:"topic"`
+	// BatchSize is how many messages are written to a partition at once, at most.
+	// A partial batch is written once it's BatchTimeout old.
+	BatchSize    int           `yaml:"batch_size"`
+	BatchTimeout time.Duration `yaml:"batch_timeout"`
+}
+
+// BackpressureConfig holds the settings of the sensors' backpressure, which slows them down
+// while the data channel stays nearly full.
+type BackpressureConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// MaxInterval is the interval the sensors slow down to, at most, under full pressure.
+	MaxInterval time.Duration `yaml:"max_interval"`
+}
+
+// BridgeConfig holds the settings of the bridge, which archives the NATS stream to a file. It requires NATS.
+type BridgeConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Output is the NDJSON file the stream is archived to.
+	Output string `yaml:"output"`
+}
+
+// LiveFeedConfig holds the settings of the live feed, which streams readings to WebSocket clients
+// at `/ws` on the metrics address.
+type LiveFeedConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Queue is how many readings are queued per client before it's disconnected as too slow.
+	Queue int `yaml:"queue"`
+}
+
+// ParquetConfig holds the settings of the Parquet sink, which writes every reading to Parquet files, for analytics.
+type ParquetConfig struct {
+	// Output, when set, is the path the files are named after, e.g. data.parquet for data-<start>-0001.parquet.
+	Output string `yaml:"output"`
+	// RowGroupSize is how many readings are buffered, then written to the file as a row group.
+	RowGroupSize int `yaml:"row_group_size"`
+	// RollRecords and RollInterval roll over to a new file once the current one holds that many readings,
+	// or is that old (0 disables either).
+	RollRecords  int           `yaml:"roll_records"`
+	RollInterval time.Duration `yaml:"roll_interval"`
+}
+
+// AggregatorConfig holds the settings of the aggregator's summaries and checks.
+type AggregatorConfig struct {
+	// SummaryOutput is how the periodic summaries are emitted: log, json (appended to SummaryFile), or metrics-only.
+	SummaryOutput string `yaml:"summary_output"`
+	SummaryFile   string `yaml:"summary_file"`
+	// WindowedStats makes the summaries' value statistics cover each window, rather than the whole run.
+	WindowedStats bool `yaml:"windowed_stats"`
+	// StaleAfter flags sensors whose latest reading is older as stale (0 disables it).
+	StaleAfter time.Duration `yaml:"stale_after"`
+	// DetectAnomalies flags readings more than aggregator.DefaultZThreshold standard deviations from their sensor's mean.
+	DetectAnomalies bool `yaml:"detect_anomalies"`
+}
+
+// LogConfig holds the logging settings.
+type LogConfig struct {
+	// Level is the minimum level logged: debug, info, warn or error.
+	Level string `yaml:"level"`
+	// Format is the log output format: json or text.
+	Format string `yaml:"format"`
+	// File, when set, is the file logs are written to instead of stdout, rotated once it reaches MaxSizeMB.
+	// MaxBackups rotated files are kept, for up to MaxAgeDays (0 keeps them regardless of count or age).
+	File       string `yaml:"file"`
+	MaxSizeMB  int    `yaml:"max_size_mb"`
+	MaxBackups int    `yaml:"max_backups"`
+	MaxAgeDays int    `yaml:"max_age_days"`
+}
+
+// Default returns the default configuration.
+func Default() Config {
+	mqttDefaults := mqtt.DefaultConfig()
+	kafkaDefaults := kafka.DefaultConfig()
+	return Config{
+		SensorCount:        5000,
+		SensorInterval:     100 * time.Millisecond,
+		SimulationDuration: 10 * time.Minute, // Long enough to allow time to monitor metrics.
+		MetricsAddr:        ":2112",
+		PprofAddr:          ":6060",
+		ReplaySpeed:        1,
+		DeviceIDs:          "sequential",
+		Backpressure:       BackpressureConfig{MaxInterval: time.Second},
+		Bridge:             BridgeConfig{Output: "bridge.ndjson"},
+		LiveFeed:           LiveFeedConfig{Queue: 256},
+		Parquet:            ParquetConfig{RowGroupSize: sink.DefaultParquetRowGroupSize, RollRecords: 1_000_000, RollInterval: time.Hour},
+		Aggregator: AggregatorConfig{
+			SummaryOutput: string(aggregator.SummaryLog),
+			SummaryFile:   "summaries.ndjson",
+			StaleAfter:    10 * time.Second,
+		},
+		NATS: NATSConfig{
+			Enabled:       true,
+			URL:           "nats://localhost:4222",
+			Stream:        nats.DefaultStreamName,
+			SubjectPrefix: nats.DefaultSubjectPrefix,
+			Storage:       "file",
+			Replicas:      1,
+		},
+		Sink:  SinkNATS,
+		Codec: "json",
+		MQTT: MQTTConfig{
+			URL:         mqttDefaults.URL,
+			ClientID:    mqttDefaults.ClientID,
+			TopicPrefix: mqttDefaults.TopicPrefix,
+		},
+		Kafka: KafkaConfig{
+			Brokers:      strings.Join(kafkaDefaults.Brokers, ","),
+			Topic:        kafkaDefaults.Topic,
+			BatchSize:    kafkaDefaults.BatchSize,
+			BatchTimeout: kafkaDefaults.BatchTimeout,
+		},
+		Log: LogConfig{
+			Level:      "info",
+			Format:     logging.FormatJSON,
+			MaxSizeMB:  logging.DefaultMaxSizeMB,
+			MaxBackups: logging.DefaultMaxBackups,
+			MaxAgeDays: logging.DefaultMaxAgeDays,
+		},
+	}
+}
+
+// Load reads a YAML configuration file from path and validates it.
+// Settings the file omits keep their Default values. Unknown keys are an error.
+func Load(path string) (*Config, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	cfg := Default()
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) { // An empty file keeps every default.
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// bindFlags defines the command-line flags on fs, with cfg's values as their defaults.
+// -config is bound to configPath, and -version to showVersion.
+func (cfg *Config) bindFlags(fs *flag.FlagSet, configPath *string, showVersion *bool) {
+	fs.StringVar(configPath, "config", "", "YAML config file; flags override its settings")
+	fs.BoolVar(showVersion, "version", false, "print the build's version and exit")
+	fs.IntVar(&cfg.SensorCount, "sensors", cfg.SensorCount, "number of simulated sensors (0 runs as a consumer only)")
+	fs.DurationVar(&cfg.SensorInterval, "interval", cfg.SensorInterval, "interval between each sensor's readings")
+	fs.DurationVar(&cfg.SimulationDuration, "duration", cfg.SimulationDuration, "how long the simulation runs")
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", cfg.MetricsAddr, "address the metrics server listens on")
+	fs.StringVar(&cfg.PprofAddr, "pprof-addr", cfg.PprofAddr, "address the pprof server listens on")
+	fs.StringVar(&cfg.GRPCAddr, "grpc-addr", cfg.GRPCAddr, "address the gRPC ingestion and subscription service listens on (e.g. :9090; disabled if empty)")
+	fs.StringVar(&cfg.AdminAddr, "admin-addr", cfg.AdminAddr, "address the admin server listens on (e.g. :8081; disabled if empty)")
+	fs.BoolVar(&cfg.NATS.Enabled, "nats", cfg.NATS.Enabled, "publish sensor data to NATS (with -sink=nats)")
+	fs.StringVar(&cfg.Sink, "sink", cfg.Sink, "broker sensor data is published to: nats, mqtt or kafka")
+	fs.StringVar(&cfg.Kafka.Brokers, "kafka-brokers", cfg.Kafka.Brokers, "comma-separated Kafka broker addresses (with -sink=kafka)")
+	fs.StringVar(&cfg.Kafka.Topic, "kafka-topic", cfg.Kafka.Topic, "Kafka topic sensor data is produced to (with -sink=kafka)")
+	fs.IntVar(&cfg.Kafka.BatchSize, "kafka-batch-size", cfg.Kafka.BatchSize, "most messages written to a Kafka partition at once (with -sink=kafka)")
+	fs.DurationVar(&cfg.Kafka.BatchTimeout, "kafka-batch-timeout", cfg.Kafka.BatchTimeout, "longest a partial Kafka batch waits before being written (with -sink=kafka)")
+	fs.StringVar(&cfg.Codec, "codec", cfg.Codec, "encoding of published readings: json or proto")
+	fs.StringVar(&cfg.Log.Level, "log-level", cfg.Log.Level, "minimum level logged: debug, info, warn or error")
+	fs.StringVar(&cfg.Log.Format, "log-format", cfg.Log.Format, "log output format: json or text")
+	fs.StringVar(&cfg.Log.File, "log-file", cfg.Log.File, "file logs are written to, with size-based rotation, instead of stdout")
+	fs.DurationVar(&cfg.RampDuration, "ramp", cfg.RampDuration, "duration to stagger the sensors' starts over, e.g. 30s (0 starts them all at once)")
+	fs.Float64Var(&cfg.MaxRate, "max-rate", cfg.MaxRate, "cap on the readings the whole fleet sends per second, e.g. 10000 (0 is unlimited)")
+	fs.BoolVar(&cfg.DropOnFull, "drop-on-full", cfg.DropOnFull, "drop sensor readings while the data channel is full, instead of slowing the sensors down")
+	fs.StringVar(&cfg.Replay, "replay", cfg.Replay, "file of recorded readings (e.g. data.csv) to replay instead of running the synthetic sensors")
+	fs.Float64Var(&cfg.ReplaySpeed, "replay-speed", cfg.ReplaySpeed, "multiplier of the pace readings are replayed at, e.g. 2 for twice as fast")
+	fs.StringVar(&cfg.CSVOut, "csv-out", cfg.CSVOut, "CSV file every reading is also written to (e.g. data.csv)")
+	fs.StringVar(&cfg.SocketOut, "socket-out", cfg.SocketOut, "address every reading is also sent to as a JSON line, tcp://host:port or udp://host:port")
+	fs.StringVar(&cfg.Parquet.Output, "parquet-out", cfg.Parquet.Output, "path of the Parquet files every reading is also written to, e.g. data.parquet (numbered as they roll over)")
+	fs.IntVar(&cfg.Parquet.RowGroupSize, "parquet-row-group", cfg.Parquet.RowGroupSize, "readings buffered into each Parquet row group")
+	fs.IntVar(&cfg.Parquet.RollRecords, "parquet-roll-records", cfg.Parquet.RollRecords, "readings per Parquet file before rolling over to a new one (0 disables it)")
+	fs.DurationVar(&cfg.Parquet.RollInterval, "parquet-roll-interval", cfg.Parquet.RollInterval, "age of a Parquet file at which it's rolled over to a new one (0 disables it)")
+	fs.StringVar(&cfg.Locations, "locations", cfg.Locations, "semicolon-separated lat,lon positions of the first sensors, e.g. \"51.5,-0.12;48.86,2.35\"")
+	fs.StringVar(&cfg.LocationBox, "location-box", cfg.LocationBox, "area the other sensors are placed at random within, as min_lat,min_lon,max_lat,max_lon")
+	fs.StringVar(&cfg.DeviceIDs, "device-ids", cfg.DeviceIDs, "how sensors' device IDs are allocated: sequential, uuid or mac")
+	fs.StringVar(&cfg.ValueExpression, "value-expr", cfg.ValueExpression, "expression of t (seconds since start) and id generating sensor values, e.g. \"20 + 5*sin(t/3600)\"")
+	fs.Float64Var(&cfg.DriftRate, "drift-rate", cfg.DriftRate, "how much sensors' values drift by per second, simulating degradation (0 disables drift)")
+	fs.Float64Var(&cfg.Jitter, "jitter", cfg.Jitter, "fraction sensors' intervals randomly vary by, e.g. 0.1 for ±10%")
+	fs.BoolVar(&cfg.Dashboard, "dashboard", cfg.Dashboard, "show a live terminal dashboard of the simulation (logs go to a file meanwhile)")
+	fs.BoolVar(&cfg.Ingest, "ingest", cfg.Ingest, "accept readings pushed by external sensors at POST /ingest on the metrics address")
+	fs.BoolVar(&cfg.LatestCache, "latest-cache", cfg.LatestCache, "serve each sensor's latest reading at GET /latest/{id} on the metrics address")
+	fs.BoolVar(&cfg.Registry, "registry", cfg.Registry, "register live sensors in a NATS KV bucket (with NATS)")
+	fs.Uint64Var(&cfg.MemoryLimit, "memory-limit", cfg.MemoryLimit, "soft heap cap in bytes, above which sensors shed load (0 disables it)")
+	fs.BoolVar(&cfg.Backpressure.Enabled, "backpressure", cfg.Backpressure.Enabled, "slow the sensors down while the data channel stays nearly full")
+	fs.DurationVar(&cfg.Backpressure.MaxInterval, "backpressure-max", cfg.Backpressure.MaxInterval, "interval sensors slow down to, at most, under backpressure")
+	fs.BoolVar(&cfg.Bridge.Enabled, "bridge", cfg.Bridge.Enabled, "archive the NATS stream to the bridge output file (with NATS)")
+	fs.StringVar(&cfg.Bridge.Output, "bridge-output", cfg.Bridge.Output, "NDJSON file the bridge archives the NATS stream to")
+	fs.BoolVar(&cfg.LiveFeed.Enabled, "live-feed", cfg.LiveFeed.Enabled, "stream readings to WebSocket clients at /ws on the metrics address")
+	fs.IntVar(&cfg.LiveFeed.Queue, "live-feed-queue", cfg.LiveFeed.Queue, "readings queued per WebSocket client before it's disconnected as too slow")
+	fs.StringVar(&cfg.Aggregator.SummaryOutput, "summary-output", cfg.Aggregator.SummaryOutput, "how the aggregator emits its summaries: log, json (to -summary-file) or metrics-only")
+	fs.StringVar(&cfg.Aggregator.SummaryFile, "summary-file", cfg.Aggregator.SummaryFile, "file JSON summaries are appended to (with -summary-output=json)")
+	fs.BoolVar(&cfg.Aggregator.WindowedStats, "windowed-stats", cfg.Aggregator.WindowedStats, "have each summary's value statistics cover only its window")
+	fs.DurationVar(&cfg.Aggregator.StaleAfter, "stale-after", cfg.Aggregator.StaleAfter, "age of a sensor's latest reading beyond which it's flagged as stale (0 disables it)")
+	fs.BoolVar(&cfg.Aggregator.DetectAnomalies, "detect-anomalies", cfg.Aggregator.DetectAnomalies, "flag readings far from their sensor's running mean")
+	fs.Int64Var(&cfg.Seed, "seed", cfg.Seed, "base seed of the sensors' random sources, to reproduce a run (0 generates one)")
+}
+
+// Parse populates a Config from command-line args (excluding the program name) and validates it.
+// Settings start from Default, or from the file given with -config, and are overridden by any other flags.
+// Usage and flag errors are written to output.
+// It returns flag.ErrHelp if help was requested with -h or -help, and ErrVersion if the version was requested with -version.
+func Parse(name string, args []string, output io.Writer) (Config, error) {
+	// A first pass finds the config file, whose settings the flags are then applied over.
+	var configPath string
+	var showVersion bool
+	scratch := Default()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	scratch.bindFlags(fs, &configPath, &showVersion)
+	if err := fs.Parse(args); err != nil {
+		return scratch, err
+	}
+	if fs.NArg() > 0 {
+		return scratch, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	if showVersion {
+		return scratch, ErrVersion
+	}
+
+	cfg := Default()
+	if configPath != "" {
+		loaded, err := Load(configPath)
+		if err != nil {
+			return cfg, err
+		}
+		cfg = *loaded
+	}
+
+	fs = flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+	cfg.bindFlags(fs, &configPath, &showVersion)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate reports whether cfg's settings are usable, describing every invalid one.
+func (cfg Config) Validate() error {
+	var errs []error
+	if cfg.SensorCount < 0 {
+		errs = append(errs, fmt.Errorf("sensors must not be negative, got %d", cfg.SensorCount))
+	}
+	if cfg.SensorInterval <= 0 {
+		errs = append(errs, fmt.Errorf("interval must be positive, got %v", cfg.SensorInterval))
+	}
+	if cfg.SimulationDuration <= 0 {
+		errs = append(errs, fmt.Errorf("duration must be positive, got %v", cfg.SimulationDuration))
+	}
+	if cfg.RampDuration < 0 {
+		errs = append(errs, fmt.Errorf("ramp must not be negative, got %v", cfg.RampDuration))
+	}
+	if cfg.MaxRate < 0 || math.IsNaN(cfg.MaxRate) || math.IsInf(cfg.MaxRate, 0) {
+		errs = append(errs, fmt.Errorf("max rate must be a finite, non-negative number, got %v", cfg.MaxRate))
+	}
+	if !(cfg.ReplaySpeed > 0) || math.IsInf(cfg.ReplaySpeed, 0) {
+		errs = append(errs, fmt.Errorf("replay speed must be a positive number, got %v", cfg.ReplaySpeed))
+	}
+	if _, err := sensor.NewIDAllocator(cfg.DeviceIDs); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.ValueExpression != "" {
+		if _, err := sensor.NewExprGenerator(cfg.ValueExpression); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if math.IsNaN(cfg.DriftRate) || math.IsInf(cfg.DriftRate, 0) {
+		errs = append(errs, fmt.Errorf("drift rate must be a finite number, got %v", cfg.DriftRate))
+	}
+	if !(cfg.Jitter >= 0 && cfg.Jitter < 1) {
+		errs = append(errs, fmt.Errorf("jitter must be at least 0 and less than 1, got %v", cfg.Jitter))
+	}
+	if cfg.Backpressure.Enabled && cfg.Backpressure.MaxInterval <= 0 {
+		errs = append(errs, fmt.Errorf("backpressure max_interval must be positive, got %v", cfg.Backpressure.MaxInterval))
+	}
+	if cfg.Bridge.Enabled && cfg.Bridge.Output == "" {
+		errs = append(errs, errors.New("bridge output must not be empty"))
+	}
+	if cfg.LiveFeed.Enabled && cfg.LiveFeed.Queue < 1 {
+		errs = append(errs, fmt.Errorf("live feed queue must be at least 1, got %d", cfg.LiveFeed.Queue))
+	}
+	if cfg.SocketOut != "" {
+		if _, _, err := sink.ParseSocketAddr(cfg.SocketOut); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.Parquet.Output != "" {
+		if cfg.Parquet.RowGroupSize < 1 {
+			errs = append(errs, fmt.Errorf("parquet row_group_size must be at least 1, got %d", cfg.Parquet.RowGroupSize))
+		}
+		if cfg.Parquet.RollRecords < 0 || cfg.Parquet.RollInterval < 0 {
+			errs = append(errs, fmt.Errorf("parquet roll_records and roll_interval must not be negative, got %d and %v", cfg.Parquet.RollRecords, cfg.Parquet.RollInterval))
+		}
+	}
+	switch aggregator.SummaryOutput(cfg.Aggregator.SummaryOutput) {
+	case aggregator.SummaryLog, aggregator.SummaryMetricsOnly:
+	case aggregator.SummaryJSON:
+		if cfg.Aggregator.SummaryFile == "" {
+			errs = append(errs, errors.New("aggregator summary_file must not be empty with json summaries"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("aggregator summary_output must be %s, %s or %s, got %q",
+			aggregator.SummaryLog, aggregator.SummaryJSON, aggregator.SummaryMetricsOnly, cfg.Aggregator.SummaryOutput))
+	}
+	if cfg.Aggregator.StaleAfter < 0 {
+		errs = append(errs, fmt.Errorf("aggregator stale_after must not be negative, got %v", cfg.Aggregator.StaleAfter))
+	}
+	if _, err := sensor.ParseLocations(cfg.Locations); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.LocationBox != "" {
+		if _, err := sensor.ParseBoundingBox(cfg.LocationBox); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch cfg.Sink {
+	case SinkNATS:
+	case SinkMQTT:
+		if cfg.MQTT.URL == "" {
+			errs = append(errs, errors.New("mqtt url must not be empty"))
+		}
+		if _, err := mqtt.NormalizeTopicPrefix(cfg.MQTT.TopicPrefix); err != nil {
+			errs = append(errs, err)
+		}
+	case SinkKafka:
+		if _, err := kafka.ParseBrokers(cfg.Kafka.Brokers); err != nil {
+			errs = append(errs, err)
+		}
+		if err := kafka.ValidateTopic(cfg.Kafka.Topic); err != nil {
+			errs = append(errs, err)
+		}
+		if cfg.Kafka.BatchSize < 1 {
+			errs = append(errs, fmt.Errorf("kafka batch_size must be at least 1, got %d", cfg.Kafka.BatchSize))
+		}
+		if cfg.Kafka.BatchTimeout <= 0 {
+			errs = append(errs, fmt.Errorf("kafka batch_timeout must be positive, got %v", cfg.Kafka.BatchTimeout))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("sink must be %s, %s or %s, got %q", SinkNATS, SinkMQTT, SinkKafka, cfg.Sink))
+	}
+	if _, err := codec.Parse(cfg.Codec); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Sink == SinkNATS && cfg.NATS.Enabled {
+		if cfg.NATS.URL == "" {
+			errs = append(errs, errors.New("nats url must not be empty"))
+		}
+		if cfg.NATS.Stream == "" {
+			errs = append(errs, errors.New("nats stream must not be empty"))
+		}
+		if _, err := nats.NormalizeSubjectPrefix(cfg.NATS.SubjectPrefix); err != nil {
+			errs = append(errs, err)
+		}
+		if _, err := nats.ParseStorage(cfg.NATS.Storage); err != nil {
+			errs = append(errs, err)
+		}
+		if cfg.NATS.Replicas < 1 {
+			errs = append(errs, fmt.Errorf("nats replicas must be at least 1, got %d", cfg.NATS.Replicas))
+		}
+	}
+	if _, err := logging.ParseLevel(cfg.Log.Level); err != nil {
+		errs = append(errs, err)
+	}
+	if cfg.Log.Format != logging.FormatJSON && cfg.Log.Format != logging.FormatText {
+		errs = append(errs, fmt.Errorf("log format must be %s or %s, got %q", logging.FormatJSON, logging.FormatText, cfg.Log.Format))
+	}
+	if cfg.Log.File != "" {
+		if cfg.Log.MaxSizeMB <= 0 {
+			errs = append(errs, fmt.Errorf("log max_size_mb must be positive, got %d", cfg.Log.MaxSizeMB))
+		}
+		if cfg.Log.MaxBackups < 0 || cfg.Log.MaxAgeDays < 0 {
+			errs = append(errs, fmt.Errorf("log max_backups and max_age_days must not be negative, got %d and %d", cfg.Log.MaxBackups, cfg.Log.MaxAgeDays))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,446 @@
+// Package config_test contains tests for the config package.
+package config_test
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/config"
+)
+
+// TestParse_Defaults verifies that without flags, Parse returns the default configuration.
+func TestParse_Defaults(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := config.Parse("simulator", nil, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg != config.Default() {
+		t.Errorf("expected the default config %+v, got %+v", config.Default(), cfg)
+	}
+}
+
+// TestParse_Flags verifies each flag sets its setting.
+func TestParse_Flags(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"-sensors=1000", "-interval=50ms", "-duration=2m", "-metrics-addr=:9090", "-pprof-addr=:6061", "-grpc-addr=:9091", "-admin-addr=:8081", "-nats=false", "-seed=42", "-sink=mqtt", "-log-level=debug", "-log-format=text", "-log-file=sim.log", "-csv-out=data.csv", "-socket-out=tcp://collector:5000", "-replay=recorded.csv", "-replay-speed=4", "-drop-on-full", "-ramp=30s", "-max-rate=10000", "-codec=proto", "-locations=51.5,-0.12;48.86,2.35", "-location-box=51.28,-0.51,51.69,0.33", "-dashboard",
+		"-device-ids=uuid", "-value-expr=20 + id", "-drift-rate=0.01", "-jitter=0.1", "-ingest", "-latest-cache", "-registry", "-memory-limit=536870912",
+		"-backpressure", "-backpressure-max=2s", "-bridge", "-bridge-output=archive.ndjson", "-live-feed", "-live-feed-queue=64",
+		"-summary-output=json", "-summary-file=sums.ndjson", "-windowed-stats", "-stale-after=30s", "-detect-anomalies",
+		"-parquet-out=data.parquet", "-parquet-row-group=500", "-parquet-roll-records=10000", "-parquet-roll-interval=15m"}
+	cfg, err := config.Parse("simulator", args, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := config.Config{
+		SensorCount:        1000,
+		SensorInterval:     50 * time.Millisecond,
+		SimulationDuration: 2 * time.Minute,
+		MetricsAddr:        ":9090",
+		PprofAddr:          ":6061",
+		GRPCAddr:           ":9091",
+		AdminAddr:          ":8081",
+		Seed:               42,
+		DropOnFull:         true,
+		RampDuration:       30 * time.Second,
+		MaxRate:            10000,
+		CSVOut:             "data.csv",
+		SocketOut:          "tcp://collector:5000",
+		Locations:          "51.5,-0.12;48.86,2.35",
+		LocationBox:        "51.28,-0.51,51.69,0.33",
+		Dashboard:          true,
+		DeviceIDs:          "uuid",
+		ValueExpression:    "20 + id",
+		DriftRate:          0.01,
+		Jitter:             0.1,
+		Ingest:             true,
+		LatestCache:        true,
+		Registry:           true,
+		MemoryLimit:        512 << 20,
+		Backpressure:       config.BackpressureConfig{Enabled: true, MaxInterval: 2 * time.Second},
+		Bridge:             config.BridgeConfig{Enabled: true, Output: "archive.ndjson"},
+		LiveFeed:           config.LiveFeedConfig{Enabled: true, Queue: 64},
+		Parquet:            config.ParquetConfig{Output: "data.parquet", RowGroupSize: 500, RollRecords: 10000, RollInterval: 15 * time.Minute},
+		Replay:             "recorded.csv",
+		ReplaySpeed:        4,
+		Sink:               config.SinkMQTT,
+		Codec:              "proto",
+		NATS:               config.Default().NATS,
+		MQTT:               config.Default().MQTT,
+		Kafka:              config.Default().Kafka,
+		Log:                config.Default().Log,
+		Aggregator: config.AggregatorConfig{
+			SummaryOutput:   "json",
+			SummaryFile:     "sums.ndjson",
+			WindowedStats:   true,
+			StaleAfter:      30 * time.Second,
+			DetectAnomalies: true,
+		},
+	}
+	want.NATS.Enabled = false
+	want.Log.Level, want.Log.Format, want.Log.File = "debug", "text", "sim.log"
+	if cfg != want {
+		t.Errorf("expected %+v, got %+v", want, cfg)
+	}
+}
+
+// TestParse_KafkaFlags verifies the Kafka flags set their settings.
+func TestParse_KafkaFlags(t *testing.T) {
+	t.Parallel()
+
+	args := []string{"-sink=kafka", "-kafka-brokers=kafka-1:9092,kafka-2:9092", "-kafka-topic=lab-sensors", "-kafka-batch-size=500", "-kafka-batch-timeout=20ms"}
+	cfg, err := config.Parse("simulator", args, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := config.KafkaConfig{
+		Brokers:      "kafka-1:9092,kafka-2:9092",
+		Topic:        "lab-sensors",
+		BatchSize:    500,
+		BatchTimeout: 20 * time.Millisecond,
+	}
+	if cfg.Sink != config.SinkKafka || cfg.Kafka != want {
+		t.Errorf("expected sink kafka with %+v, got sink %s with %+v", want, cfg.Sink, cfg.Kafka)
+	}
+}
+
+// TestParse_Invalid verifies bad input is rejected with a message naming the problem.
+func TestParse_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		args    []string
+		wantErr string
+	}{
+		{"negative sensors", []string{"-sensors=-1"}, "sensors must not be negative"},
+		{"zero interval", []string{"-interval=0"}, "interval must be positive"},
+		{"negative max rate", []string{"-max-rate=-1"}, "max rate must be a finite, non-negative number"},
+		{"zero replay speed", []string{"-replay-speed=0"}, "replay speed must be a positive number"},
+		{"negative ramp", []string{"-ramp=-1s"}, "ramp must not be negative"},
+		{"negative duration", []string{"-duration=-1m"}, "duration must be positive"},
+		{"malformed interval", []string{"-interval=fast"}, "invalid value"},
+		{"unknown flag", []string{"-sensor-count=10"}, "flag provided but not defined"},
+		{"extra arguments", []string{"-sensors=10", "now"}, "unexpected arguments"},
+		{"unknown sink", []string{"-sink=pulsar"}, "sink must be nats, mqtt or kafka"},
+		{"no kafka brokers", []string{"-sink=kafka", "-kafka-brokers="}, "kafka brokers must not be empty"},
+		{"zero kafka batch size", []string{"-sink=kafka", "-kafka-batch-size=0"}, "kafka batch_size must be at least 1"},
+		{"unknown codec", []string{"-codec=avro"}, "codec must be json or proto"},
+		{"malformed locations", []string{"-locations=51.5"}, `invalid location "51.5"`},
+		{"unknown device ID scheme", []string{"-device-ids=serial"}, `unknown device ID scheme "serial"`},
+		{"malformed value expression", []string{"-value-expr=20 +"}, "invalid expression"},
+		{"jitter of 1", []string{"-jitter=1"}, "jitter must be at least 0 and less than 1"},
+		{"zero backpressure max", []string{"-backpressure", "-backpressure-max=0"}, "backpressure max_interval must be positive"},
+		{"no bridge output", []string{"-bridge", "-bridge-output="}, "bridge output must not be empty"},
+		{"malformed socket out", []string{"-socket-out=collector:5000"}, "socket sink address must be tcp://host:port or udp://host:port"},
+		{"zero parquet row group", []string{"-parquet-out=data.parquet", "-parquet-row-group=0"}, "parquet row_group_size must be at least 1"},
+		{"negative parquet roll interval", []string{"-parquet-out=data.parquet", "-parquet-roll-interval=-1m"}, "parquet roll_records and roll_interval must not be negative"},
+		{"zero live feed queue", []string{"-live-feed", "-live-feed-queue=0"}, "live feed queue must be at least 1"},
+		{"unknown summary output", []string{"-summary-output=csv"}, "aggregator summary_output must be log, json or metrics-only"},
+		{"negative stale after", []string{"-stale-after=-1s"}, "aggregator stale_after must not be negative"},
+		{"swapped location box", []string{"-location-box=51.69,0.33,51.28,-0.51"}, "min must be south-west of max"},
+		{"unknown log level", []string{"-log-level=verbose"}, `invalid log level "verbose"`},
+		{"unknown log format", []string{"-log-format=xml"}, "log format must be json or text"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := config.Parse("simulator", tt.args, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestParse_Help verifies requesting help is reported as flag.ErrHelp, with the usage written to output.
+func TestParse_Help(t *testing.T) {
+	t.Parallel()
+
+	var out strings.Builder
+	_, err := config.Parse("simulator", []string{"-h"}, &out)
+	if !errors.Is(err, flag.ErrHelp) {
+		t.Errorf("expected flag.ErrHelp, got %v", err)
+	}
+	if !strings.Contains(out.String(), "-sensors") {
+		t.Errorf("expected usage listing the flags, got:\n%s", out.String())
+	}
+}
+
+// TestParse_Version verifies requesting the version is reported as ErrVersion,
+// even alongside a config file that doesn't exist or otherwise invalid settings.
+func TestParse_Version(t *testing.T) {
+	t.Parallel()
+
+	_, err := config.Parse("simulator", []string{"-version", "-config=missing.yaml", "-sensors=-1"}, io.Discard)
+	if !errors.Is(err, config.ErrVersion) {
+		t.Errorf("expected config.ErrVersion, got %v", err)
+	}
+}
+
+// writeConfig writes a YAML config file with the given contents, returning its path.
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "sim.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write config file: %v", err)
+	}
+	return path
+}
+
+// TestLoad verifies every setting is read from a YAML file, with durations parsed by time.ParseDuration.
+func TestLoad(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfig(t, `
+sensors: 1000
+interval: 50ms
+duration: 1h30m
+metrics_addr: ":9090"
+pprof_addr: ":6061"
+grpc_addr: ":9091"
+admin_addr: ":8081"
+admin_token: s3cret
+seed: 7
+drop_on_full: true
+ramp: 1m
+max_rate: 2500.5
+csv_out: readings.csv
+socket_out: udp://collector.example:514
+locations: "51.5,-0.12"
+location_box: "51.28,-0.51,51.69,0.33"
+dashboard: true
+device_ids: mac
+value_expression: 20 + 5*sin(t/3600)
+drift_rate: -0.5
+jitter: 0.25
+ingest: true
+latest_cache: true
+registry: true
+memory_limit: 1073741824
+backpressure:
+  enabled: true
+  max_interval: 5s
+bridge:
+  enabled: true
+  output: /var/lib/simulator/bridge.ndjson
+live_feed:
+  enabled: true
+  queue: 32
+parquet:
+  output: /var/lib/simulator/readings.parquet
+  row_group_size: 1000
+  roll_records: 0
+  roll_interval: 24h
+aggregator:
+  summary_output: metrics-only
+  summary_file: ""
+  windowed_stats: true
+  stale_after: 0s
+  detect_anomalies: true
+replay: recorded.ndjson
+replay_speed: 0.5
+nats:
+  enabled: true
+  url: nats://nats.example:4222
+  stream: LAB_SENSORS
+  subject_prefix: lab.sensors
+  storage: memory
+  replicas: 3
+sink: mqtt
+codec: proto
+mqtt:
+  url: tcp://mqtt.example:1883
+  client_id: lab-simulator
+  topic_prefix: lab/sensors
+kafka:
+  brokers: kafka-1:9092,kafka-2:9092
+  topic: lab-sensors
+  batch_size: 500
+  batch_timeout: 20ms
+log:
+  level: warn
+  format: text
+  file: /var/log/simulator/sim.log
+  max_size_mb: 10
+  max_backups: 3
+  max_age_days: 7
+`)
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := config.Config{
+		SensorCount:        1000,
+		SensorInterval:     50 * time.Millisecond,
+		SimulationDuration: 90 * time.Minute,
+		MetricsAddr:        ":9090",
+		PprofAddr:          ":6061",
+		GRPCAddr:           ":9091",
+		AdminAddr:          ":8081",
+		AdminToken:         "s3cret",
+		Seed:               7,
+		DropOnFull:         true,
+		RampDuration:       time.Minute,
+		MaxRate:            2500.5,
+		CSVOut:             "readings.csv",
+		SocketOut:          "udp://collector.example:514",
+		Locations:          "51.5,-0.12",
+		LocationBox:        "51.28,-0.51,51.69,0.33",
+		Dashboard:          true,
+		DeviceIDs:          "mac",
+		ValueExpression:    "20 + 5*sin(t/3600)",
+		DriftRate:          -0.5,
+		Jitter:             0.25,
+		Ingest:             true,
+		LatestCache:        true,
+		Registry:           true,
+		MemoryLimit:        1 << 30,
+		Backpressure:       config.BackpressureConfig{Enabled: true, MaxInterval: 5 * time.Second},
+		Bridge:             config.BridgeConfig{Enabled: true, Output: "/var/lib/simulator/bridge.ndjson"},
+		LiveFeed:           config.LiveFeedConfig{Enabled: true, Queue: 32},
+		Parquet:            config.ParquetConfig{Output: "/var/lib/simulator/readings.parquet", RowGroupSize: 1000, RollInterval: 24 * time.Hour},
+		Replay:             "recorded.ndjson",
+		ReplaySpeed:        0.5,
+		NATS: config.NATSConfig{
+			Enabled:       true,
+			URL:           "nats://nats.example:4222",
+			Stream:        "LAB_SENSORS",
+			SubjectPrefix: "lab.sensors",
+			Storage:       "memory",
+			Replicas:      3,
+		},
+		Sink:  config.SinkMQTT,
+		Codec: "proto",
+		MQTT: config.MQTTConfig{
+			URL:         "tcp://mqtt.example:1883",
+			ClientID:    "lab-simulator",
+			TopicPrefix: "lab/sensors",
+		},
+		Kafka: config.KafkaConfig{
+			Brokers:      "kafka-1:9092,kafka-2:9092",
+			Topic:        "lab-sensors",
+			BatchSize:    500,
+			BatchTimeout: 20 * time.Millisecond,
+		},
+		Log: config.LogConfig{
+			Level:      "warn",
+			Format:     "text",
+			File:       "/var/log/simulator/sim.log",
+			MaxSizeMB:  10,
+			MaxBackups: 3,
+			MaxAgeDays: 7,
+		},
+		Aggregator: config.AggregatorConfig{
+			SummaryOutput:   "metrics-only",
+			WindowedStats:   true,
+			DetectAnomalies: true,
+		},
+	}
+	if *cfg != want {
+		t.Errorf("expected %+v, got %+v", want, *cfg)
+	}
+}
+
+// TestLoad_Defaults verifies settings omitted from the file (or an empty file) keep their defaults.
+func TestLoad_Defaults(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := config.Load(writeConfig(t, "sensors: 10\nnats:\n  url: nats://other:4222\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := config.Default()
+	want.SensorCount = 10
+	want.NATS.URL = "nats://other:4222"
+	if *cfg != want {
+		t.Errorf("expected %+v, got %+v", want, *cfg)
+	}
+
+	cfg, err = config.Load(writeConfig(t, ""))
+	if err != nil {
+		t.Fatalf("unexpected error loading an empty file: %v", err)
+	}
+	if *cfg != config.Default() {
+		t.Errorf("expected an empty file to load the defaults, got %+v", *cfg)
+	}
+}
+
+// TestLoad_Invalid verifies unknown keys, malformed durations, and invalid settings are rejected.
+func TestLoad_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		contents string
+		wantErr  string
+	}{
+		{"unknown key", "sensor_count: 10\n", "field sensor_count not found"},
+		{"unknown nested key", "nats:\n  servers: nats://a:4222\n", "field servers not found"},
+		{"malformed duration", "interval: fast\n", "cannot unmarshal !!str `fast` into time.Duration"},
+		{"invalid value", "duration: 0s\n", "duration must be positive"},
+		{"invalid subject prefix", "nats:\n  subject_prefix: iot.*\n", "invalid subject prefix"},
+		{"unknown stream storage", "nats:\n  storage: disk\n", `unknown stream storage "disk"`},
+		{"no replicas", "nats:\n  replicas: 0\n", "nats replicas must be at least 1"},
+		{"invalid topic prefix", "sink: mqtt\nmqtt:\n  topic_prefix: iot/#\n", "invalid topic prefix"},
+		{"invalid kafka topic", "sink: kafka\nkafka:\n  topic: iot/sensors\n", "invalid kafka topic"},
+		{"invalid log rotation", "log:\n  file: sim.log\n  max_size_mb: 0\n", "log max_size_mb must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := config.Load(writeConfig(t, tt.contents))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestLoad_MissingFile verifies a missing config file is an error.
+func TestLoad_MissingFile(t *testing.T) {
+	t.Parallel()
+
+	if _, err := config.Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected an error loading a missing file")
+	}
+}
+
+// TestParse_ConfigFile verifies flags override the settings of the file given with -config.
+func TestParse_ConfigFile(t *testing.T) {
+	t.Parallel()
+
+	path := writeConfig(t, "sensors: 1000\ninterval: 50ms\n")
+	cfg, err := config.Parse("simulator", []string{"-sensors=20", "-config=" + path}, io.Discard)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.SensorCount != 20 {
+		t.Errorf("expected the -sensors flag to override the file (20), got %d", cfg.SensorCount)
+	}
+	if cfg.SensorInterval != 50*time.Millisecond {
+		t.Errorf("expected the file's interval (50ms), got %v", cfg.SensorInterval)
+	}
+	if cfg.SimulationDuration != config.Default().SimulationDuration {
+		t.Errorf("expected the default duration, got %v", cfg.SimulationDuration)
+	}
+}
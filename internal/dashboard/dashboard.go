@@ -0,0 +1,88 @@
+// Package dashboard renders a live terminal view of a running simulation:
+// active sensors, message rates, publish outcomes, and NATS connection status.
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// refreshInterval is how often the dashboard takes a new snapshot.
+const refreshInterval = time.Second
+
+// tickMsg triggers a new snapshot.
+type tickMsg time.Time
+
+// model is the bubbletea model of the dashboard.
+type model struct {
+	sampler *Sampler
+	snap    Snapshot
+	err     error
+}
+
+func tick() tea.Cmd {
+	return tea.Tick(refreshInterval, func(t time.Time) tea.Msg {
+		return tickMsg(t)
+	})
+}
+
+// Init starts the refresh ticks.
+func (m model) Init() tea.Cmd {
+	return tick()
+}
+
+// Update takes a snapshot on every tick, and quits on q or ctrl+c.
+func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		switch msg.String() {
+		case "q", "ctrl+c":
+			return m, tea.Quit
+		}
+	case tickMsg:
+		m.snap, m.err = m.sampler.Sample(time.Time(msg))
+		return m, tick()
+	}
+	return m, nil
+}
+
+// View renders the snapshot's rows.
+func (m model) View() string {
+	var b strings.Builder
+	b.WriteString("IoT Sensor Network Simulator\n\n")
+
+	rows := m.snap.Rows()
+	width := 0
+	for _, r := range rows {
+		width = max(width, len(r.Label))
+	}
+	for _, r := range rows {
+		fmt.Fprintf(&b, "  %-*s  %s\n", width, r.Label, r.Value)
+	}
+
+	if m.err != nil {
+		fmt.Fprintf(&b, "\n  error: %v\n", m.err)
+	}
+	b.WriteString("\n  Press q to stop the simulation.\n")
+	return b.String()
+}
+
+// Run renders the dashboard until ctx is done or the user quits (with q or ctrl+c).
+// It returns true if the user quit. The terminal is restored before Run returns.
+func Run(ctx context.Context, sampler *Sampler) (bool, error) {
+	p := tea.NewProgram(model{sampler: sampler}, tea.WithAltScreen(), tea.WithContext(ctx))
+
+	_, err := p.Run()
+	if ctx.Err() != nil {
+		// The program was killed by ctx, rather than quit by the user.
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("dashboard failed: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,112 @@
+// Package dashboard_test contains tests for the dashboard package.
+package dashboard_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/dashboard"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+)
+
+// TestSnapshot_Rows verifies a snapshot is formatted into the displayed rows.
+func TestSnapshot_Rows(t *testing.T) {
+	t.Parallel()
+
+	snap := dashboard.Snapshot{
+		Elapsed:         90*time.Second + 400*time.Millisecond,
+		ActiveSensors:   5000,
+		MessagesSent:    120000,
+		MessagesPerSec:  49876.54,
+		PublishSuccess:  990,
+		PublishFailures: 10,
+		NATSConnected:   true,
+	}
+
+	want := []dashboard.Row{
+		{"Elapsed", "1m30s"},
+		{"Active sensors", "5000"},
+		{"Messages sent", "120000"},
+		{"Messages/sec", "49876.5"},
+		{"Published", "990"},
+		{"Publish failures", "10"},
+		{"Publish success rate", "99.0%"},
+		{"NATS", "connected"},
+	}
+
+	got := snap.Rows()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d rows, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+// TestSnapshot_Rows_NoPublishes verifies the success rate isn't reported before anything is published.
+func TestSnapshot_Rows_NoPublishes(t *testing.T) {
+	t.Parallel()
+
+	for _, r := range (dashboard.Snapshot{}).Rows() {
+		switch r.Label {
+		case "Publish success rate":
+			if r.Value != "n/a" {
+				t.Errorf("expected success rate n/a, got %q", r.Value)
+			}
+		case "NATS":
+			if r.Value != "disconnected" {
+				t.Errorf("expected NATS disconnected, got %q", r.Value)
+			}
+		}
+	}
+}
+
+// TestSampler_Sample verifies snapshots are read from the metrics, with rates computed between samples.
+func TestSampler_Sample(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	m := metrics.NewMetrics(reg, metrics.ConfigInfo{})
+	start := time.Now()
+	sampler := dashboard.NewSampler(reg, start)
+
+	m.ActiveSensors.WithLabelValues("standard").Add(3)
+	m.ActiveSensors.WithLabelValues("legacy").Add(2)
+	m.MessagesSent.WithLabelValues("1").Add(100)
+	m.MessagesSent.WithLabelValues("2").Add(100)
+	m.NATSPublishSuccess.WithLabelValues("1").Add(150)
+	m.NATSPublishFailures.WithLabelValues("1", "publish_error").Add(50)
+	m.NATSConnectionStatus.Set(1)
+
+	snap, err := sampler.Sample(start.Add(2 * time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if snap.ActiveSensors != 5 {
+		t.Errorf("expected 5 active sensors, got %v", snap.ActiveSensors)
+	}
+	if snap.MessagesSent != 200 || snap.MessagesPerSec != 100 {
+		t.Errorf("expected 200 messages at 100/sec, got %v at %v/sec", snap.MessagesSent, snap.MessagesPerSec)
+	}
+	if snap.PublishSuccess != 150 || snap.PublishFailures != 50 {
+		t.Errorf("expected 150 published and 50 failures, got %v and %v", snap.PublishSuccess, snap.PublishFailures)
+	}
+	if !snap.NATSConnected {
+		t.Error("expected NATS to be connected")
+	}
+
+	// The rate covers only the messages since the previous sample.
+	m.MessagesSent.WithLabelValues("1").Add(50)
+	snap, err = sampler.Sample(start.Add(3 * time.Second))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if snap.MessagesPerSec != 50 {
+		t.Errorf("expected 50 messages/sec, got %v", snap.MessagesPerSec)
+	}
+}
@@ -0,0 +1,118 @@
+package dashboard
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Metric families the dashboard reads its snapshot from.
+const (
+	metricActiveSensors   = "iot_simulator_active_sensors"
+	metricMessagesSent    = "iot_simulator_sensor_messages_sent_total"
+	metricPublishSuccess  = "iot_simulator_nats_publish_success_total"
+	metricPublishFailures = "iot_simulator_nats_publish_failures_total"
+	metricNATSConnection  = "iot_simulator_nats_connection_status"
+)
+
+// Snapshot is the simulation state shown on the dashboard at one point in time.
+type Snapshot struct {
+	Elapsed         time.Duration
+	ActiveSensors   float64
+	MessagesSent    float64
+	MessagesPerSec  float64
+	PublishSuccess  float64
+	PublishFailures float64
+	NATSConnected   bool
+}
+
+// Row is a single labeled line on the dashboard.
+type Row struct {
+	Label string
+	Value string
+}
+
+// Rows formats s into the rows displayed on the dashboard.
+func (s Snapshot) Rows() []Row {
+	nats := "disconnected"
+	if s.NATSConnected {
+		nats = "connected"
+	}
+
+	successRate := "n/a"
+	if total := s.PublishSuccess + s.PublishFailures; total > 0 {
+		successRate = fmt.Sprintf("%.1f%%", 100*s.PublishSuccess/total)
+	}
+
+	return []Row{
+		{"Elapsed", s.Elapsed.Truncate(time.Second).String()},
+		{"Active sensors", fmt.Sprintf("%.0f", s.ActiveSensors)},
+		{"Messages sent", fmt.Sprintf("%.0f", s.MessagesSent)},
+		{"Messages/sec", fmt.Sprintf("%.1f", s.MessagesPerSec)},
+		{"Published", fmt.Sprintf("%.0f", s.PublishSuccess)},
+		{"Publish failures", fmt.Sprintf("%.0f", s.PublishFailures)},
+		{"Publish success rate", successRate},
+		{"NATS", nats},
+	}
+}
+
+// Sampler takes snapshots from the simulator's metrics.
+// Message rates are computed from the change since the previous snapshot.
+type Sampler struct {
+	gatherer prometheus.Gatherer
+	start    time.Time
+	prevAt   time.Time
+	prevSent float64
+}
+
+// NewSampler creates a Sampler reading metrics from g, measuring elapsed time from start.
+func NewSampler(g prometheus.Gatherer, start time.Time) *Sampler {
+	return &Sampler{gatherer: g, start: start, prevAt: start}
+}
+
+// Sample gathers the current metrics into a Snapshot, taken at now.
+func (s *Sampler) Sample(now time.Time) (Snapshot, error) {
+	families, err := s.gatherer.Gather()
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	snap := Snapshot{Elapsed: now.Sub(s.start)}
+	for _, f := range families {
+		switch f.GetName() {
+		case metricActiveSensors:
+			snap.ActiveSensors = sum(f)
+		case metricMessagesSent:
+			snap.MessagesSent = sum(f)
+		case metricPublishSuccess:
+			snap.PublishSuccess = sum(f)
+		case metricPublishFailures:
+			snap.PublishFailures = sum(f)
+		case metricNATSConnection:
+			snap.NATSConnected = sum(f) > 0
+		}
+	}
+
+	if dt := now.Sub(s.prevAt).Seconds(); dt > 0 {
+		snap.MessagesPerSec = (snap.MessagesSent - s.prevSent) / dt
+	}
+	s.prevAt, s.prevSent = now, snap.MessagesSent
+
+	return snap, nil
+}
+
+// sum adds up the values of every series in a counter or gauge family.
+func sum(f *dto.MetricFamily) float64 {
+	var total float64
+	for _, m := range f.GetMetric() {
+		switch {
+		case m.GetCounter() != nil:
+			total += m.GetCounter().GetValue()
+		case m.GetGauge() != nil:
+			total += m.GetGauge().GetValue()
+		}
+	}
+	return total
+}
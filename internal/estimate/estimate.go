@@ -0,0 +1,130 @@
+// Package estimate computes the approximate resources a simulation will need,
+// so a configuration can be sanity-checked before it is deployed.
+// It is a pure calculation over the configuration; nothing is started.
+package estimate
+
+import (
+	"fmt"
+	"io"
+	"time"
+	"unsafe"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// Goroutines started by the simulator itself (library and runtime goroutines are not counted).
+const (
+	// baseGoroutines: main (which runs the shutdown sequence), metrics server (2), pprof server (2),
+	// signal handler, aggregator, and the channel depth sampler.
+	baseGoroutines = 8
+	// natsGoroutines: publisher and connection status poller (plus any publisher workers).
+	natsGoroutines = 2
+	// bridgeGoroutines: bridge, which writes to its sink itself.
+	bridgeGoroutines = 1
+	// goroutinesPerSensor: the sensor goroutine.
+	goroutinesPerSensor = 1
+)
+
+// Metric series exported by the simulator (Go runtime and process collectors are not counted).
+// Series that only appear after an error (restarts, publish failures) are not counted either.
+const (
+	// histogramSeries is the series count of a histogram with 10 buckets: the buckets, +Inf, _sum and _count.
+	histogramSeries = 10 + 3
+	// fixedSeries: paused sensors, sensor shutdown timeouts, throttled duration, messages received, out-of-order readings,
+	// stale sensors, aggregator degraded, NATS connection status, buffered and dead-lettered messages, the two bridge
+	// counters, CSV and Parquet write errors, WebSocket clients, memory pressure, config info, build info,
+	// the data channel's depth and capacity, and the aggregator's queue age and the publisher's compression ratio histograms.
+	fixedSeries = 20 + 2*histogramSeries
+	// natsFixedSeries: the publisher's queue age histogram.
+	natsFixedSeries = histogramSeries
+	// seriesPerSensor: messages sent and the generated values histogram.
+	seriesPerSensor = 1 + histogramSeries
+	// natsSeriesPerSensor: publish successes and the publish latency histogram.
+	natsSeriesPerSensor = 1 + histogramSeries
+	// seriesPerProfile: active sensors, and messages by model and firmware version.
+	seriesPerProfile = 2
+	// natsSeriesPerProfile: published bytes by model.
+	natsSeriesPerProfile = 1
+)
+
+// Config holds the simulation settings that drive resource usage.
+type Config struct {
+	SensorCount    int
+	SensorInterval time.Duration
+	ChannelBuffer  int
+	Profiles       int
+	NATSEnabled    bool
+	BridgeEnabled  bool
+	SubjectPrefix  string
+	// PublisherWorkers is the publisher's worker count (see publisher.Options.Workers).
+	PublisherWorkers int
+	// Codec is the codec readings are published with (JSON if nil).
+	Codec codec.Codec
+}
+
+// Estimate holds the estimated resource requirements of a simulation.
+type Estimate struct {
+	Goroutines         int
+	ChannelBufferBytes int64
+	MetricSeries       int
+	MessagesPerSecond  float64
+	// BrokerBytesPerSecond is the approximate payload and subject throughput to NATS (0 if NATS is disabled).
+	BrokerBytesPerSecond float64
+}
+
+// Resources estimates the resources a simulation configured with cfg will need.
+func Resources(cfg Config) Estimate {
+	e := Estimate{
+		Goroutines:         baseGoroutines + cfg.SensorCount*goroutinesPerSensor,
+		ChannelBufferBytes: int64(cfg.ChannelBuffer) * int64(unsafe.Sizeof(model.SensorData{})),
+		MetricSeries:       fixedSeries + cfg.SensorCount*seriesPerSensor + cfg.Profiles*seriesPerProfile,
+	}
+
+	if cfg.SensorInterval > 0 {
+		e.MessagesPerSecond = float64(cfg.SensorCount) / cfg.SensorInterval.Seconds()
+	}
+
+	if cfg.NATSEnabled {
+		e.Goroutines += natsGoroutines
+		if cfg.PublisherWorkers > 1 {
+			e.Goroutines += cfg.PublisherWorkers
+		}
+		e.MetricSeries += natsFixedSeries + cfg.SensorCount*natsSeriesPerSensor + cfg.Profiles*natsSeriesPerProfile
+		e.BrokerBytesPerSecond = e.MessagesPerSecond * float64(messageSize(cfg))
+
+		if cfg.BridgeEnabled {
+			e.Goroutines += bridgeGoroutines
+		}
+	}
+
+	return e
+}
+
+// messageSize approximates the size of a published message: its subject and encoded payload.
+func messageSize(cfg Config) int {
+	sample := model.SensorData{
+		ID:        cfg.SensorCount,
+		Value:     0.123456789012345,
+		Timestamp: time.Date(2006, 1, 2, 15, 4, 5, 999999999, time.UTC),
+	}
+	c := cfg.Codec
+	if c == nil {
+		c = codec.JSONCodec{}
+	}
+	payload, _ := c.Marshal(sample)
+	subject := fmt.Sprintf("%s.data.%d", cfg.SubjectPrefix, cfg.SensorCount)
+	return len(payload) + len(subject)
+}
+
+// Print writes a human-readable summary of e to w.
+func (e Estimate) Print(w io.Writer) error {
+	_, err := fmt.Fprintf(w, `Estimated resources:
+  Goroutines:           %d
+  Channel buffer:       %d bytes
+  Metric series:        %d
+  Messages per second:  %.0f
+  Broker throughput:    %.0f bytes/sec
+`, e.Goroutines, e.ChannelBufferBytes, e.MetricSeries, e.MessagesPerSecond, e.BrokerBytesPerSecond)
+	return err
+}
@@ -0,0 +1,117 @@
+// Package estimate_test contains tests for the estimate package.
+package estimate_test
+
+import (
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/estimate"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// TestResources verifies the estimate against hand-calculated values for sample configs.
+func TestResources(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		cfg            estimate.Config
+		wantGoroutines int
+		wantSeries     int
+	}{
+		{
+			// 8 base + 2 NATS + 100 sensors.
+			// 46 fixed + 13 NATS fixed + 100 sensors * (14 + 14 NATS) + 2 profiles * (2 + 1 NATS).
+			name: "with NATS",
+			cfg: estimate.Config{
+				SensorCount:    100,
+				SensorInterval: 100 * time.Millisecond,
+				ChannelBuffer:  1000,
+				Profiles:       2,
+				NATSEnabled:    true,
+				SubjectPrefix:  "iot.sensors",
+			},
+			wantGoroutines: 110,
+			wantSeries:     2865,
+		},
+		{
+			// 8 base + 1 NATS bridge + 2 NATS + 10 sensors.
+			// 46 fixed + 13 NATS fixed + 10 sensors * (14 + 14 NATS) + 1 profile * (2 + 1 NATS).
+			name: "with bridge",
+			cfg: estimate.Config{
+				SensorCount:    10,
+				SensorInterval: 100 * time.Millisecond,
+				ChannelBuffer:  1000,
+				Profiles:       1,
+				NATSEnabled:    true,
+				BridgeEnabled:  true,
+				SubjectPrefix:  "iot.sensors",
+			},
+			wantGoroutines: 21,
+			wantSeries:     342,
+		},
+		{
+			// 8 base + 2 NATS + 4 publisher workers + 10 sensors.
+			// 46 fixed + 13 NATS fixed + 10 sensors * (14 + 14 NATS) + 1 profile * (2 + 1 NATS).
+			name: "with publisher workers",
+			cfg: estimate.Config{
+				SensorCount:      10,
+				SensorInterval:   100 * time.Millisecond,
+				ChannelBuffer:    1000,
+				Profiles:         1,
+				NATSEnabled:      true,
+				SubjectPrefix:    "iot.sensors",
+				PublisherWorkers: 4,
+			},
+			wantGoroutines: 24,
+			wantSeries:     342,
+		},
+		{
+			// 8 base + 50 sensors.
+			// 46 fixed + 50 sensors * 14 + 2 profiles * 2.
+			name: "without NATS",
+			cfg: estimate.Config{
+				SensorCount:    50,
+				SensorInterval: 100 * time.Millisecond,
+				ChannelBuffer:  1000,
+				Profiles:       2,
+				BridgeEnabled:  true, // Ignored, since the bridge requires NATS.
+			},
+			wantGoroutines: 58,
+			wantSeries:     750,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			e := estimate.Resources(tt.cfg)
+
+			if e.Goroutines != tt.wantGoroutines {
+				t.Errorf("expected %d goroutines, got %d", tt.wantGoroutines, e.Goroutines)
+			}
+			if e.MetricSeries != tt.wantSeries {
+				t.Errorf("expected %d metric series, got %d", tt.wantSeries, e.MetricSeries)
+			}
+
+			wantBytes := int64(tt.cfg.ChannelBuffer) * int64(unsafe.Sizeof(model.SensorData{}))
+			if e.ChannelBufferBytes != wantBytes {
+				t.Errorf("expected %d channel buffer bytes, got %d", wantBytes, e.ChannelBufferBytes)
+			}
+
+			wantRate := float64(tt.cfg.SensorCount) / tt.cfg.SensorInterval.Seconds()
+			if e.MessagesPerSecond != wantRate {
+				t.Errorf("expected %v messages/sec, got %v", wantRate, e.MessagesPerSecond)
+			}
+
+			if tt.cfg.NATSEnabled && e.BrokerBytesPerSecond <= e.MessagesPerSecond {
+				t.Errorf("expected broker throughput to exceed 1 byte per message, got %v bytes/sec", e.BrokerBytesPerSecond)
+			}
+			if !tt.cfg.NATSEnabled && e.BrokerBytesPerSecond != 0 {
+				t.Errorf("expected no broker throughput without NATS, got %v bytes/sec", e.BrokerBytesPerSecond)
+			}
+		})
+	}
+}
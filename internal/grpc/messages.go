@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/aggregator"
+)
+
+//go:generate protoc -I ../.. --go_out=../.. --go_opt=paths=source_relative --go-grpc_out=../.. --go-grpc_opt=paths=source_relative internal/grpc/sensor_service.proto
+
+// SummaryToProto returns sum as a Summary message.
+func SummaryToProto(sum aggregator.Summary) *Summary {
+	return &Summary{
+		WindowStart: timestamppb.New(sum.WindowStart),
+		WindowEnd:   timestamppb.New(sum.WindowEnd),
+		Messages:    int64(sum.Messages),
+		Total:       int64(sum.Total),
+		Stats: &Stats{
+			Count: int64(sum.Stats.Count),
+			Min:   sum.Stats.Min,
+			Max:   sum.Stats.Max,
+			Mean:  sum.Stats.Mean,
+			P50:   sum.Stats.P50,
+			P95:   sum.Stats.P95,
+			P99:   sum.Stats.P99,
+		},
+	}
+}
+
+// SummaryFromProto returns the summary msg carries, with its window bounds in UTC.
+func SummaryFromProto(msg *Summary) aggregator.Summary {
+	stats := msg.GetStats()
+	return aggregator.Summary{
+		WindowStart: msg.GetWindowStart().AsTime(),
+		WindowEnd:   msg.GetWindowEnd().AsTime(),
+		Messages:    int(msg.GetMessages()),
+		Total:       int(msg.GetTotal()),
+		Stats: aggregator.Stats{
+			Count: int(stats.GetCount()),
+			Min:   stats.GetMin(),
+			Max:   stats.GetMax(),
+			Mean:  stats.GetMean(),
+			P50:   stats.GetP50(),
+			P95:   stats.GetP95(),
+			P99:   stats.GetP99(),
+		},
+	}
+}
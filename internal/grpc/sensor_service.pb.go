@@ -0,0 +1,460 @@
+// Protobuf schema of the SensorService gRPC service.
+// Field numbers must never be reused: retire removed fields with `reserved`.
+// After changing it, regenerate sensor_service.pb.go and sensor_service_grpc.pb.go with `go generate ./internal/grpc`.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        (unknown)
+// source: internal/grpc/sensor_service.proto
+
+package grpc
+
+import (
+	codec "github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// StreamRequest is a message from the client: a reading to ingest, or a subscription request.
+type StreamRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Request:
+	//
+	//	*StreamRequest_Reading
+	//	*StreamRequest_Subscribe
+	Request       isStreamRequest_Request `protobuf_oneof:"request"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamRequest) Reset() {
+	*x = StreamRequest{}
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamRequest) ProtoMessage() {}
+
+func (x *StreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamRequest.ProtoReflect.Descriptor instead.
+func (*StreamRequest) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_sensor_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *StreamRequest) GetRequest() isStreamRequest_Request {
+	if x != nil {
+		return x.Request
+	}
+	return nil
+}
+
+func (x *StreamRequest) GetReading() *codec.SensorData {
+	if x != nil {
+		if x, ok := x.Request.(*StreamRequest_Reading); ok {
+			return x.Reading
+		}
+	}
+	return nil
+}
+
+func (x *StreamRequest) GetSubscribe() *Subscribe {
+	if x != nil {
+		if x, ok := x.Request.(*StreamRequest_Subscribe); ok {
+			return x.Subscribe
+		}
+	}
+	return nil
+}
+
+type isStreamRequest_Request interface {
+	isStreamRequest_Request()
+}
+
+type StreamRequest_Reading struct {
+	Reading *codec.SensorData `protobuf:"bytes,1,opt,name=reading,proto3,oneof"`
+}
+
+type StreamRequest_Subscribe struct {
+	Subscribe *Subscribe `protobuf:"bytes,2,opt,name=subscribe,proto3,oneof"`
+}
+
+func (*StreamRequest_Reading) isStreamRequest_Request() {}
+
+func (*StreamRequest_Subscribe) isStreamRequest_Request() {}
+
+// Subscribe requests the summary of every aggregation window that completes from then on.
+type Subscribe struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Subscribe) Reset() {
+	*x = Subscribe{}
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Subscribe) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Subscribe) ProtoMessage() {}
+
+func (x *Subscribe) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Subscribe.ProtoReflect.Descriptor instead.
+func (*Subscribe) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_sensor_service_proto_rawDescGZIP(), []int{1}
+}
+
+// StreamResponse is a message to the client.
+type StreamResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Summary       *Summary               `protobuf:"bytes,1,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamResponse) Reset() {
+	*x = StreamResponse{}
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamResponse) ProtoMessage() {}
+
+func (x *StreamResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamResponse.ProtoReflect.Descriptor instead.
+func (*StreamResponse) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_sensor_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StreamResponse) GetSummary() *Summary {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+// Summary is the aggregator's record of one aggregation window.
+type Summary struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	WindowStart *timestamppb.Timestamp `protobuf:"bytes,1,opt,name=window_start,json=windowStart,proto3" json:"window_start,omitempty"`
+	WindowEnd   *timestamppb.Timestamp `protobuf:"bytes,2,opt,name=window_end,json=windowEnd,proto3" json:"window_end,omitempty"`
+	// The number of readings processed during the window, and since the aggregator started.
+	Messages      int64  `protobuf:"varint,3,opt,name=messages,proto3" json:"messages,omitempty"`
+	Total         int64  `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	Stats         *Stats `protobuf:"bytes,5,opt,name=stats,proto3" json:"stats,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Summary) Reset() {
+	*x = Summary{}
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Summary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Summary) ProtoMessage() {}
+
+func (x *Summary) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Summary.ProtoReflect.Descriptor instead.
+func (*Summary) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_sensor_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Summary) GetWindowStart() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowStart
+	}
+	return nil
+}
+
+func (x *Summary) GetWindowEnd() *timestamppb.Timestamp {
+	if x != nil {
+		return x.WindowEnd
+	}
+	return nil
+}
+
+func (x *Summary) GetMessages() int64 {
+	if x != nil {
+		return x.Messages
+	}
+	return 0
+}
+
+func (x *Summary) GetTotal() int64 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *Summary) GetStats() *Stats {
+	if x != nil {
+		return x.Stats
+	}
+	return nil
+}
+
+// Stats are statistics of the values of readings.
+type Stats struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Count         int64                  `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Min           float64                `protobuf:"fixed64,2,opt,name=min,proto3" json:"min,omitempty"`
+	Max           float64                `protobuf:"fixed64,3,opt,name=max,proto3" json:"max,omitempty"`
+	Mean          float64                `protobuf:"fixed64,4,opt,name=mean,proto3" json:"mean,omitempty"`
+	P50           float64                `protobuf:"fixed64,5,opt,name=p50,proto3" json:"p50,omitempty"`
+	P95           float64                `protobuf:"fixed64,6,opt,name=p95,proto3" json:"p95,omitempty"`
+	P99           float64                `protobuf:"fixed64,7,opt,name=p99,proto3" json:"p99,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stats) Reset() {
+	*x = Stats{}
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stats) ProtoMessage() {}
+
+func (x *Stats) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_grpc_sensor_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stats.ProtoReflect.Descriptor instead.
+func (*Stats) Descriptor() ([]byte, []int) {
+	return file_internal_grpc_sensor_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Stats) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Stats) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *Stats) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *Stats) GetMean() float64 {
+	if x != nil {
+		return x.Mean
+	}
+	return 0
+}
+
+func (x *Stats) GetP50() float64 {
+	if x != nil {
+		return x.P50
+	}
+	return 0
+}
+
+func (x *Stats) GetP95() float64 {
+	if x != nil {
+		return x.P95
+	}
+	return 0
+}
+
+func (x *Stats) GetP99() float64 {
+	if x != nil {
+		return x.P99
+	}
+	return 0
+}
+
+var File_internal_grpc_sensor_service_proto protoreflect.FileDescriptor
+
+const file_internal_grpc_sensor_service_proto_rawDesc = "" +
+	"\n" +
+	"\"internal/grpc/sensor_service.proto\x12\x0eiot.sensors.v1\x1a\x1fgoogle/protobuf/timestamp.proto\x1a\x1finternal/codec/sensordata.proto\"\x8d\x01\n" +
+	"\rStreamRequest\x126\n" +
+	"\areading\x18\x01 \x01(\v2\x1a.iot.sensors.v1.SensorDataH\x00R\areading\x129\n" +
+	"\tsubscribe\x18\x02 \x01(\v2\x19.iot.sensors.v1.SubscribeH\x00R\tsubscribeB\t\n" +
+	"\arequest\"\v\n" +
+	"\tSubscribe\"C\n" +
+	"\x0eStreamResponse\x121\n" +
+	"\asummary\x18\x01 \x01(\v2\x17.iot.sensors.v1.SummaryR\asummary\"\xe2\x01\n" +
+	"\aSummary\x12=\n" +
+	"\fwindow_start\x18\x01 \x01(\v2\x1a.google.protobuf.TimestampR\vwindowStart\x129\n" +
+	"\n" +
+	"window_end\x18\x02 \x01(\v2\x1a.google.protobuf.TimestampR\twindowEnd\x12\x1a\n" +
+	"\bmessages\x18\x03 \x01(\x03R\bmessages\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x03R\x05total\x12+\n" +
+	"\x05stats\x18\x05 \x01(\v2\x15.iot.sensors.v1.StatsR\x05stats\"\x8b\x01\n" +
+	"\x05Stats\x12\x14\n" +
+	"\x05count\x18\x01 \x01(\x03R\x05count\x12\x10\n" +
+	"\x03min\x18\x02 \x01(\x01R\x03min\x12\x10\n" +
+	"\x03max\x18\x03 \x01(\x01R\x03max\x12\x12\n" +
+	"\x04mean\x18\x04 \x01(\x01R\x04mean\x12\x10\n" +
+	"\x03p50\x18\x05 \x01(\x01R\x03p50\x12\x10\n" +
+	"\x03p95\x18\x06 \x01(\x01R\x03p95\x12\x10\n" +
+	"\x03p99\x18\a \x01(\x01R\x03p992\\\n" +
+	"\rSensorService\x12K\n" +
+	"\x06Stream\x12\x1d.iot.sensors.v1.StreamRequest\x1a\x1e.iot.sensors.v1.StreamResponse(\x010\x01BBZ@github.com/allthepins/iot-sensor-network-simulator/internal/grpcb\x06proto3"
+
+var (
+	file_internal_grpc_sensor_service_proto_rawDescOnce sync.Once
+	file_internal_grpc_sensor_service_proto_rawDescData []byte
+)
+
+func file_internal_grpc_sensor_service_proto_rawDescGZIP() []byte {
+	file_internal_grpc_sensor_service_proto_rawDescOnce.Do(func() {
+		file_internal_grpc_sensor_service_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_grpc_sensor_service_proto_rawDesc), len(file_internal_grpc_sensor_service_proto_rawDesc)))
+	})
+	return file_internal_grpc_sensor_service_proto_rawDescData
+}
+
+var file_internal_grpc_sensor_service_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_internal_grpc_sensor_service_proto_goTypes = []any{
+	(*StreamRequest)(nil),         // 0: iot.sensors.v1.StreamRequest
+	(*Subscribe)(nil),             // 1: iot.sensors.v1.Subscribe
+	(*StreamResponse)(nil),        // 2: iot.sensors.v1.StreamResponse
+	(*Summary)(nil),               // 3: iot.sensors.v1.Summary
+	(*Stats)(nil),                 // 4: iot.sensors.v1.Stats
+	(*codec.SensorData)(nil),      // 5: iot.sensors.v1.SensorData
+	(*timestamppb.Timestamp)(nil), // 6: google.protobuf.Timestamp
+}
+var file_internal_grpc_sensor_service_proto_depIdxs = []int32{
+	5, // 0: iot.sensors.v1.StreamRequest.reading:type_name -> iot.sensors.v1.SensorData
+	1, // 1: iot.sensors.v1.StreamRequest.subscribe:type_name -> iot.sensors.v1.Subscribe
+	3, // 2: iot.sensors.v1.StreamResponse.summary:type_name -> iot.sensors.v1.Summary
+	6, // 3: iot.sensors.v1.Summary.window_start:type_name -> google.protobuf.Timestamp
+	6, // 4: iot.sensors.v1.Summary.window_end:type_name -> google.protobuf.Timestamp
+	4, // 5: iot.sensors.v1.Summary.stats:type_name -> iot.sensors.v1.Stats
+	0, // 6: iot.sensors.v1.SensorService.Stream:input_type -> iot.sensors.v1.StreamRequest
+	2, // 7: iot.sensors.v1.SensorService.Stream:output_type -> iot.sensors.v1.StreamResponse
+	7, // [7:8] is the sub-list for method output_type
+	6, // [6:7] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_internal_grpc_sensor_service_proto_init() }
+func file_internal_grpc_sensor_service_proto_init() {
+	if File_internal_grpc_sensor_service_proto != nil {
+		return
+	}
+	file_internal_grpc_sensor_service_proto_msgTypes[0].OneofWrappers = []any{
+		(*StreamRequest_Reading)(nil),
+		(*StreamRequest_Subscribe)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_grpc_sensor_service_proto_rawDesc), len(file_internal_grpc_sensor_service_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_internal_grpc_sensor_service_proto_goTypes,
+		DependencyIndexes: file_internal_grpc_sensor_service_proto_depIdxs,
+		MessageInfos:      file_internal_grpc_sensor_service_proto_msgTypes,
+	}.Build()
+	File_internal_grpc_sensor_service_proto = out.File
+	file_internal_grpc_sensor_service_proto_goTypes = nil
+	file_internal_grpc_sensor_service_proto_depIdxs = nil
+}
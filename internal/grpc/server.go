@@ -0,0 +1,245 @@
+// Package grpc serves the SensorService gRPC service (see sensor_service.proto),
+// a streaming alternative to the HTTP ingest endpoint: clients push readings into the pipeline,
+// and can subscribe to the aggregator's window summaries on the same stream.
+package grpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/aggregator"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/ingest"
+)
+
+const (
+	// subscriberBuffer is how many summaries are buffered for each subscriber.
+	// Summaries a subscriber is too slow to receive are dropped.
+	subscriberBuffer = 16
+	// shutdownTimeout bounds how long Serve waits for open streams to finish when stopping.
+	shutdownTimeout = 5 * time.Second
+)
+
+// Server serves the SensorService gRPC service.
+// Clients can use the stubs generated from sensor_service.proto (e.g. NewSensorServiceClient).
+type Server struct {
+	UnimplementedSensorServiceServer
+
+	addr     string
+	listener net.Listener
+	server   *grpcgo.Server
+	ingester *ingest.Ingester
+	logger   *slog.Logger
+
+	mu          sync.Mutex // Guards subscribers and broadcastDone.
+	subscribers map[chan aggregator.Summary]struct{}
+	// broadcastDone is set once Broadcast returns, after which subscribers get a closed channel.
+	broadcastDone bool
+}
+
+// NewServer creates a Server listening on addr (e.g. ":9090"), which forwards pushed readings to ingester.
+func NewServer(addr string, ingester *ingest.Ingester, l *slog.Logger) *Server {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	s := &Server{
+		addr:        addr,
+		ingester:    ingester,
+		logger:      l.With("component", "grpc_server"),
+		subscribers: make(map[chan aggregator.Summary]struct{}),
+	}
+	s.server = grpcgo.NewServer()
+	RegisterSensorServiceServer(s.server, s)
+	return s
+}
+
+// Listen binds the server's address, so that a failure (e.g. the port being in use)
+// is reported to the caller up front, rather than from Serve.
+func (s *Server) Listen() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return fmt.Errorf("gRPC server failed to listen on %s: %w", s.addr, err)
+	}
+	s.listener = ln
+	return nil
+}
+
+// Addr returns the address the server is listening on, or its configured address before Listen.
+func (s *Server) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.addr
+}
+
+// Serve serves until ctx is done, listening first if Listen hasn't been called.
+// It then waits up to 5s for open streams to finish, before closing them.
+func (s *Server) Serve(ctx context.Context) error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		s.logger.Info("gRPC server starting", "addr", s.Addr())
+		serveErr <- s.server.Serve(s.listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("gRPC server failed: %w", err)
+	case <-ctx.Done():
+	}
+	s.logger.Info("Shutting down gRPC server")
+
+	stopped := make(chan struct{})
+	go func() {
+		s.server.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(shutdownTimeout):
+		s.logger.Warn("gRPC streams did not finish in time, closing them", "timeout", shutdownTimeout)
+		s.server.Stop()
+		<-stopped
+	}
+	return nil
+}
+
+// Broadcast sends every summary received from windows (e.g. the aggregator's Windows channel)
+// to each subscribed client, until windows is closed. Subscribers' streams then end.
+func (s *Server) Broadcast(windows <-chan aggregator.Summary) {
+	for sum := range windows {
+		s.mu.Lock()
+		for sub := range s.subscribers {
+			select {
+			case sub <- sum:
+			default:
+				s.logger.Warn("Subscriber too slow, dropping window summary", "window_start", sum.WindowStart)
+			}
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.broadcastDone = true
+	for sub := range s.subscribers {
+		close(sub)
+		delete(s.subscribers, sub)
+	}
+}
+
+// subscribe returns a channel the window summaries are sent to, until unsubscribe is called with it
+// or Broadcast returns, when it's closed.
+func (s *Server) subscribe() chan aggregator.Summary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub := make(chan aggregator.Summary, subscriberBuffer)
+	if s.broadcastDone {
+		close(sub)
+		return sub
+	}
+	s.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe stops sending summaries to sub.
+func (s *Server) unsubscribe(sub chan aggregator.Summary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.subscribers, sub)
+}
+
+// Stream handles a Stream RPC: it ingests the client's readings until the client closes its side,
+// and, once the client subscribes, sends it the window summaries until Broadcast returns.
+// The stream ends when the client disconnects, once both sides are done, or on the first invalid reading.
+func (s *Server) Stream(stream SensorService_StreamServer) error {
+	ctx := stream.Context()
+
+	// The sender goroutine, started on subscription, is always waited for before returning,
+	// since a stream can't be sent to once its handler returns. It stops when the client disconnects
+	// (which cancels ctx), when the subscription ends, or when stop is closed.
+	var sent chan error
+	stop := make(chan struct{})
+	waitSender := func() error {
+		if sent == nil {
+			return nil
+		}
+		err := <-sent
+		sent = nil
+		return err
+	}
+	defer func() {
+		close(stop)
+		_ = waitSender()
+	}()
+
+	for {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			// The client is done sending. The stream stays open for as long as it's subscribed.
+			return waitSender()
+		}
+		if err != nil {
+			return err
+		}
+
+		if req.GetSubscribe() != nil && sent == nil {
+			sub := s.subscribe()
+			sent = make(chan error, 1)
+			go func() {
+				defer s.unsubscribe(sub)
+				sent <- s.send(ctx, stream, sub, stop)
+			}()
+		}
+		if reading := req.GetReading(); reading != nil {
+			data := codec.FromProto(reading)
+			if _, err := s.ingester.Ingest(data); err != nil {
+				switch {
+				case errors.Is(err, ingest.ErrFull):
+					s.logger.Warn("Dropped reading pushed over gRPC", "sensor_id", data.ID, "error", err)
+				case errors.Is(err, ingest.ErrClosed):
+					return status.Error(codes.Unavailable, err.Error())
+				default:
+					return status.Errorf(codes.InvalidArgument, "invalid reading: %v", err)
+				}
+			}
+		}
+	}
+}
+
+// send sends each summary received from sub to the client, until sub is closed, ctx is done, or stop is closed.
+func (s *Server) send(ctx context.Context, stream SensorService_StreamServer, sub <-chan aggregator.Summary, stop <-chan struct{}) error {
+	for {
+		select {
+		case sum, ok := <-sub:
+			if !ok {
+				return nil
+			}
+			if err := stream.Send(&StreamResponse{Summary: SummaryToProto(sum)}); err != nil {
+				return err
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stop:
+			return nil
+		}
+	}
+}
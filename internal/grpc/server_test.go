@@ -0,0 +1,242 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/aggregator"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/grpc"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/ingest"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// startServer starts a Server forwarding to dataCh and broadcasting windows,
+// returning a client connection to it and a func that stops it, which reports how long stopping took.
+func startServer(t *testing.T, dataCh chan model.SensorData, windows <-chan aggregator.Summary) (*grpcgo.ClientConn, func() time.Duration) {
+	t.Helper()
+
+	logger := slog.New(slog.DiscardHandler)
+	srv := grpc.NewServer("127.0.0.1:0", ingest.New(dataCh, nil, logger), logger)
+	if err := srv.Listen(); err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	go srv.Broadcast(windows)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan struct{})
+	go func() {
+		defer close(served)
+		if err := srv.Serve(ctx); err != nil {
+			t.Errorf("Serve returned error: %v", err)
+		}
+	}()
+
+	conn, err := grpcgo.NewClient(srv.Addr(), grpcgo.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	stop := func() time.Duration {
+		start := time.Now()
+		cancel()
+		<-served
+		return time.Since(start)
+	}
+	t.Cleanup(func() { stop() })
+	return conn, stop
+}
+
+// openStream opens a Stream RPC on conn.
+func openStream(t *testing.T, ctx context.Context, conn *grpcgo.ClientConn) grpc.SensorService_StreamClient {
+	t.Helper()
+
+	stream, err := grpc.NewSensorServiceClient(conn).Stream(ctx)
+	if err != nil {
+		t.Fatalf("failed to open stream: %v", err)
+	}
+	return stream
+}
+
+// pushRequest returns a request pushing data.
+func pushRequest(data model.SensorData) *grpc.StreamRequest {
+	return &grpc.StreamRequest{Request: &grpc.StreamRequest_Reading{Reading: codec.ToProto(data)}}
+}
+
+// subscribeRequest returns a subscription request.
+func subscribeRequest() *grpc.StreamRequest {
+	return &grpc.StreamRequest{Request: &grpc.StreamRequest_Subscribe{Subscribe: &grpc.Subscribe{}}}
+}
+
+// TestServer_Stream_Push verifies readings pushed on a stream are forwarded to the data channel.
+func TestServer_Stream_Push(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 10)
+	conn, _ := startServer(t, dataCh, make(chan aggregator.Summary))
+
+	stream := openStream(t, context.Background(), conn)
+	for id := 1; id <= 3; id++ {
+		if err := stream.Send(pushRequest(model.SensorData{ID: id, Value: float64(id) / 2})); err != nil {
+			t.Fatalf("failed to send reading: %v", err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("failed to close send: %v", err)
+	}
+	if _, err := stream.Recv(); !errors.Is(err, io.EOF) {
+		t.Fatalf("expected the stream to end once the unsubscribed client was done, got %v", err)
+	}
+
+	for id := 1; id <= 3; id++ {
+		select {
+		case data := <-dataCh:
+			if data.ID != id || data.Value != float64(id)/2 || data.Timestamp.IsZero() {
+				t.Errorf("expected sensor %d's reading with a defaulted timestamp, got %+v", id, data)
+			}
+		default:
+			t.Fatalf("expected 3 forwarded readings, got %d", id-1)
+		}
+	}
+}
+
+// TestServer_Stream_InvalidReading verifies an invalid reading ends the stream with InvalidArgument.
+func TestServer_Stream_InvalidReading(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 10)
+	conn, _ := startServer(t, dataCh, make(chan aggregator.Summary))
+
+	stream := openStream(t, context.Background(), conn)
+	if err := stream.Send(pushRequest(model.SensorData{ID: 1, Value: math.NaN()})); err != nil {
+		t.Fatalf("failed to send reading: %v", err)
+	}
+	_, err := stream.Recv()
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+	if len(dataCh) != 0 {
+		t.Errorf("expected nothing forwarded, got %d readings", len(dataCh))
+	}
+}
+
+// TestServer_Stream_Subscribe verifies a subscribed client receives each window summary,
+// and its stream ends once the windows channel is closed.
+func TestServer_Stream_Subscribe(t *testing.T) {
+	t.Parallel()
+
+	windows := make(chan aggregator.Summary)
+	conn, _ := startServer(t, make(chan model.SensorData, 1), windows)
+
+	stream := openStream(t, context.Background(), conn)
+	if err := stream.Send(subscribeRequest()); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatalf("failed to close send: %v", err)
+	}
+
+	start := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC)
+	want := aggregator.Summary{
+		WindowStart: start,
+		WindowEnd:   start.Add(10 * time.Second),
+		Messages:    40,
+		Total:       120,
+		Stats:       aggregator.Stats{Count: 40, Min: -1.5, Max: 30, Mean: 12.25, P50: 12, P95: 28, P99: 30},
+	}
+	// The subscription may not be registered yet, so the summary is broadcast until it's received.
+	received := make(chan *grpc.StreamResponse, 1)
+	go func() {
+		resp, err := stream.Recv()
+		if err != nil {
+			t.Errorf("failed to receive summary: %v", err)
+			close(received)
+			return
+		}
+		received <- resp
+	}()
+	var got *grpc.StreamResponse
+	for done := false; !done; {
+		select {
+		case windows <- want:
+			time.Sleep(5 * time.Millisecond)
+		case got, done = <-received:
+			done = true
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the summary")
+		}
+	}
+	if sum := grpc.SummaryFromProto(got.GetSummary()); !reflect.DeepEqual(sum, want) {
+		t.Errorf("expected summary %+v, got %+v", want, sum)
+	}
+
+	close(windows)
+	for {
+		_, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("expected the stream to end once the windows channel closed, got %v", err)
+		}
+	}
+}
+
+// TestServer_Stream_ClientDisconnect verifies a subscribed client disconnecting ends its stream on the server,
+// so stopping the server doesn't wait on it.
+func TestServer_Stream_ClientDisconnect(t *testing.T) {
+	t.Parallel()
+
+	conn, stop := startServer(t, make(chan model.SensorData, 1), make(chan aggregator.Summary))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream := openStream(t, ctx, conn)
+	if err := stream.Send(subscribeRequest()); err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	cancel()
+	if _, err := stream.Recv(); status.Code(err) != codes.Canceled {
+		t.Errorf("expected the stream to be canceled, got %v", err)
+	}
+
+	if took := stop(); took > time.Second {
+		t.Errorf("expected the server to stop promptly once its only client disconnected, took %v", took)
+	}
+}
+
+// TestSummaryFromProto_RoundTrip verifies summaries survive conversion to and encoding as Summary messages.
+func TestSummaryFromProto_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	start := time.Unix(1751371200, 5).UTC()
+	for _, want := range []aggregator.Summary{
+		{},
+		{WindowStart: start, WindowEnd: start.Add(time.Second), Messages: 3, Total: 9, Stats: aggregator.Stats{Count: 3, Min: -2, P99: 1e9}},
+	} {
+		b, err := proto.Marshal(grpc.SummaryToProto(want))
+		if err != nil {
+			t.Fatalf("failed to marshal %+v: %v", want, err)
+		}
+
+		var msg grpc.Summary
+		if err := proto.Unmarshal(b, &msg); err != nil {
+			t.Fatalf("failed to unmarshal %+v: %v", want, err)
+		}
+		if got := grpc.SummaryFromProto(&msg); !reflect.DeepEqual(got, want) {
+			t.Errorf("expected %+v, got %+v", want, got)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package ingest accepts readings pushed over HTTP by external sensors,
+// feeding them into the same pipeline as the simulated sensors' readings.
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// MaxBodyBytes bounds the size of an ingest request's body.
+const MaxBodyBytes = 1 << 20
+
+var (
+	// ErrFull is returned by Ingest when the data channel has no room for a reading.
+	ErrFull = errors.New("data channel full")
+	// ErrClosed is returned by Ingest once the Ingester is closed.
+	ErrClosed = errors.New("shutting down")
+)
+
+// Ingester forwards readings posted to `POST /ingest` (or passed to Ingest) onto a data channel.
+// It is safe for concurrent use.
+type Ingester struct {
+	// mu is held for reading while forwarding readings, so Close can wait out in-flight requests.
+	mu      sync.RWMutex
+	closed  bool
+	dataCh  chan<- model.SensorData
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+}
+
+// New creates an Ingester forwarding readings to dataCh.
+// Close must be called before dataCh is closed.
+func New(dataCh chan<- model.SensorData, m *metrics.Metrics, l *slog.Logger) *Ingester {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	return &Ingester{
+		dataCh:  dataCh,
+		metrics: m,
+		logger:  l.With("component", "ingest"),
+	}
+}
+
+// Close stops the Ingester forwarding readings, waiting for requests already forwarding to finish,
+// so that the data channel can then be closed. Later requests are rejected with a 503.
+func (in *Ingester) Close() {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+
+	in.closed = true
+}
+
+// Handler returns an http.Handler serving `POST /ingest`, which accepts a JSON SensorData, or an array of them.
+// Readings must have a finite value. A missing timestamp defaults to now, and a missing schema version to the current one.
+// Readings are accepted with a 202, or rejected as a whole with a 400 if any is malformed or invalid.
+// If the data channel fills up, the readings that didn't fit are rejected with a 503 (the response reports
+// how many were accepted), rather than blocking the request while the pipeline catches up.
+func (in *Ingester) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /ingest", in.handleIngest)
+	return mux
+}
+
+// response is the JSON body of an ingest response.
+type response struct {
+	Accepted int    `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// handleIngest handles `POST /ingest`.
+func (in *Ingester) handleIngest(w http.ResponseWriter, r *http.Request) {
+	readings, err := decode(http.MaxBytesReader(w, r.Body, MaxBodyBytes))
+	if err != nil {
+		in.writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+		return
+	}
+
+	accepted, err := in.Ingest(readings...)
+	switch {
+	case errors.Is(err, ErrFull), errors.Is(err, ErrClosed):
+		in.logger.Warn("Dropped ingested readings", "accepted", accepted, "dropped", len(readings)-accepted, "error", err)
+		in.writeResponse(w, http.StatusServiceUnavailable, response{Accepted: accepted, Error: err.Error()})
+	case err != nil:
+		in.writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+	default:
+		in.writeResponse(w, http.StatusAccepted, response{Accepted: accepted})
+	}
+}
+
+// decode decodes a JSON SensorData, or an array of them, from body.
+// Unknown fields are rejected, to catch misspelled ones.
+func decode(body io.Reader) ([]model.SensorData, error) {
+	raw, err := io.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body: %w", err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	var readings []model.SensorData
+	if trimmed := bytes.TrimLeft(raw, " \t\r\n"); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = dec.Decode(&readings)
+	} else {
+		var data model.SensorData
+		err = dec.Decode(&data)
+		readings = append(readings, data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("malformed JSON: %w", err)
+	}
+	if dec.More() {
+		return nil, errors.New("malformed JSON: unexpected data after the readings")
+	}
+	if len(readings) == 0 {
+		return nil, errors.New("no readings")
+	}
+	return readings, nil
+}
+
+// prepare validates readings, and fills in their defaults: now as their timestamp, and the current schema version.
+func prepare(readings []model.SensorData, now time.Time) error {
+	for i := range readings {
+		data := &readings[i]
+		if math.IsNaN(data.Value) || math.IsInf(data.Value, 0) {
+			return fmt.Errorf("reading %d: value must be finite, got %v", i, data.Value)
+		}
+		if data.Timestamp.IsZero() {
+			data.Timestamp = now
+		}
+		if data.SchemaVersion == 0 {
+			data.SchemaVersion = model.SchemaVersion
+		}
+	}
+	return nil
+}
+
+// Ingest validates readings, fills in their defaults (see Handler), and forwards them to the data channel
+// without blocking. It returns how many were forwarded: none if any reading is invalid,
+// and only those that fit if the data channel fills up (with ErrFull), or none once the Ingester is closed (with ErrClosed).
+func (in *Ingester) Ingest(readings ...model.SensorData) (int, error) {
+	if err := prepare(readings, time.Now()); err != nil {
+		return 0, err
+	}
+
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+
+	if in.closed {
+		return 0, ErrClosed
+	}
+	for i, data := range readings {
+		select {
+		case in.dataCh <- data:
+		default:
+			return i, ErrFull
+		}
+	}
+	return len(readings), nil
+}
+
+// writeResponse writes resp as JSON with the status code code, and counts the request by it.
+func (in *Ingester) writeResponse(w http.ResponseWriter, code int, resp response) {
+	if in.metrics != nil {
+		in.metrics.IngestRequests.WithLabelValues(strconv.Itoa(code)).Inc()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(resp)
+}
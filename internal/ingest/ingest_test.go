@@ -0,0 +1,152 @@
+package ingest_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/ingest"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// post posts body to the ingester's handler, returning the response code and decoded body.
+func post(t *testing.T, in *ingest.Ingester, body string) (int, map[string]any) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	in.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(body)))
+
+	var resp map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response %q: %v", rec.Body.String(), err)
+	}
+	return rec.Code, resp
+}
+
+// TestIngester_Accepts verifies single readings and arrays are forwarded to the data channel,
+// with a missing timestamp defaulted to now and the current schema version filled in.
+func TestIngester_Accepts(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 10)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	in := ingest.New(dataCh, m, nil)
+
+	before := time.Now()
+	code, resp := post(t, in, `{"ID": 1, "Value": 21.5, "Timestamp": "2025-07-01T12:00:00Z"}`)
+	if code != http.StatusAccepted || resp["accepted"] != 1.0 {
+		t.Fatalf("expected 202 accepting 1 reading, got %d %v", code, resp)
+	}
+	code, resp = post(t, in, `[{"ID": 2, "Value": 1}, {"ID": 3, "Value": 2, "Type": "humidity"}]`)
+	if code != http.StatusAccepted || resp["accepted"] != 2.0 {
+		t.Fatalf("expected 202 accepting 2 readings, got %d %v", code, resp)
+	}
+	close(dataCh)
+
+	var got []model.SensorData
+	for data := range dataCh {
+		got = append(got, data)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected 3 forwarded readings, got %d", len(got))
+	}
+	if want := time.Date(2025, 7, 1, 12, 0, 0, 0, time.UTC); !got[0].Timestamp.Equal(want) {
+		t.Errorf("expected the posted timestamp %v to be kept, got %v", want, got[0].Timestamp)
+	}
+	if got[1].Timestamp.Before(before) {
+		t.Errorf("expected a missing timestamp to default to now, got %v", got[1].Timestamp)
+	}
+	if got[2].ID != 3 || got[2].Type != "humidity" || got[2].SchemaVersion != model.SchemaVersion {
+		t.Errorf("expected sensor 3's humidity reading at schema version %d, got %+v", model.SchemaVersion, got[2])
+	}
+	if got := testutil.ToFloat64(m.IngestRequests.WithLabelValues("202")); got != 2 {
+		t.Errorf("expected 2 accepted requests, got %v", got)
+	}
+}
+
+// TestIngester_RejectsMalformed verifies malformed or invalid payloads are rejected with a 400, forwarding nothing.
+func TestIngester_RejectsMalformed(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"not JSON", `value=1`, "malformed JSON"},
+		{"unknown field", `{"ID": 1, "Reading": 1}`, "unknown field"},
+		{"trailing data", `{"ID": 1} {"ID": 2}`, "unexpected data"},
+		{"out of range value", `{"ID": 1, "Value": 1e999}`, "malformed JSON"},
+		{"empty array", `[]`, "no readings"},
+		{"one bad reading", `[{"ID": 1}, {"ID": "two"}]`, "malformed JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dataCh := make(chan model.SensorData, 10)
+			code, resp := post(t, ingest.New(dataCh, nil, nil), tt.body)
+			if code != http.StatusBadRequest {
+				t.Errorf("expected 400, got %d", code)
+			}
+			if msg, _ := resp["error"].(string); !strings.Contains(msg, tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %q", tt.wantErr, msg)
+			}
+			if len(dataCh) != 0 {
+				t.Errorf("expected nothing forwarded, got %d readings", len(dataCh))
+			}
+		})
+	}
+}
+
+// TestIngester_Full verifies readings that don't fit in the data channel are rejected with a 503,
+// reporting how many did fit, rather than blocking.
+func TestIngester_Full(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 2)
+	code, resp := post(t, ingest.New(dataCh, nil, nil), `[{"ID": 1}, {"ID": 2}, {"ID": 3}]`)
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", code)
+	}
+	if resp["accepted"] != 2.0 {
+		t.Errorf("expected 2 readings accepted, got %v", resp["accepted"])
+	}
+	if len(dataCh) != 2 {
+		t.Errorf("expected 2 forwarded readings, got %d", len(dataCh))
+	}
+}
+
+// TestIngester_Close verifies requests are rejected with a 503 once the Ingester is closed,
+// so the data channel can safely be closed.
+func TestIngester_Close(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 1)
+	in := ingest.New(dataCh, nil, nil)
+	in.Close()
+	close(dataCh)
+
+	if code, _ := post(t, in, `{"ID": 1}`); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 after Close, got %d", code)
+	}
+}
+
+// TestIngester_MethodNotAllowed verifies only POST is accepted.
+func TestIngester_MethodNotAllowed(t *testing.T) {
+	t.Parallel()
+
+	rec := httptest.NewRecorder()
+	ingest.New(make(chan model.SensorData), nil, nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/ingest", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,214 @@
+// Package kafka provides a Kafka producer for publishing sensor data,
+// as an alternative to NATS for backends standardized on Kafka.
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+const (
+	// DefaultTopic is the topic sensor data is produced to.
+	DefaultTopic = "iot-sensors"
+	// subjectPrefix is the prefix of the subjects the publisher builds, which only their last token is kept of.
+	subjectPrefix = "iot.sensors"
+	// maxTopicLength is the longest topic name Kafka accepts.
+	maxTopicLength = 249
+)
+
+// Client produces sensor data to a Kafka topic.
+//
+// It publishes NATS-style subjects, so it can stand in for the NATS client: every message goes to the one topic,
+// keyed by its subject's last token (the sensor ID, for readings), so each sensor's readings land on the same
+// partition, in order. Messages are batched and written asynchronously; Close flushes those still buffered.
+// Messages that fail to be written are reported to the function set with OnWriteFailure.
+type Client struct {
+	writer    *kafkago.Writer
+	closed    atomic.Bool
+	onFailure atomic.Pointer[func(subject string, data []byte, err error)]
+	logger    *slog.Logger
+}
+
+// Config holds configuration for the Kafka client.
+type Config struct {
+	Brokers []string
+	Topic   string
+	// BatchSize is how many messages are written to a partition at once, at most.
+	// A partial batch is written once it's BatchTimeout old.
+	BatchSize      int
+	BatchTimeout   time.Duration
+	ConnectTimeout time.Duration
+}
+
+// DefaultConfig returns a Config with sensible defaults.
+func DefaultConfig() Config {
+	return Config{
+		Brokers:        []string{"localhost:9092"},
+		Topic:          DefaultTopic,
+		BatchSize:      100,
+		BatchTimeout:   100 * time.Millisecond,
+		ConnectTimeout: 10 * time.Second,
+	}
+}
+
+// ParseBrokers splits a comma-separated list of broker addresses (as "host:port"), ignoring surrounding whitespace.
+// Lists that are empty or have empty entries are rejected.
+func ParseBrokers(list string) ([]string, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, errors.New("kafka brokers must not be empty")
+	}
+
+	brokers := strings.Split(list, ",")
+	for i, b := range brokers {
+		brokers[i] = strings.TrimSpace(b)
+		if brokers[i] == "" {
+			return nil, fmt.Errorf("invalid kafka brokers %q: empty broker address", list)
+		}
+	}
+	return brokers, nil
+}
+
+// ValidateTopic reports whether topic is a legal Kafka topic name:
+// at most 249 ASCII letters, digits, dots, underscores and hyphens, and not "." or "..".
+func ValidateTopic(topic string) error {
+	if topic == "" || topic == "." || topic == ".." {
+		return fmt.Errorf("invalid kafka topic %q", topic)
+	}
+	if len(topic) > maxTopicLength {
+		return fmt.Errorf("invalid kafka topic %q: longer than %d characters", topic, maxTopicLength)
+	}
+	for _, r := range topic {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '_' || r == '-') {
+			return fmt.Errorf("invalid kafka topic %q: only letters, digits, '.', '_' and '-' are allowed", topic)
+		}
+	}
+	return nil
+}
+
+// Key returns the message key of a subject: its last dot-separated token, e.g. `42` for `iot.sensors.data.42`.
+func Key(subject string) []byte {
+	return []byte(subject[strings.LastIndexByte(subject, '.')+1:])
+}
+
+// NewClient creates a new Kafka client, after checking a broker is reachable.
+func NewClient(cfg Config, logger *slog.Logger) (*Client, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With("component", "kafka_client")
+
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("kafka brokers must not be empty")
+	}
+	if err := ValidateTopic(cfg.Topic); err != nil {
+		return nil, err
+	}
+
+	// The writer only connects once there's something to write, so check up front that the brokers can be reached.
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
+	defer cancel()
+	var errs []error
+	for _, broker := range cfg.Brokers {
+		conn, err := kafkago.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		conn.Close()
+		errs = nil
+		break
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("failed to connect to Kafka: %w", errors.Join(errs...))
+	}
+
+	c := &Client{logger: logger}
+	c.writer = &kafkago.Writer{
+		Addr:         kafkago.TCP(cfg.Brokers...),
+		Topic:        cfg.Topic,
+		Balancer:     &kafkago.Hash{},
+		BatchSize:    cfg.BatchSize,
+		BatchTimeout: cfg.BatchTimeout,
+		RequiredAcks: kafkago.RequireAll,
+		// Writes don't block the publisher: messages the writer gives up on are reported by complete instead.
+		Async:      true,
+		Completion: c.complete,
+		ErrorLogger: kafkago.LoggerFunc(func(format string, args ...any) {
+			logger.Warn("Kafka write attempt failed", "error", fmt.Sprintf(format, args...))
+		}),
+	}
+
+	logger.Info("Connected to Kafka", "brokers", cfg.Brokers, "topic", cfg.Topic)
+	return c, nil
+}
+
+// OnWriteFailure sets f to be called with the subject and payload of each message that fails to be written,
+// once the writer has given up retrying it, and the error it failed with.
+// f is called from the writer's goroutines, and Close waits for it to return.
+func (c *Client) OnWriteFailure(f func(subject string, data []byte, err error)) {
+	c.onFailure.Store(&f)
+}
+
+// complete reports the messages of a batch the writer failed to write to the OnWriteFailure function, if any.
+func (c *Client) complete(msgs []kafkago.Message, err error) {
+	if err == nil {
+		return
+	}
+	c.logger.Error("Kafka write failed", "messages", len(msgs), "error", err)
+
+	f := c.onFailure.Load()
+	if f == nil {
+		return
+	}
+	for _, msg := range msgs {
+		subject, _ := msg.WriterData.(string)
+		(*f)(subject, msg.Value, err)
+	}
+}
+
+// Publish queues a message keyed by the subject's last token for the topic.
+// It doesn't wait for the message to be written, so only fails once the client is closed (or ctx is done):
+// failing to write it is reported to the OnWriteFailure function.
+func (c *Client) Publish(ctx context.Context, subject string, data []byte) error {
+	// data is reused once Publish returns, but is only written later, so it's copied.
+	value := make([]byte, len(data))
+	copy(value, data)
+	return c.writer.WriteMessages(ctx, kafkago.Message{Key: Key(subject), Value: value, WriterData: subject})
+}
+
+// PublishJson queues a JSON-encoded message keyed by the subject's last token for the topic.
+func (c *Client) PublishJson(ctx context.Context, subject string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return c.Publish(ctx, subject, data)
+}
+
+// Close flushes the messages still buffered and closes the producer.
+func (c *Client) Close() error {
+	if c.closed.Swap(true) {
+		return nil
+	}
+	c.logger.Info("Flushing and closing Kafka producer")
+	return c.writer.Close()
+}
+
+// IsConnected returns true until the client is closed. The producer (re)connects to brokers as it writes.
+func (c *Client) IsConnected() bool {
+	return !c.closed.Load()
+}
+
+// SubjectPrefix returns the prefix of the subjects the client is published to.
+// Only their last token is used, as the message key.
+func (c *Client) SubjectPrefix() string {
+	return subjectPrefix
+}
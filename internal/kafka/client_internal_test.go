@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"errors"
+	"log/slog"
+	"slices"
+	"testing"
+
+	kafkago "github.com/segmentio/kafka-go"
+)
+
+// TestClient_Complete verifies the messages of a batch the writer failed to write are reported
+// to the OnWriteFailure function, with the subject they were published to, and that written batches aren't.
+func TestClient_Complete(t *testing.T) {
+	t.Parallel()
+
+	type failure struct {
+		subject, data string
+		err           error
+	}
+	var got []failure
+	c := &Client{logger: slog.New(slog.DiscardHandler)}
+	c.OnWriteFailure(func(subject string, data []byte, err error) {
+		got = append(got, failure{subject: subject, data: string(data), err: err})
+	})
+
+	msgs := []kafkago.Message{
+		{Key: []byte("1"), Value: []byte("a"), WriterData: "iot.sensors.data.1"},
+		{Key: []byte("2"), Value: []byte("b"), WriterData: "iot.sensors.data.2"},
+	}
+	c.complete(msgs, nil)
+	if len(got) != 0 {
+		t.Fatalf("expected no failures for a written batch, got %+v", got)
+	}
+
+	writeErr := errors.New("leader not available")
+	c.complete(msgs, writeErr)
+	want := []failure{
+		{subject: "iot.sensors.data.1", data: "a", err: writeErr},
+		{subject: "iot.sensors.data.2", data: "b", err: writeErr},
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("expected failures %+v, got %+v", want, got)
+	}
+}
@@ -0,0 +1,106 @@
+package kafka_test
+
+import (
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/kafka"
+)
+
+// TestKey verifies messages are keyed by their subject's last token.
+func TestKey(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"iot.sensors.data.42":             "42",
+		"iot.sensors.data.temperature.42": "42",
+		"iot.sensors.dlq":                 "dlq",
+		"iot":                             "iot",
+	}
+	for subject, want := range tests {
+		if got := string(kafka.Key(subject)); got != want {
+			t.Errorf("Key(%q): expected %q, got %q", subject, want, got)
+		}
+	}
+}
+
+// TestParseBrokers verifies broker lists are split and trimmed, and empty entries are rejected.
+func TestParseBrokers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		list    string
+		want    []string
+		wantErr string
+	}{
+		{list: "localhost:9092", want: []string{"localhost:9092"}},
+		{list: "kafka-1:9092, kafka-2:9092", want: []string{"kafka-1:9092", "kafka-2:9092"}},
+		{list: " ", wantErr: "must not be empty"},
+		{list: "kafka-1:9092,,kafka-2:9092", wantErr: "empty broker address"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.list, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := kafka.ParseBrokers(tt.list)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestValidateTopic verifies only legal Kafka topic names are accepted.
+func TestValidateTopic(t *testing.T) {
+	t.Parallel()
+
+	for _, topic := range []string{"iot-sensors", "iot.sensors_v2", strings.Repeat("t", 249)} {
+		if err := kafka.ValidateTopic(topic); err != nil {
+			t.Errorf("expected topic %q to be valid, got %v", topic, err)
+		}
+	}
+	for _, topic := range []string{"", ".", "..", "iot/sensors", "iot sensors", strings.Repeat("t", 250)} {
+		if err := kafka.ValidateTopic(topic); err == nil {
+			t.Errorf("expected topic %q to be rejected", topic)
+		}
+	}
+}
+
+// TestNewClient_Unreachable verifies NewClient returns an error when no broker can be reached.
+func TestNewClient_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	// Listen on a free port, then close it, so connecting to it is refused.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cfg := kafka.DefaultConfig()
+	cfg.Brokers = []string{addr}
+	cfg.ConnectTimeout = time.Second
+
+	client, err := kafka.NewClient(cfg, nil)
+	if err == nil {
+		client.Close()
+		t.Fatal("expected an error connecting to an unreachable broker")
+	}
+	if client != nil {
+		t.Error("expected a nil client on error")
+	}
+}
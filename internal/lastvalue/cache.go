@@ -0,0 +1,82 @@
+// Package lastvalue provides a cache of the most recent reading from each sensor,
+// served over HTTP. It lets a minimal deployment (without the aggregator)
+// answer "what is sensor N reading right now?".
+package lastvalue
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// Cache holds the latest reading per sensor ID. It is safe for concurrent use.
+type Cache struct {
+	mu     sync.RWMutex
+	latest map[int]model.SensorData
+}
+
+// New creates and returns an empty Cache.
+func New() *Cache {
+	return &Cache{latest: make(map[int]model.SensorData)}
+}
+
+// Put records data as the latest reading from its sensor, replacing any earlier one.
+func (c *Cache) Put(data model.SensorData) {
+	c.mu.Lock()
+	c.latest[data.ID] = data
+	c.mu.Unlock()
+}
+
+// Get returns the latest reading from sensor id, and whether there is one.
+func (c *Cache) Get(id int) (model.SensorData, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	data, ok := c.latest[id]
+	return data, ok
+}
+
+// Len returns the number of sensors with a cached reading.
+func (c *Cache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return len(c.latest)
+}
+
+// Tap caches every reading received on in and forwards it to out, unchanged.
+// It returns once in is closed, closing out, so it can sit between the sensors and their consumers.
+func (c *Cache) Tap(in <-chan model.SensorData, out chan<- model.SensorData) {
+	defer close(out)
+
+	for data := range in {
+		c.Put(data)
+		out <- data
+	}
+}
+
+// Handler returns an HTTP handler serving `GET /latest/{id}`,
+// which responds with the JSON-encoded latest reading from sensor id, or 404 if there is none.
+func (c *Cache) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /latest/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(r.PathValue("id"))
+		if err != nil {
+			http.Error(w, "invalid sensor id", http.StatusBadRequest)
+			return
+		}
+
+		data, ok := c.Get(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(data)
+	})
+	return mux
+}
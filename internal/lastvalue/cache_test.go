@@ -0,0 +1,107 @@
+// Package lastvalue_test contains tests for the lastvalue package.
+package lastvalue_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/lastvalue"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// TestCache_Put verifies the last write wins per sensor, independently of other sensors.
+func TestCache_Put(t *testing.T) {
+	t.Parallel()
+
+	c := lastvalue.New()
+	c.Put(model.SensorData{ID: 1, Value: 0.1})
+	c.Put(model.SensorData{ID: 2, Value: 0.2})
+	c.Put(model.SensorData{ID: 1, Value: 0.3})
+
+	if got := c.Len(); got != 2 {
+		t.Errorf("expected 2 cached sensors, got %d", got)
+	}
+	if data, ok := c.Get(1); !ok || data.Value != 0.3 {
+		t.Errorf("expected sensor 1 to read 0.3, got %v (found: %v)", data.Value, ok)
+	}
+	if data, ok := c.Get(2); !ok || data.Value != 0.2 {
+		t.Errorf("expected sensor 2 to read 0.2, got %v (found: %v)", data.Value, ok)
+	}
+	if _, ok := c.Get(3); ok {
+		t.Error("expected no reading for sensor 3")
+	}
+}
+
+// TestCache_Tap verifies readings are cached and forwarded, and the output is closed with the input.
+func TestCache_Tap(t *testing.T) {
+	t.Parallel()
+
+	c := lastvalue.New()
+	in := make(chan model.SensorData, 2)
+	out := make(chan model.SensorData, 2)
+	in <- model.SensorData{ID: 1, Value: 0.1}
+	in <- model.SensorData{ID: 1, Value: 0.2}
+	close(in)
+
+	go c.Tap(in, out)
+
+	var forwarded int
+	for range out {
+		forwarded++
+	}
+	if forwarded != 2 {
+		t.Errorf("expected 2 forwarded readings, got %d", forwarded)
+	}
+	if data, _ := c.Get(1); data.Value != 0.2 {
+		t.Errorf("expected sensor 1 to read 0.2, got %v", data.Value)
+	}
+}
+
+// TestCache_Handler verifies the handler serves the latest reading for known sensors,
+// and 404 for unknown ones.
+func TestCache_Handler(t *testing.T) {
+	t.Parallel()
+
+	c := lastvalue.New()
+	ts := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.Put(model.SensorData{ID: 42, Value: 0.5, Timestamp: ts})
+	c.Put(model.SensorData{ID: 42, Value: 0.7, Timestamp: ts.Add(time.Second)})
+	h := c.Handler()
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"known sensor", "/latest/42", http.StatusOK},
+		{"unknown sensor", "/latest/7", http.StatusNotFound},
+		{"invalid id", "/latest/abc", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rec.Code)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var data model.SensorData
+			if err := json.NewDecoder(rec.Body).Decode(&data); err != nil {
+				t.Fatalf("failed to decode response: %v", err)
+			}
+			if data.ID != 42 || data.Value != 0.7 || !data.Timestamp.Equal(ts.Add(time.Second)) {
+				t.Errorf("expected the latest reading from sensor 42, got %+v", data)
+			}
+		})
+	}
+}
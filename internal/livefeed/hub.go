@@ -0,0 +1,210 @@
+// Package livefeed streams live readings to browsers over WebSocket,
+// e.g. for a dashboard demo that plots them as they're generated.
+package livefeed
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+const (
+	// writeTimeout bounds writing a single message to a client.
+	writeTimeout = 5 * time.Second
+	// maxReadBytes bounds the messages read from clients, which are only read to notice them hanging up.
+	maxReadBytes = 512
+)
+
+// client is a connected WebSocket client, and the queue of messages waiting to be written to it.
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+}
+
+// Hub fans readings out to every connected WebSocket client, as JSON text messages.
+// Each client has its own send queue, and is disconnected if it falls so far behind that the queue fills up,
+// so one stuck client can't hold back the others (or the readings' other consumers).
+// It is safe for concurrent use.
+type Hub struct {
+	mu          sync.Mutex
+	clients     map[*client]struct{}
+	closed      bool
+	clientQueue int
+	upgrader    websocket.Upgrader
+	metrics     *metrics.Metrics
+	logger      *slog.Logger
+}
+
+// New creates a Hub queuing up to clientQueue messages per client before disconnecting it.
+func New(clientQueue int, m *metrics.Metrics, l *slog.Logger) *Hub {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	return &Hub{
+		clients:     make(map[*client]struct{}),
+		clientQueue: max(clientQueue, 1),
+		// The feed is read-only and carries no credentials, so any origin may connect (e.g. a dashboard served from a file).
+		upgrader: websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }},
+		metrics:  m,
+		logger:   l.With("component", "livefeed"),
+	}
+}
+
+// Clients returns the number of connected clients.
+func (h *Hub) Clients() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.clients)
+}
+
+// Broadcast queues data for every connected client, disconnecting those whose queue is full.
+func (h *Hub) Broadcast(data model.SensorData) {
+	msg, err := json.Marshal(data)
+	if err != nil {
+		h.logger.Warn("Failed to marshal reading", "sensor_id", data.ID, "error", err)
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		select {
+		case c.send <- msg:
+		default:
+			h.logger.Warn("Disconnecting slow WebSocket client", "remote_addr", c.conn.RemoteAddr().String())
+			h.removeLocked(c)
+			c.conn.Close() // Unblocks a write the client is stuck on.
+		}
+	}
+}
+
+// Tap broadcasts every reading received on in and forwards it to out, unchanged.
+// It returns once in is closed, closing out and disconnecting every client,
+// so it can sit between the sensors and their consumers.
+func (h *Hub) Tap(in <-chan model.SensorData, out chan<- model.SensorData) {
+	defer close(out)
+	defer h.Close()
+
+	for data := range in {
+		h.Broadcast(data)
+		out <- data
+	}
+}
+
+// Close disconnects every client, once the messages already queued for them are written.
+// Later connection attempts are rejected with a 503.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for c := range h.clients {
+		h.removeLocked(c)
+	}
+}
+
+// Handler returns an http.Handler serving `GET /ws`, which upgrades the connection to a WebSocket
+// streaming each reading as a JSON SensorData text message.
+func (h *Hub) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /ws", h.handleWS)
+	return mux
+}
+
+// handleWS handles `GET /ws`, serving the client until it hangs up or is disconnected.
+func (h *Hub) handleWS(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	closed := h.closed
+	h.mu.Unlock()
+	if closed {
+		http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader has already responded with the error.
+		h.logger.Debug("Failed to upgrade WebSocket connection", "remote_addr", r.RemoteAddr, "error", err)
+		return
+	}
+
+	c := &client{conn: conn, send: make(chan []byte, h.clientQueue)}
+	if !h.add(c) {
+		conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, "shutting down"), time.Now().Add(writeTimeout))
+		conn.Close()
+		return
+	}
+	h.logger.Debug("WebSocket client connected", "remote_addr", r.RemoteAddr)
+
+	go h.write(c)
+
+	// Clients aren't expected to send anything; reading just processes control frames and notices them hanging up.
+	conn.SetReadLimit(maxReadBytes)
+	for {
+		if _, _, err := conn.NextReader(); err != nil {
+			break
+		}
+	}
+
+	h.remove(c)
+	h.logger.Debug("WebSocket client disconnected", "remote_addr", r.RemoteAddr)
+}
+
+// write writes the messages queued for c until its queue is closed, then closes the connection.
+func (h *Hub) write(c *client) {
+	defer c.conn.Close()
+
+	for msg := range c.send {
+		c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+		if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+			h.remove(c)
+			return
+		}
+	}
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(writeTimeout))
+}
+
+// add registers c, unless the hub is closed. It reports whether c was added.
+func (h *Hub) add(c *client) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return false
+	}
+	h.clients[c] = struct{}{}
+	if h.metrics != nil {
+		h.metrics.WebSocketClients.Inc()
+	}
+	return true
+}
+
+// remove unregisters c, if it's still registered.
+func (h *Hub) remove(c *client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.removeLocked(c)
+}
+
+// removeLocked unregisters c, if it's still registered, closing its queue. The caller must hold h.mu.
+func (h *Hub) removeLocked(c *client) {
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	delete(h.clients, c)
+	close(c.send)
+	if h.metrics != nil {
+		h.metrics.WebSocketClients.Dec()
+	}
+}
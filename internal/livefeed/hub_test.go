@@ -0,0 +1,143 @@
+// Package livefeed_test contains tests for the livefeed package.
+package livefeed_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/livefeed"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// dial connects a WebSocket client to the hub served by srv.
+func dial(t *testing.T, srv *httptest.Server) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws", nil)
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// waitForClients waits until hub has want connected clients.
+func waitForClients(t *testing.T, hub *livefeed.Hub, want int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for hub.Clients() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d clients, got %d", want, hub.Clients())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestHub_Tap verifies tapped readings are streamed to every client and forwarded,
+// and clients are disconnected once the input is closed.
+func TestHub_Tap(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	hub := livefeed.New(16, m, nil)
+	srv := httptest.NewServer(hub.Handler())
+	defer srv.Close()
+
+	conns := []*websocket.Conn{dial(t, srv), dial(t, srv)}
+	waitForClients(t, hub, 2)
+	if got := testutil.ToFloat64(m.WebSocketClients); got != 2 {
+		t.Errorf("expected the clients gauge to read 2, got %v", got)
+	}
+
+	in := make(chan model.SensorData, 1)
+	out := make(chan model.SensorData, 1)
+	go hub.Tap(in, out)
+
+	in <- model.SensorData{ID: 7, Value: 0.5}
+	if data := <-out; data.ID != 7 {
+		t.Errorf("expected sensor 7's reading to be forwarded, got %+v", data)
+	}
+	for i, conn := range conns {
+		var data model.SensorData
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if err := conn.ReadJSON(&data); err != nil {
+			t.Fatalf("client %d: failed to read reading: %v", i, err)
+		}
+		if data.ID != 7 || data.Value != 0.5 {
+			t.Errorf("client %d: expected sensor 7 reading 0.5, got %+v", i, data)
+		}
+	}
+
+	close(in)
+	if _, ok := <-out; ok {
+		t.Error("expected the output to be closed with the input")
+	}
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Errorf("client %d: expected a normal close, got %v", i, err)
+		}
+	}
+	if got := hub.Clients(); got != 0 {
+		t.Errorf("expected no clients after closing, got %d", got)
+	}
+
+	// Connections after the hub is closed are rejected.
+	resp, err := http.Get(srv.URL + "/ws")
+	if err != nil {
+		t.Fatalf("failed to request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d once closed, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+}
+
+// TestHub_SlowClient verifies a client that stops reading is disconnected, without holding back the others.
+func TestHub_SlowClient(t *testing.T) {
+	t.Parallel()
+
+	hub := livefeed.New(8, nil, nil)
+	srv := httptest.NewServer(hub.Handler())
+	defer srv.Close()
+	defer hub.Close()
+
+	dial(t, srv) // Never reads.
+	fast := dial(t, srv)
+	waitForClients(t, hub, 2)
+
+	// Keep the fast client reading, while broadcasting until the slow one's socket buffers and queue fill up.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := fast.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	deadline := time.Now().Add(10 * time.Second)
+	data := model.SensorData{ID: 1, Model: strings.Repeat("x", 4096)}
+	for hub.Clients() == 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the slow client to be disconnected")
+		}
+		hub.Broadcast(data)
+		time.Sleep(100 * time.Microsecond) // Paced so the fast client keeps up.
+	}
+	if got := hub.Clients(); got != 1 {
+		t.Errorf("expected the fast client to stay connected, got %d clients", got)
+	}
+
+	hub.Close()
+	<-done
+}
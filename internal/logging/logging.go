@@ -2,13 +2,97 @@
 package logging
 
 import (
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+// Log output formats.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Options configures a logger created by NewLogger.
+type Options struct {
+	// Level is the minimum level logged (slog.LevelInfo if zero).
+	Level slog.Level
+	// Format is FormatJSON (the default, if empty) or FormatText, which is easier to read locally.
+	Format string
+	// Output is where logs are written (os.Stdout if nil).
+	Output io.Writer
+}
+
+// NewLogger returns a slog.Logger configured by opts.
+// It returns an error if opts.Format is unknown.
+func NewLogger(opts Options) (*slog.Logger, error) {
+	w := opts.Output
+	if w == nil {
+		w = os.Stdout
+	}
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+
+	switch opts.Format {
+	case FormatJSON, "":
+		return slog.New(slog.NewJSONHandler(w, handlerOpts)), nil
+	case FormatText:
+		return slog.New(slog.NewTextHandler(w, handlerOpts)), nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q: must be %s or %s", opts.Format, FormatJSON, FormatText)
+	}
+}
+
+// ParseLevel parses a log level name (debug, info, warn or error, case-insensitively),
+// optionally with an offset (e.g. "info+2").
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf("invalid log level %q: must be debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+// Default log file rotation settings.
+const (
+	DefaultMaxSizeMB  = 100
+	DefaultMaxBackups = 5
+	DefaultMaxAgeDays = 28
 )
 
+// FileOptions configures a rotating log file.
+type FileOptions struct {
+	Path string
+	// MaxSizeMB is the size, in megabytes, at which the file is rotated.
+	MaxSizeMB int
+	// MaxBackups is how many rotated files are kept (0 keeps them all).
+	MaxBackups int
+	// MaxAgeDays is how many days rotated files are kept for (0 keeps them regardless of age).
+	MaxAgeDays int
+}
+
+// NewFileWriter returns a writer, for Options.Output, that appends to the log file at opts.Path
+// (creating it and its directory if needed), rotating it once it reaches opts.MaxSizeMB.
+// The writer should be closed once logging is done.
+func NewFileWriter(opts FileOptions) io.WriteCloser {
+	return &lumberjack.Logger{
+		Filename:   opts.Path,
+		MaxSize:    opts.MaxSizeMB,
+		MaxBackups: opts.MaxBackups,
+		MaxAge:     opts.MaxAgeDays,
+	}
+}
+
 // NewJSONLogger returns a slog.Logger configured for JSON output.
 func NewJSONLogger() *slog.Logger {
-	return slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{
-		Level: slog.LevelInfo,
-	}))
+	return NewJSONLoggerTo(os.Stdout)
+}
+
+// NewJSONLoggerTo returns a slog.Logger configured for JSON output to w
+// (e.g. a file, when stdout is taken by the terminal dashboard).
+func NewJSONLoggerTo(w io.Writer) *slog.Logger {
+	logger, _ := NewLogger(Options{Level: slog.LevelInfo, Format: FormatJSON, Output: w}) // The JSON format is always valid.
+	return logger
 }
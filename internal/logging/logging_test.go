@@ -0,0 +1,94 @@
+// Package logging_test contains tests for the logging package.
+package logging_test
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/logging"
+)
+
+// TestParseLevel verifies level names parse case-insensitively, and unknown ones are an error.
+func TestParseLevel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		want    slog.Level
+		wantErr bool
+	}{
+		{in: "debug", want: slog.LevelDebug},
+		{in: "INFO", want: slog.LevelInfo},
+		{in: "warn", want: slog.LevelWarn},
+		{in: "error", want: slog.LevelError},
+		{in: "verbose", wantErr: true},
+		{in: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := logging.ParseLevel(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseLevel(%q): expected %v (error: %t), got %v, %v", tt.in, tt.want, tt.wantErr, got, err)
+		}
+	}
+}
+
+// TestNewLogger verifies the logger writes in the configured format, filtering records below its level.
+func TestNewLogger(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{logging.FormatJSON, `"msg":"shown"`},
+		{logging.FormatText, `msg=shown`},
+	}
+
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		logger, err := logging.NewLogger(logging.Options{Level: slog.LevelWarn, Format: tt.format, Output: &buf})
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.format, err)
+		}
+
+		logger.Info("hidden")
+		logger.Warn("shown")
+		if out := buf.String(); !strings.Contains(out, tt.want) || strings.Contains(out, "hidden") {
+			t.Errorf("%s: expected only the warning, formatted with %s, got %q", tt.format, tt.want, out)
+		}
+	}
+
+	if _, err := logging.NewLogger(logging.Options{Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+// TestNewFileWriter verifies logs are written to the configured file, creating its directory.
+func TestNewFileWriter(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "logs", "sim.log")
+	w := logging.NewFileWriter(logging.FileOptions{Path: path, MaxSizeMB: 1})
+	logger, err := logging.NewLogger(logging.Options{Output: w})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	logger.Info("persisted")
+	if err := w.Close(); err != nil {
+		t.Fatalf("failed to close the log file: %v", err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read the log file: %v", err)
+	}
+	if !strings.Contains(string(contents), `"msg":"persisted"`) {
+		t.Errorf("expected the log file to contain the record, got %q", contents)
+	}
+}
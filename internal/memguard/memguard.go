@@ -0,0 +1,144 @@
+// Package memguard provides a soft memory cap for long unattended runs.
+// A Monitor periodically samples heap usage and, while it's over the configured limit,
+// reports memory pressure so components can shed load.
+package memguard
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+)
+
+const (
+	// DefaultInterval is how often heap usage is sampled by default.
+	DefaultInterval = 5 * time.Second
+	// recoveryRatio is the fraction of the limit heap usage must drop below for pressure to be relieved.
+	// The gap keeps usage hovering around the limit from flapping the shedding hooks.
+	recoveryRatio = 0.9
+)
+
+// Hook is called when memory pressure starts (underPressure is true) or is relieved (false).
+type Hook func(underPressure bool)
+
+// Config configures a Monitor.
+type Config struct {
+	// Limit is the heap size, in bytes, above which the monitor reports memory pressure.
+	Limit uint64
+	// Interval is how often heap usage is sampled. Non-positive values use DefaultInterval.
+	Interval time.Duration
+	// ReadHeap returns the current heap size in bytes. Defaults to runtime.MemStats' HeapAlloc.
+	ReadHeap func() uint64
+}
+
+// Monitor watches heap usage and fires its hooks when memory pressure starts or is relieved.
+type Monitor struct {
+	cfg     Config
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu            sync.Mutex
+	hooks         []Hook
+	underPressure bool
+}
+
+// New creates and returns a new Monitor instance.
+func New(cfg Config, m *metrics.Metrics, l *slog.Logger) *Monitor {
+	if l == nil {
+		l = slog.Default()
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.ReadHeap == nil {
+		cfg.ReadHeap = readHeap
+	}
+
+	return &Monitor{
+		cfg:     cfg,
+		metrics: m,
+		logger:  l.With("component", "memguard"),
+	}
+}
+
+// readHeap returns the bytes of allocated heap objects.
+func readHeap() uint64 {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	return ms.HeapAlloc
+}
+
+// OnPressure registers a hook to shed load while under memory pressure.
+func (mon *Monitor) OnPressure(h Hook) {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	mon.hooks = append(mon.hooks, h)
+}
+
+// UnderPressure reports whether heap usage is currently over the limit.
+func (mon *Monitor) UnderPressure() bool {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+
+	return mon.underPressure
+}
+
+// Run samples heap usage every interval until ctx is canceled.
+func (mon *Monitor) Run(ctx context.Context) {
+	mon.logger.Info("Memory guard starting", "limit_bytes", mon.cfg.Limit, "interval", mon.cfg.Interval)
+
+	ticker := time.NewTicker(mon.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mon.Check()
+		}
+	}
+}
+
+// Check samples heap usage once, firing the hooks if memory pressure started or was relieved.
+func (mon *Monitor) Check() {
+	heap := mon.cfg.ReadHeap()
+
+	mon.mu.Lock()
+	was := mon.underPressure
+	switch {
+	case !was && heap > mon.cfg.Limit:
+		mon.underPressure = true
+	case was && float64(heap) < float64(mon.cfg.Limit)*recoveryRatio:
+		mon.underPressure = false
+	}
+	now := mon.underPressure
+	hooks := mon.hooks
+	mon.mu.Unlock()
+
+	if now == was {
+		return
+	}
+
+	if now {
+		mon.logger.Warn("Memory pressure, shedding load", "heap_bytes", heap, "limit_bytes", mon.cfg.Limit)
+	} else {
+		mon.logger.Info("Memory pressure relieved", "heap_bytes", heap, "limit_bytes", mon.cfg.Limit)
+	}
+
+	if mon.metrics != nil {
+		if now {
+			mon.metrics.MemoryPressure.Set(1)
+		} else {
+			mon.metrics.MemoryPressure.Set(0)
+		}
+	}
+
+	for _, h := range hooks {
+		h(now)
+	}
+}
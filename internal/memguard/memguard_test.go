@@ -0,0 +1,68 @@
+// Package memguard_test contains tests for the memguard package.
+package memguard_test
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/memguard"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+)
+
+// TestMonitor_Check verifies the shedding hooks fire once when heap usage crosses the limit,
+// and once more when it drops back below the recovery threshold.
+func TestMonitor_Check(t *testing.T) {
+	t.Parallel()
+
+	const limit = 1000
+	var heap uint64
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	mon := memguard.New(memguard.Config{
+		Limit:    limit,
+		ReadHeap: func() uint64 { return heap },
+	}, m, nil)
+
+	var calls []bool
+	mon.OnPressure(func(underPressure bool) {
+		calls = append(calls, underPressure)
+	})
+
+	steps := []struct {
+		heap         uint64
+		wantPressure bool
+		wantCalls    int
+	}{
+		{500, false, 0},
+		{1500, true, 1}, // Over the limit.
+		{2000, true, 1}, // Still over: no repeat.
+		{950, true, 1},  // Under the limit, but not under the recovery threshold.
+		{800, false, 2}, // Relieved.
+		{900, false, 2},
+	}
+
+	for i, step := range steps {
+		heap = step.heap
+		mon.Check()
+
+		if got := mon.UnderPressure(); got != step.wantPressure {
+			t.Errorf("step %d (heap %d): expected pressure %v, got %v", i, step.heap, step.wantPressure, got)
+		}
+		if len(calls) != step.wantCalls {
+			t.Fatalf("step %d (heap %d): expected %d hook calls, got %d", i, step.heap, step.wantCalls, len(calls))
+		}
+
+		wantGauge := 0.0
+		if step.wantPressure {
+			wantGauge = 1
+		}
+		if got := testutil.ToFloat64(m.MemoryPressure); got != wantGauge {
+			t.Errorf("step %d (heap %d): expected memory pressure gauge %v, got %v", i, step.heap, wantGauge, got)
+		}
+	}
+
+	if !calls[0] || calls[1] {
+		t.Errorf("expected hooks to be called with true then false, got %v", calls)
+	}
+}
@@ -1,31 +1,108 @@
 package metrics
 
 import (
+	"errors"
+	"fmt"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/collectors"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/buildinfo"
 )
 
 const namespace = "iot_simulator"
 
 // Metrics holds all Prometheus collectors for the application.
 type Metrics struct {
-	ActiveSensors        prometheus.Gauge
-	MessagesSent         *prometheus.CounterVec
-	GeneratedValues      *prometheus.HistogramVec
-	SensorRestarts       *prometheus.CounterVec
-	MessagesReceived     prometheus.Counter
-	NATSPublishSuccess   *prometheus.CounterVec
-	NATSPublishFailures  *prometheus.CounterVec
-	NATSPublishLatency   *prometheus.HistogramVec
-	NATSConnectionStatus prometheus.Gauge
+	ActiveSensors          *prometheus.GaugeVec
+	PausedSensors          prometheus.Gauge
+	MessagesSent           *prometheus.CounterVec
+	MessagesDropped        *prometheus.CounterVec
+	MessagesByModel        *prometheus.CounterVec
+	GeneratedValues        *prometheus.HistogramVec
+	ValueClamped           *prometheus.CounterVec
+	NonFiniteValues        *prometheus.CounterVec
+	SensorFaults           *prometheus.CounterVec
+	SensorDrift            *prometheus.GaugeVec
+	SensorRestarts         *prometheus.CounterVec
+	SensorGaveUp           *prometheus.CounterVec
+	SensorShutdownTimeouts prometheus.Counter
+	ThrottledDuration      prometheus.Counter
+	MessagesReceived       prometheus.Counter
+	MessageQueueAgeSeconds *prometheus.HistogramVec
+	OutOfOrderReadings     prometheus.Counter
+	AnomaliesDetected      *prometheus.CounterVec
+	StaleSensors           prometheus.Gauge
+	AggregatorDegraded     prometheus.Gauge
+	ChannelDepth           *prometheus.GaugeVec
+	ChannelCapacity        *prometheus.GaugeVec
+	NATSPublishSuccess     *prometheus.CounterVec
+	NATSPublishFailures    *prometheus.CounterVec
+	NATSPublishRetries     *prometheus.CounterVec
+	NATSPublishLatency     *prometheus.HistogramVec
+	NATSBytesPublished     *prometheus.CounterVec
+	NATSConnectionStatus   prometheus.Gauge
+	BufferedMessages       prometheus.Gauge
+	DeadLetteredMessages   prometheus.Counter
+	CompressionRatio       prometheus.Histogram
+	BridgeRecordsWritten   prometheus.Counter
+	BridgeWriteFailures    prometheus.Counter
+	SinkDropped            *prometheus.CounterVec
+	IngestRequests         *prometheus.CounterVec
+	WebSocketClients       prometheus.Gauge
+	CSVWriteErrors         prometheus.Counter
+	ParquetWriteErrors     prometheus.Counter
+	MemoryPressure         prometheus.Gauge
+	ConfigInfo             *prometheus.GaugeVec
+	BuildInfo              *prometheus.GaugeVec
+}
+
+// ConfigInfo is the resolved, non-sensitive configuration exported by the config info metric,
+// so dashboards can group instances by configuration.
+type ConfigInfo struct {
+	SensorCount    int
+	SensorInterval time.Duration
+	// Broker is the message broker readings are published to, e.g. "nats" (or "none").
+	Broker string
+	// Encoding is the encoding of published readings, e.g. "json".
+	Encoding string
+}
+
+// labels returns info's label values, in the config info metric's label order.
+func (info ConfigInfo) labels() []string {
+	return []string{sensorCountBucket(info.SensorCount), info.SensorInterval.String(), info.Broker, info.Encoding}
+}
+
+// sensorCountBucket buckets a sensor count by order of magnitude (e.g. "100-999"),
+// so instances with similar fleet sizes group together without a label per exact count.
+func sensorCountBucket(n int) string {
+	if n <= 0 {
+		return "0"
+	}
+
+	lower := 1
+	for ; lower <= n/10; lower *= 10 {
+	}
+	if lower >= 100_000 {
+		return "100000+"
+	}
+	return fmt.Sprintf("%d-%d", lower, lower*10-1)
 }
 
-func NewMetrics(reg prometheus.Registerer) *Metrics {
+// NewMetrics creates the application's metrics and registers them with reg.
+// The config info metric is set to 1 for info, and the build info metric to 1 for the running build.
+func NewMetrics(reg prometheus.Registerer, info ConfigInfo) *Metrics {
 	m := &Metrics{
-		ActiveSensors: prometheus.NewGauge(prometheus.GaugeOpts{
+		ActiveSensors: prometheus.NewGaugeVec(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "active_sensors",
-			Help:      "The current number of active sensor goroutines.",
+			Help:      "The current number of active sensor goroutines, by sensor profile.",
+		}, []string{"profile"}),
+		PausedSensors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "paused_sensors",
+			Help:      "The current number of sensors waiting to be resumed by the runtime controller.",
 		}),
 		MessagesSent: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
@@ -33,6 +110,18 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Name:      "messages_sent_total",
 			Help:      "Total number of messages sent by each sensor.",
 		}, []string{"sensor_id"}),
+		MessagesDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "messages_dropped_total",
+			Help:      "Total number of messages each sensor dropped because its data channel was full.",
+		}, []string{"sensor_id"}),
+		MessagesByModel: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "messages_by_model_total",
+			Help:      "Total number of messages sent, by device model and firmware version.",
+		}, []string{"model", "firmware_version"}),
 		GeneratedValues: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: namespace,
 			Subsystem: "sensor",
@@ -40,18 +129,100 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Help:      "Distribution of values generated by sensors.",
 			Buckets:   prometheus.LinearBuckets(0, 0.1, 10),
 		}, []string{"sensor_id"}),
+		ValueClamped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "values_clamped_total",
+			Help:      "Total number of generated values clamped into their sensor's range.",
+		}, []string{"sensor_id"}),
+		NonFiniteValues: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "non_finite_values_total",
+			Help:      "Total number of generated values skipped for being NaN or infinite.",
+		}, []string{"sensor_id"}),
+		SensorFaults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "faults_total",
+			Help:      "Total number of faults injected into sensor readings, by fault mode.",
+		}, []string{"sensor_id", "fault"}),
+		SensorDrift: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "drift",
+			Help:      "The bias each drifting sensor's values are currently offset by.",
+		}, []string{"sensor_id"}),
 		SensorRestarts: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Subsystem: "sensor",
 			Name:      "restarts_total",
 			Help:      "Total number of times a sensor has been restarted after a panic.",
 		}, []string{"sensor_id"}),
+		SensorGaveUp: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "gave_up_total",
+			Help:      "Total number of times a sensor was stopped for good after panicking more than its maximum restarts.",
+		}, []string{"sensor_id"}),
+		SensorShutdownTimeouts: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "shutdown_timeouts_total",
+			Help:      "Total number of sensors that did not stop within the shutdown grace period.",
+		}),
+		ThrottledDuration: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sensor",
+			Name:      "throttled_seconds_total",
+			Help:      "Total time sensors spent waiting on the fleet-wide rate limiter before sending a reading.",
+		}),
 		MessagesReceived: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: namespace,
 			Subsystem: "aggregator",
 			Name:      "messages_received_total",
 			Help:      "Total number of messages received by the aggregator.",
 		}),
+		MessageQueueAgeSeconds: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "message_queue_age_seconds",
+			Help:      "How long readings waited in the data channel before being dequeued, by consumer.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10), // 100µs to ~26s
+		}, []string{"consumer"}),
+		OutOfOrderReadings: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "aggregator",
+			Name:      "out_of_order_readings_total",
+			Help:      "Total number of readings with a timestamp earlier than the previous reading from the same sensor.",
+		}),
+		AnomaliesDetected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "aggregator",
+			Name:      "anomalies_detected_total",
+			Help:      "Total number of readings further from their sensor's running mean than the z-score threshold, by sensor.",
+		}, []string{"sensor_id"}),
+		StaleSensors: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "aggregator",
+			Name:      "stale_sensors",
+			Help:      "Number of sensors whose latest reading is older than the aggregator's staleness threshold, as of its last summary.",
+		}),
+		AggregatorDegraded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "aggregator",
+			Name:      "degraded",
+			Help:      "Whether the aggregator is falling behind the readings, and computing percentiles less often than every summary (1) or not (0).",
+		}),
+		ChannelDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "channel_depth",
+			Help:      "Number of readings buffered in a channel, sampled periodically, by channel.",
+		}, []string{"channel"}),
+		ChannelCapacity: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "channel_capacity",
+			Help:      "Capacity of a channel's buffer, by channel.",
+		}, []string{"channel"}),
 		NATSPublishSuccess: prometheus.NewCounterVec(prometheus.CounterOpts{
 			Namespace: namespace,
 			Subsystem: "nats",
@@ -64,6 +235,12 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Name:      "publish_failures_total",
 			Help:      "Total number of failed message publishes to NATS.",
 		}, []string{"sensor_id", "error_type"}),
+		NATSPublishRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "nats",
+			Name:      "publish_retries_total",
+			Help:      "Total number of retried message publishes to NATS.",
+		}, []string{"sensor_id"}),
 		NATSPublishLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Namespace: namespace,
 			Subsystem: "nats",
@@ -71,31 +248,166 @@ func NewMetrics(reg prometheus.Registerer) *Metrics {
 			Help:      "Latency of publishing messages to NATS in seconds.",
 			Buckets:   prometheus.ExponentialBuckets(0.001, 2, 10), // 1ms to ~1s
 		}, []string{"sensor_id"}),
+		NATSBytesPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "nats",
+			Name:      "published_bytes_total",
+			Help:      "Total encoded payload bytes of messages successfully published to NATS, by device model.",
+		}, []string{"model"}),
 		NATSConnectionStatus: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Subsystem: "nats",
 			Name:      "connection_status",
 			Help:      "Nats connection status (1 = connected, 0 = disconnected).",
 		}),
+		BufferedMessages: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "publisher",
+			Name:      "buffered_messages",
+			Help:      "Number of messages held in the publisher's reconnect buffer, waiting for NATS to reconnect.",
+		}),
+		DeadLetteredMessages: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "publisher",
+			Name:      "dead_lettered_messages_total",
+			Help:      "Total number of messages that failed to publish and were forwarded to the dead-letter subject.",
+		}),
+		CompressionRatio: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "publisher",
+			Name:      "compression_ratio",
+			Help:      "Compressed size of compressed payloads, as a fraction of their original size.",
+			Buckets:   prometheus.LinearBuckets(0.1, 0.1, 10), // 0.1 to 1
+		}),
+		BridgeRecordsWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "bridge",
+			Name:      "records_written_total",
+			Help:      "Total number of consumed records written to the bridge sink (and flushed), before being acked.",
+		}),
+		BridgeWriteFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "bridge",
+			Name:      "write_failures_total",
+			Help:      "Total number of consumed records the bridge sink failed to write.",
+		}),
+		SinkDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "sink",
+			Name:      "dropped_total",
+			Help:      "Total number of records dropped because a sink's queue was full, by sink.",
+		}, []string{"sink"}),
+		IngestRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ingest",
+			Name:      "requests_total",
+			Help:      "Total number of requests to the HTTP ingest endpoint, by response status code.",
+		}, []string{"code"}),
+		WebSocketClients: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "websocket",
+			Name:      "clients",
+			Help:      "Current number of WebSocket clients connected to the live feed.",
+		}),
+		CSVWriteErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "csv",
+			Name:      "write_errors_total",
+			Help:      "Total number of failed writes of records to the CSV sink's file.",
+		}),
+		ParquetWriteErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "parquet",
+			Name:      "write_errors_total",
+			Help:      "Total number of failed writes of records to the Parquet sink's files.",
+		}),
+		MemoryPressure: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "memory_pressure",
+			Help:      "Whether heap usage is over the memory guard's limit (1 = shedding load, 0 = normal).",
+		}),
+		ConfigInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_info",
+			Help:      "Always 1, labeled with the simulator's effective configuration (sensor count bucketed by order of magnitude).",
+		}, []string{"sensor_count", "sensor_interval", "broker", "encoding"}),
+		BuildInfo: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "build_info",
+			Help:      "Always 1, labeled with the running build's version, commit and Go version.",
+		}, []string{"version", "commit", "go_version"}),
 	}
 
 	// Register all collectors with the provided registerer.
-	reg.MustRegister(
-		// Custom application metrics
-		m.ActiveSensors,
-		m.MessagesSent,
-		m.GeneratedValues,
-		m.SensorRestarts,
-		m.MessagesReceived,
-		m.NATSPublishSuccess,
-		m.NATSPublishFailures,
-		m.NATSPublishLatency,
-		m.NATSConnectionStatus,
-
-		// Go runtime and process metrics
-		collectors.NewGoCollector(),
-		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
-	)
+	// Collectors already registered (e.g. by an earlier NewMetrics call on the same registerer) are reused.
+	// Custom application metrics
+	m.ActiveSensors = register(reg, m.ActiveSensors)
+	m.PausedSensors = register(reg, m.PausedSensors)
+	m.MessagesSent = register(reg, m.MessagesSent)
+	m.MessagesDropped = register(reg, m.MessagesDropped)
+	m.MessagesByModel = register(reg, m.MessagesByModel)
+	m.GeneratedValues = register(reg, m.GeneratedValues)
+	m.ValueClamped = register(reg, m.ValueClamped)
+	m.NonFiniteValues = register(reg, m.NonFiniteValues)
+	m.SensorFaults = register(reg, m.SensorFaults)
+	m.SensorDrift = register(reg, m.SensorDrift)
+	m.SensorRestarts = register(reg, m.SensorRestarts)
+	m.SensorGaveUp = register(reg, m.SensorGaveUp)
+	m.SensorShutdownTimeouts = register(reg, m.SensorShutdownTimeouts)
+	m.ThrottledDuration = register(reg, m.ThrottledDuration)
+	m.MessagesReceived = register(reg, m.MessagesReceived)
+	m.MessageQueueAgeSeconds = register(reg, m.MessageQueueAgeSeconds)
+	m.OutOfOrderReadings = register(reg, m.OutOfOrderReadings)
+	m.AnomaliesDetected = register(reg, m.AnomaliesDetected)
+	m.StaleSensors = register(reg, m.StaleSensors)
+	m.AggregatorDegraded = register(reg, m.AggregatorDegraded)
+	m.ChannelDepth = register(reg, m.ChannelDepth)
+	m.ChannelCapacity = register(reg, m.ChannelCapacity)
+	m.NATSPublishSuccess = register(reg, m.NATSPublishSuccess)
+	m.NATSPublishFailures = register(reg, m.NATSPublishFailures)
+	m.NATSPublishRetries = register(reg, m.NATSPublishRetries)
+	m.NATSPublishLatency = register(reg, m.NATSPublishLatency)
+	m.NATSBytesPublished = register(reg, m.NATSBytesPublished)
+	m.NATSConnectionStatus = register(reg, m.NATSConnectionStatus)
+	m.BufferedMessages = register(reg, m.BufferedMessages)
+	m.DeadLetteredMessages = register(reg, m.DeadLetteredMessages)
+	m.CompressionRatio = register(reg, m.CompressionRatio)
+	m.BridgeRecordsWritten = register(reg, m.BridgeRecordsWritten)
+	m.BridgeWriteFailures = register(reg, m.BridgeWriteFailures)
+	m.SinkDropped = register(reg, m.SinkDropped)
+	m.IngestRequests = register(reg, m.IngestRequests)
+	m.WebSocketClients = register(reg, m.WebSocketClients)
+	m.CSVWriteErrors = register(reg, m.CSVWriteErrors)
+	m.ParquetWriteErrors = register(reg, m.ParquetWriteErrors)
+	m.MemoryPressure = register(reg, m.MemoryPressure)
+	m.ConfigInfo = register(reg, m.ConfigInfo)
+	m.BuildInfo = register(reg, m.BuildInfo)
+
+	// Go runtime and process metrics
+	register(reg, collectors.NewGoCollector())
+	register(reg, collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}))
+
+	m.ConfigInfo.WithLabelValues(info.labels()...).Set(1)
+	build := buildinfo.Get()
+	m.BuildInfo.WithLabelValues(build.Version, build.Commit, build.GoVersion).Set(1)
 
 	return m
 }
+
+// register registers c with reg and returns it.
+// If an equivalent collector is already registered, that collector is returned instead,
+// so metrics keep accumulating in one place. Any other registration error panics, like MustRegister.
+func register[C prometheus.Collector](reg prometheus.Registerer, c C) C {
+	err := reg.Register(c)
+	if err == nil {
+		return c
+	}
+
+	var are prometheus.AlreadyRegisteredError
+	if errors.As(err, &are) {
+		if existing, ok := are.ExistingCollector.(C); ok {
+			return existing
+		}
+	}
+	panic(err)
+}
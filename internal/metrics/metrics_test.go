@@ -0,0 +1,88 @@
+// Package metrics_test contains tests for the metrics package.
+package metrics_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/buildinfo"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+)
+
+// TestNewMetrics_SameRegistry verifies NewMetrics can be called twice on one registry without panicking,
+// and that both instances share the registered collectors.
+func TestNewMetrics_SameRegistry(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	first := metrics.NewMetrics(reg, metrics.ConfigInfo{})
+
+	var second *metrics.Metrics
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				t.Fatalf("second NewMetrics call panicked: %v", r)
+			}
+		}()
+		second = metrics.NewMetrics(reg, metrics.ConfigInfo{})
+	}()
+
+	first.MessagesReceived.Inc()
+	second.MessagesReceived.Inc()
+	second.ActiveSensors.WithLabelValues("standard").Inc()
+
+	if got := testutil.ToFloat64(first.MessagesReceived); got != 2 {
+		t.Errorf("expected both instances to count into one collector (2), got %v", got)
+	}
+	if got := testutil.ToFloat64(first.ActiveSensors.WithLabelValues("standard")); got != 1 {
+		t.Errorf("expected 1 active sensor through the first instance, got %v", got)
+	}
+	if _, err := reg.Gather(); err != nil {
+		t.Errorf("failed to gather metrics: %v", err)
+	}
+}
+
+// TestNewMetrics_ConfigInfo verifies the config info metric is exported with the configuration's label values.
+func TestNewMetrics_ConfigInfo(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	metrics.NewMetrics(reg, metrics.ConfigInfo{
+		SensorCount:    5000,
+		SensorInterval: 100 * time.Millisecond,
+		Broker:         "nats",
+		Encoding:       "json",
+	})
+
+	want := `
+# HELP iot_simulator_config_info Always 1, labeled with the simulator's effective configuration (sensor count bucketed by order of magnitude).
+# TYPE iot_simulator_config_info gauge
+iot_simulator_config_info{broker="nats",encoding="json",sensor_count="1000-9999",sensor_interval="100ms"} 1
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "iot_simulator_config_info"); err != nil {
+		t.Error(err)
+	}
+}
+
+// TestNewMetrics_BuildInfo verifies the build info metric is exported with the running build's label values.
+func TestNewMetrics_BuildInfo(t *testing.T) {
+	t.Parallel()
+
+	reg := prometheus.NewRegistry()
+	metrics.NewMetrics(reg, metrics.ConfigInfo{})
+
+	build := buildinfo.Get()
+	want := fmt.Sprintf(`
+# HELP iot_simulator_build_info Always 1, labeled with the running build's version, commit and Go version.
+# TYPE iot_simulator_build_info gauge
+iot_simulator_build_info{commit=%q,go_version=%q,version=%q} 1
+`, build.Commit, build.GoVersion, build.Version)
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(want), "iot_simulator_build_info"); err != nil {
+		t.Error(err)
+	}
+}
@@ -3,9 +3,52 @@ package model
 
 import "time"
 
+// SchemaVersion is the current version of the SensorData schema, carried by every emitted record.
+// Bump it whenever SensorData's fields change, so consumers can branch on it during rollouts.
+const SchemaVersion = 4
+
 // SensorData represents a single reading emitted by a simulated sensor.
 type SensorData struct {
-	ID        int
+	// SchemaVersion is the schema version the record was emitted with (see the SchemaVersion constant).
+	SchemaVersion int
+	ID            int
+	// DeviceID is the sensor's external device ID (e.g. a UUID or MAC address).
+	// ID remains the internal index. DeviceID is omitted from JSON when empty.
+	DeviceID string `json:",omitempty"`
+	// Type is the kind of reading (e.g. "temperature") and Unit its unit (e.g. "celsius").
+	// They are omitted from JSON when empty.
+	Type      string `json:",omitempty"`
+	Unit      string `json:",omitempty"`
 	Value     float64
 	Timestamp time.Time
+	// Model and FirmwareVersion identify the emitting device, for fleet segmentation.
+	// They are omitted from JSON when empty.
+	Model           string `json:",omitempty"`
+	FirmwareVersion string `json:",omitempty"`
+	// Tags is arbitrary key/value metadata (e.g. site=north), omitted from JSON when empty.
+	// Readings from the same sensor share one map, so it must not be modified.
+	Tags map[string]string `json:",omitempty"`
+	// Latitude and Longitude are the emitting sensor's position, in decimal degrees.
+	// They are omitted from JSON when zero, e.g. for sensors without a location.
+	Latitude  float64 `json:",omitempty"`
+	Longitude float64 `json:",omitempty"`
+}
+
+// SensorMeta describes a running sensor, for registries enumerating the live fleet.
+type SensorMeta struct {
+	ID int
+	// DeviceID, Type, Unit, Model and FirmwareVersion are as in the sensor's readings,
+	// and are omitted from JSON when empty.
+	DeviceID        string `json:",omitempty"`
+	Type            string `json:",omitempty"`
+	Unit            string `json:",omitempty"`
+	Model           string `json:",omitempty"`
+	FirmwareVersion string `json:",omitempty"`
+	// Interval is the sensor's configured interval between readings.
+	Interval time.Duration
+	// Tags is the sensor's metadata (e.g. site=north), omitted from JSON when empty.
+	Tags map[string]string `json:",omitempty"`
+	// Latitude and Longitude are the sensor's position, omitted from JSON when zero.
+	Latitude  float64 `json:",omitempty"`
+	Longitude float64 `json:",omitempty"`
 }
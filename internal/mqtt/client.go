@@ -0,0 +1,193 @@
+// Package mqtt provides an MQTT client for publishing sensor data,
+// as an alternative to NATS for backends that speak MQTT.
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+const (
+	// DefaultTopicPrefix is the prefix of the topics sensor data is published to, e.g. `iot/sensors/data/42`.
+	DefaultTopicPrefix = "iot/sensors"
+	// disconnectQuiesce is how long Close waits for in-flight work before disconnecting, in milliseconds.
+	disconnectQuiesce = 250
+)
+
+// Client manages the MQTT connection.
+//
+// It publishes NATS-style subjects, so it can stand in for the NATS client:
+// a subject's dot-separated tokens become topic levels, e.g. `iot.sensors.data.42` is published to `iot/sensors/data/42`.
+type Client struct {
+	conn          paho.Client
+	qos           byte
+	subjectPrefix string
+	logger        *slog.Logger
+
+	mu          sync.Mutex // Guards reconnected, which is replaced on every reconnect.
+	reconnected chan struct{}
+}
+
+// Config holds configuration for the MQTT client.
+type Config struct {
+	URL            string
+	ClientID       string
+	TopicPrefix    string
+	QoS            byte
+	ConnectTimeout time.Duration
+}
+
+// DefaultConfig returns a Config with sensible defaults.
+func DefaultConfig() Config {
+	return Config{
+		URL:            "tcp://localhost:1883",
+		ClientID:       "iot-simulator",
+		TopicPrefix:    DefaultTopicPrefix,
+		QoS:            1,
+		ConnectTimeout: 10 * time.Second,
+	}
+}
+
+// Topic returns the MQTT topic a subject is published to, with its dot-separated tokens as topic levels.
+func Topic(subject string) string {
+	return strings.ReplaceAll(subject, ".", "/")
+}
+
+// NormalizeTopicPrefix validates a topic prefix and normalizes it by trimming
+// surrounding whitespace and stray leading or trailing slashes (e.g. "iot/sensors/" becomes "iot/sensors").
+// Prefixes that are empty, contain wildcards, dots, whitespace, or empty levels are rejected,
+// since they would break topic construction.
+func NormalizeTopicPrefix(prefix string) (string, error) {
+	normalized := strings.Trim(strings.TrimSpace(prefix), "/")
+	if normalized == "" {
+		return "", fmt.Errorf("invalid topic prefix %q: prefix is empty", prefix)
+	}
+	if strings.ContainsAny(normalized, "+#") {
+		return "", fmt.Errorf("invalid topic prefix %q: wildcards are not allowed", prefix)
+	}
+	if strings.Contains(normalized, ".") {
+		return "", fmt.Errorf("invalid topic prefix %q: dots are not allowed", prefix)
+	}
+	if strings.ContainsAny(normalized, " \t\r\n") {
+		return "", fmt.Errorf("invalid topic prefix %q: whitespace is not allowed", prefix)
+	}
+	if strings.Contains(normalized, "//") {
+		return "", fmt.Errorf("invalid topic prefix %q: empty levels are not allowed", prefix)
+	}
+	return normalized, nil
+}
+
+// NewClient creates a new MQTT client and establishes a connection.
+// The configured topic prefix is normalized (see NormalizeTopicPrefix) before use.
+// The client reconnects automatically if the connection is lost.
+func NewClient(cfg Config, logger *slog.Logger) (*Client, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger = logger.With("component", "mqtt_client")
+
+	prefix, err := NormalizeTopicPrefix(cfg.TopicPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &Client{
+		qos:           cfg.QoS,
+		subjectPrefix: strings.ReplaceAll(prefix, "/", "."),
+		logger:        logger,
+		reconnected:   make(chan struct{}),
+	}
+
+	opts := paho.NewClientOptions().
+		AddBroker(cfg.URL).
+		SetClientID(cfg.ClientID).
+		SetConnectTimeout(cfg.ConnectTimeout).
+		SetAutoReconnect(true).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			logger.Warn("MQTT disconnected", "error", err)
+		}).
+		SetReconnectingHandler(func(_ paho.Client, _ *paho.ClientOptions) {
+			logger.Info("MQTT reconnecting")
+		}).
+		SetOnConnectHandler(func(_ paho.Client) {
+			client.notifyReconnected()
+		})
+
+	client.conn = paho.NewClient(opts)
+	token := client.conn.Connect()
+	if !token.WaitTimeout(cfg.ConnectTimeout) {
+		client.conn.Disconnect(0)
+		return nil, errors.New("failed to connect to MQTT: timed out")
+	}
+	if err := token.Error(); err != nil {
+		return nil, fmt.Errorf("failed to connect to MQTT: %w", err)
+	}
+
+	logger.Info("Connected to MQTT", "url", cfg.URL)
+	return client, nil
+}
+
+// Publish publishes a message to the topic of the specified subject,
+// waiting for the broker to acknowledge it (at QoS 1 or above) until ctx is done.
+func (c *Client) Publish(ctx context.Context, subject string, data []byte) error {
+	token := c.conn.Publish(Topic(subject), c.qos, false, data)
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// PublishJson publishes a JSON-encoded message to the topic of the specified subject.
+func (c *Client) PublishJson(ctx context.Context, subject string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON: %w", err)
+	}
+	return c.Publish(ctx, subject, data)
+}
+
+// Reconnected returns a channel that is closed the next time the connection is (re-)established,
+// so any number of callers can wait for it. Call it again after each reconnect for the next one.
+func (c *Client) Reconnected() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reconnected
+}
+
+// notifyReconnected wakes the callers waiting on Reconnected.
+func (c *Client) notifyReconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.reconnected)
+	c.reconnected = make(chan struct{})
+}
+
+// Close disconnects from the broker, after waiting briefly for in-flight work.
+func (c *Client) Close() error {
+	if c.conn != nil {
+		c.logger.Info("Closing MQTT connection")
+		c.conn.Disconnect(disconnectQuiesce)
+	}
+	return nil
+}
+
+// IsConnected returns true if the MQTT connection is established.
+func (c *Client) IsConnected() bool {
+	return c.conn != nil && c.conn.IsConnectionOpen()
+}
+
+// SubjectPrefix returns the topic prefix, in the subject form the publisher builds subjects on
+// (e.g. `iot.sensors` for the `iot/sensors` prefix).
+func (c *Client) SubjectPrefix() string {
+	return c.subjectPrefix
+}
@@ -0,0 +1,66 @@
+package mqtt_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/mqtt"
+)
+
+// TestTopic verifies subjects are mapped to topics, with their tokens as topic levels.
+func TestTopic(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"iot.sensors.data.42":             "iot/sensors/data/42",
+		"iot.sensors.data.temperature.42": "iot/sensors/data/temperature/42",
+		"iot":                             "iot",
+	}
+	for subject, want := range tests {
+		if got := mqtt.Topic(subject); got != want {
+			t.Errorf("Topic(%q): expected %q, got %q", subject, want, got)
+		}
+	}
+}
+
+// TestNewClient_InvalidTopicPrefix verifies NewClient rejects topic prefixes that would break topic construction.
+func TestNewClient_InvalidTopicPrefix(t *testing.T) {
+	t.Parallel()
+
+	for _, prefix := range []string{"", "/", "iot/+", "iot/#", "iot.sensors"} {
+		cfg := mqtt.DefaultConfig()
+		cfg.TopicPrefix = prefix
+
+		if client, err := mqtt.NewClient(cfg, nil); err == nil {
+			client.Close()
+			t.Errorf("expected an error for topic prefix %q", prefix)
+		}
+	}
+}
+
+// TestNewClient_Unreachable verifies NewClient returns an error when the broker can't be reached.
+func TestNewClient_Unreachable(t *testing.T) {
+	t.Parallel()
+
+	// Listen on a free port, then close it, so connecting to it is refused.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to reserve a port: %v", err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	cfg := mqtt.DefaultConfig()
+	cfg.URL = "tcp://" + addr
+	cfg.ConnectTimeout = time.Second
+
+	client, err := mqtt.NewClient(cfg, nil)
+	if err == nil {
+		client.Close()
+		t.Fatal("expected an error connecting to an unreachable broker")
+	}
+	if client != nil {
+		t.Error("expected a nil client on error")
+	}
+}
@@ -3,9 +3,12 @@ package nats
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"strings"
+	"sync"
 	"time"
 
 	natsio "github.com/nats-io/nats.go"
@@ -17,13 +20,20 @@ const (
 	DefaultStreamName = "IOT_SENSORS"
 	// DefaultSubjectPrefix is the prefix for all sensor subjects.
 	DefaultSubjectPrefix = "iot.sensors"
+	// DefaultStreamSetupTimeout bounds creating or updating the JetStream stream.
+	DefaultStreamSetupTimeout = 10 * time.Second
 )
 
 // Client manages the NATS connection and JetStream operations.
 type Client struct {
-	conn   *natsio.Conn
-	js     jetstream.JetStream
-	logger *slog.Logger
+	conn          *natsio.Conn
+	js            jetstream.JetStream
+	streamName    string
+	subjectPrefix string
+	logger        *slog.Logger
+
+	mu          sync.Mutex // Guards reconnected, which is replaced on every reconnect.
+	reconnected chan struct{}
 }
 
 // Config holds configuration for the NATS client.
@@ -34,28 +44,88 @@ type Config struct {
 	MaxAge         time.Duration
 	MaxMessages    int64
 	ConnectTimeout time.Duration
+	// Authentication, for secured servers. Only the first set of CredsFile (a JWT and NKey credentials file),
+	// Token, and Username with Password is used, in that order.
+	CredsFile string
+	Token     string
+	Username  string
+	Password  string
+	// TLSConfig, when set, secures the connection with TLS (e.g. with a custom CA, or a client certificate).
+	TLSConfig *tls.Config
+	// Retention, Discard, Replicas and Storage are the stream's retention policy, discard policy
+	// (which messages make way once a limit is reached), replica count, and storage backend.
+	Retention jetstream.RetentionPolicy
+	Discard   jetstream.DiscardPolicy
+	Replicas  int
+	Storage   jetstream.StorageType
+	// StreamSetupTimeout bounds creating or updating the stream on startup.
+	// Large streams on a loaded cluster can need longer. Non-positive values use DefaultStreamSetupTimeout.
+	StreamSetupTimeout time.Duration
 }
 
 // DefaultConfig returns a Config with sensible defaults.
 func DefaultConfig() Config {
 	return Config{
-		URL:            natsio.DefaultURL,
-		StreamName:     DefaultStreamName,
-		SubjectPrefix:  DefaultSubjectPrefix,
-		MaxAge:         24 * time.Hour,
-		MaxMessages:    10_000_000,
-		ConnectTimeout: 10 * time.Second,
+		URL:                natsio.DefaultURL,
+		StreamName:         DefaultStreamName,
+		SubjectPrefix:      DefaultSubjectPrefix,
+		MaxAge:             24 * time.Hour,
+		MaxMessages:        10_000_000,
+		Retention:          jetstream.LimitsPolicy,
+		Discard:            jetstream.DiscardOld,
+		Replicas:           1,
+		Storage:            jetstream.FileStorage,
+		ConnectTimeout:     10 * time.Second,
+		StreamSetupTimeout: DefaultStreamSetupTimeout,
+	}
+}
+
+// NormalizeSubjectPrefix validates a subject prefix and normalizes it by trimming
+// surrounding whitespace and stray leading or trailing dots (e.g. "iot.sensors." becomes "iot.sensors").
+// Prefixes that are empty, contain wildcards, whitespace, or empty tokens are rejected,
+// since they would break subject construction and the stream's subject filter.
+func NormalizeSubjectPrefix(prefix string) (string, error) {
+	normalized := strings.Trim(strings.TrimSpace(prefix), ".")
+	if normalized == "" {
+		return "", fmt.Errorf("invalid subject prefix %q: prefix is empty", prefix)
+	}
+	if strings.ContainsAny(normalized, "*>") {
+		return "", fmt.Errorf("invalid subject prefix %q: wildcards are not allowed", prefix)
+	}
+	if strings.ContainsAny(normalized, " \t\r\n") {
+		return "", fmt.Errorf("invalid subject prefix %q: whitespace is not allowed", prefix)
 	}
+	if strings.Contains(normalized, "..") {
+		return "", fmt.Errorf("invalid subject prefix %q: empty tokens are not allowed", prefix)
+	}
+	return normalized, nil
 }
 
 // NewClient creates a new NATS client, establishes a connection,
 // and configures the JetStream stream.
+// The configured subject prefix is normalized (see NormalizeSubjectPrefix) before use.
 func NewClient(cfg Config, logger *slog.Logger) (*Client, error) {
 	if logger == nil {
 		logger = slog.Default()
 	}
 	logger = logger.With("component", "nats_client")
 
+	prefix, err := NormalizeSubjectPrefix(cfg.SubjectPrefix)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != cfg.SubjectPrefix {
+		logger.Warn("Normalized subject prefix", "configured", cfg.SubjectPrefix, "normalized", prefix)
+	}
+	cfg.SubjectPrefix = prefix
+
+	client := &Client{
+		streamName:    cfg.StreamName,
+		subjectPrefix: cfg.SubjectPrefix,
+		logger:        logger,
+		reconnected:   make(chan struct{}),
+	}
+
 	opts := []natsio.Option{
 		natsio.Name("iot-simulator"),
 		natsio.Timeout(cfg.ConnectTimeout),
@@ -67,9 +137,12 @@ func NewClient(cfg Config, logger *slog.Logger) (*Client, error) {
 		}),
 		natsio.ReconnectHandler(func(nc *natsio.Conn) {
 			logger.Info("NATS reconnected", "url", nc.ConnectedUrl())
+			client.notifyReconnected()
 		}),
 	}
 
+	opts = append(opts, cfg.authOptions()...)
+
 	conn, err := natsio.Connect(cfg.URL, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to NATS: %w", err)
@@ -83,11 +156,8 @@ func NewClient(cfg Config, logger *slog.Logger) (*Client, error) {
 		return nil, fmt.Errorf("failed to create JetStream context: %w", err)
 	}
 
-	client := &Client{
-		conn:   conn,
-		js:     js,
-		logger: logger,
-	}
+	client.conn = conn
+	client.js = js
 
 	// TODO: create or update stream
 	if err := client.configureStream(cfg); err != nil {
@@ -98,19 +168,33 @@ func NewClient(cfg Config, logger *slog.Logger) (*Client, error) {
 	return client, nil
 }
 
+// authOptions returns the connection options authenticating and securing the connection, if configured.
+func (cfg Config) authOptions() []natsio.Option {
+	var opts []natsio.Option
+	switch {
+	case cfg.CredsFile != "":
+		opts = append(opts, natsio.UserCredentials(cfg.CredsFile))
+	case cfg.Token != "":
+		opts = append(opts, natsio.Token(cfg.Token))
+	case cfg.Username != "":
+		opts = append(opts, natsio.UserInfo(cfg.Username, cfg.Password))
+	}
+	if cfg.TLSConfig != nil {
+		opts = append(opts, natsio.Secure(cfg.TLSConfig))
+	}
+	return opts
+}
+
 // configureStream creates or updates the JetStream stream config.
 func (c *Client) configureStream(cfg Config) error {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	timeout := cfg.StreamSetupTimeout
+	if timeout <= 0 {
+		timeout = DefaultStreamSetupTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
-	streamConfig := jetstream.StreamConfig{
-		Name:        cfg.StreamName,
-		Description: "IoT sensor data stream with 24-hour retention",
-		Subjects:    []string{fmt.Sprintf("%s.>", cfg.SubjectPrefix)},
-		MaxAge:      cfg.MaxAge,
-		MaxMsgs:     cfg.MaxMessages,
-		Discard:     jetstream.DiscardOld,
-	}
+	streamConfig := cfg.streamConfig()
 
 	// Try to create stream
 	stream, err := c.js.CreateStream(ctx, streamConfig)
@@ -132,6 +216,8 @@ func (c *Client) configureStream(cfg Config) error {
 		c.logger.Warn("Failed to get stream info", "error", err)
 	} else {
 		c.logger.Info("Stream configured",
+			"storage", info.Config.Storage.String(),
+			"replicas", info.Config.Replicas,
 			"messages", info.State.Msgs,
 			"bytes", info.State.Bytes,
 			"first_seq", info.State.FirstSeq,
@@ -142,12 +228,51 @@ func (c *Client) configureStream(cfg Config) error {
 	return nil
 }
 
+// streamConfig maps cfg to the JetStream stream configuration.
+func (cfg Config) streamConfig() jetstream.StreamConfig {
+	return jetstream.StreamConfig{
+		Name:        cfg.StreamName,
+		Description: fmt.Sprintf("IoT sensor data stream with %v retention", cfg.MaxAge),
+		Subjects:    []string{fmt.Sprintf("%s.>", cfg.SubjectPrefix)},
+		Retention:   cfg.Retention,
+		MaxAge:      cfg.MaxAge,
+		MaxMsgs:     cfg.MaxMessages,
+		Discard:     cfg.Discard,
+		Replicas:    cfg.Replicas,
+		Storage:     cfg.Storage,
+	}
+}
+
+// ParseStorage returns the stream storage type named s: "file" or "memory".
+func ParseStorage(s string) (jetstream.StorageType, error) {
+	switch s {
+	case "file":
+		return jetstream.FileStorage, nil
+	case "memory":
+		return jetstream.MemoryStorage, nil
+	default:
+		return 0, fmt.Errorf("unknown stream storage %q: must be file or memory", s)
+	}
+}
+
 // Publish publishes a message to the specified subject.
 func (c *Client) Publish(ctx context.Context, subject string, data []byte) error {
 	_, err := c.js.Publish(ctx, subject, data)
 	return err
 }
 
+// PublishMsg publishes a message, including its headers.
+func (c *Client) PublishMsg(ctx context.Context, msg *natsio.Msg) error {
+	_, err := c.js.PublishMsg(ctx, msg)
+	return err
+}
+
+// PublishMsgAsync publishes a message without waiting for its ack.
+// The returned future resolves once the server acks (or rejects) the message.
+func (c *Client) PublishMsgAsync(msg *natsio.Msg) (jetstream.PubAckFuture, error) {
+	return c.js.PublishMsgAsync(msg)
+}
+
 // PublishJson publishes a JSON-encoded message to the specified subject.
 func (c *Client) PublishJson(ctx context.Context, subject string, v any) error {
 	data, err := json.Marshal(v)
@@ -157,6 +282,22 @@ func (c *Client) PublishJson(ctx context.Context, subject string, v any) error {
 	return c.Publish(ctx, subject, data)
 }
 
+// Reconnected returns a channel that is closed the next time the connection is re-established,
+// so any number of callers can wait for it. Call it again after each reconnect for the next one.
+func (c *Client) Reconnected() <-chan struct{} {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.reconnected
+}
+
+// notifyReconnected wakes the callers waiting on Reconnected.
+func (c *Client) notifyReconnected() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(c.reconnected)
+	c.reconnected = make(chan struct{})
+}
+
 // Close gracefully closes the NATS connection.
 func (c *Client) Close() error {
 	if c.conn != nil {
@@ -179,6 +320,11 @@ func (c *Client) Stats() natsio.Statistics {
 	return c.conn.Stats()
 }
 
+// SubjectPrefix returns the client's normalized subject prefix.
+func (c *Client) SubjectPrefix() string {
+	return c.subjectPrefix
+}
+
 // JetStream returns the underlying JetStream context for advanced operations.
 func (c *Client) JetStream() jetstream.JetStream {
 	return c.js
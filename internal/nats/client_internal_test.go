@@ -0,0 +1,122 @@
+package nats
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// unresponsiveJetStream is a JetStream whose stream management calls never complete,
+// like an unreachable or overloaded server. They return once their context is done.
+type unresponsiveJetStream struct {
+	jetstream.JetStream
+}
+
+func (unresponsiveJetStream) CreateStream(ctx context.Context, _ jetstream.StreamConfig) (jetstream.Stream, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (unresponsiveJetStream) UpdateStream(ctx context.Context, _ jetstream.StreamConfig) (jetstream.Stream, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestConfigureStream_UsesStreamSetupTimeout verifies stream setup gives up after the configured timeout.
+func TestConfigureStream_UsesStreamSetupTimeout(t *testing.T) {
+	t.Parallel()
+
+	c := &Client{js: unresponsiveJetStream{}, logger: slog.Default()}
+	cfg := DefaultConfig()
+	cfg.StreamSetupTimeout = 50 * time.Millisecond
+
+	start := time.Now()
+	err := c.configureStream(cfg)
+	elapsed := time.Since(start)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline exceeded error, got %v", err)
+	}
+	if elapsed < cfg.StreamSetupTimeout || elapsed > time.Second {
+		t.Errorf("expected stream setup to give up after ~%v, took %v", cfg.StreamSetupTimeout, elapsed)
+	}
+}
+
+// TestConfig_StreamConfig verifies the default stream keeps its previous settings,
+// and that the stream settings are passed through to JetStream.
+func TestConfig_StreamConfig(t *testing.T) {
+	t.Parallel()
+
+	got := DefaultConfig().streamConfig()
+	if got.Retention != jetstream.LimitsPolicy || got.Discard != jetstream.DiscardOld ||
+		got.Replicas != 1 || got.Storage != jetstream.FileStorage {
+		t.Errorf("expected a replica of a file-backed, limits-based stream discarding old messages, got %+v", got)
+	}
+	if len(got.Subjects) != 1 || got.Subjects[0] != DefaultSubjectPrefix+".>" {
+		t.Errorf("expected the stream to capture every subject under %s, got %v", DefaultSubjectPrefix, got.Subjects)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Retention = jetstream.WorkQueuePolicy
+	cfg.Discard = jetstream.DiscardNew
+	cfg.Replicas = 3
+	cfg.Storage = jetstream.MemoryStorage
+	got = cfg.streamConfig()
+	if got.Retention != cfg.Retention || got.Discard != cfg.Discard || got.Replicas != cfg.Replicas || got.Storage != cfg.Storage {
+		t.Errorf("expected the configured stream settings, got %+v", got)
+	}
+}
+
+// TestConsumerConfig_JetStreamConfig verifies deliver policies map to their JetStream equivalents.
+func TestConsumerConfig_JetStreamConfig(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		policy    DeliverPolicy
+		startTime time.Time
+		want      jetstream.DeliverPolicy
+		wantErr   bool
+	}{
+		{"default", "", time.Time{}, jetstream.DeliverAllPolicy, false},
+		{"all", DeliverAll, time.Time{}, jetstream.DeliverAllPolicy, false},
+		{"last", DeliverLast, time.Time{}, jetstream.DeliverLastPolicy, false},
+		{"new", DeliverNew, time.Time{}, jetstream.DeliverNewPolicy, false},
+		{"by start time", DeliverByStartTime, start, jetstream.DeliverByStartTimePolicy, false},
+		{"by start time without a start time", DeliverByStartTime, time.Time{}, 0, true},
+		{"unknown", "first", time.Time{}, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			cfg := DefaultConsumerConfig()
+			cfg.DeliverPolicy = tt.policy
+			cfg.StartTime = tt.startTime
+
+			jsCfg, err := cfg.jetStreamConfig()
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if jsCfg.DeliverPolicy != tt.want {
+				t.Errorf("expected deliver policy %v, got %v", tt.want, jsCfg.DeliverPolicy)
+			}
+			if tt.policy == DeliverByStartTime && (jsCfg.OptStartTime == nil || !jsCfg.OptStartTime.Equal(start)) {
+				t.Errorf("expected start time %v, got %v", start, jsCfg.OptStartTime)
+			}
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package nats_test
 
 import (
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,6 +37,10 @@ func TestDefaultConfig(t *testing.T) {
 	if cfg.ConnectTimeout != 10*time.Second {
 		t.Errorf("expected ConnectTimeout 10s, got %v", cfg.ConnectTimeout)
 	}
+
+	if cfg.StreamSetupTimeout != nats.DefaultStreamSetupTimeout {
+		t.Errorf("expected StreamSetupTimeout %v, got %v", nats.DefaultStreamSetupTimeout, cfg.StreamSetupTimeout)
+	}
 }
 
 // TestNewClient_InvalidURL tests that NewClient returns an error for invalid NATS URLs.
@@ -51,9 +60,103 @@ func TestNewClient_InvalidURL(t *testing.T) {
 	}
 }
 
+// TestNormalizeSubjectPrefix verifies stray dots are trimmed and invalid prefixes are rejected.
+func TestNormalizeSubjectPrefix(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		prefix  string
+		want    string
+		wantErr string
+	}{
+		{prefix: "iot.sensors", want: "iot.sensors"},
+		{prefix: "iot.sensors.", want: "iot.sensors"},
+		{prefix: ".iot", want: "iot"},
+		{prefix: " ..iot.sensors.. ", want: "iot.sensors"},
+		{prefix: "iot.*", wantErr: "wildcards"},
+		{prefix: "iot.>", wantErr: "wildcards"},
+		{prefix: "iot..sensors", wantErr: "empty tokens"},
+		{prefix: "iot sensors", wantErr: "whitespace"},
+		{prefix: "...", wantErr: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := nats.NormalizeSubjectPrefix(tt.prefix)
+			if tt.wantErr != "" {
+				if err == nil {
+					t.Fatalf("expected error containing %q, got prefix %q", tt.wantErr, got)
+				}
+				if !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestNewClient_InvalidSubjectPrefix tests that NewClient rejects a wildcard prefix before connecting.
+func TestNewClient_InvalidSubjectPrefix(t *testing.T) {
+	t.Parallel()
+
+	cfg := nats.DefaultConfig()
+	cfg.SubjectPrefix = "iot.*"
+
+	client, err := nats.NewClient(cfg, nil)
+	if err == nil {
+		t.Fatal("expected error for wildcard subject prefix, got nil")
+	}
+	if client != nil {
+		t.Error("expected nil client on error")
+	}
+}
+
 // TODO: Implement integration tests with a real NATS server:
 // - Connection to NATS server
 // - Stream create/update
-// - Publish messages
 // - Connection/Reconnection
 // - Graceful shutdown
+
+// TestNewClient_BadCredsFile verifies a credentials file that can't be read surfaces as a connection error.
+func TestNewClient_BadCredsFile(t *testing.T) {
+	t.Parallel()
+
+	// A server that only greets clients, which is as far as a connection gets before credentials are needed.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		fmt.Fprint(conn, "INFO {\"server_id\":\"test\",\"nonce\":\"nonce\",\"max_payload\":1048576}\r\n")
+		io.Copy(io.Discard, conn) // Hold the connection open until the client hangs up.
+	}()
+
+	cfg := nats.DefaultConfig()
+	cfg.URL = "nats://" + ln.Addr().String()
+	cfg.ConnectTimeout = time.Second
+	cfg.CredsFile = filepath.Join(t.TempDir(), "missing.creds")
+
+	client, err := nats.NewClient(cfg, nil)
+	if err == nil {
+		client.Close()
+		t.Fatal("expected an error connecting with a missing creds file, got nil")
+	}
+	if !strings.Contains(err.Error(), "failed to connect to NATS") || !strings.Contains(err.Error(), "missing.creds") {
+		t.Errorf("expected a connection error naming the creds file, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package nats
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// DefaultConsumerName is the durable name of the JetStream consumer used to read sensor data.
+const DefaultConsumerName = "iot-bridge"
+
+// DeliverPolicy is the point in the stream a new consumer starts consuming from.
+type DeliverPolicy string
+
+const (
+	// DeliverAll consumes every message in the stream. It is the default.
+	DeliverAll DeliverPolicy = "all"
+	// DeliverLast starts with the last message in the stream.
+	DeliverLast DeliverPolicy = "last"
+	// DeliverNew only consumes messages published after the consumer is created.
+	DeliverNew DeliverPolicy = "new"
+	// DeliverByStartTime consumes messages stored at or after ConsumerConfig.StartTime,
+	// e.g. to reprocess the last hour of data.
+	DeliverByStartTime DeliverPolicy = "by_start_time"
+)
+
+// ConsumerConfig holds configuration for a JetStream consumer.
+type ConsumerConfig struct {
+	Durable       string
+	FilterSubject string
+	AckWait       time.Duration
+	// DeliverPolicy sets where the consumer starts (DeliverAll when empty).
+	// It only applies when the durable consumer is created: JetStream doesn't allow changing it afterwards.
+	DeliverPolicy DeliverPolicy
+	// StartTime is the stream time consumption starts from, with DeliverByStartTime.
+	StartTime time.Time
+}
+
+// DefaultConsumerConfig returns a ConsumerConfig with sensible defaults.
+// It consumes every sensor data subject under DefaultSubjectPrefix.
+func DefaultConsumerConfig() ConsumerConfig {
+	return ConsumerConfig{
+		Durable:       DefaultConsumerName,
+		FilterSubject: fmt.Sprintf("%s.data.>", DefaultSubjectPrefix),
+		AckWait:       30 * time.Second,
+	}
+}
+
+// jetStreamConfig maps cfg to a JetStream consumer configuration.
+func (cfg ConsumerConfig) jetStreamConfig() (jetstream.ConsumerConfig, error) {
+	jsCfg := jetstream.ConsumerConfig{
+		Durable:       cfg.Durable,
+		FilterSubject: cfg.FilterSubject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       cfg.AckWait,
+	}
+
+	switch cfg.DeliverPolicy {
+	case "", DeliverAll:
+		jsCfg.DeliverPolicy = jetstream.DeliverAllPolicy
+	case DeliverLast:
+		jsCfg.DeliverPolicy = jetstream.DeliverLastPolicy
+	case DeliverNew:
+		jsCfg.DeliverPolicy = jetstream.DeliverNewPolicy
+	case DeliverByStartTime:
+		if cfg.StartTime.IsZero() {
+			return jsCfg, fmt.Errorf("deliver policy %q requires a start time", cfg.DeliverPolicy)
+		}
+		start := cfg.StartTime
+		jsCfg.DeliverPolicy = jetstream.DeliverByStartTimePolicy
+		jsCfg.OptStartTime = &start
+	default:
+		return jsCfg, fmt.Errorf("unknown deliver policy %q", cfg.DeliverPolicy)
+	}
+
+	return jsCfg, nil
+}
+
+// Consumer reads SensorData messages from the JetStream stream using a durable pull consumer.
+type Consumer struct {
+	consumer jetstream.Consumer
+	logger   *slog.Logger
+}
+
+// NewConsumer creates (or updates) a durable pull consumer on the client's stream.
+func (c *Client) NewConsumer(ctx context.Context, cfg ConsumerConfig) (*Consumer, error) {
+	jsCfg, err := cfg.jetStreamConfig()
+	if err != nil {
+		return nil, fmt.Errorf("invalid consumer config: %w", err)
+	}
+
+	consumer, err := c.js.CreateOrUpdateConsumer(ctx, c.streamName, jsCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create consumer: %w", err)
+	}
+
+	c.logger.Info("Consumer configured",
+		"stream", c.streamName,
+		"durable", cfg.Durable,
+		"filter", cfg.FilterSubject,
+		"deliver_policy", jsCfg.DeliverPolicy.String())
+
+	return &Consumer{
+		consumer: consumer,
+		logger:   c.logger.With("consumer", cfg.Durable),
+	}, nil
+}
+
+// Consume decodes each message as SensorData and passes it to handler until ctx is canceled.
+// Messages are decompressed if they were compressed, then decoded with the codec
+// their Content-Type header names (JSON without one).
+// Messages are acked when handler returns nil and nak'ed (for redelivery) when it returns an error.
+// Messages that can't be decoded are terminated, since redelivering them would never succeed.
+func (c *Consumer) Consume(ctx context.Context, handler func(model.SensorData) error) error {
+	consumeCtx, err := c.consumer.Consume(func(msg jetstream.Msg) {
+		var data model.SensorData
+		payload, err := DecodePayload(msg.Headers(), msg.Data())
+		if err == nil {
+			var dec codec.Codec
+			if dec, err = codec.ForContentType(msg.Headers().Get(codec.HeaderContentType)); err == nil {
+				err = dec.Unmarshal(payload, &data)
+			}
+		}
+		if err != nil {
+			c.logger.Warn("Discarding undecodable message", "subject", msg.Subject(), "error", err)
+			if err := msg.Term(); err != nil {
+				c.logger.Warn("Failed to terminate message", "error", err)
+			}
+			return
+		}
+
+		if err := handler(data); err != nil {
+			c.logger.Warn("Handler failed, requesting redelivery", "sensor_id", data.ID, "error", err)
+			if err := msg.Nak(); err != nil {
+				c.logger.Warn("Failed to nak message", "error", err)
+			}
+			return
+		}
+
+		if err := msg.Ack(); err != nil {
+			c.logger.Warn("Failed to ack message", "sensor_id", data.ID, "error", err)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to start consuming: %w", err)
+	}
+
+	<-ctx.Done()
+	consumeCtx.Stop()
+	<-consumeCtx.Closed()
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package nats_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/nats"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/publisher"
+)
+
+// TestConsumer_Consume_DeliverByStartTime verifies a consumer started from a point in time
+// only receives messages stored after it.
+// It is skipped unless NATS_URL points at a JetStream-enabled server.
+func TestConsumer_Consume_DeliverByStartTime(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL not set, skipping NATS integration test")
+	}
+
+	// Use a dedicated stream and prefix so the test doesn't interfere with a running simulator.
+	suffix := time.Now().UnixNano()
+	cfg := nats.DefaultConfig()
+	cfg.URL = url
+	cfg.StreamName = fmt.Sprintf("REPLAY_TEST_%d", suffix)
+	cfg.SubjectPrefix = fmt.Sprintf("replaytest.%d", suffix)
+
+	client, err := nats.NewClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to NATS: %v", err)
+	}
+	defer client.Close()
+	defer client.JetStream().DeleteStream(context.Background(), cfg.StreamName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	publish := func(ids ...int) {
+		for _, id := range ids {
+			subject := fmt.Sprintf("%s.data.%d", cfg.SubjectPrefix, id)
+			if err := client.PublishJson(ctx, subject, model.SensorData{ID: id, Timestamp: time.Now()}); err != nil {
+				t.Fatalf("failed to publish message %d: %v", id, err)
+			}
+		}
+	}
+
+	// Spread the messages out in time, around the start time.
+	publish(1, 2, 3)
+	time.Sleep(100 * time.Millisecond)
+	start := time.Now()
+	time.Sleep(100 * time.Millisecond)
+	publish(4, 5)
+
+	consumerCfg := nats.ConsumerConfig{
+		Durable:       "replay-test",
+		FilterSubject: fmt.Sprintf("%s.data.>", cfg.SubjectPrefix),
+		AckWait:       5 * time.Second,
+		DeliverPolicy: nats.DeliverByStartTime,
+		StartTime:     start,
+	}
+	consumer, err := client.NewConsumer(ctx, consumerCfg)
+	if err != nil {
+		t.Fatalf("failed to create consumer: %v", err)
+	}
+
+	var mu sync.Mutex
+	var got []int
+	consumeCtx, stopConsume := context.WithTimeout(ctx, time.Second)
+	defer stopConsume()
+	err = consumer.Consume(consumeCtx, func(data model.SensorData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, data.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error consuming: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 2 || got[0] != 4 || got[1] != 5 {
+		t.Errorf("expected only messages [4 5] after the start time, got %v", got)
+	}
+}
+
+// TestConsumer_Consume_RoundTrip verifies readings published by the publisher land on their typed subjects
+// and are read back unchanged, and that a message whose handler fails is redelivered.
+// It is skipped unless NATS_URL points at a JetStream-enabled server.
+func TestConsumer_Consume_RoundTrip(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL not set, skipping NATS integration test")
+	}
+
+	// Use a dedicated stream and prefix so the test doesn't interfere with a running simulator.
+	suffix := time.Now().UnixNano()
+	cfg := nats.DefaultConfig()
+	cfg.URL = url
+	cfg.StreamName = fmt.Sprintf("ROUNDTRIP_TEST_%d", suffix)
+	cfg.SubjectPrefix = fmt.Sprintf("roundtriptest.%d", suffix)
+
+	client, err := nats.NewClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to NATS: %v", err)
+	}
+	defer client.Close()
+	defer client.JetStream().DeleteStream(context.Background(), cfg.StreamName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Publish a mix of typed and untyped readings, and wait for the publisher to drain them.
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	sent := []model.SensorData{
+		{SchemaVersion: model.SchemaVersion, ID: 1, Type: "temperature", Unit: "celsius", Value: 21.5, Timestamp: now},
+		{SchemaVersion: model.SchemaVersion, ID: 2, Value: 0.25, Timestamp: now},
+		{SchemaVersion: model.SchemaVersion, ID: 3, Type: "temperature", Unit: "celsius", Value: 19, Timestamp: now},
+	}
+	dataCh := make(chan model.SensorData, len(sent))
+	for _, data := range sent {
+		dataCh <- data
+	}
+	close(dataCh)
+	publisher.New(dataCh, client, client.SubjectPrefix(), publisher.Options{}, nil, nil).Run(ctx)
+
+	// Only the temperature readings are on the typed subjects.
+	consumer, err := client.NewConsumer(ctx, nats.ConsumerConfig{
+		Durable:       "roundtrip-test",
+		FilterSubject: client.SubjectPrefix() + ".data.temperature.>",
+		AckWait:       5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("failed to create consumer: %v", err)
+	}
+
+	var mu sync.Mutex
+	var got []model.SensorData
+	failed := false
+	consumeCtx, stopConsume := context.WithTimeout(ctx, time.Second)
+	defer stopConsume()
+	err = consumer.Consume(consumeCtx, func(data model.SensorData) error {
+		mu.Lock()
+		defer mu.Unlock()
+		// Fail the first delivery, which should be nak'ed and redelivered.
+		if !failed {
+			failed = true
+			return errors.New("handler failed")
+		}
+		got = append(got, data)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error consuming: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	slices.SortFunc(got, func(a, b model.SensorData) int { return a.ID - b.ID })
+	want := []model.SensorData{sent[0], sent[2]}
+	if len(got) != len(want) {
+		t.Fatalf("expected readings %+v, got %+v", want, got)
+	}
+	for i := range want {
+		if !got[i].Timestamp.Equal(want[i].Timestamp) {
+			t.Errorf("reading %d: expected timestamp %v, got %v", i, want[i].Timestamp, got[i].Timestamp)
+		}
+		got[i].Timestamp = want[i].Timestamp
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("reading %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
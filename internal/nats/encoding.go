@@ -0,0 +1,42 @@
+package nats
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	natsio "github.com/nats-io/nats.go"
+)
+
+const (
+	// HeaderContentEncoding is the NATS header naming the encoding of a compressed message payload.
+	// Messages without it carry plain JSON.
+	HeaderContentEncoding = "Content-Encoding"
+	// EncodingGzip is the HeaderContentEncoding of gzip-compressed payloads.
+	EncodingGzip = "gzip"
+)
+
+// DecodePayload returns a message's payload as it was before compression,
+// decompressing it according to its Content-Encoding header.
+// Payloads without the header are returned as is.
+func DecodePayload(header natsio.Header, data []byte) ([]byte, error) {
+	switch encoding := header.Get(HeaderContentEncoding); encoding {
+	case "":
+		return data, nil
+	case EncodingGzip:
+		r, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		defer r.Close()
+
+		decoded, err := io.ReadAll(r)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress payload: %w", err)
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
@@ -0,0 +1,61 @@
+package nats_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	natsio "github.com/nats-io/nats.go"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/nats"
+)
+
+// TestDecodePayload verifies payloads are decompressed according to their Content-Encoding header,
+// and returned as is without one.
+func TestDecodePayload(t *testing.T) {
+	t.Parallel()
+
+	const payload = `{"id":1,"value":21.5}`
+
+	var compressed bytes.Buffer
+	zw := gzip.NewWriter(&compressed)
+	zw.Write([]byte(payload))
+	zw.Close()
+
+	tests := []struct {
+		name     string
+		encoding string
+		data     []byte
+		wantErr  bool
+	}{
+		{name: "plain", data: []byte(payload)},
+		{name: "gzip", encoding: nats.EncodingGzip, data: compressed.Bytes()},
+		{name: "corrupt gzip", encoding: nats.EncodingGzip, data: []byte(payload), wantErr: true},
+		{name: "unsupported", encoding: "br", data: []byte(payload), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			header := natsio.Header{}
+			if tt.encoding != "" {
+				header.Set(nats.HeaderContentEncoding, tt.encoding)
+			}
+
+			got, err := nats.DecodePayload(header, tt.data)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got payload %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != payload {
+				t.Errorf("expected %q, got %q", payload, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package nats
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+const (
+	// DefaultRegistryBucket is the name of the KV bucket live sensors are registered in.
+	DefaultRegistryBucket = "IOT_SENSOR_REGISTRY"
+	// DefaultRegistryTTL is how long registry entries live by default.
+	DefaultRegistryTTL = 24 * time.Hour
+)
+
+// RegistryConfig holds configuration for a sensor registry.
+type RegistryConfig struct {
+	// Bucket is the KV bucket sensors are registered in.
+	Bucket string
+	// TTL is how long entries live in a bucket the registry creates (0 keeps them until they're deleted),
+	// so sensors that never deregister (e.g. after a crash) eventually drop out of it.
+	// It doesn't apply to an existing bucket.
+	TTL time.Duration
+}
+
+// DefaultRegistryConfig returns a RegistryConfig with sensible defaults.
+func DefaultRegistryConfig() RegistryConfig {
+	return RegistryConfig{
+		Bucket: DefaultRegistryBucket,
+		TTL:    DefaultRegistryTTL,
+	}
+}
+
+// Registry records the live fleet in a JetStream KV bucket, keyed by sensor ID,
+// so other processes (e.g. dashboards) can enumerate it.
+type Registry struct {
+	kv jetstream.KeyValue
+}
+
+// NewRegistry opens the registry's KV bucket, creating it if it doesn't exist yet.
+func (c *Client) NewRegistry(ctx context.Context, cfg RegistryConfig) (*Registry, error) {
+	kv, err := c.js.KeyValue(ctx, cfg.Bucket)
+	if errors.Is(err, jetstream.ErrBucketNotFound) {
+		kv, err = c.js.CreateKeyValue(ctx, jetstream.KeyValueConfig{
+			Bucket:      cfg.Bucket,
+			Description: "Live simulated sensors, by sensor ID",
+			TTL:         cfg.TTL,
+		})
+		if errors.Is(err, jetstream.ErrBucketExists) {
+			// Another instance created it first.
+			kv, err = c.js.KeyValue(ctx, cfg.Bucket)
+		} else if err == nil {
+			c.logger.Info("Created registry bucket", "bucket", cfg.Bucket, "ttl", cfg.TTL)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open registry bucket %s: %w", cfg.Bucket, err)
+	}
+
+	return &Registry{kv: kv}, nil
+}
+
+// Register records sensor id's metadata, replacing any previous entry.
+func (r *Registry) Register(ctx context.Context, id int, meta model.SensorMeta) error {
+	value, err := json.Marshal(meta)
+	if err != nil {
+		return fmt.Errorf("failed to encode sensor %d's metadata: %w", id, err)
+	}
+	if _, err := r.kv.Put(ctx, strconv.Itoa(id), value); err != nil {
+		return fmt.Errorf("failed to register sensor %d: %w", id, err)
+	}
+	return nil
+}
+
+// Deregister removes sensor id's entry.
+func (r *Registry) Deregister(ctx context.Context, id int) error {
+	if err := r.kv.Delete(ctx, strconv.Itoa(id)); err != nil {
+		return fmt.Errorf("failed to deregister sensor %d: %w", id, err)
+	}
+	return nil
+}
+
+// Lookup returns sensor id's registered metadata, and whether it is registered.
+func (r *Registry) Lookup(ctx context.Context, id int) (model.SensorMeta, bool, error) {
+	var meta model.SensorMeta
+	entry, err := r.kv.Get(ctx, strconv.Itoa(id))
+	if errors.Is(err, jetstream.ErrKeyNotFound) {
+		return meta, false, nil
+	}
+	if err != nil {
+		return meta, false, fmt.Errorf("failed to look up sensor %d: %w", id, err)
+	}
+	if err := json.Unmarshal(entry.Value(), &meta); err != nil {
+		return meta, false, fmt.Errorf("failed to decode sensor %d's metadata: %w", id, err)
+	}
+	return meta, true, nil
+}
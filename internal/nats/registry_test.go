@@ -0,0 +1,67 @@
+package nats_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/nats"
+)
+
+// TestRegistry verifies the registry creates its bucket when it doesn't exist,
+// and that sensors can be registered, looked up, and deregistered.
+// It is skipped unless NATS_URL points at a JetStream-enabled server.
+func TestRegistry(t *testing.T) {
+	url := os.Getenv("NATS_URL")
+	if url == "" {
+		t.Skip("NATS_URL not set, skipping NATS integration test")
+	}
+
+	// Use a dedicated stream, prefix and bucket so the test doesn't interfere with a running simulator.
+	suffix := time.Now().UnixNano()
+	cfg := nats.DefaultConfig()
+	cfg.URL = url
+	cfg.StreamName = fmt.Sprintf("REGISTRY_TEST_%d", suffix)
+	cfg.SubjectPrefix = fmt.Sprintf("registrytest.%d", suffix)
+
+	client, err := nats.NewClient(cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to connect to NATS: %v", err)
+	}
+	defer client.Close()
+	defer client.JetStream().DeleteStream(context.Background(), cfg.StreamName)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	registryCfg := nats.RegistryConfig{Bucket: fmt.Sprintf("REGISTRY_TEST_%d", suffix), TTL: time.Minute}
+	registry, err := client.NewRegistry(ctx, registryCfg)
+	if err != nil {
+		t.Fatalf("failed to create registry: %v", err)
+	}
+	defer client.JetStream().DeleteKeyValue(context.Background(), registryCfg.Bucket)
+
+	// Opening the now existing bucket again reuses it.
+	if _, err := client.NewRegistry(ctx, registryCfg); err != nil {
+		t.Fatalf("failed to open existing registry: %v", err)
+	}
+
+	meta := model.SensorMeta{ID: 7, DeviceID: "dev-7", Type: "temperature", Unit: "celsius", Interval: time.Second}
+	if err := registry.Register(ctx, 7, meta); err != nil {
+		t.Fatalf("failed to register: %v", err)
+	}
+	got, ok, err := registry.Lookup(ctx, 7)
+	if err != nil || !ok || got.ID != meta.ID || got.DeviceID != meta.DeviceID || got.Interval != meta.Interval {
+		t.Errorf("expected sensor 7 to be registered as %+v, got %+v (registered %v, error %v)", meta, got, ok, err)
+	}
+
+	if err := registry.Deregister(ctx, 7); err != nil {
+		t.Fatalf("failed to deregister: %v", err)
+	}
+	if _, ok, err := registry.Lookup(ctx, 7); err != nil || ok {
+		t.Errorf("expected sensor 7 to be deregistered, got registered %v (error %v)", ok, err)
+	}
+}
@@ -0,0 +1,102 @@
+package publisher
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"sync"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// maxPooledPayload is the capacity above which an encoder's buffer isn't pooled,
+// so one large batch doesn't pin its buffer for the rest of the run.
+const maxPooledPayload = 64 << 10
+
+// encoder encodes messages as JSON into a reusable buffer.
+type encoder struct {
+	buf bytes.Buffer
+	enc *json.Encoder
+
+	// zbuf holds buf's gzip-compressed copy, written by zw (created on first use).
+	zbuf bytes.Buffer
+	zw   *gzip.Writer
+}
+
+// encoders recycles encoders, so encoding a message doesn't allocate its payload.
+// An encoder may only be put back (see putEncoder) once its payload is no longer referenced,
+// i.e. once the publish that sent it has succeeded: a failed (e.g. timed out) publish
+// may still be in flight in the sink, so its encoder is left to the garbage collector.
+var encoders = sync.Pool{
+	New: func() any {
+		e := &encoder{}
+		e.enc = json.NewEncoder(&e.buf)
+		return e
+	},
+}
+
+// getEncoder returns an encoder with an empty buffer.
+func getEncoder() *encoder {
+	e := encoders.Get().(*encoder)
+	e.buf.Reset()
+	e.zbuf.Reset()
+	return e
+}
+
+// putEncoder returns e to the pool. Its payload must no longer be referenced.
+func putEncoder(e *encoder) {
+	if e.buf.Cap() > maxPooledPayload || e.zbuf.Cap() > maxPooledPayload {
+		return
+	}
+	encoders.Put(e)
+}
+
+// append appends v's JSON encoding (as json.Marshal encodes it) to the buffer, returning its length.
+// Nothing is appended if v fails to encode.
+func (e *encoder) append(v any) (int, error) {
+	start := e.buf.Len()
+	if err := e.enc.Encode(v); err != nil {
+		e.buf.Truncate(start)
+		return 0, err
+	}
+	e.buf.Truncate(e.buf.Len() - 1) // Encode terminates each value with a newline, which Marshal doesn't.
+	return e.buf.Len() - start, nil
+}
+
+// appendCodec appends data's encoding with c to the buffer. A nil c encodes JSON, as append does.
+func (e *encoder) appendCodec(c codec.Codec, data model.SensorData) error {
+	var b []byte
+	var err error
+	switch c := c.(type) {
+	case nil, codec.JSONCodec:
+		_, err := e.append(data)
+		return err
+	case codec.Appender:
+		b, err = c.Append(e.buf.AvailableBuffer(), data)
+	default:
+		b, err = c.Marshal(data)
+	}
+	if err != nil {
+		return err
+	}
+	e.buf.Write(b)
+	return nil
+}
+
+// gzip compresses the buffer into zbuf, returning the compressed payload.
+func (e *encoder) gzip() ([]byte, error) {
+	e.zbuf.Reset()
+	if e.zw == nil {
+		e.zw = gzip.NewWriter(&e.zbuf)
+	} else {
+		e.zw.Reset(&e.zbuf)
+	}
+	if _, err := e.zw.Write(e.buf.Bytes()); err != nil {
+		return nil, err
+	}
+	if err := e.zw.Close(); err != nil {
+		return nil, err
+	}
+	return e.zbuf.Bytes(), nil
+}
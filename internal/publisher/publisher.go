@@ -1,127 +1,914 @@
 // Package publisher provides functionality for
-// publishing sensor data from a Go channel to NATS.
+// publishing sensor data from a Go channel to NATS (or another broker, through a Sink).
 package publisher
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 
+	natsio "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/clock"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/nats"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/shutdown"
 )
 
+const (
+	// HeaderModel is the NATS header carrying the emitting device's model.
+	HeaderModel = "Sensor-Model"
+	// HeaderFirmwareVersion is the NATS header carrying the emitting device's firmware version.
+	HeaderFirmwareVersion = "Sensor-Firmware-Version"
+	// HeaderTagPrefix prefixes the NATS headers carrying a reading's tags, e.g. "Sensor-Tag-site".
+	HeaderTagPrefix = "Sensor-Tag-"
+
+	// asyncFlushInterval is the longest a partial async batch waits before being published.
+	asyncFlushInterval = 100 * time.Millisecond
+	// ackTimeout is how long to wait for the acks of an async batch, and for a dead letter to be published.
+	ackTimeout = 2 * time.Second
+	// workerQueueSize is how many messages each worker's queue holds, in sharded mode.
+	workerQueueSize = 100
+
+	// DefaultPublishTimeout is how long each publish waits for the broker (e.g. a JetStream ack) before it's failed.
+	DefaultPublishTimeout = 2 * time.Second
+	// DefaultBatchTimeout is the longest a partial batch waits before being published, in batch mode.
+	DefaultBatchTimeout = 100 * time.Millisecond
+	// DefaultRetryInitialDelay is the backoff before a failed publish is first retried.
+	DefaultRetryInitialDelay = 50 * time.Millisecond
+	// DefaultRetryMaxDelay is the longest backoff between retries of a failed publish.
+	DefaultRetryMaxDelay = 2 * time.Second
+)
+
+// Sink is the broker client the publisher publishes to, addressing messages by NATS-style subject.
+// *nats.Client, *mqtt.Client and *kafka.Client satisfy this interface.
+// Payloads are reused once a publish succeeds, so sinks must not retain them after it returns (like io.Writer).
+type Sink interface {
+	IsConnected() bool
+	Publish(ctx context.Context, subject string, data []byte) error
+	PublishJson(ctx context.Context, subject string, v any) error
+}
+
+// Client is a Sink that can also publish messages with headers.
+// Headers (the device model, firmware version and tags) are only sent through a Client.
+// *nats.Client satisfies this interface.
+type Client interface {
+	Sink
+	PublishMsg(ctx context.Context, msg *natsio.Msg) error
+}
+
+// AsyncClient is a Client that can also publish without waiting for each message's ack.
+// *nats.Client satisfies this interface.
+type AsyncClient interface {
+	Client
+	PublishMsgAsync(msg *natsio.Msg) (jetstream.PubAckFuture, error)
+}
+
+// WriteFailureNotifier is implemented by sinks that write messages after Publish returns,
+// so a message can fail to be written after it was published (and counted as a success).
+// *kafka.Client satisfies this interface.
+type WriteFailureNotifier interface {
+	// OnWriteFailure sets f to be called, from any goroutine, with the subject and payload
+	// of each message published with Publish or PublishJson that then fails to be written.
+	OnWriteFailure(f func(subject string, data []byte, err error))
+}
+
+// ReconnectNotifier is implemented by clients that signal when NATS reconnects.
+// *nats.Client satisfies this interface.
+type ReconnectNotifier interface {
+	// Reconnected returns a channel that is closed on the next reconnect.
+	Reconnected() <-chan struct{}
+}
+
+// Options configures optional Publisher behavior.
+// The zero value publishes one message at a time, waiting for each ack.
+type Options struct {
+	// AsyncBatchSize enables async batch mode when greater than 1 and the client is an AsyncClient.
+	// Up to AsyncBatchSize messages are published without waiting, then their acks are collected.
+	AsyncBatchSize int
+	// DeadLetterSubject, when set, receives a DeadLetter for every message that fails to publish
+	// (e.g. DeadLetterSubject(prefix)), so failed messages can be inspected and replayed.
+	// Messages that can't be dead-lettered either are logged in full.
+	DeadLetterSubject string
+	// ReconnectBufferSize, when positive, enables the reconnect buffer (outside async batch mode).
+	// Messages that fail to publish because NATS is disconnected are held in a ring buffer,
+	// up to ReconnectBufferSize, and republished once the connection is back: as soon as the client
+	// signals the reconnect (if it's a ReconnectNotifier), or at the latest with the next message.
+	// When the buffer is full, the oldest message is shed (and dead-lettered) to make room.
+	//
+	// Ordering: while any message is buffered, live messages are buffered behind it rather than published,
+	// and the buffer is republished oldest first before any live message, so messages (and so each sensor's)
+	// are still published in the order they were received. Shed messages are gaps in that order.
+	ReconnectBufferSize int
+	// Workers, when greater than 1, publishes with that many concurrent workers.
+	// Messages are sharded across workers by sensor ID, so all of a sensor's messages
+	// go through the same worker and are still published in order.
+	Workers int
+	// BatchSize enables batch mode when greater than 1, taking precedence over AsyncBatchSize.
+	// Up to BatchSize messages are published together as one JSON array, to `iot.sensors.batch.{shard}`,
+	// where shard is the publishing worker's index (0 with a single worker).
+	// Batches carry no headers, and failed batches aren't held in the reconnect buffer.
+	BatchSize int
+	// BatchTimeout is the longest a partial batch waits before being published (DefaultBatchTimeout if zero).
+	BatchTimeout time.Duration
+	// RetryAttempts is how many times a failed publish is retried before the message is given up on
+	// (outside either batch mode). 0 disables retries.
+	// The backoff between retries doubles from RetryInitialDelay up to RetryMaxDelay,
+	// and is cut short once Run's context is canceled, so retries don't delay shutdown.
+	RetryAttempts int
+	// RetryInitialDelay is the backoff before the first retry (DefaultRetryInitialDelay if zero).
+	RetryInitialDelay time.Duration
+	// RetryMaxDelay caps the backoff between retries (DefaultRetryMaxDelay if zero).
+	RetryMaxDelay time.Duration
+	// Codec encodes each message (JSON if nil). Messages encoded with anything but JSON carry
+	// a `Content-Type` header naming their codec (see codec.ForContentType), so sinks that can't carry headers
+	// leave consumers to know the codec. Batches (see BatchSize) are always JSON arrays.
+	Codec codec.Codec
+	// CompressThreshold, when positive, gzip-compresses payloads (including batches) larger than CompressThreshold bytes,
+	// marking them with a `Content-Encoding: gzip` header (see nats.DecodePayload, which decompresses them).
+	// Only payloads sent through a Client are compressed, since other sinks can't carry the header.
+	CompressThreshold int
+	// PublishTimeout bounds how long each publish (of a message, or a batch in batch mode) waits for the broker
+	// (DefaultPublishTimeout if zero). Publishes that time out are counted as failed with the "publish_timeout"
+	// error type, rather than "publish_error", and are retried like any other failed publish.
+	PublishTimeout time.Duration
+	// DrainTimeout, when positive, bounds how long Run keeps draining the data channel after ctx is canceled.
+	// If the channel isn't closed by then, Run returns, abandoning the messages still in it.
+	// 0 drains until the channel is closed.
+	DrainTimeout time.Duration
+	// Clock times the publisher's flushes, statistics, retry backoffs and drain timeout,
+	// and measures publish latency (clock.Real if nil). Tests can set a clock.Fake to step through them.
+	Clock clock.Clock
+}
+
+// DeadLetter wraps a SensorData message that failed to publish, along with the reason it failed.
+type DeadLetter struct {
+	Data  model.SensorData `json:"data"`
+	Error string           `json:"error"`
+	// Attempts is how many times publishing the message was attempted before it was given up on.
+	// It is 0 for messages given up on without being attempted (e.g. that couldn't be encoded),
+	// and for messages shed from the reconnect buffer, whose earlier attempts aren't tracked.
+	Attempts int `json:"attempts"`
+}
+
+// DeadLetterSubject returns the conventional dead-letter subject under prefix, i.e. `iot.sensors.dlq`.
+func DeadLetterSubject(prefix string) string {
+	return prefix + ".dlq"
+}
+
 // Publisher reads sensor data from a channel and publishes it to NATS.
 type Publisher struct {
 	dataCh        <-chan model.SensorData
-	natsClient    *nats.Client
+	client        Sink
 	subjectPrefix string
+	opts          Options
+	shard         int             // The worker's index, in sharded mode.
+	done          <-chan struct{} // Run's ctx.Done(), which cuts retry backoffs short.
 	metrics       *metrics.Metrics
 	logger        *slog.Logger
+
+	// The counts are atomic, since failures reported by a WriteFailureNotifier are counted from its goroutines.
+	successCount atomic.Int64
+	failureCount atomic.Int64
+
+	// reconnectBuf holds messages waiting for NATS to reconnect, oldest first.
+	reconnectBuf ring
+}
+
+// pendingAck pairs an asynchronously published message with its ack future.
+type pendingAck struct {
+	data   model.SensorData
+	size   int
+	future jetstream.PubAckFuture
+	// encoder holds the message's payload, and is recycled once the message is acked.
+	encoder *encoder
 }
 
-// New creates a new Publisher instance.
-func New(dataCh <-chan model.SensorData, natsClient *nats.Client, subjectPrefix string, m *metrics.Metrics, l *slog.Logger) *Publisher {
+// New creates a new Publisher instance, publishing to sink.
+func New(dataCh <-chan model.SensorData, sink Sink, subjectPrefix string, opts Options, m *metrics.Metrics, l *slog.Logger) *Publisher {
 	if l == nil {
 		l = slog.Default()
 	}
+	if opts.Clock == nil {
+		opts.Clock = clock.Real
+	}
+	if opts.PublishTimeout <= 0 {
+		opts.PublishTimeout = DefaultPublishTimeout
+	}
 
-	return &Publisher{
+	p := &Publisher{
 		dataCh:        dataCh,
-		natsClient:    natsClient,
+		client:        sink,
 		subjectPrefix: subjectPrefix,
+		opts:          opts,
 		metrics:       m,
 		logger:        l.With("component", "publisher"),
+		reconnectBuf:  ring{capacity: opts.ReconnectBufferSize},
+	}
+	if notifier, ok := sink.(WriteFailureNotifier); ok {
+		notifier.OnWriteFailure(p.writeFailed)
 	}
+	return p
 }
 
 // Run starts the publisher loop (that reads from the data channel and pulishes to NATS).
-// It continues until the context is canceled or the data channel is closed.
+// It drains the data channel until it is closed, even after ctx is canceled,
+// so messages still buffered in the channel on shutdown are published rather than abandoned.
+// Closing the data channel is what stops the publisher (or, with a DrainTimeout, the timeout elapsing
+// after ctx is canceled); in-flight publishes aren't canceled with ctx.
+// In batch mode, a partial batch is published as soon as ctx is canceled.
+// In either batch mode, any partial batch is published before Run returns.
+// With multiple workers, Run returns once every worker has drained its share.
 func (p *Publisher) Run(ctx context.Context) {
+	if p.opts.Workers > 1 {
+		p.runSharded(ctx)
+		return
+	}
+
 	p.logger.Info("Publisher starting")
 	defer p.logger.Info("Publisher stopping")
 
+	arrays := p.opts.BatchSize > 1
+	asyncClient, async := p.client.(AsyncClient)
+	async = async && p.opts.AsyncBatchSize > 1 && !arrays
+
+	// Publishes keep ctx's values, but not its cancellation.
+	p.done = ctx.Done()
+	ctxDone := ctx.Done()
+	pubCtx := context.WithoutCancel(ctx)
+
+	var batch []model.SensorData
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if arrays {
+			p.publishArray(pubCtx, batch)
+		} else {
+			p.publishBatch(pubCtx, asyncClient, batch)
+		}
+		batch = batch[:0]
+	}
+	batchSize := p.opts.AsyncBatchSize
+	flushInterval := asyncFlushInterval
+	if arrays {
+		batchSize = p.opts.BatchSize
+		flushInterval = p.opts.BatchTimeout
+		if flushInterval <= 0 {
+			flushInterval = DefaultBatchTimeout
+		}
+	}
+
 	// ticker to trigger periodic logging of publish statistics
-	ticker := time.NewTicker(5 * time.Second)
+	ticker := p.opts.Clock.NewTicker(5 * time.Second)
 	defer ticker.Stop()
 
-	successCount := 0
-	failureCount := 0
+	// reconnected is closed when the client reconnects, to republish the reconnect buffer right away.
+	var reconnected <-chan struct{}
+	notifier, notifies := p.client.(ReconnectNotifier)
+	if notifies && p.opts.ReconnectBufferSize > 0 {
+		reconnected = notifier.Reconnected()
+	}
+
+	// flushTicker bounds how long a partial batch waits.
+	flushTicker := p.opts.Clock.NewTicker(flushInterval)
+	defer flushTicker.Stop()
+
+	// Once ctx is canceled, drained counts the messages received, and drainTimeout bounds how long they're waited for.
+	draining, drained := false, 0
+	var drainTimeout <-chan time.Time
 
 	for {
 		select {
-		case <-ctx.Done():
-			p.logger.Info("Publisher context canceled",
-				"success", successCount,
-				"failures", failureCount)
+		case <-ctxDone:
+			p.logger.Info("Publisher context canceled, draining until the data channel is closed",
+				"cause", shutdown.Reason(ctx),
+				"drain_timeout", p.opts.DrainTimeout,
+				"success", p.successCount.Load(),
+				"failures", p.failureCount.Load())
+			ctxDone = nil // Stop selecting on it, and keep draining.
+			draining = true
+			if p.opts.DrainTimeout > 0 {
+				timer := p.opts.Clock.NewTimer(p.opts.DrainTimeout)
+				defer timer.Stop()
+				drainTimeout = timer.C()
+			}
+			if arrays {
+				flush()
+			}
+
+		case <-drainTimeout:
+			flush()
+			p.drainReconnectBuffer(pubCtx)
+			p.logger.Warn("Drain timeout elapsed, abandoning the messages left in the data channel",
+				"drained", drained,
+				"abandoned", len(p.dataCh),
+				"success", p.successCount.Load(),
+				"failures", p.failureCount.Load())
 			return
 
 		case data, ok := <-p.dataCh:
 			if !ok {
+				flush()
+				p.drainReconnectBuffer(pubCtx)
 				p.logger.Info("Data channel closed",
-					"success", successCount,
-					"failures", failureCount)
+					"drained", drained,
+					"success", p.successCount.Load(),
+					"failures", p.failureCount.Load())
 				return
 			}
+			if draining {
+				drained++
+			}
 
-			if err := p.publish(ctx, data); err != nil {
-				p.logger.Warn("Failed to publish to NATS",
-					"sensor_id", data.ID,
-					"error", err)
-				failureCount++
-
-				if p.metrics != nil {
-					p.metrics.NATSPublishFailures.WithLabelValues(
-						strconv.Itoa(data.ID),
-						"publish_error",
-					).Inc()
-				}
-			} else {
-				successCount++
+			// Instrument how long the reading waited in the channel.
+			if p.metrics != nil {
+				p.metrics.MessageQueueAgeSeconds.WithLabelValues("publisher").Observe(p.opts.Clock.Now().Sub(data.Timestamp).Seconds())
+			}
 
-				if p.metrics != nil {
-					p.metrics.NATSPublishSuccess.WithLabelValues(
-						strconv.Itoa(data.ID),
-					).Inc()
+			if async || arrays {
+				batch = append(batch, data)
+				if len(batch) >= batchSize {
+					flush()
 				}
+				continue
 			}
 
-		case <-ticker.C:
+			p.publishOrBuffer(pubCtx, data)
+
+		case <-flushTicker.C():
+			flush()
+			p.retryReconnectBuffer(pubCtx)
+
+		case <-reconnected:
+			reconnected = notifier.Reconnected() // Before retrying, so a reconnect during the retry isn't missed.
+			p.retryReconnectBuffer(pubCtx)
+
+		case <-ticker.C():
 			p.logger.Info("Publisher statistics",
-				"success", successCount,
-				"failures", failureCount,
-				"nats_connected", p.natsClient.IsConnected(),
+				"success", p.successCount.Load(),
+				"failures", p.failureCount.Load(),
+				"nats_connected", p.client.IsConnected(),
 			)
 		}
 	}
 }
 
-// publish publishes a single SensorData message to NATS.
-func (p *Publisher) publish(ctx context.Context, data model.SensorData) error {
-	if !p.natsClient.IsConnected() {
-		return fmt.Errorf("NATS not connected")
+// runSharded distributes messages across the configured number of workers by sensor ID.
+// Each worker is a single-worker Publisher with its own queue, so per-sensor ordering is preserved
+// while different sensors are published concurrently. Closing the data channel stops every worker.
+func (p *Publisher) runSharded(ctx context.Context) {
+	p.logger.Info("Publisher starting", "workers", p.opts.Workers)
+	defer p.logger.Info("Publisher stopping")
+
+	// Workers drain their queues until they're closed: the dispatcher enforces the drain timeout.
+	workerOpts := p.opts
+	workerOpts.Workers = 0
+	workerOpts.DrainTimeout = 0
+
+	shards := make([]chan model.SensorData, p.opts.Workers)
+	workers := make([]*Publisher, p.opts.Workers)
+	var wg sync.WaitGroup
+	for i := range workers {
+		shards[i] = make(chan model.SensorData, workerQueueSize)
+		workers[i] = &Publisher{
+			dataCh:        shards[i],
+			client:        p.client,
+			subjectPrefix: p.subjectPrefix,
+			opts:          workerOpts,
+			shard:         i,
+			metrics:       p.metrics,
+			logger:        p.logger.With("worker", i),
+			reconnectBuf:  ring{capacity: workerOpts.ReconnectBufferSize},
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			workers[i].Run(ctx)
+		}()
+	}
+
+	p.dispatch(ctx, shards)
+	for _, s := range shards {
+		close(s)
+	}
+	wg.Wait()
+
+	for _, w := range workers {
+		p.successCount.Add(w.successCount.Load())
+		p.failureCount.Add(w.failureCount.Load())
+	}
+	p.logger.Info("Data channel closed",
+		"success", p.successCount.Load(),
+		"failures", p.failureCount.Load())
+}
+
+// dispatch sends each message to its sensor's shard until the data channel is closed or,
+// with a DrainTimeout, the timeout elapses after ctx is canceled.
+func (p *Publisher) dispatch(ctx context.Context, shards []chan model.SensorData) {
+	ctxDone := ctx.Done()
+	var drainTimeout <-chan time.Time
+
+	for {
+		select {
+		case <-ctxDone:
+			ctxDone = nil
+			if p.opts.DrainTimeout > 0 {
+				timer := p.opts.Clock.NewTimer(p.opts.DrainTimeout)
+				defer timer.Stop()
+				drainTimeout = timer.C()
+			}
+
+		case <-drainTimeout:
+			p.logger.Warn("Drain timeout elapsed, abandoning the messages left in the data channel",
+				"abandoned", len(p.dataCh))
+			return
+
+		case data, ok := <-p.dataCh:
+			if !ok {
+				return
+			}
+			select {
+			case shards[shard(data.ID, len(shards))] <- data:
+			case <-drainTimeout:
+				p.logger.Warn("Drain timeout elapsed, abandoning the messages left in the data channel",
+					"abandoned", len(p.dataCh)+1)
+				return
+			}
+		}
+	}
+}
+
+// shard returns which of n workers publishes the messages of sensor id.
+// Sensor IDs are sequential, so taking them modulo n spreads sensors evenly across workers.
+func shard(id, n int) int {
+	return int(uint(id) % uint(n))
+}
+
+// publishOrBuffer publishes data, holding it in the reconnect buffer (if enabled)
+// when it can't be published because NATS is disconnected.
+// Buffered messages are retried first, so messages are published in order.
+func (p *Publisher) publishOrBuffer(ctx context.Context, data model.SensorData) {
+	if p.opts.ReconnectBufferSize > 0 {
+		p.retryReconnectBuffer(ctx)
+		if p.reconnectBuf.len() > 0 {
+			p.buffer(ctx, data, errors.New("NATS not connected"))
+			return
+		}
+	}
+
+	if size, attempts, err := p.publishWithRetry(ctx, data); err != nil {
+		if p.opts.ReconnectBufferSize > 0 && !p.client.IsConnected() {
+			p.buffer(ctx, data, err)
+			return
+		}
+		p.recordFailure(ctx, data, publishErrorType(err), attempts, err)
+	} else {
+		p.recordSuccess(data, size)
+	}
+}
+
+// buffer adds data to the reconnect buffer, shedding the oldest buffered message if it's full.
+func (p *Publisher) buffer(ctx context.Context, data model.SensorData, cause error) {
+	if p.reconnectBuf.len() == 0 {
+		p.logger.Warn("NATS disconnected, buffering messages until reconnect",
+			"capacity", p.opts.ReconnectBufferSize)
+	}
+
+	if oldest, shed := p.reconnectBuf.push(data); shed {
+		p.recordFailure(ctx, oldest, "reconnect_buffer_full", 0, cause)
+		return
+	}
+	if p.metrics != nil {
+		p.metrics.BufferedMessages.Inc()
+	}
+}
+
+// retryReconnectBuffer republishes buffered messages, oldest first, while NATS is connected.
+// It stops at the first message that fails because NATS disconnected again.
+func (p *Publisher) retryReconnectBuffer(ctx context.Context) {
+	if p.reconnectBuf.len() == 0 || !p.client.IsConnected() {
+		return
+	}
+
+	p.logger.Info("NATS reconnected, republishing buffered messages", "count", p.reconnectBuf.len())
+	for p.reconnectBuf.len() > 0 {
+		data := p.reconnectBuf.peek()
+		if size, err := p.publish(ctx, data); err != nil {
+			if !p.client.IsConnected() {
+				return
+			}
+			p.recordFailure(ctx, data, publishErrorType(err), 1, err)
+		} else {
+			p.recordSuccess(data, size)
+		}
+		p.reconnectBuf.pop()
+		if p.metrics != nil {
+			p.metrics.BufferedMessages.Dec()
+		}
+	}
+}
+
+// drainReconnectBuffer makes a last attempt at publishing buffered messages on shutdown,
+// recording (and dead-lettering) any that are still waiting for NATS to reconnect.
+func (p *Publisher) drainReconnectBuffer(ctx context.Context) {
+	p.retryReconnectBuffer(context.WithoutCancel(ctx))
+
+	for p.reconnectBuf.len() > 0 {
+		p.recordFailure(ctx, p.reconnectBuf.pop(), "reconnect_buffer_shutdown", 0, errors.New("NATS not connected"))
+		if p.metrics != nil {
+			p.metrics.BufferedMessages.Dec()
+		}
+	}
+}
+
+// publish publishes a single SensorData message to NATS, returning its encoded payload size.
+func (p *Publisher) publish(ctx context.Context, data model.SensorData) (int, error) {
+	if !p.client.IsConnected() {
+		return 0, fmt.Errorf("NATS not connected")
 	}
 
-	// Construct the message subject as `iot.sensors.data.{sensor_id}`
-	subject := fmt.Sprintf("%s.data.%d", p.subjectPrefix, data.ID)
+	e := getEncoder()
+	msg, err := p.message(data, e)
+	if err != nil {
+		return 0, err
+	}
+	size := len(msg.Data)
+	if err := p.compress(msg, e); err != nil {
+		return 0, err
+	}
 
 	// Measure publish latency
-	start := time.Now()
+	start := p.opts.Clock.Now()
 
-	publishCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	publishCtx, cancel := context.WithTimeout(ctx, p.opts.PublishTimeout)
 	defer cancel()
 
-	err := p.natsClient.PublishJson(publishCtx, subject, data)
+	err = p.send(publishCtx, msg)
+	if err == nil {
+		putEncoder(e)
+	}
 
 	if p.metrics != nil {
-		duration := time.Since(start).Seconds()
+		duration := p.opts.Clock.Now().Sub(start).Seconds()
 		p.metrics.NATSPublishLatency.WithLabelValues(
 			strconv.Itoa(data.ID),
 		).Observe(duration)
 	}
 
-	return err
+	return size, err
+}
+
+// publishWithRetry publishes data, retrying failed publishes (up to RetryAttempts times) with exponential backoff.
+// It stops retrying once Run's context is canceled, and returns the number of attempts made and the last attempt's error.
+func (p *Publisher) publishWithRetry(ctx context.Context, data model.SensorData) (size, attempts int, err error) {
+	size, err = p.publish(ctx, data)
+	attempts = 1
+	if err == nil || p.opts.RetryAttempts <= 0 {
+		return size, attempts, err
+	}
+
+	delay := p.opts.RetryInitialDelay
+	if delay <= 0 {
+		delay = DefaultRetryInitialDelay
+	}
+	maxDelay := p.opts.RetryMaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+
+	for attempt := 1; err != nil && attempt <= p.opts.RetryAttempts; attempt++ {
+		timer := p.opts.Clock.NewTimer(min(delay, maxDelay))
+		select {
+		case <-p.done:
+			timer.Stop()
+			return size, attempts, err
+		case <-timer.C():
+		}
+
+		p.logger.Debug("Retrying publish", "sensor_id", data.ID, "attempt", attempt, "error", err)
+		if p.metrics != nil {
+			p.metrics.NATSPublishRetries.WithLabelValues(strconv.Itoa(data.ID)).Inc()
+		}
+		size, err = p.publish(ctx, data)
+		attempts++
+		delay *= 2
+	}
+	return size, attempts, err
+}
+
+// publishBatch publishes a batch of messages asynchronously, then waits for each message's ack.
+// Success and failure are attributed per message, so a partially acked batch
+// only records (and dead-letters) the messages that actually failed.
+func (p *Publisher) publishBatch(ctx context.Context, client AsyncClient, batch []model.SensorData) {
+	start := p.opts.Clock.Now()
+	pending := make([]pendingAck, 0, len(batch))
+
+	for _, data := range batch {
+		e := getEncoder()
+		msg, err := p.message(data, e)
+		if err != nil {
+			p.recordFailure(ctx, data, "marshal_error", 0, err)
+			continue
+		}
+		size := len(msg.Data)
+		if err := p.compress(msg, e); err != nil {
+			p.recordFailure(ctx, data, "marshal_error", 0, err)
+			continue
+		}
+
+		future, err := client.PublishMsgAsync(msg)
+		if err != nil {
+			p.recordFailure(ctx, data, "publish_error", 1, err)
+			continue
+		}
+		pending = append(pending, pendingAck{data: data, size: size, future: future, encoder: e})
+	}
+
+	// Acks for messages already sent are still worth collecting during shutdown,
+	// so the wait isn't tied to ctx's cancellation.
+	ackCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ackTimeout)
+	defer cancel()
+
+	for _, pa := range pending {
+		select {
+		case <-pa.future.Ok():
+			p.recordSuccess(pa.data, pa.size)
+			putEncoder(pa.encoder)
+		case err := <-pa.future.Err():
+			p.recordFailure(ctx, pa.data, "ack_error", 1, err)
+		case <-ackCtx.Done():
+			p.recordFailure(ctx, pa.data, "ack_timeout", 1, ackCtx.Err())
+		}
+
+		if p.metrics != nil {
+			p.metrics.NATSPublishLatency.WithLabelValues(
+				strconv.Itoa(pa.data.ID),
+			).Observe(p.opts.Clock.Now().Sub(start).Seconds())
+		}
+	}
+}
+
+// publishArray publishes a batch of messages as a single JSON array, on the worker's batch subject.
+// The batch succeeds or fails as a whole, but is still recorded (and dead-lettered) per message.
+func (p *Publisher) publishArray(ctx context.Context, batch []model.SensorData) {
+	e := getEncoder()
+	e.buf.WriteByte('[')
+	sizes := make([]int, 0, len(batch))
+	encoded := make([]model.SensorData, 0, len(batch))
+	for _, data := range batch {
+		if len(encoded) > 0 {
+			e.buf.WriteByte(',')
+		}
+		size, err := e.append(data)
+		if err != nil {
+			if len(encoded) > 0 {
+				e.buf.Truncate(e.buf.Len() - 1) // Drop the separator.
+			}
+			p.recordFailure(ctx, data, "marshal_error", 0, err)
+			continue
+		}
+		sizes = append(sizes, size)
+		encoded = append(encoded, data)
+	}
+	e.buf.WriteByte(']')
+	if len(encoded) == 0 {
+		putEncoder(e)
+		return
+	}
+
+	start := p.opts.Clock.Now()
+	err := fmt.Errorf("NATS not connected")
+	attempts := 0
+	if p.client.IsConnected() {
+		attempts = 1
+		msg := natsio.NewMsg(fmt.Sprintf("%s.batch.%d", p.subjectPrefix, p.shard))
+		msg.Data = e.buf.Bytes()
+
+		if err = p.compress(msg, e); err == nil {
+			publishCtx, cancel := context.WithTimeout(ctx, p.opts.PublishTimeout)
+			err = p.send(publishCtx, msg)
+			cancel()
+		}
+	}
+	if err == nil {
+		putEncoder(e)
+	}
+
+	for i, data := range encoded {
+		if err != nil {
+			p.recordFailure(ctx, data, publishErrorType(err), attempts, err)
+			continue
+		}
+		p.recordSuccess(data, sizes[i])
+		if p.metrics != nil {
+			p.metrics.NATSPublishLatency.WithLabelValues(
+				strconv.Itoa(data.ID),
+			).Observe(p.opts.Clock.Now().Sub(start).Seconds())
+		}
+	}
+}
+
+// publishErrorType returns the error type a failed publish is counted under:
+// "publish_timeout" if it timed out waiting for the broker, or "publish_error" otherwise (e.g. NATS being disconnected).
+func publishErrorType(err error) string {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, natsio.ErrTimeout) {
+		return "publish_timeout"
+	}
+	return "publish_error"
+}
+
+// recordSuccess counts a successfully published message, with an encoded payload of size bytes.
+func (p *Publisher) recordSuccess(data model.SensorData, size int) {
+	p.successCount.Add(1)
+
+	if p.metrics != nil {
+		p.metrics.NATSPublishSuccess.WithLabelValues(
+			strconv.Itoa(data.ID),
+		).Inc()
+		p.metrics.NATSBytesPublished.WithLabelValues(data.Model).Add(float64(size))
+	}
+}
+
+// recordFailure counts a message that failed to publish after attempts attempts,
+// and forwards it to the dead-letter subject.
+func (p *Publisher) recordFailure(ctx context.Context, data model.SensorData, errorType string, attempts int, err error) {
+	p.logger.Warn("Failed to publish to NATS",
+		"sensor_id", data.ID,
+		"error_type", errorType,
+		"error", err)
+	p.failureCount.Add(1)
+
+	if p.metrics != nil {
+		p.metrics.NATSPublishFailures.WithLabelValues(
+			strconv.Itoa(data.ID),
+			errorType,
+		).Inc()
+	}
+
+	p.deadLetter(ctx, data, attempts, err)
+}
+
+// writeFailed counts the readings of a message the sink failed to write after it was published
+// (see WriteFailureNotifier) as failed, with the "write_error" type, and dead-letters them.
+// A dead letter that fails to be written is logged in full instead.
+func (p *Publisher) writeFailed(subject string, payload []byte, err error) {
+	if p.opts.DeadLetterSubject != "" && subject == p.opts.DeadLetterSubject {
+		p.logger.Error("Failed to write dead letter", "dead_letter", string(payload), "error", err)
+		return
+	}
+
+	readings, decodeErr := p.decode(subject, payload)
+	if decodeErr != nil {
+		p.logger.Error("Failed to write message, and to decode it for dead-lettering",
+			"subject", subject,
+			"error", err,
+			"decode_error", decodeErr)
+		return
+	}
+	for _, data := range readings {
+		p.recordFailure(context.Background(), data, "write_error", 1, err)
+	}
+}
+
+// decode returns the readings of a payload the publisher published to subject without headers:
+// a batch's JSON array, or a reading encoded with the codec.
+func (p *Publisher) decode(subject string, payload []byte) ([]model.SensorData, error) {
+	if strings.HasPrefix(subject, p.subjectPrefix+".batch.") {
+		var batch []model.SensorData
+		if err := json.Unmarshal(payload, &batch); err != nil {
+			return nil, err
+		}
+		return batch, nil
+	}
+
+	c := p.opts.Codec
+	if c == nil {
+		c = codec.JSONCodec{}
+	}
+	var data model.SensorData
+	if err := c.Unmarshal(payload, &data); err != nil {
+		return nil, err
+	}
+	return []model.SensorData{data}, nil
+}
+
+// deadLetter publishes a failed message to the dead-letter subject, if one is configured.
+// If that fails too, the message is logged in full, so it isn't lost without a trace.
+func (p *Publisher) deadLetter(ctx context.Context, data model.SensorData, attempts int, cause error) {
+	if p.opts.DeadLetterSubject == "" {
+		return
+	}
+
+	letter := DeadLetter{Data: data, Error: cause.Error(), Attempts: attempts}
+	// The dead-letter subject is on the broker that just failed, so while it's disconnected the letter is logged
+	// rather than published. Waiting out a publish that can't succeed would stall the publisher for every message
+	// shed from (or drained out of) the reconnect buffer during an outage.
+	if !p.client.IsConnected() {
+		p.logger.Error("Broker disconnected, logging dead letter instead",
+			"sensor_id", data.ID,
+			"dead_letter", letter)
+		return
+	}
+
+	dlqCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), ackTimeout)
+	defer cancel()
+
+	if err := p.client.PublishJson(dlqCtx, p.opts.DeadLetterSubject, letter); err != nil {
+		p.logger.Error("Failed to dead-letter message",
+			"sensor_id", data.ID,
+			"dead_letter", letter,
+			"error", err)
+		return
+	}
+	if p.metrics != nil {
+		p.metrics.DeadLetteredMessages.Inc()
+	}
+}
+
+// send publishes msg, with its headers if the sink is a Client.
+func (p *Publisher) send(ctx context.Context, msg *natsio.Msg) error {
+	if client, ok := p.client.(Client); ok {
+		return client.PublishMsg(ctx, msg)
+	}
+	return p.client.Publish(ctx, msg.Subject, msg.Data)
+}
+
+// message builds the NATS message for data: its encoding with the configured codec (into e's buffer,
+// which the message references), with the codec's content type (unless JSON), the device model
+// and firmware version (when known), and tags, as headers.
+func (p *Publisher) message(data model.SensorData, e *encoder) (*natsio.Msg, error) {
+	if err := e.appendCodec(p.opts.Codec, data); err != nil {
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+
+	msg := natsio.NewMsg(p.subject(data))
+	msg.Data = e.buf.Bytes()
+	if p.opts.Codec != nil && p.opts.Codec.ContentType() != codec.ContentTypeJSON {
+		msg.Header.Set(codec.HeaderContentType, p.opts.Codec.ContentType())
+	}
+	if data.Model != "" {
+		msg.Header.Set(HeaderModel, data.Model)
+	}
+	if data.FirmwareVersion != "" {
+		msg.Header.Set(HeaderFirmwareVersion, data.FirmwareVersion)
+	}
+	for k, v := range data.Tags {
+		msg.Header.Set(HeaderTagPrefix+k, v)
+	}
+	return msg, nil
+}
+
+// compress gzip-compresses msg's payload (e's buffer) into e, if it's larger than CompressThreshold
+// and the sink is a Client, and sets its Content-Encoding header.
+func (p *Publisher) compress(msg *natsio.Msg, e *encoder) error {
+	if p.opts.CompressThreshold <= 0 || len(msg.Data) <= p.opts.CompressThreshold {
+		return nil
+	}
+	if _, ok := p.client.(Client); !ok {
+		return nil
+	}
+
+	compressed, err := e.gzip()
+	if err != nil {
+		return fmt.Errorf("failed to compress payload: %w", err)
+	}
+	if p.metrics != nil {
+		p.metrics.CompressionRatio.Observe(float64(len(compressed)) / float64(len(msg.Data)))
+	}
+	msg.Data = compressed
+	msg.Header.Set(nats.HeaderContentEncoding, nats.EncodingGzip)
+	return nil
+}
+
+// subject returns the subject a message is published to, i.e. `iot.sensors.data.{sensor_id}`,
+// or `iot.sensors.data.{type}.{sensor_id}` for readings with a type.
+func (p *Publisher) subject(data model.SensorData) string {
+	if data.Type != "" {
+		return fmt.Sprintf("%s.data.%s.%d", p.subjectPrefix, subjectToken(data.Type), data.ID)
+	}
+	return fmt.Sprintf("%s.data.%d", p.subjectPrefix, data.ID)
+}
+
+// subjectToken makes s safe to use as a single subject token,
+// replacing the separators, wildcards, and whitespace NATS doesn't allow in one.
+func subjectToken(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '*' || r == '>' || unicode.IsSpace(r) {
+			return '_'
+		}
+		return r
+	}, s)
 }
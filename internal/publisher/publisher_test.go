@@ -2,32 +2,157 @@
 package publisher_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	natsio "github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/clock"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/codec"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/nats"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/publisher"
 )
 
+// fakeFuture is a jetstream.PubAckFuture that resolves immediately with an ack or an error.
+type fakeFuture struct {
+	ok  chan *jetstream.PubAck
+	err chan error
+	msg *natsio.Msg
+}
+
+func (f *fakeFuture) Ok() <-chan *jetstream.PubAck { return f.ok }
+func (f *fakeFuture) Err() <-chan error            { return f.err }
+func (f *fakeFuture) Msg() *natsio.Msg             { return f.msg }
+
+// published records a message published through the fake client.
+type published struct {
+	subject string
+	payload []byte
+	header  natsio.Header
+}
+
+// fakeAsyncClient is a publisher.AsyncClient that records every publish
+// and acks async publishes unless failAck reports otherwise.
+// Setting disconnected simulates a dropped NATS connection, failing every publish.
+type fakeAsyncClient struct {
+	failAck      func(subject string) bool
+	failPublish  func(subject string) bool
+	disconnected atomic.Bool
+
+	mu        sync.Mutex
+	published []published
+}
+
+func (c *fakeAsyncClient) IsConnected() bool { return !c.disconnected.Load() }
+
+func (c *fakeAsyncClient) PublishJson(_ context.Context, subject string, v any) error {
+	if c.disconnected.Load() {
+		return natsio.ErrConnectionClosed
+	}
+
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.published = append(c.published, published{subject: subject, payload: payload})
+	return nil
+}
+
+func (c *fakeAsyncClient) Publish(ctx context.Context, subject string, data []byte) error {
+	return c.PublishMsg(ctx, &natsio.Msg{Subject: subject, Data: data})
+}
+
+func (c *fakeAsyncClient) PublishMsg(_ context.Context, msg *natsio.Msg) error {
+	if c.disconnected.Load() {
+		return natsio.ErrConnectionClosed
+	}
+	if c.failPublish != nil && c.failPublish(msg.Subject) {
+		return errors.New("nats: no response from stream")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// The publisher reuses payloads once a publish succeeds, so they're copied.
+	c.published = append(c.published, published{subject: msg.Subject, payload: bytes.Clone(msg.Data), header: msg.Header})
+	return nil
+}
+
+func (c *fakeAsyncClient) PublishMsgAsync(msg *natsio.Msg) (jetstream.PubAckFuture, error) {
+	f := &fakeFuture{
+		ok:  make(chan *jetstream.PubAck, 1),
+		err: make(chan error, 1),
+		msg: msg,
+	}
+	if c.failAck != nil && c.failAck(msg.Subject) {
+		f.err <- errors.New("nats: timeout")
+	} else {
+		f.ok <- &jetstream.PubAck{Stream: "IOT_SENSORS"}
+	}
+	return f, nil
+}
+
+// publishedTo returns the payloads synchronously published to subject.
+func (c *fakeAsyncClient) publishedTo(subject string) [][]byte {
+	var payloads [][]byte
+	for _, p := range c.messagesTo(subject) {
+		payloads = append(payloads, p.payload)
+	}
+	return payloads
+}
+
+// messagesTo returns the messages synchronously published to subject.
+func (c *fakeAsyncClient) messagesTo(subject string) []published {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var msgs []published
+	for _, p := range c.published {
+		if p.subject == subject {
+			msgs = append(msgs, p)
+		}
+	}
+	return msgs
+}
+
 // TestNew verifies that New creates a Publisher instance.
 func TestNew(t *testing.T) {
 	t.Parallel()
 
 	dataCh := make(chan model.SensorData)
-	pub := publisher.New(dataCh, nil, "iot.sensors", nil, nil)
+	pub := publisher.New(dataCh, nil, "iot.sensors", publisher.Options{}, nil, nil)
 
 	if pub == nil {
 		t.Fatal("New returned nil")
 	}
 }
 
-// TestPublisher_Run_StopsOnContextCancel verifies the publisher stops when context is canceled.
-func TestPublisher_Run_StopsOnContextCancel(t *testing.T) {
+// TestPublisher_Run_DrainsAfterContextCancel verifies the publisher keeps running after its context is canceled,
+// and only stops once the data channel is closed.
+func TestPublisher_Run_DrainsAfterContextCancel(t *testing.T) {
 	t.Parallel()
 
 	dataCh := make(chan model.SensorData)
-	pub := publisher.New(dataCh, nil, "iot.sensors", nil, nil)
+	pub := publisher.New(dataCh, nil, "iot.sensors", publisher.Options{}, nil, nil)
 
 	ctx, cancel := context.WithCancel(context.Background())
 
@@ -42,9 +167,85 @@ func TestPublisher_Run_StopsOnContextCancel(t *testing.T) {
 
 	select {
 	case <-runFinished:
-		// Expected behavior: Run exited gracefully
+		t.Fatal("Publisher stopped on context cancellation, before the data channel was closed")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(dataCh)
+
+	select {
+	case <-runFinished:
+		// Expected behavior: Run exited once the channel was closed
 	case <-time.After(100 * time.Millisecond):
-		t.Fatal("Publisher did not stop after context cancellation")
+		t.Fatal("Publisher did not stop after the data channel was closed")
+	}
+}
+
+// TestPublisher_Run_DrainTimeout verifies that with a DrainTimeout, the publisher stops draining
+// once the timeout elapses after its context is canceled, even though the data channel isn't closed,
+// logging how many messages it drained and abandoned.
+func TestPublisher_Run_DrainTimeout(t *testing.T) {
+	t.Parallel()
+
+	const n = 100
+	client := &slowClient{delay: 5 * time.Millisecond}
+	dataCh := make(chan model.SensorData, n)
+	for id := 1; id <= n; id++ {
+		dataCh <- model.SensorData{ID: id}
+	}
+
+	logs := &bytes.Buffer{}
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+	opts := publisher.Options{DrainTimeout: 50 * time.Millisecond}
+	pub := publisher.New(dataCh, client, "iot.sensors", opts, nil, logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	runFinished := make(chan struct{})
+	go func() {
+		pub.Run(ctx)
+		close(runFinished)
+	}()
+
+	select {
+	case <-runFinished:
+	case <-time.After(time.Second):
+		t.Fatal("publisher did not stop after its drain timeout elapsed")
+	}
+
+	published := len(client.publishedIDs(t, "iot.sensors"))
+	if published == 0 || published == n {
+		t.Errorf("expected some but not all of the %d messages to be drained, got %d", n, published)
+	}
+	// The first message may be received before the cancellation is noticed, so isn't necessarily counted as drained.
+	if !strings.Contains(logs.String(), "drained=") ||
+		!strings.Contains(logs.String(), "abandoned="+strconv.Itoa(len(dataCh))) {
+		t.Errorf("expected the drained and abandoned counts to be logged, got logs:\n%s", logs.String())
+	}
+}
+
+// TestPublisher_Run_PublishesBufferedMessagesOnShutdown verifies every message still buffered
+// in the data channel at shutdown is published before Run returns.
+func TestPublisher_Run_PublishesBufferedMessagesOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	const n = 20
+	client := &fakeAsyncClient{}
+	dataCh := make(chan model.SensorData, n)
+	for i := 1; i <= n; i++ {
+		dataCh <- model.SensorData{ID: i}
+	}
+	close(dataCh)
+
+	// The context is already canceled, as it is by the time main closes the data channel.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	publisher.New(dataCh, client, "iot.sensors", publisher.Options{}, nil, nil).Run(ctx)
+
+	if got := client.publishedIDs(t, "iot.sensors"); len(got) != n {
+		t.Errorf("expected all %d buffered messages to be published, got %d", n, len(got))
 	}
 }
 
@@ -53,7 +254,7 @@ func TestPublisher_Run_StopsOnChannelClose(t *testing.T) {
 	t.Parallel()
 
 	dataCh := make(chan model.SensorData)
-	pub := publisher.New(dataCh, nil, "iot.sensors", nil, nil)
+	pub := publisher.New(dataCh, nil, "iot.sensors", publisher.Options{}, nil, nil)
 
 	ctx := context.Background()
 
@@ -74,9 +275,1095 @@ func TestPublisher_Run_StopsOnChannelClose(t *testing.T) {
 	}
 }
 
-// TODO: Integration tests with a real NATS connection:
-// - successful publishing to NATS
-// - error handling when NATS is unavailable
-// - metrics recording
-// - publishing multiple messages
-// - subject formatting
+// TestPublisher_Run_AsyncBatchPartialAcks verifies that in async batch mode
+// success and failure are attributed per message rather than per batch,
+// and only the messages whose acks failed are dead-lettered.
+func TestPublisher_Run_AsyncBatchPartialAcks(t *testing.T) {
+	t.Parallel()
+
+	const dlq = "iot.sensors.dlq"
+	failed := map[string]bool{
+		"iot.sensors.data.2": true,
+		"iot.sensors.data.5": true,
+	}
+	client := &fakeAsyncClient{failAck: func(subject string) bool { return failed[subject] }}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+
+	dataCh := make(chan model.SensorData, 6)
+	for i := 1; i <= 6; i++ {
+		dataCh <- model.SensorData{ID: i, Value: float64(i)}
+	}
+	close(dataCh)
+
+	pub := publisher.New(dataCh, client, "iot.sensors", publisher.Options{
+		AsyncBatchSize:    3,
+		DeadLetterSubject: dlq,
+	}, m, nil)
+	pub.Run(context.Background())
+
+	for i := 1; i <= 6; i++ {
+		id := strconv.Itoa(i)
+		wantSuccess, wantFailure := 1.0, 0.0
+		if failed["iot.sensors.data."+id] {
+			wantSuccess, wantFailure = 0, 1
+		}
+
+		if got := testutil.ToFloat64(m.NATSPublishSuccess.WithLabelValues(id)); got != wantSuccess {
+			t.Errorf("sensor %s: expected %v successes, got %v", id, wantSuccess, got)
+		}
+		if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues(id, "ack_error")); got != wantFailure {
+			t.Errorf("sensor %s: expected %v ack failures, got %v", id, wantFailure, got)
+		}
+	}
+
+	var deadIDs []int
+	for _, payload := range client.publishedTo(dlq) {
+		var letter publisher.DeadLetter
+		if err := json.Unmarshal(payload, &letter); err != nil {
+			t.Fatalf("failed to decode dead letter %s: %v", payload, err)
+		}
+		if letter.Error == "" {
+			t.Errorf("expected dead letter for sensor %d to carry an error", letter.Data.ID)
+		}
+		if letter.Attempts != 1 {
+			t.Errorf("expected dead letter for sensor %d to record 1 attempt, got %d", letter.Data.ID, letter.Attempts)
+		}
+		deadIDs = append(deadIDs, letter.Data.ID)
+	}
+	sort.Ints(deadIDs)
+
+	if len(deadIDs) != 2 || deadIDs[0] != 2 || deadIDs[1] != 5 {
+		t.Errorf("expected dead letters for sensors [2 5], got %v", deadIDs)
+	}
+}
+
+// TestPublisher_Run_ModelHeaders verifies that a reading's model, firmware version, and tags
+// are published both in the JSON record and as NATS headers.
+func TestPublisher_Run_ModelHeaders(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	dataCh := make(chan model.SensorData, 1)
+	dataCh <- model.SensorData{ID: 7, Value: 0.5, Model: "SIM-100", FirmwareVersion: "1.4.2", Tags: map[string]string{"site": "north"}}
+	close(dataCh)
+
+	publisher.New(dataCh, client, "iot.sensors", publisher.Options{}, nil, nil).Run(context.Background())
+
+	msgs := client.messagesTo("iot.sensors.data.7")
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(msgs))
+	}
+
+	if got := msgs[0].header.Get(publisher.HeaderModel); got != "SIM-100" {
+		t.Errorf("expected %s header %q, got %q", publisher.HeaderModel, "SIM-100", got)
+	}
+	if got := msgs[0].header.Get(publisher.HeaderFirmwareVersion); got != "1.4.2" {
+		t.Errorf("expected %s header %q, got %q", publisher.HeaderFirmwareVersion, "1.4.2", got)
+	}
+	if got := msgs[0].header.Get(publisher.HeaderTagPrefix + "site"); got != "north" {
+		t.Errorf("expected %ssite header %q, got %q", publisher.HeaderTagPrefix, "north", got)
+	}
+
+	var record model.SensorData
+	if err := json.Unmarshal(msgs[0].payload, &record); err != nil {
+		t.Fatalf("failed to decode published record: %v", err)
+	}
+	if record.Model != "SIM-100" || record.FirmwareVersion != "1.4.2" {
+		t.Errorf("expected model/firmware SIM-100/1.4.2 in record, got %s/%s", record.Model, record.FirmwareVersion)
+	}
+	if record.Tags["site"] != "north" {
+		t.Errorf("expected tag site=north in record, got %v", record.Tags)
+	}
+}
+
+// TestPublisher_Run_Codec verifies messages are encoded with the configured codec,
+// and carry a Content-Type header naming it.
+func TestPublisher_Run_Codec(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	dataCh := make(chan model.SensorData, 1)
+	want := model.SensorData{ID: 7, Value: 0.5, Model: "SIM-100"}
+	dataCh <- want
+	close(dataCh)
+
+	opts := publisher.Options{Codec: codec.ProtoCodec{}}
+	publisher.New(dataCh, client, "iot.sensors", opts, nil, nil).Run(context.Background())
+
+	msgs := client.messagesTo("iot.sensors.data.7")
+	if len(msgs) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(msgs))
+	}
+	if got := msgs[0].header.Get(codec.HeaderContentType); got != codec.ContentTypeProto {
+		t.Errorf("expected %s header %q, got %q", codec.HeaderContentType, codec.ContentTypeProto, got)
+	}
+
+	var got model.SensorData
+	if err := (codec.ProtoCodec{}).Unmarshal(msgs[0].payload, &got); err != nil {
+		t.Fatalf("failed to decode published record: %v", err)
+	}
+	if got.ID != want.ID || got.Value != want.Value || got.Model != want.Model {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+// TestPublisher_Run_RetriesFailedPublishes verifies a failed publish is retried with a doubling backoff,
+// with every retry counted, until it succeeds.
+func TestPublisher_Run_RetriesFailedPublishes(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	client.disconnected.Store(true)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 1)
+	dataCh <- model.SensorData{ID: 7}
+	close(dataCh)
+
+	clk := clock.NewFake(time.Now())
+	opts := publisher.Options{RetryAttempts: 10, RetryInitialDelay: time.Second, RetryMaxDelay: time.Minute, Clock: clk}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		publisher.New(dataCh, client, "iot.sensors", opts, m, nil).Run(context.Background())
+	}()
+
+	// The statistics and flush tickers, and the backoff timer, wait on the clock during each backoff.
+	const waiting = 3
+	clk.BlockUntil(waiting)
+	clk.Advance(time.Second)
+	clk.BlockUntil(waiting) // The first retry failed, and the backoff doubled.
+	if got := testutil.ToFloat64(m.NATSPublishRetries.WithLabelValues("7")); got != 1 {
+		t.Fatalf("expected 1 retry after the first backoff, got %v", got)
+	}
+
+	// Reconnect, and the second retry, after twice the backoff, succeeds.
+	client.disconnected.Store(false)
+	clk.Advance(time.Second)
+	select {
+	case <-done:
+		t.Fatal("expected the second backoff to be twice the first")
+	default:
+	}
+	clk.Advance(time.Second)
+	<-done
+
+	if got := len(client.publishedTo("iot.sensors.data.7")); got != 1 {
+		t.Errorf("expected the message to be published once reconnected, got %d publishes", got)
+	}
+	if got := testutil.ToFloat64(m.NATSPublishRetries.WithLabelValues("7")); got != 2 {
+		t.Errorf("expected 2 retries, got %v", got)
+	}
+	if got := testutil.CollectAndCount(m.NATSPublishFailures); got != 0 {
+		t.Errorf("expected no publish failures, got %d series", got)
+	}
+}
+
+// TestPublisher_Run_RetriesExhausted verifies a message is given up on (and counted as failed)
+// after its retries are exhausted.
+func TestPublisher_Run_RetriesExhausted(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	client.disconnected.Store(true)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 1)
+	dataCh <- model.SensorData{ID: 7}
+	close(dataCh)
+
+	opts := publisher.Options{RetryAttempts: 3, RetryInitialDelay: time.Millisecond}
+	publisher.New(dataCh, client, "iot.sensors", opts, m, nil).Run(context.Background())
+
+	if got := testutil.ToFloat64(m.NATSPublishRetries.WithLabelValues("7")); got != 3 {
+		t.Errorf("expected 3 retries, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues("7", "publish_error")); got != 1 {
+		t.Errorf("expected the message to fail once retries were exhausted, got %v failures", got)
+	}
+}
+
+// TestPublisher_Run_DeadLettersExhaustedRetries verifies a message whose retries are exhausted
+// is forwarded to the dead-letter subject with its error and attempt count.
+func TestPublisher_Run_DeadLettersExhaustedRetries(t *testing.T) {
+	t.Parallel()
+
+	dlq := publisher.DeadLetterSubject("iot.sensors")
+	client := &fakeAsyncClient{failPublish: func(subject string) bool { return subject != dlq }}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 1)
+	dataCh <- model.SensorData{ID: 7, Value: 21.5}
+	close(dataCh)
+
+	opts := publisher.Options{RetryAttempts: 2, RetryInitialDelay: time.Millisecond, DeadLetterSubject: dlq}
+	publisher.New(dataCh, client, "iot.sensors", opts, m, nil).Run(context.Background())
+
+	letters := client.publishedTo(dlq)
+	if len(letters) != 1 {
+		t.Fatalf("expected 1 dead letter on %s, got %d", dlq, len(letters))
+	}
+	var letter publisher.DeadLetter
+	if err := json.Unmarshal(letters[0], &letter); err != nil {
+		t.Fatalf("failed to decode dead letter %s: %v", letters[0], err)
+	}
+	if letter.Data.ID != 7 || letter.Data.Value != 21.5 {
+		t.Errorf("expected the original reading, got %+v", letter.Data)
+	}
+	if !strings.Contains(letter.Error, "no response from stream") {
+		t.Errorf("expected the last publish error, got %q", letter.Error)
+	}
+	if letter.Attempts != 3 {
+		t.Errorf("expected 3 attempts (1 + 2 retries), got %d", letter.Attempts)
+	}
+	if got := testutil.ToFloat64(m.DeadLetteredMessages); got != 1 {
+		t.Errorf("expected 1 dead-lettered message, got %v", got)
+	}
+}
+
+// TestPublisher_Run_RetriesStopOnCancel verifies canceling the context cuts a retry backoff short,
+// so the message is given up on without delaying shutdown.
+func TestPublisher_Run_RetriesStopOnCancel(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	client.disconnected.Store(true)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 1)
+	dataCh <- model.SensorData{ID: 7}
+	close(dataCh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	clk := clock.NewFake(time.Now())
+	opts := publisher.Options{RetryAttempts: 3, RetryInitialDelay: time.Hour, Clock: clk}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		publisher.New(dataCh, client, "iot.sensors", opts, m, nil).Run(ctx)
+	}()
+
+	// Cancel once the publisher is waiting out the backoff (behind its statistics and flush tickers),
+	// which the clock never passes.
+	clk.BlockUntil(3)
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publisher did not stop retrying after its context was canceled")
+	}
+	if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues("7", "publish_error")); got != 1 {
+		t.Errorf("expected the message to be counted as failed, got %v failures", got)
+	}
+}
+
+// hangingSink is a publisher.Sink recording the contexts it's given to publish with.
+// With hang set, publishes never complete, returning only once their context is done, like a broker that never acks.
+// With disconnected set, it reports being disconnected (but still hangs, if asked to publish anyway).
+type hangingSink struct {
+	hang         bool
+	disconnected bool
+
+	mu   sync.Mutex
+	ctxs []context.Context
+}
+
+func (s *hangingSink) IsConnected() bool { return !s.disconnected }
+
+func (s *hangingSink) Publish(ctx context.Context, _ string, _ []byte) error {
+	s.mu.Lock()
+	s.ctxs = append(s.ctxs, ctx)
+	s.mu.Unlock()
+
+	if !s.hang {
+		return nil
+	}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (s *hangingSink) PublishJson(ctx context.Context, subject string, _ any) error {
+	return s.Publish(ctx, subject, nil)
+}
+
+// TestPublisher_Run_PublishTimeout verifies publishes are bounded by the configured PublishTimeout,
+// counted as timeouts rather than publish errors, and that every publish's context is released once it returns.
+func TestPublisher_Run_PublishTimeout(t *testing.T) {
+	t.Parallel()
+
+	for name, hang := range map[string]bool{"hanging": true, "acking": false} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			sink := &hangingSink{hang: hang}
+			m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+			dataCh := make(chan model.SensorData, 2)
+			dataCh <- model.SensorData{ID: 7}
+			dataCh <- model.SensorData{ID: 8}
+			close(dataCh)
+
+			opts := publisher.Options{PublishTimeout: 20 * time.Millisecond, RetryAttempts: 1, RetryInitialDelay: time.Millisecond}
+			start := time.Now()
+			publisher.New(dataCh, sink, "iot.sensors", opts, m, nil).Run(context.Background())
+			if elapsed := time.Since(start); elapsed >= publisher.DefaultPublishTimeout {
+				t.Errorf("expected the publishes to time out after 20ms each, took %v in all", elapsed)
+			}
+
+			wantTimeouts := 0.0
+			if hang {
+				wantTimeouts = 1 // Each message times out on both its attempts, then is counted as failed once.
+			}
+			for _, id := range []string{"7", "8"} {
+				if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues(id, "publish_timeout")); got != wantTimeouts {
+					t.Errorf("sensor %s: expected %v publish timeouts, got %v", id, wantTimeouts, got)
+				}
+				if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues(id, "publish_error")); got != 0 {
+					t.Errorf("sensor %s: expected timeouts not to be counted as publish errors, got %v", id, got)
+				}
+			}
+
+			sink.mu.Lock()
+			defer sink.mu.Unlock()
+			if want := map[bool]int{true: 4, false: 2}[hang]; len(sink.ctxs) != want {
+				t.Fatalf("expected %d publishes, got %d", want, len(sink.ctxs))
+			}
+			for i, ctx := range sink.ctxs {
+				if _, ok := ctx.Deadline(); !ok || ctx.Err() == nil {
+					t.Errorf("publish %d: expected a context with a deadline, released once the publish returned, got err %v", i, ctx.Err())
+				}
+			}
+		})
+	}
+}
+
+// TestPublisher_Run_DeadLettersWhileDisconnected verifies messages shed from the reconnect buffer during an outage,
+// and drained out of it on shutdown, are logged rather than dead-lettered to the disconnected broker,
+// so that shedding doesn't wait on a publish that can't succeed.
+func TestPublisher_Run_DeadLettersWhileDisconnected(t *testing.T) {
+	t.Parallel()
+
+	const n, buffered = 100, 10
+	sink := &hangingSink{hang: true, disconnected: true}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, n)
+	for id := 1; id <= n; id++ {
+		dataCh <- model.SensorData{ID: id}
+	}
+	close(dataCh)
+
+	logs := &bytes.Buffer{}
+	opts := publisher.Options{ReconnectBufferSize: buffered, DeadLetterSubject: publisher.DeadLetterSubject("iot.sensors")}
+	pub := publisher.New(dataCh, sink, "iot.sensors", opts, m, slog.New(slog.NewTextHandler(logs, nil)))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pub.Run(context.Background())
+	}()
+	// Publishing each dead letter would take 2s (the ack timeout), so n of them minutes.
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher blocked dead-lettering messages to a disconnected broker")
+	}
+
+	if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues("1", "reconnect_buffer_full")); got != 1 {
+		t.Errorf("expected the oldest message to be shed from the full reconnect buffer, got %v failures", got)
+	}
+	if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues(strconv.Itoa(n), "reconnect_buffer_shutdown")); got != 1 {
+		t.Errorf("expected the newest message to be drained from the reconnect buffer on shutdown, got %v failures", got)
+	}
+	if got := strings.Count(logs.String(), "logging dead letter instead"); got != n {
+		t.Errorf("expected all %d dead letters to be logged, got %d", n, got)
+	}
+	if got := testutil.ToFloat64(m.DeadLetteredMessages); got != 0 {
+		t.Errorf("expected no messages to be dead-lettered while disconnected, got %v", got)
+	}
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	if len(sink.ctxs) != 0 {
+		t.Errorf("expected nothing to be published to the disconnected broker, got %d publishes", len(sink.ctxs))
+	}
+}
+
+// decodeBatches decodes the JSON array batches in payloads.
+func decodeBatches(t *testing.T, payloads [][]byte) [][]model.SensorData {
+	t.Helper()
+
+	batches := make([][]model.SensorData, 0, len(payloads))
+	for _, payload := range payloads {
+		var batch []model.SensorData
+		if err := json.Unmarshal(payload, &batch); err != nil {
+			t.Fatalf("failed to decode batch %q: %v", payload, err)
+		}
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// TestPublisher_Run_Batches verifies that in batch mode readings are published as JSON arrays of up to BatchSize
+// on the batch subject, rather than one message each, and that the partial last batch is published.
+func TestPublisher_Run_Batches(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 5)
+	for id := 1; id <= 5; id++ {
+		dataCh <- model.SensorData{ID: id, Value: float64(id)}
+	}
+	close(dataCh)
+
+	opts := publisher.Options{BatchSize: 2, BatchTimeout: time.Hour}
+	publisher.New(dataCh, client, "iot.sensors", opts, m, nil).Run(context.Background())
+
+	batches := decodeBatches(t, client.publishedTo("iot.sensors.batch.0"))
+	if len(batches) != 3 {
+		t.Fatalf("expected 3 batches, got %d: %v", len(batches), batches)
+	}
+	id := 1
+	for i, batch := range batches {
+		if want := min(2, 6-id); len(batch) != want {
+			t.Errorf("batch %d: expected %d readings, got %d", i, want, len(batch))
+		}
+		for _, data := range batch {
+			if data.ID != id {
+				t.Errorf("batch %d: expected sensor %d, got %d", i, id, data.ID)
+			}
+			id++
+		}
+	}
+	if got := len(client.publishedTo("iot.sensors.data.1")); got != 0 {
+		t.Errorf("expected no individual messages in batch mode, got %d", got)
+	}
+	if got := testutil.ToFloat64(m.NATSPublishSuccess.WithLabelValues("5")); got != 1 {
+		t.Errorf("expected sensor 5's reading to be counted as published, got %v", got)
+	}
+}
+
+// TestPublisher_Run_CompressesLargePayloads verifies payloads over CompressThreshold are gzip-compressed,
+// with a Content-Encoding header that DecodePayload decompresses them by, while smaller ones are sent as is.
+func TestPublisher_Run_CompressesLargePayloads(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 11)
+	for id := 1; id <= 11; id++ {
+		dataCh <- model.SensorData{ID: 1, Value: float64(id), Model: "SIM-100"}
+	}
+	close(dataCh)
+
+	opts := publisher.Options{BatchSize: 10, BatchTimeout: time.Hour, CompressThreshold: 200}
+	publisher.New(dataCh, client, "iot.sensors", opts, m, nil).Run(context.Background())
+
+	msgs := client.messagesTo("iot.sensors.batch.0")
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(msgs))
+	}
+	if got := msgs[0].header.Get(nats.HeaderContentEncoding); got != nats.EncodingGzip {
+		t.Errorf("expected the full batch to be gzip-compressed, got content encoding %q", got)
+	}
+	if got := msgs[1].header.Get(nats.HeaderContentEncoding); got != "" {
+		t.Errorf("expected the single-reading batch to be sent uncompressed, got content encoding %q", got)
+	}
+
+	var payloads [][]byte
+	for _, msg := range msgs {
+		payload, err := nats.DecodePayload(msg.header, msg.payload)
+		if err != nil {
+			t.Fatalf("failed to decode payload: %v", err)
+		}
+		payloads = append(payloads, payload)
+	}
+	batches := decodeBatches(t, payloads)
+	if len(batches[0]) != 10 || len(batches[1]) != 1 {
+		t.Errorf("expected batches of 10 and 1 readings, got %v", batches)
+	}
+
+	var metric dto.Metric
+	if err := m.CompressionRatio.Write(&metric); err != nil {
+		t.Fatalf("failed to read compression ratio histogram: %v", err)
+	}
+	h := metric.GetHistogram()
+	if h.GetSampleCount() != 1 {
+		t.Fatalf("expected 1 compression ratio observation, got %d", h.GetSampleCount())
+	}
+	if got := h.GetSampleSum(); got <= 0 || got >= 1 {
+		t.Errorf("expected the batch to compress to a fraction of its size, got a ratio of %v", got)
+	}
+}
+
+// TestPublisher_Run_FlushesBatchOnCancel verifies a partial batch is published when the context is canceled,
+// without waiting for the batch timeout or the data channel to be closed.
+func TestPublisher_Run_FlushesBatchOnCancel(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	dataCh := make(chan model.SensorData)
+	opts := publisher.Options{BatchSize: 10, BatchTimeout: time.Hour}
+	pub := publisher.New(dataCh, client, "iot.sensors", opts, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pub.Run(ctx)
+	}()
+
+	dataCh <- model.SensorData{ID: 1}
+	dataCh <- model.SensorData{ID: 2}
+	cancel()
+
+	deadline := time.Now().Add(time.Second)
+	for len(client.publishedTo("iot.sensors.batch.0")) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the partial batch to be published")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	close(dataCh)
+	<-done
+
+	batches := decodeBatches(t, client.publishedTo("iot.sensors.batch.0"))
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Errorf("expected one batch of 2 readings, got %v", batches)
+	}
+}
+
+// fakeSink is a publisher.Sink that records published subjects and payloads, in publish order.
+// It isn't a publisher.Client (it can't publish headers), like the MQTT client.
+// Setting disconnected simulates an unavailable broker, failing every publish.
+type fakeSink struct {
+	disconnected atomic.Bool
+
+	mu        sync.Mutex
+	published []published
+}
+
+func (s *fakeSink) IsConnected() bool { return !s.disconnected.Load() }
+
+func (s *fakeSink) Publish(_ context.Context, subject string, data []byte) error {
+	if s.disconnected.Load() {
+		return natsio.ErrConnectionClosed
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// The publisher reuses payloads once a publish succeeds, so they're copied.
+	s.published = append(s.published, published{subject: subject, payload: bytes.Clone(data)})
+	return nil
+}
+
+func (s *fakeSink) PublishJson(ctx context.Context, subject string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return s.Publish(ctx, subject, data)
+}
+
+// messages returns the messages published so far.
+func (s *fakeSink) messages() []published {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.published)
+}
+
+// TestPublisher_Run_PublishesToSink verifies every message is published through the sink, in order,
+// on its sensor's subject with its JSON record (including its location) as the payload, and counted as a success.
+func TestPublisher_Run_PublishesToSink(t *testing.T) {
+	t.Parallel()
+
+	s := &fakeSink{}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 3)
+	sent := []model.SensorData{
+		{ID: 42, Model: "SIM-100", Value: 0.5, Latitude: 51.5, Longitude: -0.12},
+		{ID: 7, Model: "SIM-50", Value: 1.5},
+		{ID: 42, Model: "SIM-100", Value: 2.5, Latitude: 51.5, Longitude: -0.12},
+	}
+	for _, data := range sent {
+		dataCh <- data
+	}
+	close(dataCh)
+
+	publisher.New(dataCh, s, "iot.sensors", publisher.Options{}, m, nil).Run(context.Background())
+
+	msgs := s.messages()
+	if len(msgs) != len(sent) {
+		t.Fatalf("expected %d messages published, got %d", len(sent), len(msgs))
+	}
+	for i, want := range sent {
+		if wantSubject := "iot.sensors.data." + strconv.Itoa(want.ID); msgs[i].subject != wantSubject {
+			t.Errorf("message %d: expected subject %s, got %s", i, wantSubject, msgs[i].subject)
+		}
+		var record model.SensorData
+		if err := json.Unmarshal(msgs[i].payload, &record); err != nil {
+			t.Fatalf("message %d: failed to decode published record: %v", i, err)
+		}
+		if record.ID != want.ID || record.Model != want.Model || record.Value != want.Value ||
+			record.Latitude != want.Latitude || record.Longitude != want.Longitude {
+			t.Errorf("message %d: expected record %+v, got %+v", i, want, record)
+		}
+	}
+
+	if got := testutil.ToFloat64(m.NATSPublishSuccess.WithLabelValues("42")); got != 2 {
+		t.Errorf("expected 2 successes for sensor 42, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.NATSPublishSuccess.WithLabelValues("7")); got != 1 {
+		t.Errorf("expected 1 success for sensor 7, got %v", got)
+	}
+}
+
+// TestPublisher_Run_SinkUnavailable verifies messages that can't be published because the sink is unavailable
+// are counted as failures, without stopping the publisher.
+func TestPublisher_Run_SinkUnavailable(t *testing.T) {
+	t.Parallel()
+
+	s := &fakeSink{}
+	s.disconnected.Store(true)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 2)
+	dataCh <- model.SensorData{ID: 1}
+	dataCh <- model.SensorData{ID: 2}
+	close(dataCh)
+
+	publisher.New(dataCh, s, "iot.sensors", publisher.Options{}, m, nil).Run(context.Background())
+
+	if msgs := s.messages(); len(msgs) != 0 {
+		t.Errorf("expected nothing published, got %d messages", len(msgs))
+	}
+	for _, id := range []string{"1", "2"} {
+		if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues(id, "publish_error")); got != 1 {
+			t.Errorf("expected 1 failure for sensor %s, got %v", id, got)
+		}
+	}
+	if got := testutil.CollectAndCount(m.NATSPublishSuccess); got != 0 {
+		t.Errorf("expected no successes, got %d series", got)
+	}
+}
+
+// writeFailingSink is a fakeSink that, like the Kafka client, writes messages after Publish returns,
+// reporting those that fail to be written to the OnWriteFailure function.
+type writeFailingSink struct {
+	fakeSink
+	onFailure func(subject string, data []byte, err error)
+}
+
+func (s *writeFailingSink) OnWriteFailure(f func(subject string, data []byte, err error)) {
+	s.onFailure = f
+}
+
+// fail reports every message published so far to a subject starting with prefix as failed to be written.
+func (s *writeFailingSink) fail(prefix string, err error) {
+	for _, msg := range s.messages() {
+		if strings.HasPrefix(msg.subject, prefix) {
+			s.onFailure(msg.subject, msg.payload, err)
+		}
+	}
+}
+
+// TestPublisher_Run_WriteFailures verifies readings a sink fails to write after they were published,
+// singly or in a batch, are counted as failures with the "write_error" type and dead-lettered,
+// and that dead letters failing to be written aren't dead-lettered in turn.
+func TestPublisher_Run_WriteFailures(t *testing.T) {
+	t.Parallel()
+
+	dlq := publisher.DeadLetterSubject("iot.sensors")
+	tests := map[string]publisher.Options{
+		"messages": {Codec: codec.ProtoCodec{}, DeadLetterSubject: dlq},
+		"batches":  {BatchSize: 2, DeadLetterSubject: dlq},
+	}
+
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			s := &writeFailingSink{}
+			m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+			dataCh := make(chan model.SensorData, 2)
+			dataCh <- model.SensorData{ID: 1, Value: 1.5}
+			dataCh <- model.SensorData{ID: 2, Value: 2.5}
+			close(dataCh)
+
+			publisher.New(dataCh, s, "iot.sensors", opts, m, nil).Run(context.Background())
+
+			writeErr := errors.New("leader not available")
+			s.fail("iot.sensors.", writeErr)
+			for _, id := range []string{"1", "2"} {
+				if got := testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues(id, "write_error")); got != 1 {
+					t.Errorf("expected 1 write error for sensor %s, got %v", id, got)
+				}
+			}
+
+			var letters []publisher.DeadLetter
+			for _, msg := range s.messages() {
+				if msg.subject != dlq {
+					continue
+				}
+				var letter publisher.DeadLetter
+				if err := json.Unmarshal(msg.payload, &letter); err != nil {
+					t.Fatalf("failed to decode dead letter %s: %v", msg.payload, err)
+				}
+				letters = append(letters, letter)
+			}
+			if len(letters) != 2 {
+				t.Fatalf("expected 2 dead letters, got %d", len(letters))
+			}
+			for i, letter := range letters {
+				if letter.Data.ID != i+1 || letter.Data.Value != float64(i+1)+0.5 || letter.Error != writeErr.Error() {
+					t.Errorf("dead letter %d: expected sensor %d's reading and the write error, got %+v", i, i+1, letter)
+				}
+			}
+
+			s.fail(dlq, writeErr)
+			if got := testutil.ToFloat64(m.DeadLetteredMessages); got != 2 {
+				t.Errorf("expected 2 dead-lettered messages, got %v", got)
+			}
+		})
+	}
+}
+
+// TestPublisher_Run_TypedSubject verifies readings with a type are published to a subject including it,
+// with the type and unit in the JSON record, and that a type can't break the subject's tokens.
+func TestPublisher_Run_TypedSubject(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	dataCh := make(chan model.SensorData, 3)
+	dataCh <- model.SensorData{ID: 42, Type: "temperature", Unit: "celsius"}
+	dataCh <- model.SensorData{ID: 43, Type: "air.quality *"}
+	dataCh <- model.SensorData{ID: 44}
+	close(dataCh)
+
+	publisher.New(dataCh, client, "iot.sensors", publisher.Options{}, nil, nil).Run(context.Background())
+
+	payloads := client.publishedTo("iot.sensors.data.temperature.42")
+	if len(payloads) != 1 {
+		t.Fatalf("expected 1 message published to iot.sensors.data.temperature.42, got %d", len(payloads))
+	}
+	var record model.SensorData
+	if err := json.Unmarshal(payloads[0], &record); err != nil {
+		t.Fatalf("failed to decode published record: %v", err)
+	}
+	if record.Type != "temperature" || record.Unit != "celsius" {
+		t.Errorf("expected type temperature in celsius in record, got %q in %q", record.Type, record.Unit)
+	}
+
+	if got := len(client.publishedTo("iot.sensors.data.air_quality__.43")); got != 1 {
+		t.Errorf("expected 1 message published to iot.sensors.data.air_quality__.43, got %d", got)
+	}
+	if got := len(client.publishedTo("iot.sensors.data.44")); got != 1 {
+		t.Errorf("expected 1 message published to iot.sensors.data.44, got %d", got)
+	}
+}
+
+// publishedIDs returns the sensor IDs of the data messages published so far, in publish order.
+func (c *fakeAsyncClient) publishedIDs(t *testing.T, subjectPrefix string) []int {
+	t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ids []int
+	for _, p := range c.published {
+		if !strings.HasPrefix(p.subject, subjectPrefix+".data.") {
+			continue
+		}
+		var data model.SensorData
+		if err := json.Unmarshal(p.payload, &data); err != nil {
+			t.Fatalf("failed to decode published message: %v", err)
+		}
+		ids = append(ids, data.ID)
+	}
+	return ids
+}
+
+// TestPublisher_Run_ReconnectBuffer verifies messages that fail during a disconnect are buffered
+// and republished in order on reconnect, with the oldest shed when over capacity.
+func TestPublisher_Run_ReconnectBuffer(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeAsyncClient{}
+	client.disconnected.Store(true)
+
+	dataCh := make(chan model.SensorData)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	pub := publisher.New(dataCh, client, "iot.sensors", publisher.Options{ReconnectBufferSize: 3}, m, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	runFinished := make(chan struct{})
+	go func() {
+		pub.Run(ctx)
+		close(runFinished)
+	}()
+
+	// Five messages during the disconnect window: the two oldest don't fit in the buffer.
+	for i := 1; i <= 5; i++ {
+		dataCh <- model.SensorData{ID: i}
+	}
+
+	shed := func(id int) float64 {
+		return testutil.ToFloat64(m.NATSPublishFailures.WithLabelValues(strconv.Itoa(id), "reconnect_buffer_full"))
+	}
+	deadline := time.Now().Add(time.Second)
+	for shed(1)+shed(2) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the oldest messages to be shed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Reconnect. The buffered messages are republished without waiting for new data.
+	client.disconnected.Store(false)
+	deadline = time.Now().Add(time.Second)
+	for len(client.publishedIDs(t, "iot.sensors")) < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for buffered messages to be republished, got %v", client.publishedIDs(t, "iot.sensors"))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	dataCh <- model.SensorData{ID: 6}
+	close(dataCh)
+
+	select {
+	case <-runFinished:
+	case <-time.After(time.Second):
+		t.Fatal("publisher did not stop after the data channel closed")
+	}
+
+	got := client.publishedIDs(t, "iot.sensors")
+	want := []int{3, 4, 5, 6}
+	if len(got) != len(want) {
+		t.Fatalf("expected sensors %v to be published, got %v", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected sensors %v to be published in order, got %v", want, got)
+		}
+	}
+	for _, id := range []int{3, 4, 5} {
+		if got := shed(id); got != 0 {
+			t.Errorf("expected sensor %d not to be shed, got %v", id, got)
+		}
+	}
+}
+
+// notifyingClient is a fakeAsyncClient that signals reconnects, like *nats.Client.
+type notifyingClient struct {
+	fakeAsyncClient
+
+	reconnectMu sync.Mutex
+	reconnected chan struct{}
+}
+
+func (c *notifyingClient) Reconnected() <-chan struct{} {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+	return c.reconnected
+}
+
+// reconnect simulates NATS reconnecting, waking anyone waiting on Reconnected.
+func (c *notifyingClient) reconnect() {
+	c.reconnectMu.Lock()
+	defer c.reconnectMu.Unlock()
+	c.disconnected.Store(false)
+	close(c.reconnected)
+	c.reconnected = make(chan struct{})
+}
+
+// TestPublisher_Run_RepublishesOnReconnect verifies buffered messages are counted by the buffered messages gauge,
+// and republished as soon as the client signals it has reconnected.
+func TestPublisher_Run_RepublishesOnReconnect(t *testing.T) {
+	t.Parallel()
+
+	client := &notifyingClient{reconnected: make(chan struct{})}
+	client.disconnected.Store(true)
+
+	dataCh := make(chan model.SensorData)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	pub := publisher.New(dataCh, client, "iot.sensors", publisher.Options{ReconnectBufferSize: 10}, m, nil)
+
+	runFinished := make(chan struct{})
+	go func() {
+		pub.Run(context.Background())
+		close(runFinished)
+	}()
+
+	dataCh <- model.SensorData{ID: 1}
+	dataCh <- model.SensorData{ID: 2}
+
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(m.BufferedMessages) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for 2 buffered messages, got %v", testutil.ToFloat64(m.BufferedMessages))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	client.reconnect()
+	deadline = time.Now().Add(time.Second)
+	for len(client.publishedIDs(t, "iot.sensors")) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for buffered messages to be republished")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(dataCh)
+	<-runFinished
+
+	if got := testutil.ToFloat64(m.BufferedMessages); got != 0 {
+		t.Errorf("expected no buffered messages after republishing, got %v", got)
+	}
+}
+
+// TestPublisher_Run_BytesPublished verifies the encoded sizes of published messages are summed per device model.
+func TestPublisher_Run_BytesPublished(t *testing.T) {
+	t.Parallel()
+
+	records := []model.SensorData{
+		{ID: 1, Value: 0.5, Model: "SIM-100"},
+		{ID: 2, Value: 0.25, Model: "SIM-50"},
+		{ID: 3, Value: 0.125, Model: "SIM-100"},
+	}
+	want := make(map[string]float64)
+	for _, r := range records {
+		payload, err := json.Marshal(r)
+		if err != nil {
+			t.Fatalf("failed to marshal record: %v", err)
+		}
+		want[r.Model] += float64(len(payload))
+	}
+
+	dataCh := make(chan model.SensorData, len(records))
+	for _, r := range records {
+		dataCh <- r
+	}
+	close(dataCh)
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	publisher.New(dataCh, &fakeAsyncClient{}, "iot.sensors", publisher.Options{}, m, nil).Run(context.Background())
+
+	for modelName, bytes := range want {
+		if got := testutil.ToFloat64(m.NATSBytesPublished.WithLabelValues(modelName)); got != bytes {
+			t.Errorf("expected %v bytes published for %s, got %v", bytes, modelName, got)
+		}
+	}
+}
+
+// slowClient is a fakeAsyncClient whose synchronous publishes take delay,
+// tracking the most publishes that were ever in flight at once.
+type slowClient struct {
+	fakeAsyncClient
+	delay       time.Duration
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (c *slowClient) PublishMsg(ctx context.Context, msg *natsio.Msg) error {
+	n := c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	for {
+		max := c.maxInFlight.Load()
+		if n <= max || c.maxInFlight.CompareAndSwap(max, n) {
+			break
+		}
+	}
+
+	time.Sleep(c.delay)
+	return c.fakeAsyncClient.PublishMsg(ctx, msg)
+}
+
+// TestPublisher_Run_WorkersPreserveSensorOrder verifies that with multiple workers,
+// each sensor's messages are published in order while different sensors are published concurrently.
+func TestPublisher_Run_WorkersPreserveSensorOrder(t *testing.T) {
+	t.Parallel()
+
+	const (
+		sensors    = 8
+		perSensor  = 10
+		subjPrefix = "iot.sensors"
+	)
+
+	client := &slowClient{delay: time.Millisecond}
+	dataCh := make(chan model.SensorData, sensors*perSensor)
+	for seq := 0; seq < perSensor; seq++ {
+		for id := 1; id <= sensors; id++ {
+			dataCh <- model.SensorData{ID: id, Value: float64(seq), Timestamp: time.Now()}
+		}
+	}
+	close(dataCh)
+
+	pub := publisher.New(dataCh, client, subjPrefix, publisher.Options{Workers: 4}, nil, nil)
+
+	done := make(chan struct{})
+	go func() {
+		pub.Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("publisher did not stop after the data channel was closed")
+	}
+
+	for id := 1; id <= sensors; id++ {
+		payloads := client.publishedTo(subjPrefix + ".data." + strconv.Itoa(id))
+		if len(payloads) != perSensor {
+			t.Fatalf("expected %d messages from sensor %d, got %d", perSensor, id, len(payloads))
+		}
+		for seq, payload := range payloads {
+			var data model.SensorData
+			if err := json.Unmarshal(payload, &data); err != nil {
+				t.Fatalf("failed to decode published message: %v", err)
+			}
+			if data.Value != float64(seq) {
+				t.Errorf("sensor %d: expected message %d in position %d, got message %v", id, seq, seq, data.Value)
+			}
+		}
+	}
+
+	if got := client.maxInFlight.Load(); got < 2 {
+		t.Errorf("expected sensors to be published concurrently, but at most %d publish was in flight", got)
+	}
+}
+
+// discardSink is a publisher.Sink that accepts and discards every message, for benchmarks.
+type discardSink struct{}
+
+func (discardSink) IsConnected() bool                              { return true }
+func (discardSink) Publish(context.Context, string, []byte) error  { return nil }
+func (discardSink) PublishJson(context.Context, string, any) error { return nil }
+func (discardSink) PublishMsg(context.Context, *natsio.Msg) error  { return nil }
+
+// BenchmarkPublisher_Run measures the cost of publishing a message, from dequeuing it to the sink returning.
+func BenchmarkPublisher_Run(b *testing.B) {
+	data := model.SensorData{
+		SchemaVersion:   model.SchemaVersion,
+		ID:              42,
+		Type:            "temperature",
+		Unit:            "celsius",
+		Value:           21.5,
+		Timestamp:       time.Now(),
+		Model:           "SIM-100",
+		FirmwareVersion: "1.4.2",
+	}
+	dataCh := make(chan model.SensorData, 1000)
+	p := publisher.New(dataCh, discardSink{}, "iot.sensors", publisher.Options{}, nil, slog.New(slog.DiscardHandler))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Run(context.Background())
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		dataCh <- data
+	}
+	close(dataCh)
+	<-done
+}
@@ -0,0 +1,46 @@
+package publisher
+
+import "github.com/allthepins/iot-sensor-network-simulator/internal/model"
+
+// ring is a bounded FIFO queue of messages, backed by a circular buffer,
+// so holding messages doesn't reallocate however long NATS stays disconnected.
+// Its storage is allocated on the first push, as most runs never need it.
+type ring struct {
+	capacity int
+	items    []model.SensorData
+	head     int // Index of the oldest message.
+	size     int
+}
+
+// len returns the number of messages held.
+func (r *ring) len() int {
+	return r.size
+}
+
+// push appends data. If the ring is full, the oldest message is evicted to make room and returned.
+func (r *ring) push(data model.SensorData) (evicted model.SensorData, ok bool) {
+	if r.items == nil {
+		r.items = make([]model.SensorData, r.capacity)
+	}
+
+	if r.size == r.capacity {
+		evicted, ok = r.pop(), true
+	}
+	r.items[(r.head+r.size)%r.capacity] = data
+	r.size++
+	return evicted, ok
+}
+
+// peek returns the oldest message. The ring must not be empty.
+func (r *ring) peek() model.SensorData {
+	return r.items[r.head]
+}
+
+// pop removes and returns the oldest message. The ring must not be empty.
+func (r *ring) pop() model.SensorData {
+	data := r.items[r.head]
+	r.items[r.head] = model.SensorData{} // Release the message's tags.
+	r.head = (r.head + 1) % r.capacity
+	r.size--
+	return data
+}
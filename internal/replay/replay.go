@@ -0,0 +1,216 @@
+// Package replay feeds recorded readings back through the pipeline, in place of the synthetic sensors.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// Source replays the readings recorded in a file: a CSV file as written by the CSV sink (`-csv-out`),
+// with a header row naming at least its id, value and timestamp columns, or otherwise newline-delimited JSON
+// as written by the bridge. Readings are sent at the cadence implied by their timestamps,
+// sped up (or slowed down) by the replay speed, and timestamped when they're due to be sent,
+// so the pipeline sees them as fresh (rather than, e.g., flagging their sensors as stale).
+type Source struct {
+	path   string
+	speed  float64
+	logger *slog.Logger
+}
+
+// New returns a Source replaying the readings recorded at path, speed times faster than they were recorded
+// (e.g. 2 replays them twice as fast). It fails fast if the file can't be opened, or speed isn't positive.
+func New(path string, speed float64, l *slog.Logger) (*Source, error) {
+	if l == nil {
+		l = slog.Default()
+	}
+	if !(speed > 0) || math.IsInf(speed, 0) {
+		return nil, fmt.Errorf("replay speed must be a positive number, got %v", speed)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+	f.Close()
+
+	return &Source{
+		path:   path,
+		speed:  speed,
+		logger: l.With("component", "replay", "path", path),
+	}, nil
+}
+
+// Run sends the file's readings on dataCh, each once the time between the first reading's timestamp and its own,
+// divided by the replay speed, has elapsed. Each is timestamped with the time it was due, i.e. its recorded timestamp
+// rebased to the start of the replay: start + (timestamp - first)/speed. Readings timestamped before their predecessor
+// are sent straight away (still with their rebased timestamps, so they're still out of order).
+// It returns the number of readings sent, and nil once they all have, ctx's error if ctx is done first,
+// or an error if the file can't be read or a record can't be parsed.
+func (s *Source) Run(ctx context.Context, dataCh chan<- model.SensorData) (int, error) {
+	f, err := os.Open(s.path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open replay file: %w", err)
+	}
+	defer f.Close()
+
+	var records reader
+	if strings.EqualFold(filepath.Ext(s.path), ".csv") {
+		if records, err = newCSVReader(f); err != nil {
+			return 0, err
+		}
+	} else {
+		records = &jsonReader{dec: json.NewDecoder(bufio.NewReader(f))}
+	}
+
+	s.logger.Info("Replay starting", "speed", s.speed)
+	start := time.Now()
+	var first time.Time
+	sent := 0
+	for {
+		data, err := records.Read()
+		if errors.Is(err, io.EOF) {
+			s.logger.Info("Replay finished", "readings", sent, "elapsed", time.Since(start))
+			return sent, nil
+		}
+		if err != nil {
+			return sent, err
+		}
+		if data.SchemaVersion == 0 {
+			data.SchemaVersion = model.SchemaVersion
+		}
+
+		if sent == 0 {
+			first = data.Timestamp
+		}
+		due := start.Add(time.Duration(float64(data.Timestamp.Sub(first)) / s.speed))
+		if err := sleep(ctx, time.Until(due)); err != nil {
+			return sent, err
+		}
+		data.Timestamp = due
+
+		select {
+		case dataCh <- data:
+			sent++
+		case <-ctx.Done():
+			return sent, ctx.Err()
+		}
+	}
+}
+
+// sleep waits for d to elapse, or returns ctx's error if ctx is done first.
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reader reads recorded readings one at a time, returning io.EOF after the last.
+type reader interface {
+	Read() (model.SensorData, error)
+}
+
+// jsonReader reads newline-delimited JSON readings.
+type jsonReader struct {
+	dec  *json.Decoder
+	line int
+}
+
+// Read decodes the next reading.
+func (r *jsonReader) Read() (model.SensorData, error) {
+	var data model.SensorData
+	r.line++
+	if err := r.dec.Decode(&data); err != nil {
+		if errors.Is(err, io.EOF) {
+			return data, io.EOF
+		}
+		return data, fmt.Errorf("invalid replay record %d: %w", r.line, err)
+	}
+	return data, nil
+}
+
+// csvReader reads CSV rows, finding the columns by the names in the header row, in any order.
+type csvReader struct {
+	r *csv.Reader
+	// Column indexes of the fields; type is -1 if the file has none.
+	id, value, timestamp, typ int
+}
+
+// newCSVReader reads the header row from f, returning an error if it lacks a required column.
+func newCSVReader(f io.Reader) (*csvReader, error) {
+	r := csv.NewReader(bufio.NewReader(f))
+	r.ReuseRecord = true
+	header, err := r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, errors.New("replay file is empty, expected a CSV header row")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	c := &csvReader{r: r, typ: -1}
+	for name, index := range map[string]*int{"id": &c.id, "value": &c.value, "timestamp": &c.timestamp} {
+		i, ok := columns[name]
+		if !ok {
+			return nil, fmt.Errorf("CSV header %q has no %s column", strings.Join(header, ","), name)
+		}
+		*index = i
+	}
+	if i, ok := columns["type"]; ok {
+		c.typ = i
+	}
+	return c, nil
+}
+
+// Read parses the next row.
+func (c *csvReader) Read() (model.SensorData, error) {
+	var data model.SensorData
+	row, err := c.r.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return data, io.EOF
+		}
+		return data, fmt.Errorf("failed to read CSV row: %w", err)
+	}
+	line, _ := c.r.FieldPos(0)
+
+	if data.ID, err = strconv.Atoi(row[c.id]); err != nil {
+		return data, fmt.Errorf("invalid id on line %d: %w", line, err)
+	}
+	if data.Value, err = strconv.ParseFloat(row[c.value], 64); err != nil {
+		return data, fmt.Errorf("invalid value on line %d: %w", line, err)
+	}
+	if data.Timestamp, err = time.Parse(time.RFC3339Nano, row[c.timestamp]); err != nil {
+		return data, fmt.Errorf("invalid timestamp on line %d: %w", line, err)
+	}
+	if c.typ >= 0 {
+		data.Type = row[c.typ]
+	}
+	return data, nil
+}
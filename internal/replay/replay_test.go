@@ -0,0 +1,170 @@
+// Package replay_test contains tests for the replay package.
+package replay_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/replay"
+)
+
+// writeFile writes contents to a file named name in a temporary directory, returning its path.
+func writeFile(t *testing.T, name, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// TestSource_Run verifies recorded readings are replayed in order, at their recorded cadence divided by the speed,
+// from both CSV and newline-delimited JSON files, timestamped when they were due.
+func TestSource_Run(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		// Columns are found by name, in any order.
+		"data.csv": "timestamp,id,type,value\n" +
+			"2025-01-01T12:00:00Z,1,temperature,20.5\n" +
+			"2025-01-01T12:00:00.2Z,2,temperature,21\n" +
+			"2025-01-01T12:00:00.4Z,1,temperature,20.75\n",
+		"data.ndjson": `{"ID":1,"Type":"temperature","Value":20.5,"Timestamp":"2025-01-01T12:00:00Z"}
+{"ID":2,"Type":"temperature","Value":21,"Timestamp":"2025-01-01T12:00:00.2Z"}
+{"ID":1,"Type":"temperature","Value":20.75,"Timestamp":"2025-01-01T12:00:00.4Z"}
+`,
+	}
+	want := []model.SensorData{
+		{SchemaVersion: model.SchemaVersion, ID: 1, Type: "temperature", Value: 20.5},
+		{SchemaVersion: model.SchemaVersion, ID: 2, Type: "temperature", Value: 21},
+		{SchemaVersion: model.SchemaVersion, ID: 1, Type: "temperature", Value: 20.75},
+	}
+	// The readings are timestamped when they're due, the recorded offsets from the first halved at twice the speed.
+	wantOffsets := []time.Duration{0, 100 * time.Millisecond, 200 * time.Millisecond}
+
+	for name, contents := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			// At twice the speed, the 400ms recording takes 200ms to replay.
+			src, err := replay.New(writeFile(t, name, contents), 2, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			dataCh := make(chan model.SensorData, len(want))
+			began := time.Now()
+			n, err := src.Run(context.Background(), dataCh)
+			elapsed := time.Since(began)
+			if err != nil || n != len(want) {
+				t.Fatalf("expected %d readings to be replayed without error, got %d, %v", len(want), n, err)
+			}
+			if elapsed < 200*time.Millisecond || elapsed > time.Second {
+				t.Errorf("expected the replay to take about 200ms, took %v", elapsed)
+			}
+
+			close(dataCh)
+			var got []model.SensorData
+			for data := range dataCh {
+				got = append(got, data)
+			}
+			// The replay starts, and the first reading is timestamped, between began and when Run returned.
+			if replayed := got[0].Timestamp; replayed.Before(began) || replayed.After(began.Add(elapsed)) {
+				t.Errorf("expected the first reading to be timestamped when the replay started, between %v and %v, got %v",
+					began, began.Add(elapsed), replayed)
+			}
+			for i := range want {
+				if got[i].ID != want[i].ID || got[i].Value != want[i].Value || got[i].Type != want[i].Type ||
+					got[i].SchemaVersion != want[i].SchemaVersion {
+					t.Errorf("reading %d: expected %+v, got %+v", i, want[i], got[i])
+				}
+				if offset := got[i].Timestamp.Sub(got[0].Timestamp); offset != wantOffsets[i] {
+					t.Errorf("reading %d: expected to be timestamped %v after the first, got %v", i, wantOffsets[i], offset)
+				}
+			}
+		})
+	}
+}
+
+// TestSource_Run_Canceled verifies a replay stops promptly once its context is canceled.
+func TestSource_Run_Canceled(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, "data.csv", "id,value,timestamp\n1,1,2025-01-01T12:00:00Z\n1,2,2025-01-01T13:00:00Z\n")
+	src, err := replay.New(path, 1, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	dataCh := make(chan model.SensorData, 2)
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result)
+	go func() {
+		n, err := src.Run(ctx, dataCh)
+		done <- result{n, err}
+	}()
+
+	<-dataCh // The first reading is sent straight away; the second isn't due for an hour.
+	cancel()
+	select {
+	case r := <-done:
+		if r.n != 1 || !errors.Is(r.err, context.Canceled) {
+			t.Errorf("expected 1 reading and context.Canceled, got %d, %v", r.n, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the replay to stop promptly once canceled")
+	}
+}
+
+// TestSource_Run_Invalid verifies malformed files are reported with where they went wrong.
+func TestSource_Run_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		name, contents, wantErr string
+	}{
+		"missing column": {"data.csv", "id,value\n1,2\n", "no timestamp column"},
+		"empty":          {"data.csv", "", "expected a CSV header row"},
+		"bad value":      {"data.csv", "id,value,timestamp\n1,hot,2025-01-01T12:00:00Z\n", "invalid value on line 2"},
+		"bad timestamp":  {"data.csv", "id,value,timestamp\n1,2,yesterday\n", "invalid timestamp on line 2"},
+		"bad JSON":       {"data.ndjson", "{\"ID\":1}\nnot json\n", "invalid replay record 2"},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			src, err := replay.New(writeFile(t, tt.name, tt.contents), 1, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := src.Run(context.Background(), make(chan model.SensorData, 10)); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestNew_Invalid verifies missing files and non-positive speeds are rejected up front.
+func TestNew_Invalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := replay.New(filepath.Join(t.TempDir(), "missing.csv"), 1, nil); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+	path := writeFile(t, "data.csv", "id,value,timestamp\n")
+	for _, speed := range []float64{0, -1} {
+		if _, err := replay.New(path, speed, nil); err == nil {
+			t.Errorf("expected an error for speed %v", speed)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package sensor
+
+import "time"
+
+// AdaptiveConfig configures adaptive emission, which models event-driven sensors.
+// A sensor in adaptive mode emits faster while its value is changing rapidly and slower while it is stable.
+type AdaptiveConfig struct {
+	// MinInterval is the fastest the sensor will emit.
+	MinInterval time.Duration
+	// MaxInterval is the slowest the sensor will emit.
+	MaxInterval time.Duration
+	// ChangeThreshold is the absolute change between successive values above which
+	// the value is considered to be changing rapidly.
+	ChangeThreshold float64
+}
+
+// clamp bounds interval d to [MinInterval, MaxInterval].
+func (c AdaptiveConfig) clamp(d time.Duration) time.Duration {
+	return min(max(d, c.MinInterval), c.MaxInterval)
+}
+
+// next returns the interval to use after observing a change of delta at the current interval.
+// The interval halves while the value changes by more than ChangeThreshold and doubles otherwise.
+func (c AdaptiveConfig) next(current time.Duration, delta float64) time.Duration {
+	if delta > c.ChangeThreshold {
+		return c.clamp(current / 2)
+	}
+	return c.clamp(current * 2)
+}
@@ -0,0 +1,69 @@
+package sensor
+
+import "time"
+
+// Default pressure thresholds of backpressure-aware emission.
+const (
+	DefaultHighPressure = 0.8
+	DefaultLowPressure  = 0.2
+)
+
+// PressureSignal reports downstream pressure, from 0 (idle) to 1 (saturated).
+type PressureSignal interface {
+	Pressure() float64
+}
+
+// PressureFunc is a function that implements PressureSignal.
+type PressureFunc func() float64
+
+// Pressure calls f.
+func (f PressureFunc) Pressure() float64 {
+	return f()
+}
+
+// ChannelPressure returns a PressureSignal reporting the utilization of ch (its length over its capacity).
+// Unbuffered channels report no pressure.
+func ChannelPressure[T any](ch chan<- T) PressureSignal {
+	return PressureFunc(func() float64 {
+		if cap(ch) == 0 {
+			return 0
+		}
+		return float64(len(ch)) / float64(cap(ch))
+	})
+}
+
+// BackpressureConfig configures backpressure-aware emission, a gentle form of load shedding that preserves data.
+// A sensor with backpressure slows down while the signal reports high pressure (e.g. the data channel
+// staying nearly full), and speeds back up to its interval once the pressure drops.
+type BackpressureConfig struct {
+	Signal PressureSignal
+	// MaxInterval is the slowest the sensor will emit.
+	MaxInterval time.Duration
+	// High is the pressure at or above which the interval doubles (DefaultHighPressure when zero).
+	High float64
+	// Low is the pressure at or below which the interval halves, down to the sensor's interval
+	// (DefaultLowPressure when zero).
+	Low float64
+}
+
+// next returns the interval to use after the current one, given the sensor's base interval.
+// The interval doubles, up to MaxInterval, on every tick the pressure stays high,
+// and halves, down to base, on every tick it stays low.
+func (c BackpressureConfig) next(base, current time.Duration) time.Duration {
+	high, low := c.High, c.Low
+	if high == 0 {
+		high = DefaultHighPressure
+	}
+	if low == 0 {
+		low = DefaultLowPressure
+	}
+
+	switch p := c.Signal.Pressure(); {
+	case p >= high:
+		return max(min(current*2, c.MaxInterval), base)
+	case p <= low:
+		return max(current/2, base)
+	default:
+		return current
+	}
+}
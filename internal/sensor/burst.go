@@ -0,0 +1,21 @@
+package sensor
+
+import "time"
+
+// BurstConfig configures burst emission, which models devices that send
+// a burst of readings and then go quiet.
+// A sensor in burst mode emits BurstSize readings BurstInterval apart, then idles for IdleGap, repeating.
+type BurstConfig struct {
+	BurstSize     int
+	BurstInterval time.Duration
+	IdleGap       time.Duration
+}
+
+// next returns the interval to wait after sending the sent-th reading of a burst,
+// along with the updated count of readings sent in the current burst (reset to 0 once the burst completes).
+func (c BurstConfig) next(sent int) (time.Duration, int) {
+	if sent >= c.BurstSize {
+		return c.IdleGap, 0
+	}
+	return c.BurstInterval, sent
+}
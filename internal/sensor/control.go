@@ -0,0 +1,99 @@
+package sensor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+)
+
+// Controller is the runtime control shared by a fleet of sensors (see WithController):
+// whether they're paused, and the interval they emit at. Sensors consult it on every tick,
+// so changes take effect without restarting them. It is safe for concurrent use.
+type Controller struct {
+	paused   atomic.Bool
+	interval atomic.Int64 // A time.Duration.
+
+	mu      sync.Mutex
+	resumed chan struct{} // Closed by Resume, to release the sensors waiting in Wait. Replaced by Pause.
+	metrics *metrics.Metrics
+}
+
+// NewController creates a Controller, unpaused, with sensors emitting every interval.
+// m, if not nil, counts the sensors waiting while paused.
+func NewController(interval time.Duration, m *metrics.Metrics) *Controller {
+	c := &Controller{metrics: m}
+	c.interval.Store(int64(interval))
+	return c
+}
+
+// Pause stops the sensors emitting readings, until Resume is called.
+func (c *Controller) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused.Load() {
+		c.resumed = make(chan struct{})
+		c.paused.Store(true)
+	}
+}
+
+// Resume lets paused sensors emit readings again.
+func (c *Controller) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.paused.Load() {
+		c.paused.Store(false)
+		close(c.resumed)
+	}
+}
+
+// Paused reports whether the sensors are paused.
+func (c *Controller) Paused() bool {
+	return c.paused.Load()
+}
+
+// Wait blocks while the sensors are paused, until they're resumed or ctx is done, in which case it returns ctx's error.
+// Unpaused, it returns straight away, at the cost of an atomic load.
+func (c *Controller) Wait(ctx context.Context) error {
+	if !c.paused.Load() {
+		return nil
+	}
+
+	c.mu.Lock()
+	paused, resumed := c.paused.Load(), c.resumed
+	c.mu.Unlock()
+	if !paused {
+		return nil
+	}
+
+	if c.metrics != nil {
+		c.metrics.PausedSensors.Inc()
+		defer c.metrics.PausedSensors.Dec()
+	}
+	select {
+	case <-resumed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Interval returns the interval the sensors emit at.
+func (c *Controller) Interval() time.Duration {
+	return time.Duration(c.interval.Load())
+}
+
+// SetInterval changes the interval the sensors emit at, from their next reading on.
+// Sensors clamp it to their minimum interval, like the interval they were created with.
+func (c *Controller) SetInterval(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("interval must be positive, got %v", d)
+	}
+	c.interval.Store(int64(d))
+	return nil
+}
@@ -0,0 +1,70 @@
+package sensor
+
+import (
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Distribution generates the values a sensor emits.
+// Implementations are called from a single sensor goroutine, with that sensor's own random source
+// and the time of the reading, as told by the sensor's clock.
+type Distribution interface {
+	Sample(r *rand.Rand, now time.Time) float64
+}
+
+// DistributionFunc adapts an ordinary function to the Distribution interface.
+type DistributionFunc func(r *rand.Rand, now time.Time) float64
+
+// Sample calls f(r, now).
+func (f DistributionFunc) Sample(r *rand.Rand, now time.Time) float64 {
+	return f(r, now)
+}
+
+// Uniform is a Distribution of values uniformly distributed in [0, 1).
+// It is the default distribution.
+type Uniform struct{}
+
+// Sample returns a uniformly distributed value in [0, 1).
+func (Uniform) Sample(r *rand.Rand, _ time.Time) float64 {
+	return r.Float64()
+}
+
+// Normal is a Distribution of normally distributed values, e.g. temperature-like readings.
+type Normal struct {
+	Mean   float64
+	StdDev float64
+}
+
+// Sample returns a normally distributed value with the distribution's mean and standard deviation.
+func (d Normal) Sample(r *rand.Rand, _ time.Time) float64 {
+	return r.NormFloat64()*d.StdDev + d.Mean
+}
+
+// Sine is a Distribution of values following a sine wave over time, e.g. a diurnal pattern
+// with a Period of 24 hours. Values oscillate between Offset-Amplitude and Offset+Amplitude.
+// Sensors sharing a Sine are in step; give them different phases to spread them out.
+type Sine struct {
+	Amplitude float64
+	Period    time.Duration
+	// Phase shifts the wave, in radians.
+	Phase float64
+	// Offset is the value the wave oscillates around.
+	Offset float64
+}
+
+// Sample returns the wave's value at now. The random source is unused.
+func (d Sine) Sample(_ *rand.Rand, now time.Time) float64 {
+	return d.At(now)
+}
+
+// At returns the wave's value at time t. Waves with a non-positive period are flat at Offset.
+func (d Sine) At(t time.Time) float64 {
+	if d.Period <= 0 {
+		return d.Offset
+	}
+
+	elapsed := t.UnixNano() % int64(d.Period)
+	angle := 2*math.Pi*float64(elapsed)/float64(d.Period) + d.Phase
+	return d.Offset + d.Amplitude*math.Sin(angle)
+}
@@ -0,0 +1,29 @@
+package sensor
+
+import (
+	"sync"
+	"time"
+)
+
+// drift models a sensor's bias, which grows at rate (in value units per second)
+// since the sensor started or was last recalibrated.
+type drift struct {
+	rate float64
+
+	mu    sync.Mutex // Guards since, which ResetDrift sets while Run reads it.
+	since time.Time
+}
+
+// offset returns the bias accumulated at now.
+func (d *drift) offset(now time.Time) float64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.rate * now.Sub(d.since).Seconds()
+}
+
+// reset restarts the bias's growth from 0 at now.
+func (d *drift) reset(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.since = now
+}
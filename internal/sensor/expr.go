@@ -0,0 +1,356 @@
+package sensor
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ExprGenerator generates values from an arithmetic expression of `t` (seconds since start)
+// and `id` (the sensor ID), e.g. "20 + 5*sin(t/3600)".
+//
+// Expressions support numbers, the + - * / % ^ operators (^ is exponentiation), parentheses,
+// the constants pi and e, and the functions sin, cos, tan, abs, sqrt, exp, log, floor, ceil, min, max, and pow.
+// Where an expression is undefined (e.g. 1/t at t=0, or sqrt of a negative number) it evaluates to NaN or ±Inf,
+// which sensors skip rather than send.
+type ExprGenerator struct {
+	expr string
+	eval exprNode
+}
+
+// exprVars holds the variables an expression is evaluated with.
+type exprVars struct {
+	t  float64
+	id float64
+}
+
+// exprNode is a compiled (sub)expression.
+type exprNode func(v *exprVars) float64
+
+// NewExprGenerator parses expr, returning an error if it isn't a valid expression.
+// Expressions are validated up front, so a typo is reported at startup rather than mid-run.
+func NewExprGenerator(expr string) (*ExprGenerator, error) {
+	p := &exprParser{src: expr}
+	p.next()
+
+	eval, err := p.parseExpr()
+	if err == nil && p.tok.kind != tokEOF {
+		err = p.errorf("unexpected %q", p.tok.text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid expression %q: %w", expr, err)
+	}
+
+	return &ExprGenerator{expr: expr, eval: eval}, nil
+}
+
+// String returns the generator's expression.
+func (g *ExprGenerator) String() string {
+	return g.expr
+}
+
+// Eval evaluates the expression for sensor id, t seconds after start.
+func (g *ExprGenerator) Eval(t float64, id int) float64 {
+	return g.eval(&exprVars{t: t, id: float64(id)})
+}
+
+// Distribution returns a Distribution for sensor id that evaluates the expression
+// at the time elapsed since start. The sensor's random source is unused.
+func (g *ExprGenerator) Distribution(id int, start time.Time) Distribution {
+	return DistributionFunc(func(_ *rand.Rand, now time.Time) float64 {
+		return g.Eval(now.Sub(start).Seconds(), id)
+	})
+}
+
+// exprFuncs are the functions available to expressions, by name and arity.
+var exprFuncs = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"sin":   {1, func(a []float64) float64 { return math.Sin(a[0]) }},
+	"cos":   {1, func(a []float64) float64 { return math.Cos(a[0]) }},
+	"tan":   {1, func(a []float64) float64 { return math.Tan(a[0]) }},
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(a[0]) }},
+	"exp":   {1, func(a []float64) float64 { return math.Exp(a[0]) }},
+	"log":   {1, func(a []float64) float64 { return math.Log(a[0]) }},
+	"floor": {1, func(a []float64) float64 { return math.Floor(a[0]) }},
+	"ceil":  {1, func(a []float64) float64 { return math.Ceil(a[0]) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(a[0], a[1]) }},
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokIdent
+	tokOp // One of + - * / % ^ ( ) ,
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// exprParser is a recursive descent parser that compiles an expression into an exprNode.
+type exprParser struct {
+	src string
+	pos int
+	tok token
+}
+
+// next advances to the next token.
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		// Exponent, e.g. 1e-3.
+		if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+			end := p.pos + 1
+			if end < len(p.src) && (p.src[end] == '+' || p.src[end] == '-') {
+				end++
+			}
+			if end < len(p.src) && isDigit(p.src[end]) {
+				for end < len(p.src) && isDigit(p.src[end]) {
+					end++
+				}
+				p.pos = end
+			}
+		}
+		p.tok = token{kind: tokNumber, text: p.src[start:p.pos], pos: start}
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isDigit(p.src[p.pos]) || unicode.IsLetter(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	default:
+		p.pos++
+		p.tok = token{kind: tokOp, text: string(c), pos: start}
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	return fmt.Errorf("at offset %d: %s", p.tok.pos, fmt.Sprintf(format, args...))
+}
+
+// isOp reports whether the current token is one of the operators in ops.
+func (p *exprParser) isOp(ops string) bool {
+	return p.tok.kind == tokOp && strings.Contains(ops, p.tok.text)
+}
+
+// expect consumes the operator op, or returns an error.
+func (p *exprParser) expect(op string) error {
+	if p.tok.kind != tokOp || p.tok.text != op {
+		if p.tok.kind == tokEOF {
+			return p.errorf("expected %q, got end of expression", op)
+		}
+		return p.errorf("expected %q, got %q", op, p.tok.text)
+	}
+	p.next()
+	return nil
+}
+
+// parseExpr parses: term (('+' | '-') term)*
+func (p *exprParser) parseExpr() (exprNode, error) {
+	left, err := p.parseTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("+-") {
+		op := p.tok.text
+		p.next()
+		right, err := p.parseTerm()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		if op == "+" {
+			left = func(v *exprVars) float64 { return l(v) + right(v) }
+		} else {
+			left = func(v *exprVars) float64 { return l(v) - right(v) }
+		}
+	}
+	return left, nil
+}
+
+// parseTerm parses: unary (('*' | '/' | '%') unary)*
+func (p *exprParser) parseTerm() (exprNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOp("*/%") {
+		op := p.tok.text
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		switch op {
+		case "*":
+			left = func(v *exprVars) float64 { return l(v) * right(v) }
+		case "/":
+			left = func(v *exprVars) float64 { return l(v) / right(v) }
+		default:
+			left = func(v *exprVars) float64 { return math.Mod(l(v), right(v)) }
+		}
+	}
+	return left, nil
+}
+
+// parseUnary parses: ('+' | '-') unary | power
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("+-") {
+		op := p.tok.text
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if op == "-" {
+			return func(v *exprVars) float64 { return -operand(v) }, nil
+		}
+		return operand, nil
+	}
+	return p.parsePower()
+}
+
+// parsePower parses: primary ('^' unary)?
+// Exponentiation is right-associative and binds tighter than a unary minus on its left (-2^2 is -4).
+func (p *exprParser) parsePower() (exprNode, error) {
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.isOp("^") {
+		p.next()
+		exp, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(v *exprVars) float64 { return math.Pow(base(v), exp(v)) }, nil
+	}
+	return base, nil
+}
+
+// parsePrimary parses: number | variable | constant | function '(' args ')' | '(' expr ')'
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokNumber:
+		n, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, p.errorf("invalid number %q", tok.text)
+		}
+		p.next()
+		return func(*exprVars) float64 { return n }, nil
+
+	case tokIdent:
+		p.next()
+		switch tok.text {
+		case "t":
+			return func(v *exprVars) float64 { return v.t }, nil
+		case "id":
+			return func(v *exprVars) float64 { return v.id }, nil
+		case "pi":
+			return func(*exprVars) float64 { return math.Pi }, nil
+		case "e":
+			return func(*exprVars) float64 { return math.E }, nil
+		}
+
+		f, ok := exprFuncs[tok.text]
+		if !ok {
+			return nil, fmt.Errorf("at offset %d: unknown identifier %q", tok.pos, tok.text)
+		}
+		args, err := p.parseArgs()
+		if err != nil {
+			return nil, err
+		}
+		if len(args) != f.arity {
+			return nil, fmt.Errorf("at offset %d: %s takes %d argument(s), got %d", tok.pos, tok.text, f.arity, len(args))
+		}
+		return func(v *exprVars) float64 {
+			vals := make([]float64, len(args))
+			for i, arg := range args {
+				vals[i] = arg(v)
+			}
+			return f.fn(vals)
+		}, nil
+
+	case tokOp:
+		if tok.text == "(" {
+			p.next()
+			inner, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return inner, nil
+		}
+		return nil, p.errorf("unexpected %q", tok.text)
+
+	default:
+		return nil, p.errorf("unexpected end of expression")
+	}
+}
+
+// parseArgs parses a parenthesized, comma-separated argument list.
+func (p *exprParser) parseArgs() ([]exprNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+
+	var args []exprNode
+	if p.isOp(")") {
+		p.next()
+		return args, nil
+	}
+	for {
+		arg, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+
+		if p.isOp(",") {
+			p.next()
+			continue
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return args, nil
+	}
+}
@@ -0,0 +1,64 @@
+package sensor
+
+import (
+	"fmt"
+	"math/rand/v2"
+)
+
+// FaultMode is a way a simulated sensor misbehaves, to exercise downstream anomaly handling.
+type FaultMode string
+
+const (
+	// FaultStuck repeats the sensor's previous value instead of a new reading.
+	FaultStuck FaultMode = "stuck"
+	// FaultSpike emits an extreme outlier: the reading offset by the spike magnitude, up or down.
+	FaultSpike FaultMode = "spike"
+	// FaultDropout skips emitting the reading.
+	FaultDropout FaultMode = "dropout"
+)
+
+// DefaultSpikeMagnitude is how far spikes are from the reading they replace, by default.
+// Sensors' values are otherwise typically in [0, 1).
+const DefaultSpikeMagnitude = 100.0
+
+// FaultConfig configures fault injection.
+// Each reading is faulty with probability Probability, in [0, 1].
+type FaultConfig struct {
+	Mode        FaultMode
+	Probability float64
+	// SpikeMagnitude is how far FaultSpike outliers are from the reading (DefaultSpikeMagnitude if 0).
+	SpikeMagnitude float64
+}
+
+// validate returns an error if the config's mode is unknown or its probability out of range.
+func (c FaultConfig) validate() error {
+	switch c.Mode {
+	case FaultStuck, FaultSpike, FaultDropout:
+	default:
+		return fmt.Errorf("unknown fault mode %q", c.Mode)
+	}
+	if !(c.Probability >= 0 && c.Probability <= 1) {
+		return fmt.Errorf("invalid fault probability %v: must be in [0, 1]", c.Probability)
+	}
+	return nil
+}
+
+// roll decides whether the next reading is faulty, using r.
+// For spikes, it also returns the offset to add to the reading.
+func (c FaultConfig) roll(r *rand.Rand) (faulty bool, offset float64) {
+	if r.Float64() >= c.Probability {
+		return false, 0
+	}
+	if c.Mode != FaultSpike {
+		return true, 0
+	}
+
+	offset = c.SpikeMagnitude
+	if offset == 0 {
+		offset = DefaultSpikeMagnitude
+	}
+	if r.IntN(2) == 0 {
+		offset = -offset
+	}
+	return true, offset
+}
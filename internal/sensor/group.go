@@ -0,0 +1,97 @@
+package sensor
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// groupStream is the PCG stream groups' random sources are drawn from, so they don't coincide with the sensors' sources.
+const groupStream = 0x67726f7570 // "group"
+
+// GroupConfig configures a group of sensors sharing an underlying signal (see Group).
+type GroupConfig struct {
+	Name    string
+	Members []int // The IDs of the sensors in the group.
+	// Base generates the signal the members share, e.g. a sensor.Sine for a room's daily temperature cycle.
+	Base Distribution
+	// Noise is added independently by each member to the base value, from its own random source. Nil adds none.
+	Noise Distribution
+	// Hold is how long a value drawn from Base is shared before the next is drawn, typically the sensors' interval.
+	Hold time.Duration
+}
+
+// Group is a set of spatially clustered sensors whose readings move together, e.g. the thermostats of one room.
+// Its members read the group's current base value, each adding its own independent noise.
+// It is safe for concurrent use by its members.
+type Group struct {
+	cfg GroupConfig
+
+	mu      sync.Mutex
+	rand    *rand.Rand
+	value   float64
+	sampled time.Time
+}
+
+// NewGroups creates the groups configured by configs, with random sources derived from the base seed (see WithSeed),
+// and returns them by member ID. It returns an error if a group is invalid or a sensor is in more than one group.
+func NewGroups(configs []GroupConfig, seed int64) (map[int]*Group, error) {
+	groups := make(map[int]*Group)
+	var errs []error
+	for i, cfg := range configs {
+		if cfg.Base == nil {
+			errs = append(errs, fmt.Errorf("group %q has no base distribution", cfg.Name))
+		}
+		if cfg.Hold <= 0 {
+			errs = append(errs, fmt.Errorf("group %q hold must be positive, got %v", cfg.Name, cfg.Hold))
+		}
+		if len(cfg.Members) == 0 {
+			errs = append(errs, fmt.Errorf("group %q has no members", cfg.Name))
+		}
+
+		g := &Group{cfg: cfg, rand: rand.New(rand.NewPCG(uint64(seed)+uint64(i), groupStream))}
+		for _, id := range cfg.Members {
+			if other, ok := groups[id]; ok {
+				errs = append(errs, fmt.Errorf("sensor %d is in groups %q and %q", id, other.cfg.Name, cfg.Name))
+				continue
+			}
+			groups[id] = g
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return groups, nil
+}
+
+// Name returns the group's name.
+func (g *Group) Name() string {
+	return g.cfg.Name
+}
+
+// Value returns the group's base value at time now: the last value drawn from its base distribution,
+// or a new one if that was drawn at least Hold ago.
+func (g *Group) Value(now time.Time) float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.sampled.IsZero() || now.Sub(g.sampled) >= g.cfg.Hold {
+		g.value = g.cfg.Base.Sample(g.rand, now)
+		g.sampled = now
+	}
+	return g.value
+}
+
+// Distribution returns a member's Distribution: the group's current base value plus noise
+// sampled from the member's own random source.
+func (g *Group) Distribution() Distribution {
+	return DistributionFunc(func(r *rand.Rand, now time.Time) float64 {
+		value := g.Value(now)
+		if g.cfg.Noise != nil {
+			value += g.cfg.Noise.Sample(r, now)
+		}
+		return value
+	})
+}
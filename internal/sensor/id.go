@@ -0,0 +1,74 @@
+package sensor
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// IDAllocator assigns the external device ID of a sensor, given its integer ID.
+// The integer ID is still used internally (e.g. for indexing and subjects).
+// Allocation is deterministic, so a sensor keeps its device ID across restarts (and runs).
+type IDAllocator interface {
+	Allocate(id int) string
+}
+
+// NewIDAllocator returns the IDAllocator for scheme, which is one of "sequential", "uuid", or "mac".
+func NewIDAllocator(scheme string) (IDAllocator, error) {
+	switch scheme {
+	case "sequential":
+		return SequentialIDs{}, nil
+	case "uuid":
+		return UUIDs{}, nil
+	case "mac":
+		return MACIDs{}, nil
+	default:
+		return nil, fmt.Errorf("unknown device ID scheme %q", scheme)
+	}
+}
+
+// SequentialIDs allocates the decimal sensor ID, optionally prefixed (e.g. "sensor-42").
+type SequentialIDs struct {
+	Prefix string
+}
+
+// Allocate returns the prefixed decimal form of id.
+func (a SequentialIDs) Allocate(id int) string {
+	return a.Prefix + strconv.Itoa(id)
+}
+
+// uuidNamespace is the namespace UUIDs allocates name-based UUIDs in.
+var uuidNamespace = [16]byte{
+	0x5c, 0x1e, 0x0a, 0x7d, 0x3b, 0x2f, 0x4e, 0x91,
+	0x8a, 0x6d, 0x0f, 0x52, 0xc4, 0x17, 0xe3, 0x28,
+}
+
+// UUIDs allocates name-based (version 5) UUIDs, e.g. "3f2b1c4d-5e6f-5a7b-8c9d-0e1f2a3b4c5d".
+type UUIDs struct{}
+
+// Allocate returns the version 5 UUID for id.
+func (UUIDs) Allocate(id int) string {
+	h := sha1.New()
+	h.Write(uuidNamespace[:])
+	h.Write([]byte(strconv.Itoa(id)))
+	sum := h.Sum(nil)
+
+	var u [16]byte
+	copy(u[:], sum)
+	u[6] = (u[6] & 0x0f) | 0x50 // Version 5.
+	u[8] = (u[8] & 0x3f) | 0x80 // RFC 4122 variant.
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// MACIDs allocates locally administered unicast MAC addresses, e.g. "02:00:00:00:00:2a".
+// IDs are unique for sensor IDs up to 2^32-1.
+type MACIDs struct{}
+
+// Allocate returns the MAC address for id.
+func (MACIDs) Allocate(id int) string {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(id))
+	return fmt.Sprintf("02:00:%02x:%02x:%02x:%02x", b[0], b[1], b[2], b[3])
+}
@@ -0,0 +1,111 @@
+package sensor
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+)
+
+// Location is a position on Earth, in decimal degrees.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// validate returns an error if l isn't a valid position.
+func (l Location) validate() error {
+	if !(l.Latitude >= -90 && l.Latitude <= 90) || !(l.Longitude >= -180 && l.Longitude <= 180) {
+		return fmt.Errorf("invalid location (%v, %v): latitude must be in [-90, 90] and longitude in [-180, 180]", l.Latitude, l.Longitude)
+	}
+	return nil
+}
+
+// BoundingBox is the area between its south-west (Min) and north-east (Max) corners.
+// Boxes don't cross the antimeridian, so Min.Longitude must not be greater than Max.Longitude.
+type BoundingBox struct {
+	Min Location
+	Max Location
+}
+
+// Validate returns an error if the box's corners aren't valid positions, or aren't south-west and north-east of each other.
+func (b BoundingBox) Validate() error {
+	if err := b.Min.validate(); err != nil {
+		return err
+	}
+	if err := b.Max.validate(); err != nil {
+		return err
+	}
+	if b.Min.Latitude > b.Max.Latitude || b.Min.Longitude > b.Max.Longitude {
+		return fmt.Errorf("invalid bounding box %v to %v: min must be south-west of max", b.Min, b.Max)
+	}
+	return nil
+}
+
+// RandomLocation returns a position uniformly distributed (in degrees) within bbox, drawn from r.
+// Locations drawn from a seeded r (e.g. the fleet's seed) are the same on every run.
+func RandomLocation(bbox BoundingBox, r *rand.Rand) Location {
+	return Location{
+		Latitude:  bbox.Min.Latitude + r.Float64()*(bbox.Max.Latitude-bbox.Min.Latitude),
+		Longitude: bbox.Min.Longitude + r.Float64()*(bbox.Max.Longitude-bbox.Min.Longitude),
+	}
+}
+
+// ParseLocation parses a position written as "latitude,longitude", e.g. "51.5,-0.12", and validates it.
+func ParseLocation(s string) (Location, error) {
+	coords, err := parseCoordinates(s, 2)
+	if err != nil {
+		return Location{}, fmt.Errorf("invalid location %q: %w", s, err)
+	}
+	l := Location{Latitude: coords[0], Longitude: coords[1]}
+	return l, l.validate()
+}
+
+// ParseLocations parses a semicolon-separated list of positions, e.g. "51.5,-0.12;48.86,2.35",
+// each as ParseLocation does. An empty list has no positions.
+func ParseLocations(list string) ([]Location, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+
+	var locations []Location
+	for _, s := range strings.Split(list, ";") {
+		l, err := ParseLocation(s)
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, l)
+	}
+	return locations, nil
+}
+
+// ParseBoundingBox parses a box written as its south-west and north-east corners,
+// "min_lat,min_lon,max_lat,max_lon" (e.g. "51.28,-0.51,51.69,0.33"), and validates it.
+func ParseBoundingBox(s string) (BoundingBox, error) {
+	coords, err := parseCoordinates(s, 4)
+	if err != nil {
+		return BoundingBox{}, fmt.Errorf("invalid bounding box %q: %w", s, err)
+	}
+	b := BoundingBox{
+		Min: Location{Latitude: coords[0], Longitude: coords[1]},
+		Max: Location{Latitude: coords[2], Longitude: coords[3]},
+	}
+	return b, b.Validate()
+}
+
+// parseCoordinates parses n comma-separated decimal degrees.
+func parseCoordinates(s string, n int) ([]float64, error) {
+	fields := strings.Split(s, ",")
+	if len(fields) != n {
+		return nil, fmt.Errorf("expected %d comma-separated coordinates, got %d", n, len(fields))
+	}
+	coords := make([]float64, n)
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate %q", strings.TrimSpace(f))
+		}
+		coords[i] = v
+	}
+	return coords, nil
+}
@@ -0,0 +1,316 @@
+package sensor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+var (
+	// ErrNoLauncher is returned by Add when SetLauncher hasn't been called.
+	ErrNoLauncher = errors.New("sensor manager has no launcher")
+	// ErrStopping is returned by Add once the launcher's context is done.
+	ErrStopping = errors.New("simulation is stopping")
+	// ErrRunning is returned by Add for a sensor that is already running.
+	ErrRunning = errors.New("sensor already running")
+	// ErrNotFound is returned by Remove for a sensor that isn't tracked.
+	ErrNotFound = errors.New("sensor not found")
+)
+
+// Manager tracks running sensors, so that their exit can be confirmed during shutdown.
+// Sensors can also be added and removed while the simulation runs (see Add and Remove),
+// e.g. to scale the fleet up and down.
+type Manager struct {
+	mu       sync.Mutex
+	sensors  map[int]tracked
+	launcher *launcher
+	metrics  *metrics.Metrics
+	base     *slog.Logger // The logger added sensors log to.
+	logger   *slog.Logger
+}
+
+// tracked is a sensor tracked by a Manager.
+type tracked struct {
+	done   <-chan struct{}    // Closed when the sensor stops.
+	cancel context.CancelFunc // Stops the sensor.
+}
+
+// launcher holds how Add starts sensors.
+type launcher struct {
+	ctx      context.Context
+	dataCh   chan<- model.SensorData
+	interval time.Duration
+	opts     func(id int) []Option
+}
+
+// NewManager creates and returns a new Manager instance.
+func NewManager(m *metrics.Metrics, l *slog.Logger) *Manager {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	return &Manager{
+		sensors: make(map[int]tracked),
+		metrics: m,
+		base:    l,
+		logger:  l.With("component", "sensor_manager"),
+	}
+}
+
+// SetLauncher configures how Add starts sensors: until ctx is done (or they're removed),
+// sending to dataCh every interval, with the options opts returns for their ID (opts may be nil).
+func (mgr *Manager) SetLauncher(ctx context.Context, dataCh chan<- model.SensorData, interval time.Duration, opts func(id int) []Option) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	mgr.launcher = &launcher{ctx: ctx, dataCh: dataCh, interval: interval, opts: opts}
+}
+
+// Add starts the sensor identified by id (see Start) with the launcher set by SetLauncher, and tracks it.
+// A sensor that has stopped for good (e.g. given up after too many panics) can be added again.
+// Each sensor runs with its own context, derived from the launcher's, which is canceled once it stops.
+func (mgr *Manager) Add(id int) error {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	l := mgr.launcher
+	if l == nil {
+		return ErrNoLauncher
+	}
+	// Checked under the lock, so a sensor can't be added once Wait has taken its snapshot of the tracked sensors.
+	if l.ctx.Err() != nil {
+		return ErrStopping
+	}
+	if t, ok := mgr.sensors[id]; ok {
+		if !isDone(t.done) {
+			return ErrRunning
+		}
+		t.cancel() // The stopped sensor released its context already, but replacing it must never leak one.
+	}
+
+	var opts []Option
+	if l.opts != nil {
+		opts = l.opts(id)
+	}
+	ctx, cancel := context.WithCancel(l.ctx)
+	mgr.sensors[id] = tracked{
+		done:   start(ctx, cancel, id, l.dataCh, l.interval, mgr.metrics, mgr.base, opts),
+		cancel: cancel,
+	}
+	return nil
+}
+
+// Ramp adds sensors 1 to n (see Add), staggering their starts evenly over ramp (i.e. ramp/n apart),
+// so they come online gradually rather than all at once. Without a ramp, they're all added straight away.
+// Sensors that fail to start are logged, without interrupting the ramp. If ctx is done during the ramp,
+// it stops adding sensors and returns ctx's error.
+func (mgr *Manager) Ramp(ctx context.Context, n int, ramp time.Duration) error {
+	var step <-chan time.Time
+	if n > 1 && ramp > 0 {
+		// A ticker keeps the starts evenly spaced, however long each takes.
+		ticker := time.NewTicker(max(ramp/time.Duration(n), time.Nanosecond))
+		defer ticker.Stop()
+		step = ticker.C
+	}
+
+	for id := 1; id <= n; id++ {
+		if id > 1 && step != nil {
+			select {
+			case <-ctx.Done():
+				mgr.logger.Info("Ramp-up interrupted", "started", id-1, "sensor_count", n)
+				return ctx.Err()
+			case <-step:
+			}
+		}
+		if err := mgr.Add(id); err != nil {
+			mgr.logger.Error("Failed to start sensor", "sensor_id", id, "error", err)
+		}
+	}
+	if step != nil {
+		mgr.logger.Info("Ramp-up complete", "sensor_count", n, "ramp", ramp)
+	}
+	return nil
+}
+
+// Remove stops the sensor identified by id, and stops tracking it once it has fully stopped (i.e. Remove waits for it to stop).
+func (mgr *Manager) Remove(id int) error {
+	mgr.mu.Lock()
+	t, ok := mgr.sensors[id]
+	mgr.mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+
+	t.cancel()
+	<-t.done
+
+	// The sensor stays tracked until it has stopped, so shutdown can't close the data channel while it's still sending.
+	// It may have been removed and added again meanwhile, in which case the new sensor is kept.
+	mgr.mu.Lock()
+	if current, ok := mgr.sensors[id]; ok && current.done == t.done {
+		delete(mgr.sensors, id)
+	}
+	mgr.mu.Unlock()
+	return nil
+}
+
+// IDs returns the (sorted) IDs of the tracked sensors that are still running.
+func (mgr *Manager) IDs() []int {
+	mgr.mu.Lock()
+	sensors := make(map[int]<-chan struct{}, len(mgr.sensors))
+	for id, t := range mgr.sensors {
+		sensors[id] = t.done
+	}
+	mgr.mu.Unlock()
+
+	ids := running(sensors)
+	sort.Ints(ids)
+	return ids
+}
+
+// isDone reports whether done is closed.
+func isDone(done <-chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// Len returns the number of tracked sensors.
+func (mgr *Manager) Len() int {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+
+	return len(mgr.sensors)
+}
+
+// Wait waits up to grace for every tracked sensor to stop, and should be called after their context is canceled.
+// It returns the (sorted) IDs of sensors that didn't stop in time, which are logged and counted
+// as shutdown timeouts, rather than hanging shutdown forever.
+func (mgr *Manager) Wait(grace time.Duration) []int {
+	mgr.mu.Lock()
+	sensors := make(map[int]<-chan struct{}, len(mgr.sensors))
+	for id, t := range mgr.sensors {
+		sensors[id] = t.done
+	}
+	mgr.mu.Unlock()
+
+	deadline := time.NewTimer(grace)
+	defer deadline.Stop()
+
+	for id, done := range sensors {
+		select {
+		case <-done:
+			delete(sensors, id)
+		case <-deadline.C:
+			return mgr.reportStragglers(running(sensors), grace)
+		}
+	}
+
+	return nil
+}
+
+// running returns the IDs of the sensors whose done channel isn't closed.
+func running(sensors map[int]<-chan struct{}) []int {
+	var ids []int
+	for id, done := range sensors {
+		if !isDone(done) {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// reportStragglers logs and counts sensors that didn't stop within the grace period.
+func (mgr *Manager) reportStragglers(ids []int, grace time.Duration) []int {
+	sort.Ints(ids)
+
+	mgr.logger.Warn("Sensors did not stop within grace period",
+		"count", len(ids),
+		"grace_period", grace,
+		"sensor_ids", ids)
+
+	if mgr.metrics != nil {
+		mgr.metrics.SensorShutdownTimeouts.Add(float64(len(ids)))
+	}
+
+	return ids
+}
+
+// Handler returns an http.Handler for scaling the fleet at runtime:
+// `POST /admin/sensors/{id}` adds a sensor (see Add), `DELETE /admin/sensors/{id}` removes one (see Remove),
+// and `GET /admin/sensors` lists the running sensors' IDs. Responses are JSON.
+func (mgr *Manager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/sensors", func(w http.ResponseWriter, _ *http.Request) {
+		ids := mgr.IDs()
+		if ids == nil {
+			ids = []int{} // Listed as [], rather than null.
+		}
+		writeJSON(w, http.StatusOK, fleetResponse{Count: len(ids), IDs: ids})
+	})
+	mux.HandleFunc("POST /admin/sensors/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mgr.handleChange(w, r, "added", http.StatusCreated, mgr.Add)
+	})
+	mux.HandleFunc("DELETE /admin/sensors/{id}", func(w http.ResponseWriter, r *http.Request) {
+		mgr.handleChange(w, r, "removed", http.StatusOK, mgr.Remove)
+	})
+	return mux
+}
+
+// fleetResponse is the JSON body of a fleet listing.
+type fleetResponse struct {
+	Count int   `json:"count"`
+	IDs   []int `json:"ids"`
+}
+
+// changeResponse is the JSON body of a response to adding or removing a sensor.
+type changeResponse struct {
+	ID     int    `json:"id"`
+	Status string `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// handleChange applies change (Add or Remove) to the sensor whose ID is in the request's path,
+// responding with code and status on success, or with a status code matching the error.
+func (mgr *Manager) handleChange(w http.ResponseWriter, r *http.Request, status string, code int, change func(id int) error) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil || id < 1 {
+		writeJSON(w, http.StatusBadRequest, changeResponse{Error: fmt.Sprintf("invalid sensor id %q", r.PathValue("id"))})
+		return
+	}
+
+	err = change(id)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		code = http.StatusNotFound
+	case errors.Is(err, ErrRunning):
+		code = http.StatusConflict
+	case err != nil:
+		code = http.StatusServiceUnavailable
+	default:
+		mgr.logger.Info("Fleet changed", "sensor_id", id, "change", status)
+		writeJSON(w, code, changeResponse{ID: id, Status: status})
+		return
+	}
+	writeJSON(w, code, changeResponse{ID: id, Error: err.Error()})
+}
+
+// writeJSON writes v as JSON with the status code code.
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
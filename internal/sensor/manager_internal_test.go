@@ -0,0 +1,59 @@
+package sensor
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// TestStart_ReleasesOnStop verifies a sensor that stops of its own accord (here, with invalid options)
+// releases what was held for it before its done channel closes, as does one that never starts.
+func TestStart_ReleasesOnStop(t *testing.T) {
+	t.Parallel()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	for name, ctx := range map[string]context.Context{"stopped": context.Background(), "never started": canceled} {
+		released := make(chan struct{})
+		done := start(ctx, func() { close(released) }, 1, make(chan model.SensorData), time.Second,
+			nil, slog.New(slog.DiscardHandler), []Option{WithRange(1, 0)})
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: timed out waiting for the sensor to stop", name)
+		}
+		if !isDone(released) {
+			t.Errorf("%s: expected the sensor to be released by the time it stopped", name)
+		}
+	}
+}
+
+// TestManager_Add_CancelsReplaced verifies re-adding a sensor that has stopped cancels the context
+// it was tracked with, before tracking the new one.
+func TestManager_Add_CancelsReplaced(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr := NewManager(nil, slog.New(slog.DiscardHandler))
+	mgr.SetLauncher(ctx, make(chan model.SensorData, 1), time.Hour, nil)
+
+	stopped := make(chan struct{})
+	close(stopped)
+	replacedCanceled := false
+	mgr.sensors[1] = tracked{done: stopped, cancel: func() { replacedCanceled = true }}
+
+	if err := mgr.Add(1); err != nil {
+		t.Fatalf("failed to re-add sensor: %v", err)
+	}
+	if !replacedCanceled {
+		t.Error("expected the stopped sensor's context to be canceled")
+	}
+	if err := mgr.Remove(1); err != nil {
+		t.Errorf("failed to remove sensor: %v", err)
+	}
+}
@@ -0,0 +1,56 @@
+package sensor
+
+import (
+	"context"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// registryTimeout bounds each registry call, so an unreachable registry doesn't hold a sensor up.
+const registryTimeout = 2 * time.Second
+
+// Registry records the live fleet, so it can be enumerated elsewhere (e.g. nats.Registry, backed by a KV bucket).
+type Registry interface {
+	Register(ctx context.Context, id int, meta model.SensorMeta) error
+	Deregister(ctx context.Context, id int) error
+}
+
+// Meta returns the sensor's metadata, as recorded in its registry.
+func (s *Sensor) Meta() model.SensorMeta {
+	meta := model.SensorMeta{
+		ID:              s.ID,
+		DeviceID:        s.deviceID,
+		Type:            s.profile.Type,
+		Unit:            s.profile.Unit,
+		Model:           s.profile.Model,
+		FirmwareVersion: s.profile.FirmwareVersion,
+		Interval:        s.Interval,
+		Tags:            s.profile.Tags,
+	}
+	if s.location != nil {
+		meta.Latitude, meta.Longitude = s.location.Latitude, s.location.Longitude
+	}
+	return meta
+}
+
+// register records the sensor in its registry, logging (rather than failing on) errors.
+func (s *Sensor) register(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, registryTimeout)
+	defer cancel()
+
+	if err := s.registry.Register(ctx, s.ID, s.Meta()); err != nil {
+		s.logger.Warn("Failed to register sensor", "error", err)
+	}
+}
+
+// deregister removes the sensor from its registry, logging (rather than failing on) errors.
+// It's called as the sensor stops, once its context is done, so it isn't bound to that context.
+func (s *Sensor) deregister() {
+	ctx, cancel := context.WithTimeout(context.Background(), registryTimeout)
+	defer cancel()
+
+	if err := s.registry.Deregister(ctx, s.ID); err != nil {
+		s.logger.Warn("Failed to deregister sensor", "error", err)
+	}
+}
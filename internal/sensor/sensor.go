@@ -5,110 +5,689 @@ package sensor
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
-	"math/rand"
+	"math"
+	"math/rand/v2"
 	"strconv"
-	"sync"
 	"time"
 
+	"golang.org/x/time/rate"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/clock"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/shutdown"
 )
 
+// DefaultMinInterval is the default floor for a sensor's emission intervals.
+// Shorter intervals are clamped to it, since they would effectively spin the sensor's ticker.
+const DefaultMinInterval = time.Millisecond
+
 // Sensor encapsulates the logic for a single simulated sensor.
 type Sensor struct {
-	ID       int
-	DataCh   chan<- model.SensorData
-	Interval time.Duration
-	rand     *rand.Rand
-	randMux  sync.Mutex
-	idStr    string // Store ID as a string for performance when labeling metrics.
-	metrics  *metrics.Metrics
-	logger   *slog.Logger
+	ID           int
+	DataCh       chan<- model.SensorData
+	Interval     time.Duration
+	rand         *rand.Rand // Only used by the goroutine running Run, so it needs no lock.
+	seed         int64      // Base seed of rand, which is seeded with seed+ID.
+	idStr        string     // Store ID as a string for performance when labeling metrics.
+	deviceID     string
+	location     *Location
+	profile      Profile
+	distribution Distribution
+	valueRange   *valueRange
+	fault        *FaultConfig
+	drift        *drift
+	adaptive     *AdaptiveConfig
+	burst        *BurstConfig
+	jitter       float64 // Fraction each interval is randomly varied by, e.g. 0.1 for ±10%.
+	backpressure *BackpressureConfig
+	registry     Registry
+	dropOnFull   func() bool // Whether to drop readings DataCh has no room for, rather than block (nil never drops).
+	limiter      *rate.Limiter
+	control      *Controller
+	minInterval  time.Duration
+	maxRestarts  int
+	precision    time.Duration
+	clock        clock.Clock
+	metrics      *metrics.Metrics
+	logger       *slog.Logger
+}
+
+// Profile describes a class of sensors that share the same device characteristics.
+// It lets a fleet be segmented, e.g. by device model or firmware version.
+type Profile struct {
+	Name            string
+	Model           string
+	FirmwareVersion string
+	// Type is the kind of reading the profile's sensors emit (e.g. "temperature"), in Unit (e.g. "celsius").
+	// The type is also a token of the subject readings are published to, so it should be a single word.
+	Type string
+	Unit string
+	// Tags is attached to every reading from the profile's sensors (e.g. site=north, rack=3).
+	Tags map[string]string
+	// Distribution, when set, is the distribution the profile's sensors sample values from,
+	// so a fleet can mix kinds of sensors. WithDistribution takes precedence over it.
+	Distribution Distribution
+}
+
+// valueRange is the range [min, max] a sensor's values are clamped into.
+type valueRange struct {
+	min, max float64
+}
+
+// clamp returns v clamped into the range, and whether it had to be.
+func (r valueRange) clamp(v float64) (float64, bool) {
+	switch {
+	case v < r.min:
+		return r.min, true
+	case v > r.max:
+		return r.max, true
+	default:
+		return v, false
+	}
+}
+
+// Option configures optional Sensor behavior.
+type Option func(*Sensor)
+
+// WithProfile assigns the sensor to profile p.
+// The profile's model, firmware version, type, unit, and tags are included in every reading the sensor emits.
+func WithProfile(p Profile) Option {
+	return func(s *Sensor) {
+		s.profile = p
+	}
+}
+
+// WithDeviceID sets the sensor's external device ID (see IDAllocator),
+// which is included in every reading the sensor emits.
+func WithDeviceID(deviceID string) Option {
+	return func(s *Sensor) {
+		s.deviceID = deviceID
+	}
+}
+
+// WithLocation places the sensor at loc, which is included in every reading the sensor emits.
+func WithLocation(loc Location) Option {
+	return func(s *Sensor) {
+		s.location = &loc
+	}
+}
+
+// WithDistribution sets the distribution the sensor's values are sampled from.
+// Sensors sample from their profile's distribution, or Uniform if it has none, by default.
+func WithDistribution(d Distribution) Option {
+	return func(s *Sensor) {
+		s.distribution = d
+	}
+}
+
+// WithRange bounds the sensor's values to its physical range [lo, hi]:
+// generated values outside it are clamped into it, and counted by the clamped values metric.
+// NewSensor returns an error unless lo < hi.
+func WithRange(lo, hi float64) Option {
+	return func(s *Sensor) {
+		s.valueRange = &valueRange{min: lo, max: hi}
+	}
+}
+
+// WithDriftRate makes the sensor drift, offsetting its values by a bias that grows
+// by rate every second since the sensor started (or ResetDrift was last called).
+// The accumulated drift is exported by the sensor drift metric.
+func WithDriftRate(rate float64) Option {
+	return func(s *Sensor) {
+		s.drift = &drift{rate: rate}
+	}
+}
+
+// WithFault injects faults into the sensor's readings, as configured by cfg.
+// Each fault is counted by the sensor faults metric.
+// NewSensor returns an error if cfg is invalid.
+func WithFault(cfg FaultConfig) Option {
+	return func(s *Sensor) {
+		s.fault = &cfg
+	}
+}
+
+// WithAdaptive enables adaptive emission, adjusting the sensor's interval
+// within the configured bounds based on how fast its value changes.
+func WithAdaptive(cfg AdaptiveConfig) Option {
+	return func(s *Sensor) {
+		s.adaptive = &cfg
+	}
+}
+
+// WithBurst enables burst emission, replacing the sensor's fixed interval with the burst pattern.
+// It takes precedence over adaptive emission. Configs with a BurstSize below 1 are ignored.
+func WithBurst(cfg BurstConfig) Option {
+	return func(s *Sensor) {
+		if cfg.BurstSize >= 1 {
+			s.burst = &cfg
+		}
+	}
+}
+
+// WithJitter randomly varies each of the sensor's intervals by up to ±fraction of it (e.g. 0.1 for ±10%),
+// since real fleets aren't perfectly periodic. The intervals still average out to the configured one.
+// fraction must be in [0, 1); 0 (the default) keeps the sensor strictly periodic.
+func WithJitter(fraction float64) Option {
+	return func(s *Sensor) {
+		s.jitter = fraction
+	}
+}
+
+// WithBackpressure enables backpressure-aware emission, slowing the sensor down (up to cfg.MaxInterval)
+// while cfg.Signal reports high pressure. It only applies to the fixed interval:
+// burst and adaptive emission take precedence. Configs without a signal are ignored.
+func WithBackpressure(cfg BackpressureConfig) Option {
+	return func(s *Sensor) {
+		if cfg.Signal != nil {
+			s.backpressure = &cfg
+		}
+	}
+}
+
+// WithDropOnFull makes the sensor drop readings its DataCh has no room for while when returns true,
+// counting them as dropped, instead of blocking until there is room.
+// Blocking never loses a reading, but slows the sensor down to the pace of its consumers;
+// dropping keeps the sensor on its schedule, at the cost of gaps in its readings.
+// when is called for every reading, so it should be cheap (e.g. an atomic.Bool's Load).
+func WithDropOnFull(when func() bool) Option {
+	return func(s *Sensor) {
+		s.dropOnFull = when
+	}
+}
+
+// WithRateLimiter makes the sensor wait for a token from limiter before sending each reading.
+// Sharing one limiter across the fleet caps its aggregate rate (e.g. to simulate a constrained uplink):
+// sensors are held back, rather than dropping readings, and the time they wait is counted as throttled.
+func WithRateLimiter(limiter *rate.Limiter) Option {
+	return func(s *Sensor) {
+		s.limiter = limiter
+	}
+}
+
+// WithController makes the sensor follow c: it waits without emitting while c is paused,
+// and emits at c's interval rather than the one it was created with.
+// Like backpressure, the interval only applies to the fixed interval, not in adaptive or burst mode.
+func WithController(c *Controller) Option {
+	return func(s *Sensor) {
+		s.control = c
+	}
+}
+
+// WithRegistry registers the sensor in r when it starts, and deregisters it once it has stopped for good.
+// Registry errors are logged, and don't stop the sensor.
+func WithRegistry(r Registry) Option {
+	return func(s *Sensor) {
+		s.registry = r
+	}
+}
+
+// WithMinInterval overrides the floor (DefaultMinInterval) that the sensor's intervals are clamped to.
+func WithMinInterval(d time.Duration) Option {
+	return func(s *Sensor) {
+		s.minInterval = d
+	}
+}
+
+// WithMaxRestarts limits how many times Start restarts the sensor after it panics:
+// once a sensor has been restarted n times, its next panic stops it for good, which is logged
+// and counted by the sensors given up metric. 0 (the default) restarts it indefinitely.
+func WithMaxRestarts(n int) Option {
+	return func(s *Sensor) {
+		s.maxRestarts = n
+	}
+}
+
+// WithTimestampPrecision truncates the timestamps of emitted readings to a multiple of precision
+// (e.g. time.Millisecond), keeping payloads smaller and easier to compress and dedupe.
+// Non-positive precisions leave timestamps untruncated, which is the default.
+func WithTimestampPrecision(precision time.Duration) Option {
+	return func(s *Sensor) {
+		s.precision = precision
+	}
+}
+
+// WithClock sets the clock the sensor is timed by, and timestamps its readings with (clock.Real by default),
+// e.g. a clock.Fake, so tests can step the sensor through its intervals without waiting for them.
+func WithClock(c clock.Clock) Option {
+	return func(s *Sensor) {
+		s.clock = c
+	}
+}
+
+// WithSeed sets the base seed of the sensor's random source, so a run can be reproduced.
+// The source is seeded with base plus the sensor's ID, so sensors sharing a base seed still generate different values.
+// Without it, the base seed is taken from NewSeed.
+func WithSeed(base int64) Option {
+	return func(s *Sensor) {
+		s.seed = base
+	}
+}
+
+// NewSeed returns a new, time-based base seed for WithSeed.
+func NewSeed() int64 {
+	return time.Now().UnixNano()
 }
 
 // NewSensor creates and returns a new Sensor instance.
-func NewSensor(id int, dataCh chan<- model.SensorData, interval time.Duration, m *metrics.Metrics, l *slog.Logger) *Sensor {
+// Intervals shorter than the sensor's minimum interval are clamped to it, logging a warning.
+// It returns an error if the options are invalid (e.g. an empty WithRange, or an unknown fault mode).
+func NewSensor(id int, dataCh chan<- model.SensorData, interval time.Duration, m *metrics.Metrics, l *slog.Logger, opts ...Option) (*Sensor, error) {
 	if l == nil {
 		l = slog.Default()
 	}
 
-	randSrc := rand.NewSource(time.Now().UnixNano() + int64(id)) // Add the id to ensure sensors created at the exact same nanosecond have different random sequences.
-	return &Sensor{
-		ID:       id,
-		DataCh:   dataCh,
-		Interval: interval,
-		rand:     rand.New(randSrc),
-		idStr:    strconv.Itoa(id), // Convert ID to string once.
-		metrics:  m,
-		logger:   l.With("component", "sensor", "sensor_id", id),
+	s := &Sensor{
+		ID:          id,
+		DataCh:      dataCh,
+		Interval:    interval,
+		seed:        NewSeed(),
+		idStr:       strconv.Itoa(id), // Convert ID to string once.
+		minInterval: DefaultMinInterval,
+		clock:       clock.Real,
+		metrics:     m,
+		logger:      l.With("component", "sensor", "sensor_id", id),
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if r := s.valueRange; r != nil && !(r.min < r.max) {
+		return nil, fmt.Errorf("invalid value range [%v, %v]: min must be less than max", r.min, r.max)
+	}
+
+	if s.fault != nil {
+		if err := s.fault.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if !(s.jitter >= 0 && s.jitter < 1) {
+		return nil, fmt.Errorf("invalid jitter %v: must be at least 0 and less than 1", s.jitter)
+	}
+
+	if s.location != nil {
+		if err := s.location.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if s.distribution == nil {
+		s.distribution = s.profile.Distribution
+	}
+	if s.distribution == nil {
+		s.distribution = Uniform{}
+	}
+
+	// Add the id to ensure sensors sharing a base seed (e.g. created at the exact same nanosecond) have different random sequences.
+	s.rand = rand.New(rand.NewPCG(uint64(s.seed+int64(id)), 0))
+
+	// Enforce the interval floor on every interval that drives the sensor's ticker.
+	s.Interval = s.floorInterval("interval", s.Interval)
+	if s.adaptive != nil {
+		s.adaptive.MinInterval = s.floorInterval("adaptive_min_interval", s.adaptive.MinInterval)
+		s.adaptive.MaxInterval = s.floorInterval("adaptive_max_interval", s.adaptive.MaxInterval)
+	}
+	if s.burst != nil {
+		s.burst.BurstInterval = s.floorInterval("burst_interval", s.burst.BurstInterval)
+		s.burst.IdleGap = s.floorInterval("burst_idle_gap", s.burst.IdleGap)
+	}
+	if s.backpressure != nil {
+		s.backpressure.MaxInterval = s.floorInterval("backpressure_max_interval", s.backpressure.MaxInterval)
+	}
+
+	return s, nil
+}
+
+// floorInterval clamps d to the sensor's minimum interval, logging a warning when it does.
+func (s *Sensor) floorInterval(name string, d time.Duration) time.Duration {
+	if d >= s.minInterval {
+		return d
 	}
+
+	s.logger.Warn("Interval below minimum, clamping",
+		"setting", name,
+		"configured", d,
+		"min_interval", s.minInterval)
+	return s.minInterval
 }
 
 // Run starts the sensor's data generation loop.
-// It emits generated data to the sensors DataCh at every Interval.
+// It emits generated data to the sensors DataCh at every Interval
+// (or, in adaptive mode, at an interval that tracks how fast the value changes,
+// or in burst mode, following the burst pattern,
+// or with backpressure, slowing down while downstream pressure is high),
+// with each interval varied by the sensor's jitter, if any.
 // It stops when the context ctx is cancelled.
+// Run must not be called concurrently on the same sensor, since its random source is unsynchronized.
 func (s *Sensor) Run(ctx context.Context) {
-	ticker := time.NewTicker(s.Interval)
-	defer ticker.Stop()
+	interval := s.Interval
+	switch {
+	case s.burst != nil:
+		interval = s.burst.BurstInterval
+	case s.adaptive != nil:
+		interval = s.adaptive.clamp(interval)
+	}
+	burstSent := 0
+
+	// The timer is reset for every reading, rather than ticking periodically, so each interval can be jittered.
+	timer := s.clock.NewTimer(s.jittered(interval))
+	defer timer.Stop()
 
-	s.logger.Info("Sensor starting", "sensor_id", s.ID)
+	var lastValue, lastSent float64
+	hasLast, hasSent := false, false
+
+	s.logger.Info("Sensor starting", "sensor_id", s.ID, "seed", s.seed)
+
+	if s.drift != nil {
+		s.drift.reset(s.clock.Now())
+	}
 
 	if s.metrics != nil {
-		s.metrics.ActiveSensors.Inc()
-		defer s.metrics.ActiveSensors.Dec()
+		active := s.metrics.ActiveSensors.WithLabelValues(s.profile.Name)
+		active.Inc()
+		defer active.Dec()
 	}
 
 	for {
 		select {
 		case <-ctx.Done():
-			s.logger.Info("Sensor stopping", "sensor_id", s.ID)
+			s.logger.Info("Sensor stopping", "sensor_id", s.ID, "cause", shutdown.Reason(ctx))
 			return
-		case <-ticker.C:
-			// Use a mutex to make random number generation safe for concurrent access
-			s.randMux.Lock()
-			value := s.rand.Float64()
-			s.randMux.Unlock()
-
-			data := model.SensorData{
-				ID:        s.ID,
-				Value:     value,
-				Timestamp: time.Now(),
+		case tick := <-timer.C():
+			if s.control != nil {
+				// Block while paused, then schedule from the time of resuming, rather than catch up on the skipped readings.
+				if s.control.Paused() {
+					if err := s.control.Wait(ctx); err != nil {
+						continue // The context is done, so the sensor stops.
+					}
+					tick = s.clock.Now()
+				}
+				interval = s.followControl(interval)
+			}
+
+			now := s.clock.Now()
+			value := s.distribution.Sample(s.rand, now)
+			// Skip values that can't be encoded or aggregated, e.g. from an expression's singularity (1/t at t=0).
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				s.logger.Debug("Skipped non-finite value", "sensor_id", s.ID, "value", value)
+				if s.metrics != nil {
+					s.metrics.NonFiniteValues.WithLabelValues(s.idStr).Inc()
+				}
+				timer.Reset(s.jittered(interval) - s.clock.Now().Sub(tick))
+				continue
+			}
+			var faulty bool
+			var spike float64
+			if s.fault != nil {
+				faulty, spike = s.fault.roll(s.rand)
+			}
+
+			// Offset the value by the bias the sensor has drifted by.
+			if s.drift != nil {
+				offset := s.drift.offset(now)
+				value += offset
+				if s.metrics != nil {
+					s.metrics.SensorDrift.WithLabelValues(s.idStr).Set(offset)
+				}
+			}
+
+			// Keep the value within the sensor's physical range.
+			if s.valueRange != nil {
+				var clamped bool
+				if value, clamped = s.valueRange.clamp(value); clamped && s.metrics != nil {
+					s.metrics.ValueClamped.WithLabelValues(s.idStr).Inc()
+				}
+			}
+
+			// Inject the fault, if the reading is faulty. A stuck sensor has nothing to repeat until it has sent a value.
+			dropped := false
+			if faulty {
+				switch s.fault.Mode {
+				case FaultStuck:
+					value, faulty = lastSent, hasSent
+				case FaultSpike:
+					value += spike
+				case FaultDropout:
+					dropped = true
+				}
+			}
+			if faulty && s.metrics != nil {
+				s.metrics.SensorFaults.WithLabelValues(s.idStr, string(s.fault.Mode)).Inc()
+			}
+
+			// Adapt the emission interval to how fast the value is changing.
+			if s.adaptive != nil && s.burst == nil && hasLast {
+				interval = s.adaptive.next(interval, math.Abs(value-lastValue))
 			}
-			s.DataCh <- data
+			lastValue, hasLast = value, true
+
+			// Slow down while downstream pressure is high, and speed back up once it drops.
+			if s.backpressure != nil && s.adaptive == nil && s.burst == nil {
+				if next := s.backpressure.next(s.Interval, interval); next != interval {
+					s.logger.Debug("Backpressure adjusted interval", "interval", next)
+					interval = next
+				}
+			}
+
+			if !dropped && s.send(ctx, value) {
+				lastSent, hasSent = value, true
+			}
+
+			// Advance the burst pattern, idling once the burst is complete.
+			if s.burst != nil {
+				interval, burstSent = s.burst.next(burstSent + 1)
+			}
+
+			// Schedule the next reading an interval after this one was due, like a ticker would,
+			// so the time spent generating and sending it doesn't stretch the interval.
+			timer.Reset(s.jittered(interval) - s.clock.Now().Sub(tick))
+		}
+	}
+}
+
+// followControl adopts the controller's interval as the sensor's fixed interval, clamped to its minimum interval,
+// and returns the interval to wait next: the new fixed interval if it changed (outside adaptive and burst mode),
+// or interval unchanged.
+func (s *Sensor) followControl(interval time.Duration) time.Duration {
+	fixed := max(s.control.Interval(), s.minInterval)
+	if fixed == s.Interval {
+		return interval
+	}
+
+	s.Interval = fixed
+	if s.adaptive != nil || s.burst != nil {
+		return interval
+	}
+	return fixed
+}
+
+// jittered returns d varied by a random fraction of it, uniformly distributed in ±jitter,
+// and clamped to the sensor's minimum interval.
+func (s *Sensor) jittered(d time.Duration) time.Duration {
+	if s.jitter == 0 {
+		return d
+	}
+	d = time.Duration(float64(d) * (1 + s.jitter*(2*s.rand.Float64()-1)))
+	return max(d, s.minInterval)
+}
+
+// send emits a reading of value to the sensor's DataCh, once the rate limiter (if any) allows it.
+// It reports whether the reading was sent, rather than dropped because DataCh was full
+// (or abandoned because ctx was canceled while waiting on the rate limiter or for room in DataCh).
+func (s *Sensor) send(ctx context.Context, value float64) bool {
+	if s.limiter != nil && !s.throttle(ctx) {
+		return false
+	}
+
+	data := model.SensorData{
+		SchemaVersion:   model.SchemaVersion,
+		ID:              s.ID,
+		DeviceID:        s.deviceID,
+		Type:            s.profile.Type,
+		Unit:            s.profile.Unit,
+		Value:           value,
+		Timestamp:       s.now(),
+		Model:           s.profile.Model,
+		FirmwareVersion: s.profile.FirmwareVersion,
+		Tags:            s.profile.Tags,
+	}
+	if s.location != nil {
+		data.Latitude, data.Longitude = s.location.Latitude, s.location.Longitude
+	}
 
-			// Instrument the message send and value observation.
+	// A select picks at random among its ready cases, so on its own it would still sometimes send once ctx is done.
+	// Checking ctx first means a canceled sensor never sends, so DataCh can be closed once its sensors have stopped.
+	if ctx.Err() != nil {
+		return false
+	}
+	if s.dropOnFull != nil && s.dropOnFull() {
+		select {
+		case s.DataCh <- data:
+		default:
 			if s.metrics != nil {
-				s.metrics.MessagesSent.WithLabelValues(s.idStr).Inc()
-				s.metrics.GeneratedValues.WithLabelValues(s.idStr).Observe(value)
+				s.metrics.MessagesDropped.WithLabelValues(s.idStr).Inc()
 			}
+			return false
+		}
+	} else {
+		// Blocking, but not past ctx being done, so a removed sensor stops even while DataCh is full.
+		select {
+		case s.DataCh <- data:
+		case <-ctx.Done():
+			return false
 		}
 	}
+
+	// Instrument the message send and value observation.
+	if s.metrics != nil {
+		s.metrics.MessagesSent.WithLabelValues(s.idStr).Inc()
+		s.metrics.GeneratedValues.WithLabelValues(s.idStr).Observe(value)
+		s.metrics.MessagesByModel.WithLabelValues(s.profile.Model, s.profile.FirmwareVersion).Inc()
+	}
+	return true
+}
+
+// throttle waits for the rate limiter to allow a reading, counting the time spent waiting.
+// It reports whether the reading may be sent, i.e. ctx wasn't canceled first.
+func (s *Sensor) throttle(ctx context.Context) bool {
+	start := time.Now() // The limiter waits in real time, whatever the sensor's clock.
+	err := s.limiter.Wait(ctx)
+	if s.metrics != nil {
+		s.metrics.ThrottledDuration.Add(time.Since(start).Seconds())
+	}
+	return err == nil
+}
+
+// ResetDrift recalibrates a drifting sensor (see WithDriftRate), resetting its accumulated drift to 0.
+// It is safe to call while Run is running, and does nothing if the sensor doesn't drift.
+func (s *Sensor) ResetDrift() {
+	if s.drift != nil {
+		s.drift.reset(s.clock.Now())
+	}
+}
+
+// now returns the current time, truncated to the sensor's timestamp precision.
+func (s *Sensor) now() time.Time {
+	now := s.clock.Now()
+	if s.precision > 0 {
+		now = now.Truncate(s.precision)
+	}
+	return now
 }
 
 // Start launches a simulated sensor (identified by ID) as a goroutine with panic recovery.
-// The goroutine runs the Sensor's Run method.
-func Start(ctx context.Context, id int, dataCh chan<- model.SensorData, interval time.Duration, m *metrics.Metrics, l *slog.Logger) {
-	go func() {
-		defer func() {
-			if r := recover(); r != nil {
-				panicLogger := l.With("component", "sensor", "sensor_id", id)
-				panicLogger.Error("Sensor panicked - restarting", "sensor_id", id, "panic", r)
-
-				// Restart the sensor only if the context is not done.
-				// This prevents a panic-restart loop if the context is cancelled.
-				if ctx.Err() == nil {
-					// Instrument the restart.
-					if m != nil {
-						m.SensorRestarts.WithLabelValues(strconv.Itoa(id)).Inc()
-					}
+// The goroutine runs the Sensor's Run method, restarting it (up to WithMaxRestarts times) if it panics.
+// The options opts are applied to the sensor on every (re)start.
+// The returned channel is closed once the sensor has fully stopped (i.e. it won't be restarted, and with WithRegistry, has deregistered),
+// or straight away if the options are invalid (which is logged).
+// If ctx is already canceled (e.g. a sensor added during shutdown), no sensor is started
+// and the returned channel is already closed.
+func Start(ctx context.Context, id int, dataCh chan<- model.SensorData, interval time.Duration, m *metrics.Metrics, l *slog.Logger, opts ...Option) <-chan struct{} {
+	return start(ctx, nil, id, dataCh, interval, m, l, opts)
+}
 
-					Start(ctx, id, dataCh, interval, m, l)
-				}
+// start is Start, also calling release (if not nil) once the sensor has stopped for good, just before closing
+// the returned channel, e.g. to cancel a context made for the sensor when it stops of its own accord.
+func start(ctx context.Context, release func(), id int, dataCh chan<- model.SensorData, interval time.Duration, m *metrics.Metrics, l *slog.Logger, opts []Option) <-chan struct{} {
+	done := make(chan struct{})
+	if ctx.Err() != nil {
+		if release != nil {
+			release()
+		}
+		close(done)
+		return done
+	}
+
+	go supervise(ctx, release, id, dataCh, interval, m, l, opts, done)
+	return done
+}
+
+// supervise runs the sensor until ctx is canceled, restarting it whenever it panics
+// until it runs out of restarts, and calls release (if not nil) and closes done once the sensor has stopped for good.
+func supervise(ctx context.Context, release func(), id int, dataCh chan<- model.SensorData, interval time.Duration, m *metrics.Metrics, l *slog.Logger, opts []Option, done chan struct{}) {
+	defer close(done)
+	if release != nil {
+		defer release()
+	}
+
+	if l == nil {
+		l = slog.Default()
+	}
+	logger := l.With("component", "sensor", "sensor_id", id)
+
+	for restarts := 0; ; restarts++ {
+		s, err := NewSensor(id, dataCh, interval, m, l, opts...)
+		if err != nil {
+			logger.Error("Invalid sensor configuration, not starting", "error", err)
+			return
+		}
+
+		// Register the sensor once, rather than on every restart.
+		if restarts == 0 && s.registry != nil {
+			s.register(ctx)
+			defer s.deregister()
+		}
+
+		r := runRecovered(ctx, s)
+		if r == nil {
+			return // Run returned, so ctx is done.
+		}
+
+		// Restart the sensor only if the context is not done.
+		// This prevents a panic-restart loop if the context is cancelled.
+		// (A canceled sensor never sends on dataCh, so this isn't relied on to survive sending on it once closed.)
+		if ctx.Err() != nil {
+			logger.Error("Sensor panicked while stopping", "panic", r)
+			return
+		}
+		if s.maxRestarts > 0 && restarts >= s.maxRestarts {
+			logger.Error("Sensor panicked too many times - giving up", "panic", r, "restarts", restarts)
+			if m != nil {
+				m.SensorGaveUp.WithLabelValues(s.idStr).Inc()
 			}
-		}()
+			return
+		}
+
+		logger.Error("Sensor panicked - restarting", "panic", r, "restarts", restarts)
+		// Instrument the restart.
+		if m != nil {
+			m.SensorRestarts.WithLabelValues(s.idStr).Inc()
+		}
+	}
+}
 
-		s := NewSensor(id, dataCh, interval, m, l)
-		s.Run(ctx)
+// runRecovered runs s until ctx is done, recovering from any panic.
+// It returns the recovered value, or nil if Run returned normally.
+func runRecovered(ctx context.Context, s *Sensor) (r any) {
+	defer func() {
+		r = recover()
 	}()
+
+	s.Run(ctx)
+	return nil
 }
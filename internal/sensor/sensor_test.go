@@ -4,12 +4,29 @@ package sensor_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"golang.org/x/time/rate"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/clock"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
 	"github.com/allthepins/iot-sensor-network-simulator/internal/sensor"
 )
@@ -20,6 +37,17 @@ func newTestLogger(buf *bytes.Buffer) *slog.Logger {
 	return slog.New(handler)
 }
 
+// mustNewSensor calls sensor.NewSensor, failing the test if it returns an error.
+func mustNewSensor(t *testing.T, id int, dataCh chan<- model.SensorData, interval time.Duration, m *metrics.Metrics, l *slog.Logger, opts ...sensor.Option) *sensor.Sensor {
+	t.Helper()
+
+	s, err := sensor.NewSensor(id, dataCh, interval, m, l, opts...)
+	if err != nil {
+		t.Fatalf("NewSensor failed: %v", err)
+	}
+	return s
+}
+
 // TestNewSensor verifies that the NewSensor function correctly initializes a Sensor.
 func TestNewSensor(t *testing.T) {
 	t.Parallel()
@@ -28,8 +56,10 @@ func TestNewSensor(t *testing.T) {
 	interval := 100 * time.Millisecond
 	dataCh := make(chan model.SensorData)
 
-	s := sensor.NewSensor(id, dataCh, interval, nil, nil)
-
+	s, err := sensor.NewSensor(id, dataCh, interval, nil, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	if s == nil {
 		t.Fatal("NewSensor returned nil")
 	}
@@ -44,14 +74,55 @@ func TestNewSensor(t *testing.T) {
 	}
 }
 
+// TestNewSensor_ClampsInterval verifies that an interval below the minimum is clamped to it.
+func TestNewSensor_ClampsInterval(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		interval time.Duration
+		opts     []sensor.Option
+		want     time.Duration
+	}{
+		{name: "zero", interval: 0, want: sensor.DefaultMinInterval},
+		{name: "1ns", interval: time.Nanosecond, want: sensor.DefaultMinInterval},
+		{name: "above floor", interval: 5 * time.Millisecond, want: 5 * time.Millisecond},
+		{
+			name:     "custom floor",
+			interval: 5 * time.Millisecond,
+			opts:     []sensor.Option{sensor.WithMinInterval(10 * time.Millisecond)},
+			want:     10 * time.Millisecond,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			buf := &bytes.Buffer{}
+			s := mustNewSensor(t, 1, make(chan model.SensorData), tt.interval, nil, newTestLogger(buf), tt.opts...)
+
+			if s.Interval != tt.want {
+				t.Errorf("expected interval %v, got %v", tt.want, s.Interval)
+			}
+
+			clamped := tt.interval != tt.want
+			if logged := strings.Contains(buf.String(), "Interval below minimum"); logged != clamped {
+				t.Errorf("expected clamping warning logged to be %v, log: %s", clamped, buf.String())
+			}
+		})
+	}
+}
+
 // TestSensor_Run tests the Sensor's Run method.
 // It tests data emission and expected behavior upon context cancellation.
 func TestSensor_Run(t *testing.T) {
 	t.Parallel()
 
 	interval := 10 * time.Millisecond
+	clk := clock.NewFake(time.Now())
 	dataCh := make(chan model.SensorData, 1) // Buffered channel to prevent blocking
-	s := sensor.NewSensor(1, dataCh, interval, nil, nil)
+	s := mustNewSensor(t, 1, dataCh, interval, nil, nil, sensor.WithClock(clk))
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -63,7 +134,9 @@ func TestSensor_Run(t *testing.T) {
 		s.Run(ctx)
 	}()
 
-	// Verify data is sent to data channel.
+	// Verify data is sent to data channel once the interval has passed.
+	clk.BlockUntil(1)
+	clk.Advance(interval)
 	select {
 	case data := <-dataCh:
 		if data.ID != s.ID {
@@ -72,7 +145,10 @@ func TestSensor_Run(t *testing.T) {
 		if data.Value < 0 || data.Value > 1 {
 			t.Errorf("expected value between 0 and 1, got %f", data.Value)
 		}
-	case <-time.After(interval * 2):
+		if !data.Timestamp.Equal(clk.Now()) {
+			t.Errorf("expected the reading to be timestamped by the sensor's clock (%v), got %v", clk.Now(), data.Timestamp)
+		}
+	case <-time.After(time.Second):
 		t.Fatal("timed out waiting for sensor data")
 	}
 
@@ -81,40 +157,287 @@ func TestSensor_Run(t *testing.T) {
 	wg.Wait() // Wait for the Run method to return.
 
 	// Verify no more data is sent after stopping.
+	clk.Advance(2 * interval)
 	select {
 	case d := <-dataCh:
 		t.Errorf("received data after context was canceled: %+v", d)
-	case <-time.After(interval * 2):
+	default:
 		// Expected outcome: nothing happens.
 	}
 }
 
+// TestSensor_Run_Profile verifies that a sensor's model and firmware version
+// are included in its readings and used as metric label values.
+func TestSensor_Run_Profile(t *testing.T) {
+	t.Parallel()
+
+	interval := 10 * time.Millisecond
+	dataCh := make(chan model.SensorData, 1)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	profile := sensor.Profile{Name: "standard", Model: "SIM-100", FirmwareVersion: "1.4.2"}
+	s := mustNewSensor(t, 1, dataCh, interval, m, nil, sensor.WithProfile(profile))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Run(ctx)
+	}()
+
+	select {
+	case data := <-dataCh:
+		if data.Model != profile.Model {
+			t.Errorf("expected model %q, got %q", profile.Model, data.Model)
+		}
+		if data.FirmwareVersion != profile.FirmwareVersion {
+			t.Errorf("expected firmware version %q, got %q", profile.FirmwareVersion, data.FirmwareVersion)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for sensor data")
+	}
+
+	cancel()
+	wg.Wait()
+
+	if got := testutil.ToFloat64(m.MessagesByModel.WithLabelValues(profile.Model, profile.FirmwareVersion)); got < 1 {
+		t.Errorf("expected at least 1 message labeled %s/%s, got %v", profile.Model, profile.FirmwareVersion, got)
+	}
+}
+
+// TestSensor_Run_Tags verifies a profile's tags propagate into emitted records and their JSON encoding.
+func TestSensor_Run_Tags(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 1)
+	profile := sensor.Profile{Name: "standard", Tags: map[string]string{"site": "north", "rack": "3"}}
+	s := mustNewSensor(t, 1, dataCh, 10*time.Millisecond, nil, nil, sensor.WithProfile(profile))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	var data model.SensorData
+	select {
+	case data = <-dataCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for sensor data")
+	}
+
+	if data.Tags["site"] != "north" || data.Tags["rack"] != "3" {
+		t.Errorf("expected tags site=north and rack=3, got %v", data.Tags)
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("failed to encode reading: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"Tags":{"rack":"3","site":"north"}`) {
+		t.Errorf("expected tags in the encoded reading, got %s", encoded)
+	}
+}
+
+// TestSensor_Run_ActiveSensorsByProfile verifies that active sensors are counted per profile,
+// and that each profile's count drops back to zero once its sensors stop.
+func TestSensor_Run_ActiveSensorsByProfile(t *testing.T) {
+	t.Parallel()
+
+	// A long interval keeps the sensors from emitting, so they only need to be counted.
+	interval := time.Hour
+	dataCh := make(chan model.SensorData)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	standard := sensor.Profile{Name: "standard", Model: "SIM-100", FirmwareVersion: "1.4.2"}
+	legacy := sensor.Profile{Name: "legacy", Model: "SIM-50", FirmwareVersion: "0.9.8"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i, p := range []sensor.Profile{standard, standard, legacy} {
+		s := mustNewSensor(t, i+1, dataCh, interval, m, nil, sensor.WithProfile(p))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Run(ctx)
+		}()
+	}
+
+	want := map[string]float64{standard.Name: 2, legacy.Name: 1}
+	deadline := time.Now().Add(time.Second)
+	for name, n := range want {
+		for testutil.ToFloat64(m.ActiveSensors.WithLabelValues(name)) != n {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected %v active %q sensors, got %v", n, name, testutil.ToFloat64(m.ActiveSensors.WithLabelValues(name)))
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	cancel()
+	wg.Wait()
+
+	for name := range want {
+		if got := testutil.ToFloat64(m.ActiveSensors.WithLabelValues(name)); got != 0 {
+			t.Errorf("expected 0 active %q sensors after stop, got %v", name, got)
+		}
+	}
+}
+
+// observeIntervals runs s until it has emitted n readings,
+// and returns the intervals between the emission timestamps.
+func observeIntervals(t *testing.T, s *sensor.Sensor, dataCh <-chan model.SensorData, n int) []time.Duration {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		s.Run(ctx)
+	}()
+	defer wg.Wait()
+	defer cancel()
+
+	var last time.Time
+	intervals := make([]time.Duration, 0, n-1)
+	for i := 0; i < n; i++ {
+		select {
+		case data := <-dataCh:
+			if !last.IsZero() {
+				intervals = append(intervals, data.Timestamp.Sub(last))
+			}
+			last = data.Timestamp
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for reading %d", i)
+		}
+	}
+	return intervals
+}
+
+// TestSensor_Run_Adaptive verifies that in adaptive mode the emission interval approaches
+// MinInterval for a rapidly changing value and MaxInterval for a flat one.
+func TestSensor_Run_Adaptive(t *testing.T) {
+	t.Parallel()
+
+	cfg := sensor.AdaptiveConfig{
+		MinInterval:     5 * time.Millisecond,
+		MaxInterval:     40 * time.Millisecond,
+		ChangeThreshold: 0.1,
+	}
+
+	tests := []struct {
+		name         string
+		distribution sensor.Distribution
+		check        func(d time.Duration) bool
+		want         string
+	}{
+		{
+			name: "rapidly changing",
+			distribution: func() sensor.Distribution {
+				// Alternate between 0 and 1, the largest possible change.
+				v := 0.0
+				return sensor.DistributionFunc(func(*rand.Rand, time.Time) float64 {
+					v = 1 - v
+					return v
+				})
+			}(),
+			check: func(d time.Duration) bool { return d < (cfg.MinInterval+cfg.MaxInterval)/2 },
+			want:  "closer to MinInterval",
+		},
+		{
+			name:         "flat",
+			distribution: sensor.DistributionFunc(func(*rand.Rand, time.Time) float64 { return 0.5 }),
+			check:        func(d time.Duration) bool { return d > (cfg.MinInterval+cfg.MaxInterval)/2 },
+			want:         "closer to MaxInterval",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dataCh := make(chan model.SensorData, 1)
+			s := mustNewSensor(t, 1, dataCh, 20*time.Millisecond, nil, nil,
+				sensor.WithAdaptive(cfg),
+				sensor.WithDistribution(tt.distribution),
+			)
+
+			intervals := observeIntervals(t, s, dataCh, 10)
+
+			// The interval halves or doubles each reading, so it has settled by the last few.
+			// Average them to smooth over scheduling jitter.
+			settled := intervals[len(intervals)-4:]
+			var sum time.Duration
+			for _, d := range settled {
+				sum += d
+			}
+			if mean := sum / time.Duration(len(settled)); !tt.check(mean) {
+				t.Errorf("expected settled interval %s, got mean %v (all intervals: %v)", tt.want, mean, intervals)
+			}
+		})
+	}
+}
+
+// TestSensor_Run_Burst verifies that in burst mode the sensor emits BurstSize
+// readings BurstInterval apart, then idles for IdleGap.
+func TestSensor_Run_Burst(t *testing.T) {
+	t.Parallel()
+
+	cfg := sensor.BurstConfig{
+		BurstSize:     3,
+		BurstInterval: 5 * time.Millisecond,
+		IdleGap:       60 * time.Millisecond,
+	}
+	dataCh := make(chan model.SensorData, 1)
+	s := mustNewSensor(t, 1, dataCh, time.Second, nil, nil, sensor.WithBurst(cfg))
+
+	// Two full bursts, plus the first reading of a third.
+	intervals := observeIntervals(t, s, dataCh, 2*cfg.BurstSize+1)
+
+	for i, d := range intervals {
+		// Every BurstSize-th interval is the idle gap between bursts.
+		if (i+1)%cfg.BurstSize == 0 {
+			if d < cfg.IdleGap*3/4 {
+				t.Errorf("interval %d: expected idle gap of ~%v, got %v (all intervals: %v)", i, cfg.IdleGap, d, intervals)
+			}
+		} else if d > cfg.IdleGap/2 {
+			t.Errorf("interval %d: expected burst interval of ~%v, got %v (all intervals: %v)", i, cfg.BurstInterval, d, intervals)
+		}
+	}
+}
+
 // TestStart verifies that the Start function launches a sensor goroutine
 // that sends data to a data channel and can be stopped.
 func TestStart(t *testing.T) {
 	t.Parallel()
 
 	interval := 10 * time.Millisecond
+	clk := clock.NewFake(time.Now())
 	dataCh := make(chan model.SensorData, 1)
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	sensor.Start(ctx, 1, dataCh, interval, nil, nil)
+	done := sensor.Start(ctx, 1, dataCh, interval, nil, nil, sensor.WithClock(clk))
 
 	// Verify data is being sent.
+	clk.BlockUntil(1)
+	clk.Advance(interval)
 	select {
 	case <-dataCh:
 	// Expected behavior: data received.
-	case <-time.After(interval * 2):
+	case <-time.After(time.Second):
 		t.Fatal("timed out waiting for sensor data from Start")
 	}
 
-	// Cancel the conext to stop the sensor.
+	// Cancel the conext to stop the sensor, and wait for it to stop.
 	cancel()
-
-	// Short delay to allow the gorouting to process cancellation.
-	time.Sleep(interval * 2)
+	<-done
+	clk.Advance(2 * interval)
 
 	// Verify no more data is sent.
 	select {
@@ -157,3 +480,1581 @@ func TestStart_PanicRecovery(t *testing.T) {
 		time.Sleep(10 * time.Millisecond)
 	}
 }
+
+// TestStart_MaxRestarts verifies that a sensor which keeps panicking is restarted
+// up to its maximum restarts, then given up on: its done channel is closed and it's counted.
+func TestStart_MaxRestarts(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	// Use a closed channel to trigger a panic when the sensor tries to send data.
+	dataCh := make(chan model.SensorData)
+	close(dataCh)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := sensor.Start(ctx, 7, dataCh, time.Millisecond, m, newTestLogger(&bytes.Buffer{}), sensor.WithMaxRestarts(2))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected the sensor to be given up on")
+	}
+
+	if got := testutil.ToFloat64(m.SensorRestarts.WithLabelValues("7")); got != 2 {
+		t.Errorf("expected 2 restarts, got %v", got)
+	}
+	if got := testutil.ToFloat64(m.SensorGaveUp.WithLabelValues("7")); got != 1 {
+		t.Errorf("expected the sensor to be given up on once, got %v", got)
+	}
+}
+
+// TestStart_DoneClosesOnStop verifies that the channel returned by Start is closed once the sensor stops.
+func TestStart_DoneClosesOnStop(t *testing.T) {
+	t.Parallel()
+
+	// Buffer the channel so the sensor never blocks on a send, which would keep it from stopping.
+	ctx, cancel := context.WithCancel(context.Background())
+	done := sensor.Start(ctx, 1, make(chan model.SensorData, 100), 10*time.Millisecond, nil, nil)
+
+	select {
+	case <-done:
+		t.Fatal("done closed before the sensor was stopped")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+		// Expected behavior: the sensor stopped.
+	case <-time.After(time.Second):
+		t.Fatal("done was not closed after the sensor was stopped")
+	}
+}
+
+// fakeRegistry is a sensor.Registry recording the calls made to it.
+type fakeRegistry struct {
+	mu           sync.Mutex
+	registered   map[int]model.SensorMeta
+	deregistered []int
+}
+
+func (r *fakeRegistry) Register(_ context.Context, id int, meta model.SensorMeta) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.registered == nil {
+		r.registered = make(map[int]model.SensorMeta)
+	}
+	r.registered[id] = meta
+	return nil
+}
+
+func (r *fakeRegistry) Deregister(_ context.Context, id int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.deregistered = append(r.deregistered, id)
+	return nil
+}
+
+// TestStart_Registry verifies a sensor registers its metadata when it starts,
+// and has deregistered by the time its done channel is closed.
+func TestStart_Registry(t *testing.T) {
+	t.Parallel()
+
+	registry := &fakeRegistry{}
+	profile := sensor.Profile{Name: "standard", Model: "SIM-100", Type: "temperature", Unit: "celsius"}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := sensor.Start(ctx, 7, make(chan model.SensorData, 100), 10*time.Millisecond, nil, nil,
+		sensor.WithProfile(profile), sensor.WithDeviceID("dev-7"), sensor.WithRegistry(registry))
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		registry.mu.Lock()
+		meta, ok := registry.registered[7]
+		registry.mu.Unlock()
+		if ok {
+			want := model.SensorMeta{ID: 7, DeviceID: "dev-7", Type: "temperature", Unit: "celsius", Model: "SIM-100", Interval: 10 * time.Millisecond}
+			if !reflect.DeepEqual(meta, want) {
+				t.Errorf("expected the sensor to register %+v, got %+v", want, meta)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the sensor to register")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("done was not closed after the sensor was stopped")
+	}
+
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+	if !slices.Equal(registry.deregistered, []int{7}) {
+		t.Errorf("expected the sensor to deregister once, got deregistrations %v", registry.deregistered)
+	}
+}
+
+// TestStart_CanceledContext verifies that Start with an already canceled context
+// doesn't start a sensor: nothing runs or is counted as active, and done is already closed.
+func TestStart_CanceledContext(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := sensor.Start(ctx, 1, dataCh, time.Millisecond, m, newTestLogger(buf), sensor.WithProfile(sensor.Profile{Name: "standard"}))
+
+	select {
+	case <-done:
+	default:
+		t.Fatal("expected done to be closed when starting with a canceled context")
+	}
+
+	// Give a wrongly started sensor time to run.
+	time.Sleep(20 * time.Millisecond)
+
+	if strings.Contains(buf.String(), "Sensor starting") {
+		t.Errorf("expected no sensor to start, got logs:\n%s", buf.String())
+	}
+	if got := testutil.ToFloat64(m.ActiveSensors.WithLabelValues("standard")); got != 0 {
+		t.Errorf("expected 0 active sensors, got %v", got)
+	}
+	select {
+	case d := <-dataCh:
+		t.Errorf("received data from a sensor started with a canceled context: %+v", d)
+	default:
+	}
+}
+
+// TestStart_CancelAndClose stresses canceling sensors mid-reading while DataCh is received from,
+// and closed as soon as they've all stopped: once their context is canceled, sensors must stop without
+// sending again, even with a receiver ready, so closing DataCh after them can't make them panic.
+func TestStart_CancelAndClose(t *testing.T) {
+	t.Parallel()
+
+	const sensors, rounds = 50, 20
+	for round := range rounds {
+		buf := &bytes.Buffer{}
+		logger := newTestLogger(buf)
+		dataCh := make(chan model.SensorData)
+
+		// Whichever sensor samples first cancels them all, so the cancellation lands between sampling and sending.
+		ctx, cancel := context.WithCancel(context.Background())
+		cancelling := sensor.DistributionFunc(func(*rand.Rand, time.Time) float64 {
+			cancel()
+			return 1
+		})
+		dones := make([]<-chan struct{}, sensors)
+		for i := range dones {
+			dones[i] = sensor.Start(ctx, i+1, dataCh, time.Millisecond, nil, logger, sensor.WithDistribution(cancelling))
+		}
+
+		received := make(chan int)
+		go func() {
+			n := 0
+			for range dataCh {
+				n++
+			}
+			received <- n
+		}()
+		go func() {
+			for _, done := range dones {
+				<-done
+			}
+			close(dataCh)
+		}()
+
+		select {
+		case n := <-received:
+			if n != 0 {
+				t.Errorf("round %d: expected no readings once the sensors were canceled, got %d", round, n)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("round %d: timed out waiting for the sensors to stop", round)
+		}
+		if strings.Contains(buf.String(), "panicked") {
+			t.Fatalf("round %d: expected no sensor to panic, got logs:\n%s", round, buf.String())
+		}
+	}
+}
+
+// TestManager_Wait verifies that sensors which stop in time aren't reported,
+// while a stuck sensor is counted as a straggler instead of hanging shutdown.
+func TestManager_Wait(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	mgr := sensor.NewManager(m, nil)
+
+	// Buffer the channel so the sensors never block on a send, which would keep them from stopping.
+	// Sensor 3 gets stuck generating its first value, so it never stops.
+	stuck, sampling := make(chan struct{}), make(chan struct{})
+	defer close(stuck)
+	ctx, cancel := context.WithCancel(context.Background())
+	mgr.SetLauncher(ctx, make(chan model.SensorData, 100), 10*time.Millisecond, func(id int) []sensor.Option {
+		if id != 3 {
+			return nil
+		}
+		return []sensor.Option{sensor.WithDistribution(sensor.DistributionFunc(func(*rand.Rand, time.Time) float64 {
+			close(sampling)
+			<-stuck
+			return 0
+		}))}
+	})
+	for id := 1; id <= 3; id++ {
+		if err := mgr.Add(id); err != nil {
+			t.Fatalf("failed to add sensor %d: %v", id, err)
+		}
+	}
+	<-sampling
+
+	cancel()
+
+	waitFinished := make(chan []int)
+	go func() {
+		waitFinished <- mgr.Wait(100 * time.Millisecond)
+	}()
+
+	select {
+	case stragglers := <-waitFinished:
+		if len(stragglers) != 1 || stragglers[0] != 3 {
+			t.Errorf("expected stragglers [3], got %v", stragglers)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after the grace period")
+	}
+
+	if got := testutil.ToFloat64(m.SensorShutdownTimeouts); got != 1 {
+		t.Errorf("expected 1 shutdown timeout, got %v", got)
+	}
+}
+
+// waitForActive waits until m reports want active sensors (of the default profile).
+func waitForActive(t *testing.T, m *metrics.Metrics, want float64) {
+	t.Helper()
+
+	active := m.ActiveSensors.WithLabelValues("")
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(active) != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %v active sensors, got %v", want, testutil.ToFloat64(active))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestManager_AddRemove verifies sensors can be added and removed at runtime,
+// and that removed sensors fully stop, even while blocked on a full data channel.
+func TestManager_AddRemove(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	mgr := sensor.NewManager(m, nil)
+	if err := mgr.Add(1); !errors.Is(err, sensor.ErrNoLauncher) {
+		t.Errorf("expected ErrNoLauncher before SetLauncher, got %v", err)
+	}
+
+	// Nothing reads the channel, so once it's full the sensors block sending to it.
+	dataCh := make(chan model.SensorData, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var optsFor []int
+	mgr.SetLauncher(ctx, dataCh, time.Millisecond, func(id int) []sensor.Option {
+		optsFor = append(optsFor, id) // Add holds the manager's lock, so this needs none.
+		return nil
+	})
+
+	for _, id := range []int{1, 2, 3} {
+		if err := mgr.Add(id); err != nil {
+			t.Fatalf("failed to add sensor %d: %v", id, err)
+		}
+	}
+	if err := mgr.Add(2); !errors.Is(err, sensor.ErrRunning) {
+		t.Errorf("expected ErrRunning adding a running sensor, got %v", err)
+	}
+	if !slices.Equal(optsFor, []int{1, 2, 3}) {
+		t.Errorf("expected options for sensors [1 2 3], got %v", optsFor)
+	}
+	waitForActive(t, m, 3)
+
+	removed := make(chan error)
+	go func() { removed <- mgr.Remove(2) }()
+	select {
+	case err := <-removed:
+		if err != nil {
+			t.Fatalf("failed to remove sensor 2: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out removing a sensor blocked on a full data channel")
+	}
+	waitForActive(t, m, 2)
+	if got := mgr.IDs(); !slices.Equal(got, []int{1, 3}) {
+		t.Errorf("expected sensors [1 3] to be running, got %v", got)
+	}
+
+	if err := mgr.Remove(2); !errors.Is(err, sensor.ErrNotFound) {
+		t.Errorf("expected ErrNotFound removing a removed sensor, got %v", err)
+	}
+
+	// A removed sensor can be added back.
+	if err := mgr.Add(2); err != nil {
+		t.Fatalf("failed to add sensor 2 back: %v", err)
+	}
+	waitForActive(t, m, 3)
+
+	cancel()
+	if err := mgr.Add(5); !errors.Is(err, sensor.ErrStopping) {
+		t.Errorf("expected ErrStopping once the context is done, got %v", err)
+	}
+	if stragglers := mgr.Wait(time.Second); len(stragglers) != 0 {
+		t.Errorf("expected no stragglers, got %v", stragglers)
+	}
+	waitForActive(t, m, 0)
+}
+
+// TestManager_Handler verifies the fleet can be listed and scaled over HTTP, with errors mapped to status codes.
+func TestManager_Handler(t *testing.T) {
+	t.Parallel()
+
+	mgr := sensor.NewManager(nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr.SetLauncher(ctx, make(chan model.SensorData, 100), 10*time.Millisecond, nil)
+	h := mgr.Handler()
+
+	steps := []struct {
+		method, path string
+		wantStatus   int
+		wantIDs      []int
+	}{
+		{http.MethodPost, "/admin/sensors/7", http.StatusCreated, []int{7}},
+		{http.MethodPost, "/admin/sensors/9", http.StatusCreated, []int{7, 9}},
+		{http.MethodPost, "/admin/sensors/7", http.StatusConflict, []int{7, 9}},
+		{http.MethodPost, "/admin/sensors/abc", http.StatusBadRequest, []int{7, 9}},
+		{http.MethodDelete, "/admin/sensors/7", http.StatusOK, []int{9}},
+		{http.MethodDelete, "/admin/sensors/7", http.StatusNotFound, []int{9}},
+	}
+	for _, step := range steps {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(step.method, step.path, nil))
+		if rec.Code != step.wantStatus {
+			t.Errorf("%s %s: expected status %d, got %d (%s)", step.method, step.path, step.wantStatus, rec.Code, rec.Body)
+		}
+
+		rec = httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/sensors", nil))
+		var fleet struct {
+			Count int   `json:"count"`
+			IDs   []int `json:"ids"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&fleet); err != nil {
+			t.Fatalf("failed to decode the fleet listing: %v", err)
+		}
+		if fleet.Count != len(step.wantIDs) || !slices.Equal(fleet.IDs, step.wantIDs) {
+			t.Errorf("after %s %s: expected sensors %v, got %+v", step.method, step.path, step.wantIDs, fleet)
+		}
+	}
+}
+
+// TestManager_Ramp verifies ramped sensors are started gradually over the ramp,
+// and that canceling the context stops the ramp promptly.
+func TestManager_Ramp(t *testing.T) {
+	t.Parallel()
+
+	mgr := sensor.NewManager(nil, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr.SetLauncher(ctx, make(chan model.SensorData, 100), time.Second, nil)
+
+	start := time.Now()
+	if err := mgr.Ramp(ctx, 5, 100*time.Millisecond); err != nil {
+		t.Fatalf("unexpected error ramping up: %v", err)
+	}
+	// The five starts are 20ms apart, so the last is 80ms after the first.
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("expected the ramp to take at least 80ms, took %v", elapsed)
+	}
+	if got := mgr.IDs(); !slices.Equal(got, []int{1, 2, 3, 4, 5}) {
+		t.Errorf("expected sensors [1 2 3 4 5] to be running, got %v", got)
+	}
+
+	// A ramp interrupted by cancellation returns promptly, without starting the remaining sensors.
+	mgr = sensor.NewManager(nil, nil)
+	rampCtx, rampCancel := context.WithCancel(context.Background())
+	mgr.SetLauncher(rampCtx, make(chan model.SensorData, 100), time.Second, nil)
+	rampErr := make(chan error)
+	go func() { rampErr <- mgr.Ramp(rampCtx, 100, time.Minute) }()
+	time.Sleep(10 * time.Millisecond)
+	rampCancel()
+
+	select {
+	case err := <-rampErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled from an interrupted ramp, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the ramp to stop promptly once canceled")
+	}
+	if n := mgr.Len(); n != 1 {
+		t.Errorf("expected only the first sensor to have started, got %d", n)
+	}
+}
+
+// TestIDAllocators verifies each allocation scheme produces unique, well-formed device IDs,
+// and allocates the same ID for the same sensor every time.
+func TestIDAllocators(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		scheme string
+		format *regexp.Regexp
+	}{
+		{"sequential", regexp.MustCompile(`^[0-9]+$`)},
+		{"uuid", regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)},
+		{"mac", regexp.MustCompile(`^02(:[0-9a-f]{2}){5}$`)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scheme, func(t *testing.T) {
+			t.Parallel()
+
+			alloc, err := sensor.NewIDAllocator(tt.scheme)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			seen := make(map[string]int)
+			for id := 1; id <= 10000; id++ {
+				deviceID := alloc.Allocate(id)
+				if !tt.format.MatchString(deviceID) {
+					t.Fatalf("device ID %q for sensor %d is malformed", deviceID, id)
+				}
+				if other, dup := seen[deviceID]; dup {
+					t.Fatalf("device ID %q allocated to both sensor %d and sensor %d", deviceID, other, id)
+				}
+				seen[deviceID] = id
+
+				if again := alloc.Allocate(id); again != deviceID {
+					t.Fatalf("sensor %d allocated %q, then %q", id, deviceID, again)
+				}
+			}
+		})
+	}
+}
+
+// TestNewIDAllocator_UnknownScheme verifies an unknown scheme is rejected.
+func TestNewIDAllocator_UnknownScheme(t *testing.T) {
+	t.Parallel()
+
+	if _, err := sensor.NewIDAllocator("serial"); err == nil {
+		t.Error("expected an error for an unknown scheme, got nil")
+	}
+}
+
+// TestSensor_Run_DeviceID verifies readings carry the sensor's device ID.
+func TestSensor_Run_DeviceID(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 1)
+	s := mustNewSensor(t, 7, dataCh, 10*time.Millisecond, nil, nil, sensor.WithDeviceID("02:00:00:00:00:07"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	select {
+	case data := <-dataCh:
+		if data.ID != 7 || data.DeviceID != "02:00:00:00:00:07" {
+			t.Errorf("expected ID 7 and device ID 02:00:00:00:00:07, got %d and %q", data.ID, data.DeviceID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for sensor data")
+	}
+}
+
+// TestSensor_Run_TimestampPrecision verifies emitted timestamps are truncated to the configured precision.
+func TestSensor_Run_TimestampPrecision(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 10)
+	s := mustNewSensor(t, 1, dataCh, 3*time.Millisecond, nil, nil, sensor.WithTimestampPrecision(time.Millisecond))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	for i := 0; i < 5; i++ {
+		select {
+		case data := <-dataCh:
+			if sub := data.Timestamp.Nanosecond() % int(time.Millisecond); sub != 0 {
+				t.Errorf("expected a millisecond-precision timestamp, got %v (%dns sub-millisecond)", data.Timestamp, sub)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for sensor data")
+		}
+	}
+}
+
+// TestSensor_Run_SchemaVersion verifies every emitted record carries the current schema version.
+func TestSensor_Run_SchemaVersion(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 10)
+	s := mustNewSensor(t, 1, dataCh, 3*time.Millisecond, nil, nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	for i := 0; i < 5; i++ {
+		select {
+		case data := <-dataCh:
+			if data.SchemaVersion != model.SchemaVersion {
+				t.Errorf("expected schema version %d, got %d", model.SchemaVersion, data.SchemaVersion)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for sensor data")
+		}
+	}
+}
+
+// TestExprGenerator_Eval verifies expressions evaluate to the values of the formula they describe.
+func TestExprGenerator_Eval(t *testing.T) {
+	t.Parallel()
+
+	g, err := sensor.NewExprGenerator("20 + 5*sin(t/3600)")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, secs := range []float64{0, 900, 3600, 5655, 86400} {
+		want := 20 + 5*math.Sin(secs/3600)
+		if got := g.Eval(secs, 1); math.Abs(got-want) > 1e-9 {
+			t.Errorf("t=%v: expected %v, got %v", secs, want, got)
+		}
+	}
+
+	tests := []struct {
+		expr string
+		t    float64
+		id   int
+		want float64
+	}{
+		{"1 + 2 * 3", 0, 0, 7},
+		{"(1 + 2) * 3", 0, 0, 9},
+		{"-2^2", 0, 0, -4},
+		{"2^3^2", 0, 0, 512},
+		{"10 % 4 - 1", 0, 0, 1},
+		{"id * 10 + t", 2.5, 3, 32.5},
+		{"max(t, id) / 2", 4, 6, 3},
+		{"pow(2, 10) + abs(-1.5e1)", 0, 0, 1039},
+		{"cos(pi)", 0, 0, -1},
+	}
+	for _, tt := range tests {
+		g, err := sensor.NewExprGenerator(tt.expr)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.expr, err)
+			continue
+		}
+		if got := g.Eval(tt.t, tt.id); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q (t=%v, id=%d): expected %v, got %v", tt.expr, tt.t, tt.id, tt.want, got)
+		}
+	}
+}
+
+// TestNewExprGenerator_Invalid verifies invalid expressions are rejected when the generator is created.
+func TestNewExprGenerator_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"1 + 2)",
+		"temp * 2",
+		"sin(1, 2)",
+		"max(1)",
+		"2 $ 3",
+		"1..2",
+	} {
+		if _, err := sensor.NewExprGenerator(expr); err == nil {
+			t.Errorf("%q: expected an error, got nil", expr)
+		}
+	}
+}
+
+// collectValues runs s until it has emitted n readings, and returns their values.
+func collectValues(t *testing.T, s *sensor.Sensor, dataCh <-chan model.SensorData, n int) []float64 {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	values := make([]float64, 0, n)
+	for len(values) < n {
+		select {
+		case data := <-dataCh:
+			values = append(values, data.Value)
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for sensor data")
+		}
+	}
+	return values
+}
+
+// TestSensor_Run_ReproducibleFromLoggedSeed verifies the seed a sensor logs on startup
+// reproduces its output when passed back in with WithSeed.
+func TestSensor_Run_ReproducibleFromLoggedSeed(t *testing.T) {
+	t.Parallel()
+
+	const n = 20
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, nil))
+
+	// A run without an explicit seed.
+	firstCh := make(chan model.SensorData, n)
+	first := collectValues(t, mustNewSensor(t, 3, firstCh, time.Millisecond, nil, logger), firstCh, n)
+
+	// Capture the seed it logged.
+	var seed int64
+	found := false
+	for _, line := range bytes.Split(bytes.TrimSpace(logs.Bytes()), []byte("\n")) {
+		var record struct {
+			Msg  string
+			Seed *int64
+		}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("failed to parse log line %q: %v", line, err)
+		}
+		if record.Msg == "Sensor starting" && record.Seed != nil {
+			seed, found = *record.Seed, true
+			break
+		}
+	}
+	if !found {
+		t.Fatalf("expected the sensor to log its seed, got logs:\n%s", logs.String())
+	}
+
+	// A rerun with the logged seed.
+	secondCh := make(chan model.SensorData, n)
+	second := collectValues(t, mustNewSensor(t, 3, secondCh, time.Millisecond, nil, nil, sensor.WithSeed(seed)), secondCh, n)
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("value %d differs between runs: %v != %v", i, first[i], second[i])
+		}
+	}
+}
+
+// TestSensor_Run_SameSeedSameValues verifies a fleet sharing a base seed generates identical values on every run,
+// while each sensor in it still generates its own sequence.
+func TestSensor_Run_SameSeedSameValues(t *testing.T) {
+	t.Parallel()
+
+	const n, sensors = 20, 3
+	fleet := func() [][]float64 {
+		values := make([][]float64, sensors)
+		for i := range values {
+			dataCh := make(chan model.SensorData, n)
+			s := mustNewSensor(t, i+1, dataCh, time.Millisecond, nil, nil, sensor.WithSeed(42), sensor.WithFault(sensor.FaultConfig{Mode: sensor.FaultSpike, Probability: 0.2}))
+			values[i] = collectValues(t, s, dataCh, n)
+		}
+		return values
+	}
+
+	first, second := fleet(), fleet()
+	for id := range first {
+		for i := range first[id] {
+			if first[id][i] != second[id][i] {
+				t.Fatalf("sensor %d: value %d differs between runs: %v != %v", id+1, i, first[id][i], second[id][i])
+			}
+		}
+	}
+	if slices.Equal(first[0], first[1]) {
+		t.Error("expected sensors sharing a base seed to generate different values")
+	}
+}
+
+// TestSensor_Run_Backpressure verifies that a sensor's interval doubles, up to its max interval,
+// while downstream pressure is high, and halves back to its interval once the pressure drops.
+func TestSensor_Run_Backpressure(t *testing.T) {
+	t.Parallel()
+
+	const (
+		interval    = 2 * time.Millisecond
+		maxInterval = 32 * time.Millisecond
+	)
+
+	var high atomic.Bool
+	high.Store(true)
+	pressure := sensor.PressureFunc(func() float64 {
+		if high.Load() {
+			return 1
+		}
+		return 0
+	})
+
+	clk := clock.NewFake(time.Now())
+	dataCh := make(chan model.SensorData)
+	s := mustNewSensor(t, 1, dataCh, interval, nil, nil, sensor.WithClock(clk), sensor.WithBackpressure(sensor.BackpressureConfig{
+		Signal:      pressure,
+		MaxInterval: maxInterval,
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	// gaps steps the sensor through n readings, returning the interval it waited before each.
+	gaps := func(n int) []time.Duration {
+		t.Helper()
+		var gaps []time.Duration
+		for range n {
+			clk.BlockUntil(1)
+			gaps = append(gaps, clk.AdvanceToNext())
+			select {
+			case <-dataCh:
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for sensor data")
+			}
+		}
+		return gaps
+	}
+
+	// The interval in effect when the pressure changes is the last one adapted to the previous pressure.
+	ms := time.Millisecond
+	if got, want := gaps(6), []time.Duration{2 * ms, 4 * ms, 8 * ms, 16 * ms, 32 * ms, 32 * ms}; !slices.Equal(got, want) {
+		t.Errorf("expected the sensor to slow down under pressure, waiting %v, got %v", want, got)
+	}
+	high.Store(false)
+	if got, want := gaps(6), []time.Duration{32 * ms, 16 * ms, 8 * ms, 4 * ms, 2 * ms, 2 * ms}; !slices.Equal(got, want) {
+		t.Errorf("expected the sensor to speed back up once the pressure dropped, waiting %v, got %v", want, got)
+	}
+}
+
+// TestNormal_Sample verifies Normal samples have the configured mean and standard deviation.
+func TestNormal_Sample(t *testing.T) {
+	t.Parallel()
+
+	d := sensor.Normal{Mean: 21, StdDev: 0.5}
+	r := rand.New(rand.NewPCG(1, 0))
+
+	const n = 20_000
+	var sum, sumSq float64
+	for i := 0; i < n; i++ {
+		v := d.Sample(r, time.Time{})
+		sum += v
+		sumSq += v * v
+	}
+	mean := sum / n
+	stddev := math.Sqrt(sumSq/n - mean*mean)
+
+	if math.Abs(mean-21) > 0.02 {
+		t.Errorf("expected a mean near 21, got %v", mean)
+	}
+	if math.Abs(stddev-0.5) > 0.02 {
+		t.Errorf("expected a standard deviation near 0.5, got %v", stddev)
+	}
+}
+
+// TestSine_At verifies Sine follows its wave: offset at the start of a period, peaking a quarter period in,
+// and shifted by its phase.
+func TestSine_At(t *testing.T) {
+	t.Parallel()
+
+	day := sensor.Sine{Amplitude: 5, Period: 24 * time.Hour, Offset: 20}
+	midnight := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		d    sensor.Sine
+		at   time.Time
+		want float64
+	}{
+		{"start of period", day, midnight, 20},
+		{"quarter period", day, midnight.Add(6 * time.Hour), 25},
+		{"three quarter period", day, midnight.Add(18 * time.Hour), 15},
+		{"next period", day, midnight.Add(30 * time.Hour), 25},
+		{"phase shifted", sensor.Sine{Amplitude: 5, Period: 24 * time.Hour, Phase: math.Pi / 2, Offset: 20}, midnight, 25},
+		{"no period", sensor.Sine{Amplitude: 5, Offset: 20}, midnight.Add(6 * time.Hour), 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if got := tt.d.At(tt.at); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestSensor_Run_Sine verifies a sensor samples a Sine at the time of its own clock, rather than the wall clock.
+func TestSensor_Run_Sine(t *testing.T) {
+	t.Parallel()
+
+	d := sensor.Sine{Amplitude: 5, Period: 4 * time.Second, Offset: 20}
+	clk := clock.NewFake(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	dataCh := make(chan model.SensorData) // Unbuffered, so at most one reading is in flight.
+	s := mustNewSensor(t, 1, dataCh, time.Second, nil, nil, sensor.WithDistribution(d), sensor.WithClock(clk))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	// A second into each four second period, the wave peaks; three seconds in, it troughs.
+	for i, want := range []float64{25, 20, 15, 20, 25} {
+		clk.BlockUntil(1)
+		clk.Advance(time.Second)
+		select {
+		case data := <-dataCh:
+			if math.Abs(data.Value-want) > 1e-9 {
+				t.Errorf("reading %d: expected %v at %v, got %v", i+1, want, clk.Now(), data.Value)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for sensor data")
+		}
+	}
+}
+
+// TestSensor_Run_ProfileDistribution verifies sensors sample from their profile's distribution,
+// and that WithDistribution overrides it.
+func TestSensor_Run_ProfileDistribution(t *testing.T) {
+	t.Parallel()
+
+	profile := sensor.Profile{Name: "thermometer", Distribution: sensor.Normal{Mean: 100}}
+	constant := sensor.DistributionFunc(func(*rand.Rand, time.Time) float64 { return -1 })
+
+	tests := []struct {
+		name string
+		opts []sensor.Option
+		want float64
+	}{
+		{"profile distribution", []sensor.Option{sensor.WithProfile(profile)}, 100},
+		{"overridden before the profile", []sensor.Option{sensor.WithDistribution(constant), sensor.WithProfile(profile)}, -1},
+		{"overridden after the profile", []sensor.Option{sensor.WithProfile(profile), sensor.WithDistribution(constant)}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dataCh := make(chan model.SensorData, 1)
+			values := collectValues(t, mustNewSensor(t, 1, dataCh, time.Millisecond, nil, nil, tt.opts...), dataCh, 1)
+			if values[0] != tt.want {
+				t.Errorf("expected value %v, got %v", tt.want, values[0])
+			}
+		})
+	}
+}
+
+// TestSensor_Run_TypeAndUnit verifies a profile's type and unit propagate into emitted records,
+// and are left out of the JSON encoding of readings without them.
+func TestSensor_Run_TypeAndUnit(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 1)
+	profile := sensor.Profile{Name: "thermometer", Type: "temperature", Unit: "celsius"}
+	s := mustNewSensor(t, 1, dataCh, time.Millisecond, nil, nil, sensor.WithProfile(profile))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	var data model.SensorData
+	select {
+	case data = <-dataCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for sensor data")
+	}
+
+	if data.Type != "temperature" || data.Unit != "celsius" {
+		t.Errorf("expected type temperature in celsius, got %q in %q", data.Type, data.Unit)
+	}
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		t.Fatalf("failed to encode reading: %v", err)
+	}
+	if !strings.Contains(string(encoded), `"Type":"temperature","Unit":"celsius"`) {
+		t.Errorf("expected type and unit in the encoded reading, got %s", encoded)
+	}
+
+	encoded, err = json.Marshal(model.SensorData{ID: 1})
+	if err != nil {
+		t.Fatalf("failed to encode reading: %v", err)
+	}
+	if strings.Contains(string(encoded), "Type") || strings.Contains(string(encoded), "Unit") {
+		t.Errorf("expected no type or unit in a reading without them, got %s", encoded)
+	}
+}
+
+// TestNewSensor_InvalidRange verifies NewSensor rejects a value range whose min isn't below its max.
+func TestNewSensor_InvalidRange(t *testing.T) {
+	t.Parallel()
+
+	for _, r := range [][2]float64{{1, 1}, {2, 1}, {math.NaN(), 1}} {
+		s, err := sensor.NewSensor(1, make(chan model.SensorData), time.Millisecond, nil, nil, sensor.WithRange(r[0], r[1]))
+		if err == nil || s != nil {
+			t.Errorf("range [%v, %v]: expected an error and no sensor, got %v and %v", r[0], r[1], err, s)
+		}
+	}
+}
+
+// TestStart_InvalidOptions verifies Start doesn't run a sensor with invalid options,
+// closing its done channel and logging why.
+func TestStart_InvalidOptions(t *testing.T) {
+	t.Parallel()
+
+	buf := &bytes.Buffer{} // Written before done is closed.
+	done := sensor.Start(context.Background(), 1, make(chan model.SensorData), time.Millisecond, nil,
+		newTestLogger(buf), sensor.WithRange(1, 0))
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected done to be closed for a sensor with invalid options")
+	}
+	if !strings.Contains(buf.String(), "invalid value range") {
+		t.Errorf("expected the invalid range to be logged, got logs:\n%s", buf.String())
+	}
+}
+
+// TestSensor_Run_ClampsValues verifies values outside the sensor's range are clamped into it and counted.
+func TestSensor_Run_ClampsValues(t *testing.T) {
+	t.Parallel()
+
+	const n = 50
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, n)
+	// Uniform values in [0, 1) are clamped into [0.25, 0.75].
+	s := mustNewSensor(t, 1, dataCh, time.Millisecond, m, nil, sensor.WithRange(0.25, 0.75))
+
+	values := collectValues(t, s, dataCh, n)
+
+	clamped := 0
+	for _, v := range values {
+		if v < 0.25 || v > 0.75 {
+			t.Fatalf("expected values within [0.25, 0.75], got %v", v)
+		}
+		if v == 0.25 || v == 0.75 {
+			clamped++
+		}
+	}
+	if clamped == 0 {
+		t.Fatal("expected some values to be clamped")
+	}
+	if got := testutil.ToFloat64(m.ValueClamped.WithLabelValues("1")); got < float64(clamped) {
+		t.Errorf("expected at least %d clamped values counted, got %v", clamped, got)
+	}
+}
+
+// TestSensor_Run_Faults verifies each fault mode's effect on the sensor's readings, and that faults are counted.
+func TestSensor_Run_Faults(t *testing.T) {
+	t.Parallel()
+
+	const n = 20
+
+	tests := []struct {
+		name  string
+		fault sensor.FaultConfig
+		check func(t *testing.T, values []float64)
+	}{
+		{
+			name:  "stuck",
+			fault: sensor.FaultConfig{Mode: sensor.FaultStuck, Probability: 1},
+			check: func(t *testing.T, values []float64) {
+				for _, v := range values[1:] {
+					if v != values[0] {
+						t.Fatalf("expected every value to repeat the first, %v, got %v", values[0], values)
+					}
+				}
+			},
+		},
+		{
+			name:  "spike",
+			fault: sensor.FaultConfig{Mode: sensor.FaultSpike, Probability: 1, SpikeMagnitude: 50},
+			check: func(t *testing.T, values []float64) {
+				for _, v := range values {
+					if math.Abs(v) < 49 {
+						t.Fatalf("expected every value to be a spike of magnitude 50, got %v", v)
+					}
+				}
+			},
+		},
+		{
+			name:  "dropout",
+			fault: sensor.FaultConfig{Mode: sensor.FaultDropout, Probability: 0.5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+			dataCh := make(chan model.SensorData, n)
+			s := mustNewSensor(t, 1, dataCh, time.Millisecond, m, nil, sensor.WithSeed(1), sensor.WithFault(tt.fault))
+
+			values := collectValues(t, s, dataCh, n)
+			if tt.check != nil {
+				tt.check(t, values)
+			}
+
+			if got := testutil.ToFloat64(m.SensorFaults.WithLabelValues("1", string(tt.fault.Mode))); got == 0 {
+				t.Error("expected faults to be counted")
+			}
+		})
+	}
+}
+
+// TestNewSensor_InvalidFault verifies NewSensor rejects unknown fault modes and out-of-range probabilities.
+func TestNewSensor_InvalidFault(t *testing.T) {
+	t.Parallel()
+
+	for _, cfg := range []sensor.FaultConfig{
+		{Mode: "flaky", Probability: 0.5},
+		{Mode: sensor.FaultSpike, Probability: 1.5},
+		{Mode: sensor.FaultDropout, Probability: math.NaN()},
+	} {
+		s, err := sensor.NewSensor(1, make(chan model.SensorData), time.Millisecond, nil, nil, sensor.WithFault(cfg))
+		if err == nil || s != nil {
+			t.Errorf("fault %+v: expected an error and no sensor, got %v and %v", cfg, err, s)
+		}
+	}
+}
+
+// TestSensor_Run_Drift verifies a drifting sensor's values are offset by a growing bias,
+// that ResetDrift recalibrates it, and that the drift is exported.
+func TestSensor_Run_Drift(t *testing.T) {
+	t.Parallel()
+
+	const rate = 0.5 // Drift by 0.5 every second.
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	clk := clock.NewFake(time.Now())
+	dataCh := make(chan model.SensorData) // Unbuffered, so at most one reading is in flight.
+	zero := sensor.DistributionFunc(func(*rand.Rand, time.Time) float64 { return 0 })
+	s := mustNewSensor(t, 1, dataCh, time.Second, m, nil,
+		sensor.WithDistribution(zero), sensor.WithDriftRate(rate), sensor.WithClock(clk))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	// next steps the sensor through its next interval, returning the value it sends.
+	next := func() float64 {
+		t.Helper()
+		clk.BlockUntil(1)
+		clk.Advance(time.Second)
+		select {
+		case data := <-dataCh:
+			return data.Value
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for sensor data")
+			return 0
+		}
+	}
+
+	for i := 1; i <= 20; i++ {
+		if got, want := next(), rate*float64(i); got != want {
+			t.Fatalf("reading %d: expected the value to have drifted to %v, got %v", i, want, got)
+		}
+	}
+	if got := testutil.ToFloat64(m.SensorDrift.WithLabelValues("1")); got != 10 {
+		t.Errorf("expected the drift metric to be 10, got %v", got)
+	}
+
+	// Recalibrated while waiting for its next reading, the sensor has drifted for just one interval by then.
+	clk.BlockUntil(1)
+	s.ResetDrift()
+	if got := next(); got != rate {
+		t.Errorf("expected the drift to reset, got %v", got)
+	}
+}
+
+// BenchmarkSensor_Run measures how many readings sensors emit per second with their interval floor removed,
+// for fleets of increasing size sharing one data channel.
+func BenchmarkSensor_Run(b *testing.B) {
+	logger := slog.New(slog.DiscardHandler)
+
+	for _, sensors := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("sensors=%d", sensors), func(b *testing.B) {
+			dataCh := make(chan model.SensorData, 1000)
+			ctx, cancel := context.WithCancel(context.Background())
+
+			var wg sync.WaitGroup
+			for id := 1; id <= sensors; id++ {
+				s, err := sensor.NewSensor(id, dataCh, time.Nanosecond, nil, logger, sensor.WithMinInterval(time.Nanosecond))
+				if err != nil {
+					b.Fatalf("NewSensor returned error: %v", err)
+				}
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					s.Run(ctx)
+				}()
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for range b.N {
+				<-dataCh
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(b.N)/b.Elapsed().Seconds(), "readings/s")
+
+			cancel()
+			// Unblock sensors waiting to send, so they can see the cancellation.
+			go func() {
+				for range dataCh {
+				}
+			}()
+			wg.Wait()
+			close(dataCh)
+		})
+	}
+}
+
+// TestSensor_Run_DropOnFull verifies a sensor in drop mode keeps running while its data channel is full,
+// counting the readings it drops, and sends again once there is room.
+func TestSensor_Run_DropOnFull(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 1)
+	dataCh <- model.SensorData{ID: -1} // Fill the channel.
+	s := mustNewSensor(t, 1, dataCh, time.Millisecond, m, nil, sensor.WithDropOnFull(func() bool { return true }))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	dropped := m.MessagesDropped.WithLabelValues("1")
+	deadline := time.Now().Add(time.Second)
+	for testutil.ToFloat64(dropped) < 5 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected readings to be dropped while the channel is full, got %v dropped", testutil.ToFloat64(dropped))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Make room, and the sensor's next reading is sent.
+	<-dataCh
+	select {
+	case data := <-dataCh:
+		if data.ID != 1 {
+			t.Errorf("expected a reading from sensor 1, got %+v", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the sensor to send once the channel had room")
+	}
+}
+
+// TestSensor_Run_RateLimiter verifies sensors sharing a rate limiter are held to its aggregate rate,
+// waiting rather than dropping readings, and stop promptly while waiting.
+func TestSensor_Run_RateLimiter(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 1000)
+	limiter := rate.NewLimiter(50, 1)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	for id := 1; id <= 2; id++ {
+		s := mustNewSensor(t, id, dataCh, time.Millisecond, m, nil, sensor.WithRateLimiter(limiter))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Run(ctx)
+		}()
+	}
+
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+	stopped := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the throttled sensors to stop")
+	}
+
+	// 50/s for 0.5s, plus the initial token. Unthrottled, the sensors would have sent ~1000 readings.
+	if got := len(dataCh); got < 10 || got > 30 {
+		t.Errorf("expected about 26 readings at the limited rate, got %d", got)
+	}
+	if got := testutil.ToFloat64(m.ThrottledDuration); got <= 0 {
+		t.Errorf("expected time spent throttled to be counted, got %v", got)
+	}
+	for _, id := range []string{"1", "2"} {
+		if got := testutil.ToFloat64(m.MessagesDropped.WithLabelValues(id)); got != 0 {
+			t.Errorf("expected sensor %s not to drop throttled readings, got %v dropped", id, got)
+		}
+	}
+}
+
+// TestSensor_Run_Jitter verifies a jittered sensor's intervals vary, while their mean stays near the configured interval.
+func TestSensor_Run_Jitter(t *testing.T) {
+	t.Parallel()
+
+	const (
+		interval = 5 * time.Millisecond
+		readings = 200
+	)
+	dataCh := make(chan model.SensorData, readings)
+	s := mustNewSensor(t, 1, dataCh, interval, nil, nil, sensor.WithJitter(0.5))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	timestamps := make([]time.Time, 0, readings)
+	for range readings {
+		select {
+		case data := <-dataCh:
+			timestamps = append(timestamps, data.Timestamp)
+		case <-time.After(time.Second):
+			t.Fatalf("timed out after %d readings", len(timestamps))
+		}
+	}
+
+	var minGap, maxGap time.Duration
+	for i := 1; i < len(timestamps); i++ {
+		gap := timestamps[i].Sub(timestamps[i-1])
+		if i == 1 || gap < minGap {
+			minGap = gap
+		}
+		if gap > maxGap {
+			maxGap = gap
+		}
+	}
+	mean := timestamps[len(timestamps)-1].Sub(timestamps[0]) / (readings - 1)
+
+	// ±50% jitter spreads the intervals over 2.5ms-7.5ms; allow for scheduling delays on a busy machine.
+	if mean < interval*8/10 || mean > interval*13/10 {
+		t.Errorf("expected the mean interval to stay near %v, got %v", interval, mean)
+	}
+	if maxGap-minGap < interval/2 {
+		t.Errorf("expected jittered intervals to vary, got intervals between %v and %v", minGap, maxGap)
+	}
+}
+
+// TestNewSensor_InvalidJitter verifies jitter fractions outside [0, 1) are rejected.
+func TestNewSensor_InvalidJitter(t *testing.T) {
+	t.Parallel()
+
+	for _, fraction := range []float64{-0.1, 1, math.NaN()} {
+		if _, err := sensor.NewSensor(1, make(chan model.SensorData), time.Second, nil, nil, sensor.WithJitter(fraction)); err == nil {
+			t.Errorf("expected an error for jitter %v", fraction)
+		}
+	}
+}
+
+// TestSensor_Run_Controller verifies a controlled sensor stops emitting while paused,
+// and follows interval changes without being restarted.
+func TestSensor_Run_Controller(t *testing.T) {
+	t.Parallel()
+
+	ctrl := sensor.NewController(time.Millisecond, nil)
+	dataCh := make(chan model.SensorData, 1)
+	s := mustNewSensor(t, 1, dataCh, time.Millisecond, nil, nil, sensor.WithController(ctrl))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	select {
+	case <-dataCh:
+	case <-time.After(time.Second):
+		t.Fatal("expected a reading before pausing")
+	}
+
+	ctrl.Pause()
+	// Drain a reading that may have been in flight when pausing.
+	select {
+	case <-dataCh:
+	case <-time.After(50 * time.Millisecond):
+	}
+	select {
+	case data := <-dataCh:
+		t.Fatalf("expected no readings while paused, got %+v", data)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := ctrl.SetInterval(200 * time.Millisecond); err != nil {
+		t.Fatalf("unexpected error setting the interval: %v", err)
+	}
+	ctrl.Resume()
+	first := <-dataCh
+	second := <-dataCh
+	if gap := second.Timestamp.Sub(first.Timestamp); gap < 150*time.Millisecond {
+		t.Errorf("expected readings 200ms apart after the interval change, got %v", gap)
+	}
+
+	if err := ctrl.SetInterval(0); err == nil {
+		t.Error("expected a non-positive interval to be rejected")
+	}
+}
+
+// TestController_Wait verifies Wait blocks while paused, counting the waiting sensors,
+// until the sensors are resumed or the context is done.
+func TestController_Wait(t *testing.T) {
+	t.Parallel()
+
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	ctrl := sensor.NewController(time.Second, m)
+	if err := ctrl.Wait(context.Background()); err != nil {
+		t.Fatalf("expected Wait to return straight away unpaused, got %v", err)
+	}
+
+	ctrl.Pause()
+	ctrl.Pause() // Pausing twice takes one Resume.
+	waitErr := make(chan error, 2)
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { waitErr <- ctrl.Wait(context.Background()) }()
+	go func() { waitErr <- ctrl.Wait(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(m.PausedSensors) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 2 paused sensors, got %v", testutil.ToFloat64(m.PausedSensors))
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-waitErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the canceled Wait to return context.Canceled, got %v", err)
+	}
+	ctrl.Resume()
+	ctrl.Resume() // Resuming twice is harmless.
+	if err := <-waitErr; err != nil {
+		t.Errorf("expected Wait to return nil once resumed, got %v", err)
+	}
+	if got := testutil.ToFloat64(m.PausedSensors); got != 0 {
+		t.Errorf("expected no paused sensors once resumed, got %v", got)
+	}
+}
+
+// TestGroup_Distribution verifies a group's members share its base value, each adding independent noise,
+// and that a new base value is drawn once the hold has elapsed.
+func TestGroup_Distribution(t *testing.T) {
+	t.Parallel()
+
+	groups, err := sensor.NewGroups([]sensor.GroupConfig{{
+		Name:    "room-1",
+		Members: []int{1, 2},
+		Base:    sensor.Normal{Mean: 20, StdDev: 5},
+		Noise:   sensor.Normal{StdDev: 0.01},
+		Hold:    time.Hour,
+	}}, 42)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if groups[1] != groups[2] || groups[1].Name() != "room-1" {
+		t.Fatalf("expected sensors 1 and 2 to share group room-1, got %v", groups)
+	}
+	if _, ok := groups[3]; ok {
+		t.Error("expected sensor 3 not to be grouped")
+	}
+
+	now := time.Now()
+	a := groups[1].Distribution().Sample(rand.New(rand.NewPCG(1, 0)), now)
+	b := groups[2].Distribution().Sample(rand.New(rand.NewPCG(2, 0)), now)
+	if math.Abs(a-b) > 0.1 {
+		t.Errorf("expected members to read close values, got %v and %v", a, b)
+	}
+	if a == b {
+		t.Errorf("expected members to add independent noise, both read %v", a)
+	}
+
+	base := groups[1].Value(now)
+	if got := groups[1].Value(now.Add(59 * time.Minute)); got != base {
+		t.Errorf("expected the base value to be held, got %v then %v", base, got)
+	}
+	if got := groups[1].Value(now.Add(time.Hour)); got == base {
+		t.Errorf("expected a new base value once the hold elapsed, got %v again", got)
+	}
+}
+
+// TestNewGroups_Invalid verifies groups without a base, hold or members are rejected,
+// as are sensors in more than one group.
+func TestNewGroups_Invalid(t *testing.T) {
+	t.Parallel()
+
+	valid := sensor.GroupConfig{Name: "a", Members: []int{1}, Base: sensor.Uniform{}, Hold: time.Second}
+	tests := map[string]struct {
+		configs []sensor.GroupConfig
+		wantErr string
+	}{
+		"no base":    {[]sensor.GroupConfig{{Name: "a", Members: []int{1}, Hold: time.Second}}, "no base distribution"},
+		"no hold":    {[]sensor.GroupConfig{{Name: "a", Members: []int{1}, Base: sensor.Uniform{}}}, "hold must be positive"},
+		"no members": {[]sensor.GroupConfig{{Name: "a", Base: sensor.Uniform{}, Hold: time.Second}}, "no members"},
+		"overlap": {
+			[]sensor.GroupConfig{valid, {Name: "b", Members: []int{2, 1}, Base: sensor.Uniform{}, Hold: time.Second}},
+			`sensor 1 is in groups "a" and "b"`,
+		},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if _, err := sensor.NewGroups(tt.configs, 1); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestSensor_Run_Location verifies a located sensor's readings carry its position, and that invalid positions are rejected.
+func TestSensor_Run_Location(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 1)
+	loc := sensor.Location{Latitude: 51.5, Longitude: -0.12}
+	s := mustNewSensor(t, 1, dataCh, time.Millisecond, nil, nil, sensor.WithLocation(loc))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	select {
+	case data := <-dataCh:
+		if data.Latitude != loc.Latitude || data.Longitude != loc.Longitude {
+			t.Errorf("expected a reading at %+v, got (%v, %v)", loc, data.Latitude, data.Longitude)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for sensor data")
+	}
+
+	for _, loc := range []sensor.Location{{Latitude: 91}, {Longitude: -181}, {Latitude: math.NaN()}} {
+		if _, err := sensor.NewSensor(1, dataCh, time.Millisecond, nil, nil, sensor.WithLocation(loc)); err == nil {
+			t.Errorf("%+v: expected an error, got nil", loc)
+		}
+	}
+}
+
+// TestRandomLocation verifies random locations fall within the bounding box, and repeat for the same seed.
+func TestRandomLocation(t *testing.T) {
+	t.Parallel()
+
+	bbox := sensor.BoundingBox{
+		Min: sensor.Location{Latitude: 51.28, Longitude: -0.51},
+		Max: sensor.Location{Latitude: 51.69, Longitude: 0.33},
+	}
+	if err := bbox.Validate(); err != nil {
+		t.Fatalf("unexpected error validating the box: %v", err)
+	}
+
+	r := rand.New(rand.NewPCG(1, 0))
+	first := make([]sensor.Location, 100)
+	for i := range first {
+		first[i] = sensor.RandomLocation(bbox, r)
+		if loc := first[i]; loc.Latitude < bbox.Min.Latitude || loc.Latitude > bbox.Max.Latitude ||
+			loc.Longitude < bbox.Min.Longitude || loc.Longitude > bbox.Max.Longitude {
+			t.Fatalf("location %+v is outside the box %+v", loc, bbox)
+		}
+	}
+
+	r = rand.New(rand.NewPCG(1, 0))
+	for i, want := range first {
+		if got := sensor.RandomLocation(bbox, r); got != want {
+			t.Fatalf("location %d: expected %+v with the same seed, got %+v", i, want, got)
+		}
+	}
+
+	if err := (sensor.BoundingBox{Min: bbox.Max, Max: bbox.Min}).Validate(); err == nil {
+		t.Error("expected an error validating a box with its corners swapped, got nil")
+	}
+}
+
+// TestParseLocations verifies positions and bounding boxes are parsed from their text form, and malformed
+// or invalid ones are rejected.
+func TestParseLocations(t *testing.T) {
+	t.Parallel()
+
+	locations, err := sensor.ParseLocations(" 51.5, -0.12; 48.86,2.35")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []sensor.Location{{Latitude: 51.5, Longitude: -0.12}, {Latitude: 48.86, Longitude: 2.35}}
+	if !slices.Equal(locations, want) {
+		t.Errorf("expected %+v, got %+v", want, locations)
+	}
+	if locations, err := sensor.ParseLocations(""); err != nil || locations != nil {
+		t.Errorf("expected no locations from an empty list, got %+v (%v)", locations, err)
+	}
+
+	bbox, err := sensor.ParseBoundingBox("51.28,-0.51,51.69,0.33")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (sensor.BoundingBox{Min: sensor.Location{Latitude: 51.28, Longitude: -0.51}, Max: sensor.Location{Latitude: 51.69, Longitude: 0.33}}); bbox != want {
+		t.Errorf("expected %+v, got %+v", want, bbox)
+	}
+
+	for _, list := range []string{"51.5", "51.5,-0.12;", "north,west", "91,0"} {
+		if _, err := sensor.ParseLocations(list); err == nil {
+			t.Errorf("%q: expected an error parsing locations, got nil", list)
+		}
+	}
+	for _, box := range []string{"51.28,-0.51,51.69", "51.69,0.33,51.28,-0.51", "0,0,0,x"} {
+		if _, err := sensor.ParseBoundingBox(box); err == nil {
+			t.Errorf("%q: expected an error parsing a bounding box, got nil", box)
+		}
+	}
+}
+
+// TestSensor_Run_SkipsNonFiniteValues verifies NaN and infinite values, e.g. where an expression is undefined,
+// are counted and skipped rather than sent.
+func TestSensor_Run_SkipsNonFiniteValues(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	g, err := sensor.NewExprGenerator("log(t - 2)") // NaN at t=1, and -Inf at t=2.
+	if err != nil {
+		t.Fatalf("failed to create generator: %v", err)
+	}
+	clk := clock.NewFake(start)
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	dataCh := make(chan model.SensorData, 4)
+	s := mustNewSensor(t, 1, dataCh, time.Second, m, nil, sensor.WithDistribution(g.Distribution(1, start)), sensor.WithClock(clk))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	for range 4 {
+		clk.BlockUntil(1)
+		clk.Advance(time.Second)
+	}
+	for i, want := range []float64{0, math.Ln2} {
+		select {
+		case data := <-dataCh:
+			if math.Abs(data.Value-want) > 1e-9 {
+				t.Errorf("reading %d: expected %v, got %v", i+1, want, data.Value)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for sensor data")
+		}
+	}
+	if got := testutil.ToFloat64(m.NonFiniteValues.WithLabelValues("1")); got != 2 {
+		t.Errorf("expected 2 non-finite values counted, got %v", got)
+	}
+}
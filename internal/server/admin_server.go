@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sensor"
+)
+
+// maxAdminBodyBytes bounds the size of an admin request's body.
+const maxAdminBodyBytes = 1 << 10
+
+// AdminServer is an HTTP server for controlling a running simulation:
+// pausing and resuming the sensors, changing their interval, and querying the fleet's state.
+// Requests that change anything require the configured bearer token, if there is one.
+type AdminServer struct {
+	server   *http.Server
+	mux      *http.ServeMux
+	listener net.Listener
+	control  *sensor.Controller
+	fleet    *sensor.Manager
+	token    string
+	logger   *slog.Logger
+}
+
+// Status is the JSON body of the admin server's responses: the simulation's current state.
+type Status struct {
+	Paused   bool   `json:"paused"`
+	Interval string `json:"interval"`
+	Sensors  int    `json:"sensors"`
+	Error    string `json:"error,omitempty"`
+}
+
+// NewAdminServer creates a new AdminServer listening on addr (e.g. ":8081"),
+// driving control and reporting the size of fleet.
+// Unless token is empty, requests that change anything must carry it as an `Authorization: Bearer` header.
+func NewAdminServer(addr, token string, control *sensor.Controller, fleet *sensor.Manager, l *slog.Logger) *AdminServer {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	mux := http.NewServeMux()
+	s := &AdminServer{
+		server: &http.Server{
+			Addr:    addr,
+			Handler: mux,
+		},
+		mux:     mux,
+		control: control,
+		fleet:   fleet,
+		token:   token,
+		logger:  l.With("component", "admin_server"),
+	}
+	mux.HandleFunc("GET /admin/status", s.handleStatus)
+	mux.Handle("POST /admin/pause", s.authorize(http.HandlerFunc(s.handlePause)))
+	mux.Handle("POST /admin/resume", s.authorize(http.HandlerFunc(s.handleResume)))
+	mux.Handle("POST /admin/interval", s.authorize(http.HandlerFunc(s.handleInterval)))
+
+	// Scale the fleet at runtime (see sensor.Manager.Handler). Only listing it is allowed without the token.
+	fleetHandler := fleet.Handler()
+	mux.Handle("GET /admin/sensors", fleetHandler)
+	mux.Handle("/admin/sensors/", s.authorize(fleetHandler))
+
+	return s
+}
+
+// Handler returns the server's handler, e.g. for testing without listening.
+func (s *AdminServer) Handler() http.Handler {
+	return s.mux
+}
+
+// authorize wraps next, rejecting requests without the server's bearer token with a 401 (if it has one).
+func (s *AdminServer) authorize(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+
+	want := []byte("Bearer " + s.token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			s.logger.Warn("Rejected unauthorized admin request", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+			s.writeStatus(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleStatus handles `GET /admin/status`.
+func (s *AdminServer) handleStatus(w http.ResponseWriter, _ *http.Request) {
+	s.writeStatus(w, http.StatusOK, "")
+}
+
+// handlePause handles `POST /admin/pause`.
+func (s *AdminServer) handlePause(w http.ResponseWriter, _ *http.Request) {
+	s.control.Pause()
+	s.logger.Info("Sensors paused")
+	s.writeStatus(w, http.StatusOK, "")
+}
+
+// handleResume handles `POST /admin/resume`.
+func (s *AdminServer) handleResume(w http.ResponseWriter, _ *http.Request) {
+	s.control.Resume()
+	s.logger.Info("Sensors resumed")
+	s.writeStatus(w, http.StatusOK, "")
+}
+
+// handleInterval handles `POST /admin/interval`, whose JSON body is e.g. {"interval": "250ms"}.
+func (s *AdminServer) handleInterval(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Interval string `json:"interval"`
+	}
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAdminBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&req); err != nil {
+		s.writeStatus(w, http.StatusBadRequest, fmt.Sprintf("malformed JSON: %v", err))
+		return
+	}
+	interval, err := time.ParseDuration(strings.TrimSpace(req.Interval))
+	if err != nil {
+		s.writeStatus(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err := s.control.SetInterval(interval); err != nil {
+		s.writeStatus(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.logger.Info("Sensor interval changed", "interval", interval)
+	s.writeStatus(w, http.StatusOK, "")
+}
+
+// writeStatus writes the simulation's current state as JSON with the status code code, along with errMsg if not empty.
+func (s *AdminServer) writeStatus(w http.ResponseWriter, code int, errMsg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(Status{
+		Paused:   s.control.Paused(),
+		Interval: s.control.Interval().String(),
+		Sensors:  len(s.fleet.IDs()),
+		Error:    errMsg,
+	})
+}
+
+// Listen binds the server's address, so that a failure (e.g. the port being in use)
+// is reported to the caller up front, rather than from Serve's goroutine.
+func (s *AdminServer) Listen() error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("admin server failed to listen on %s: %w", s.server.Addr, err)
+	}
+	s.listener = ln
+	return nil
+}
+
+// Addr returns the address the server is listening on, or its configured address before Listen.
+func (s *AdminServer) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.server.Addr
+}
+
+// Serve starts the HTTP server and handles graceful shutdown, serving until ctx is done.
+// It listens first if Listen hasn't been called.
+func (s *AdminServer) Serve(ctx context.Context) error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		s.logger.Info("Admin server starting", "addr", s.Addr(), "token_required", s.token != "")
+		serveErr <- s.server.Serve(s.listener)
+	}()
+
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("admin server failed: %w", err)
+	case <-ctx.Done():
+	}
+	s.logger.Info("Shutting down admin server")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := s.server.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("admin server shutdown failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,141 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sensor"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/server"
+)
+
+// newAdminServer returns a test server for an AdminServer requiring token, with sensors 1 and 2 running.
+func newAdminServer(t *testing.T, token string) (*httptest.Server, *sensor.Controller) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	ctrl := sensor.NewController(time.Second, nil)
+	fleet := sensor.NewManager(nil, nil)
+	fleet.SetLauncher(ctx, make(chan model.SensorData, 16), time.Second, func(int) []sensor.Option {
+		return []sensor.Option{sensor.WithController(ctrl)}
+	})
+	for _, id := range []int{1, 2} {
+		if err := fleet.Add(id); err != nil {
+			t.Fatalf("failed to add sensor %d: %v", id, err)
+		}
+	}
+
+	srv := httptest.NewServer(server.NewAdminServer("", token, ctrl, fleet, nil).Handler())
+	t.Cleanup(srv.Close)
+	return srv, ctrl
+}
+
+// adminRequest makes a request to the admin server, returning the response's status code and decoded body.
+func adminRequest(t *testing.T, srv *httptest.Server, method, path, token, body string) (int, server.Status) {
+	t.Helper()
+
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s failed: %v", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	var status server.Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+	}
+	return resp.StatusCode, status
+}
+
+// TestAdminServer verifies the admin endpoints drive the controller and report the simulation's state.
+func TestAdminServer(t *testing.T) {
+	t.Parallel()
+
+	srv, ctrl := newAdminServer(t, "")
+
+	code, status := adminRequest(t, srv, http.MethodGet, "/admin/status", "", "")
+	if code != http.StatusOK || status != (server.Status{Interval: "1s", Sensors: 2}) {
+		t.Errorf("expected status 200 with the initial state, got %d %+v", code, status)
+	}
+
+	if code, status := adminRequest(t, srv, http.MethodPost, "/admin/pause", "", ""); code != http.StatusOK || !status.Paused {
+		t.Errorf("expected pausing to report the sensors paused, got %d %+v", code, status)
+	}
+	if !ctrl.Paused() {
+		t.Error("expected the controller to be paused")
+	}
+	if code, status := adminRequest(t, srv, http.MethodPost, "/admin/resume", "", ""); code != http.StatusOK || status.Paused {
+		t.Errorf("expected resuming to report the sensors running, got %d %+v", code, status)
+	}
+
+	code, status = adminRequest(t, srv, http.MethodPost, "/admin/interval", "", `{"interval": "250ms"}`)
+	if code != http.StatusOK || status.Interval != "250ms" {
+		t.Errorf("expected the interval to be changed to 250ms, got %d %+v", code, status)
+	}
+	if got := ctrl.Interval(); got != 250*time.Millisecond {
+		t.Errorf("expected the controller's interval to be 250ms, got %v", got)
+	}
+
+	for _, body := range []string{`{"interval": "fast"}`, `{"interval": "-1s"}`, `{"period": "1s"}`, `not json`} {
+		code, status := adminRequest(t, srv, http.MethodPost, "/admin/interval", "", body)
+		if code != http.StatusBadRequest || status.Error == "" {
+			t.Errorf("body %s: expected status 400 with an error, got %d %+v", body, code, status)
+		}
+	}
+	if got := ctrl.Interval(); got != 250*time.Millisecond {
+		t.Errorf("expected invalid requests to leave the interval at 250ms, got %v", got)
+	}
+}
+
+// TestAdminServer_Token verifies requests that change anything need the bearer token, while the status doesn't.
+func TestAdminServer_Token(t *testing.T) {
+	t.Parallel()
+
+	srv, ctrl := newAdminServer(t, "s3cret")
+
+	if code, _ := adminRequest(t, srv, http.MethodGet, "/admin/status", "", ""); code != http.StatusOK {
+		t.Errorf("expected the status without a token, got %d", code)
+	}
+
+	for _, path := range []string{"/admin/pause", "/admin/resume", "/admin/interval"} {
+		for _, token := range []string{"", "wrong"} {
+			if code, _ := adminRequest(t, srv, http.MethodPost, path, token, `{"interval": "1ms"}`); code != http.StatusUnauthorized {
+				t.Errorf("POST %s with token %q: expected status 401, got %d", path, token, code)
+			}
+		}
+	}
+	if ctrl.Paused() || ctrl.Interval() != time.Second {
+		t.Error("expected unauthorized requests to change nothing")
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, srv.URL+"/admin/sensors/1", nil)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("DELETE /admin/sensors/1 failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected removing a sensor without the token to be rejected, got %d", resp.StatusCode)
+	}
+
+	if code, status := adminRequest(t, srv, http.MethodPost, "/admin/pause", "s3cret", ""); code != http.StatusOK || !status.Paused {
+		t.Errorf("expected pausing with the token to succeed, got %d %+v", code, status)
+	}
+}
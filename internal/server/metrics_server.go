@@ -3,53 +3,152 @@ package server
 
 import (
 	"context"
-	"log"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// MetricsServer is an HTTP server for exposing Prometheus metrics.
+// MetricsServer is an HTTP server for exposing Prometheus metrics,
+// along with liveness (/healthz) and readiness (/readyz) probes.
 type MetricsServer struct {
-	server *http.Server
+	server   *http.Server
+	mux      *http.ServeMux
+	listener net.Listener
+	logger   *slog.Logger
+
+	readyMu sync.RWMutex // Guards ready, which SetReadiness may set while serving.
+	ready   func() bool
 }
 
 // NewMetricsServer creates a new MetricsServer.
 // It accepts an address addr (e.g. ":2112") and a Prometheus registry reg.
-func NewMetricsServer(addr string, reg *prometheus.Registry) *MetricsServer {
+func NewMetricsServer(addr string, reg *prometheus.Registry, l *slog.Logger) *MetricsServer {
+	if l == nil {
+		l = slog.Default()
+	}
+
 	mux := http.NewServeMux()
 	// Create a new handler for the given registry.
 	promHandler := promhttp.HandlerFor(reg, promhttp.HandlerOpts{})
 	mux.Handle("/metrics", promHandler)
 
-	return &MetricsServer{
+	s := &MetricsServer{
 		server: &http.Server{
 			Addr:    addr,
 			Handler: mux,
 		},
+		mux:    mux,
+		logger: l.With("component", "metrics_server"),
 	}
+	mux.HandleFunc("/healthz", s.handleHealthz)
+	mux.HandleFunc("/readyz", s.handleReadyz)
+
+	return s
 }
 
-// Serve starts the HTTP server and handles graceful shutdown.
-func (s *MetricsServer) Serve(ctx context.Context) {
-	go func() {
-		log.Printf("Metrics server starting on %s", s.server.Addr)
-		if err := s.server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Fatalf("ERROR: Metrics server failed: %v", err) // NOTE Exit out of entire application. Might make sense to change this eventually.
+// SetReadiness sets the func /readyz reports readiness from (e.g. whether the broker is connected).
+// ready must be safe for concurrent use. Until SetReadiness is called, the server reports not ready.
+// It is safe to call while serving.
+func (s *MetricsServer) SetReadiness(ready func() bool) {
+	s.readyMu.Lock()
+	defer s.readyMu.Unlock()
+	s.ready = ready
+}
+
+// isReady reports whether the readiness func reports ready.
+func (s *MetricsServer) isReady() bool {
+	s.readyMu.RLock()
+	ready := s.ready
+	s.readyMu.RUnlock()
+	return ready != nil && ready()
+}
+
+// handleHealthz reports the server is alive, which it is whenever it can respond.
+func (s *MetricsServer) handleHealthz(w http.ResponseWriter, _ *http.Request) {
+	writeStatus(w, http.StatusOK, "ok")
+}
+
+// handleReadyz reports whether the simulator is ready (see SetReadiness), with a 503 if it isn't.
+func (s *MetricsServer) handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	if !s.isReady() {
+		writeStatus(w, http.StatusServiceUnavailable, "not ready")
+		return
+	}
+	writeStatus(w, http.StatusOK, "ok")
+}
+
+// writeStatus writes a JSON {"status": status} body with the status code code.
+func writeStatus(w http.ResponseWriter, code int, status string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(struct {
+		Status string `json:"status"`
+	}{status})
+}
+
+// Handle registers an additional handler h for pattern (e.g. "/latest/") alongside the metrics.
+// It is safe to call while serving.
+func (s *MetricsServer) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+// Listen binds the server's address, so that a failure (e.g. the port being in use)
+// is reported to the caller up front, rather than from Serve's goroutine.
+func (s *MetricsServer) Listen() error {
+	ln, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return fmt.Errorf("metrics server failed to listen on %s: %w", s.server.Addr, err)
+	}
+	s.listener = ln
+	return nil
+}
+
+// Addr returns the address the server is listening on, or its configured address before Listen.
+func (s *MetricsServer) Addr() string {
+	if s.listener != nil {
+		return s.listener.Addr().String()
+	}
+	return s.server.Addr
+}
+
+// Serve starts the HTTP server and handles graceful shutdown, serving until ctx is done.
+// It listens first if Listen hasn't been called.
+// It returns an error if the server fails to listen, serve, or shut down, rather than exiting the process:
+// it's up to the caller whether to carry on without metrics.
+func (s *MetricsServer) Serve(ctx context.Context) error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
 		}
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		s.logger.Info("Metrics server starting", "addr", s.Addr())
+		serveErr <- s.server.Serve(s.listener)
 	}()
 
-	// Wait for the context to be done, which signals shutdown.
-	<-ctx.Done()
-	log.Println("Shutting down metrics server...")
+	// Wait for the context to be done, which signals shutdown, unless serving fails first.
+	select {
+	case err := <-serveErr:
+		return fmt.Errorf("metrics server failed: %w", err)
+	case <-ctx.Done():
+	}
+	s.logger.Info("Shutting down metrics server")
 
 	// Create a context with a timeout for the shutdown process.
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := s.server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("ERROR: Metrics server shutdown failed: %v", err)
+		return fmt.Errorf("metrics server shutdown failed: %w", err)
 	}
+	return nil
 }
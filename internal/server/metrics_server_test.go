@@ -0,0 +1,140 @@
+// Package server_test contains tests for the server package.
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/server"
+)
+
+// TestMetricsServer_Listen_PortInUse verifies a bind failure is returned to the caller
+// (which can carry on without metrics), rather than exiting the application.
+func TestMetricsServer_Listen_PortInUse(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	s := server.NewMetricsServer(ln.Addr().String(), prometheus.NewRegistry(), nil)
+	if err := s.Listen(); err == nil {
+		t.Fatal("expected an error listening on a port in use, got nil")
+	}
+
+	// Serve without a listener returns the error instead of exiting.
+	serveErr := make(chan error)
+	go func() {
+		serveErr <- s.Serve(context.Background())
+	}()
+
+	select {
+	case err := <-serveErr:
+		if err == nil {
+			t.Error("expected Serve to return an error after failing to listen, got nil")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after failing to listen")
+	}
+}
+
+// TestMetricsServer_Serve verifies the server exposes metrics and stops cleanly when its context is canceled.
+func TestMetricsServer_Serve(t *testing.T) {
+	t.Parallel()
+
+	s := server.NewMetricsServer("127.0.0.1:0", prometheus.NewRegistry(), nil)
+	if err := s.Listen(); err != nil {
+		t.Fatalf("unexpected error listening: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error)
+	go func() {
+		serveErr <- s.Serve(ctx)
+	}()
+
+	resp, err := http.Get("http://" + s.Addr() + "/metrics")
+	if err != nil {
+		t.Fatalf("failed to get metrics: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("expected status 200, got %d", resp.StatusCode)
+	}
+
+	cancel()
+	select {
+	case err := <-serveErr:
+		if err != nil {
+			t.Errorf("expected a clean shutdown, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Serve did not return after the context was canceled")
+	}
+}
+
+// TestMetricsServer_Probes verifies /healthz always reports ok, while /readyz follows the readiness func.
+func TestMetricsServer_Probes(t *testing.T) {
+	t.Parallel()
+
+	s := server.NewMetricsServer("127.0.0.1:0", prometheus.NewRegistry(), nil)
+	if err := s.Listen(); err != nil {
+		t.Fatalf("unexpected error listening: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Serve(ctx)
+
+	get := func(path string) (int, string) {
+		t.Helper()
+		resp, err := http.Get("http://" + s.Addr() + path)
+		if err != nil {
+			t.Fatalf("failed to get %s: %v", path, err)
+		}
+		defer resp.Body.Close()
+
+		if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%s: expected a JSON content type, got %q", path, ct)
+		}
+		var body struct {
+			Status string `json:"status"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			t.Fatalf("%s: failed to decode body: %v", path, err)
+		}
+		return resp.StatusCode, body.Status
+	}
+
+	var ready atomic.Bool
+	tests := []struct {
+		name       string
+		setup      func()
+		path       string
+		wantCode   int
+		wantStatus string
+	}{
+		{"healthz", func() {}, "/healthz", http.StatusOK, "ok"},
+		{"readyz before readiness is set", func() {}, "/readyz", http.StatusServiceUnavailable, "not ready"},
+		{"readyz not ready", func() { s.SetReadiness(ready.Load) }, "/readyz", http.StatusServiceUnavailable, "not ready"},
+		{"readyz ready", func() { ready.Store(true) }, "/readyz", http.StatusOK, "ok"},
+	}
+
+	// The cases run in order, since each builds on the readiness set up by the previous ones.
+	for _, tt := range tests {
+		tt.setup()
+		code, status := get(tt.path)
+		if code != tt.wantCode || status != tt.wantStatus {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.name, tt.wantCode, tt.wantStatus, code, status)
+		}
+	}
+}
@@ -2,14 +2,19 @@ package server
 
 import (
 	"context"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/http/pprof"
 	"time"
 )
 
 // StartPprofServer starts a dedicated HTTP server for pprof profiling endpoints.
-func StartPprofServer(ctx context.Context, addr string) {
+func StartPprofServer(ctx context.Context, addr string, l *slog.Logger) {
+	if l == nil {
+		l = slog.Default()
+	}
+	logger := l.With("component", "pprof_server")
+
 	mux := http.NewServeMux()
 
 	// Explicitly register the pprof handlers.
@@ -25,20 +30,20 @@ func StartPprofServer(ctx context.Context, addr string) {
 	}
 
 	go func() {
-		log.Printf("pprof server starting on %s", addr)
+		logger.Info("pprof server starting", "addr", addr)
 		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			log.Printf("ERROR: pprof server failed: %v", err)
+			logger.Error("pprof server failed", "error", err)
 		}
 	}()
 
 	// Wait for the context to be cancelled to start graceful shutdown.
 	<-ctx.Done()
 
-	log.Println("Shutting down pprof server...")
+	logger.Info("Shutting down pprof server")
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Printf("ERROR: pprof server shutdown failed: %v", err)
+		logger.Error("pprof server shutdown failed", "error", err)
 	}
 }
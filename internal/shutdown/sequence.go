@@ -0,0 +1,113 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// ErrStageTimeout is returned (wrapped) for a shutdown stage that didn't finish within its timeout.
+var ErrStageTimeout = errors.New("shutdown stage timed out")
+
+// Stage is one step of a shutdown Sequence.
+type Stage struct {
+	Name string
+	// Run performs the stage. Its context is done once the stage's timeout elapses, after which
+	// the sequence moves on without it, so a stage stuck waiting can't hang the shutdown.
+	Run func(ctx context.Context) error
+	// Timeout bounds how long the sequence waits for the stage to finish (0 waits for as long as it takes).
+	Timeout time.Duration
+}
+
+// Sequence runs the stages of a shutdown in order, e.g. stopping producers before closing the channel
+// they send to, and that before waiting for its consumers to drain it. Every stage is run, even after
+// an earlier one failed or timed out, so the shutdown always completes; each stage's outcome is logged.
+type Sequence struct {
+	stages []Stage
+	logger *slog.Logger
+}
+
+// NewSequence creates a Sequence of stages, run in the order given.
+func NewSequence(l *slog.Logger, stages ...Stage) *Sequence {
+	if l == nil {
+		l = slog.Default()
+	}
+
+	return &Sequence{
+		stages: stages,
+		logger: l.With("component", "shutdown"),
+	}
+}
+
+// Run runs the stages in order, returning the errors of those that failed or timed out, joined.
+func (s *Sequence) Run() error {
+	var errs []error
+	for i, stage := range s.stages {
+		logger := s.logger.With("stage", stage.Name, "step", fmt.Sprintf("%d/%d", i+1, len(s.stages)))
+		logger.Info("Shutdown stage starting", "timeout", stage.Timeout)
+
+		start := time.Now()
+		if err := s.runStage(stage); err != nil {
+			logger.Error("Shutdown stage failed", "error", err, "elapsed", time.Since(start))
+			errs = append(errs, fmt.Errorf("%s: %w", stage.Name, err))
+			continue
+		}
+		logger.Info("Shutdown stage complete", "elapsed", time.Since(start))
+	}
+	return errors.Join(errs...)
+}
+
+// runStage runs stage, returning its error, or ErrStageTimeout if it's still running once its timeout elapses.
+func (s *Sequence) runStage(stage Stage) error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if stage.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, stage.Timeout)
+	}
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- stage.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// The stage may have finished just as the timeout elapsed, in which case its result is kept.
+		select {
+		case err = <-done:
+		default:
+			err = ctx.Err()
+		}
+	}
+	// A stage giving up because its timeout elapsed is reported the same as one that's still running.
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() != nil {
+		return fmt.Errorf("%w after %v", ErrStageTimeout, stage.Timeout)
+	}
+	return err
+}
+
+// Wait returns a Stage.Run waiting for every one of wgs, e.g. for a set of consumers to return.
+// It returns ctx's error if ctx is done first (the waits themselves carry on in the background).
+func Wait(wgs ...*sync.WaitGroup) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			for _, wg := range wgs {
+				wg.Wait()
+			}
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,39 @@
+// Package shutdown provides cancellation causes, so components can report why they are shutting down.
+// Contexts are canceled with one of the package's cause errors, which components
+// retrieve with context.Cause and include in their shutdown logs.
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrSignal is the cause when an OS signal (e.g. SIGINT) requested shutdown.
+	ErrSignal = errors.New("shutdown signal received")
+	// ErrDurationElapsed is the cause when the configured simulation duration elapsed.
+	ErrDurationElapsed = errors.New("simulation duration elapsed")
+	// ErrReplayFinished is the cause when every recorded reading has been replayed (see -replay).
+	ErrReplayFinished = errors.New("replay finished")
+)
+
+// WithCancelCause returns a copy of parent and a function that cancels it with a cause,
+// e.g. cancel(ErrSignal).
+func WithCancelCause(parent context.Context) (context.Context, context.CancelCauseFunc) {
+	return context.WithCancelCause(parent)
+}
+
+// WithDuration returns a copy of parent that is canceled with ErrDurationElapsed once d elapses.
+func WithDuration(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(parent, d, ErrDurationElapsed)
+}
+
+// Reason returns a human-readable description of why ctx was canceled,
+// or an empty string if it hasn't been.
+func Reason(ctx context.Context) string {
+	if cause := context.Cause(ctx); cause != nil {
+		return cause.Error()
+	}
+	return ""
+}
@@ -0,0 +1,296 @@
+// Package shutdown_test contains tests for the shutdown package.
+package shutdown_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/ingest"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sensor"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/shutdown"
+)
+
+// TestWithCancelCause verifies that canceling with a cause makes it available via context.Cause.
+func TestWithCancelCause(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := shutdown.WithCancelCause(context.Background())
+	if reason := shutdown.Reason(ctx); reason != "" {
+		t.Errorf("expected no reason before cancellation, got %q", reason)
+	}
+
+	cancel(shutdown.ErrSignal)
+
+	if cause := context.Cause(ctx); !errors.Is(cause, shutdown.ErrSignal) {
+		t.Errorf("expected cause %v, got %v", shutdown.ErrSignal, cause)
+	}
+	if reason := shutdown.Reason(ctx); reason != shutdown.ErrSignal.Error() {
+		t.Errorf("expected reason %q, got %q", shutdown.ErrSignal.Error(), reason)
+	}
+}
+
+// TestWithDuration verifies that a context canceled by its duration elapsing reports ErrDurationElapsed,
+// while a parent's cause takes precedence if the parent is canceled first.
+func TestWithDuration(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := shutdown.WithDuration(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	<-ctx.Done()
+	if cause := context.Cause(ctx); !errors.Is(cause, shutdown.ErrDurationElapsed) {
+		t.Errorf("expected cause %v, got %v", shutdown.ErrDurationElapsed, cause)
+	}
+
+	parent, cancelParent := shutdown.WithCancelCause(context.Background())
+	child, cancelChild := shutdown.WithDuration(parent, time.Hour)
+	defer cancelChild()
+
+	cancelParent(shutdown.ErrSignal)
+	if cause := context.Cause(child); !errors.Is(cause, shutdown.ErrSignal) {
+		t.Errorf("expected parent cause %v, got %v", shutdown.ErrSignal, cause)
+	}
+}
+
+// TestSequence_Run verifies stages run in order, each bounded by its timeout,
+// and that the sequence carries on past stages that fail or time out, reporting them.
+func TestSequence_Run(t *testing.T) {
+	t.Parallel()
+
+	// The stages run on their own goroutines, and an abandoned one isn't synchronized with the next, hence the lock.
+	var mu sync.Mutex
+	var ran []string
+	record := func(stage string) {
+		mu.Lock()
+		defer mu.Unlock()
+		ran = append(ran, stage)
+	}
+	stuck := make(chan struct{})
+	defer close(stuck)
+	errBoom := errors.New("boom")
+
+	seq := shutdown.NewSequence(nil,
+		shutdown.Stage{Name: "first", Run: func(context.Context) error {
+			record("first")
+			return nil
+		}},
+		shutdown.Stage{Name: "failing", Run: func(context.Context) error {
+			record("failing")
+			return errBoom
+		}},
+		shutdown.Stage{Name: "stuck", Timeout: 20 * time.Millisecond, Run: func(context.Context) error {
+			record("stuck")
+			<-stuck // Ignores its context, so the sequence has to move on without it.
+			return nil
+		}},
+		shutdown.Stage{Name: "waiting", Timeout: 20 * time.Millisecond, Run: shutdown.Wait(new(sync.WaitGroup), blockedWaitGroup(t))},
+		shutdown.Stage{Name: "last", Run: func(context.Context) error {
+			record("last")
+			return nil
+		}},
+	)
+
+	start := time.Now()
+	err := seq.Run()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the timed out stages to be abandoned promptly, took %v", elapsed)
+	}
+	mu.Lock()
+	if want := []string{"first", "failing", "stuck", "last"}; !slices.Equal(ran, want) {
+		t.Errorf("expected stages %v to run in order, got %v", want, ran)
+	}
+	mu.Unlock()
+	if !errors.Is(err, errBoom) || !errors.Is(err, shutdown.ErrStageTimeout) {
+		t.Errorf("expected the failure and the timeouts to be reported, got %v", err)
+	}
+	for _, stage := range []string{"failing:", "stuck:", "waiting:"} {
+		if !strings.Contains(err.Error(), stage) {
+			t.Errorf("expected the error to name stage %q, got %v", stage, err)
+		}
+	}
+	if strings.Contains(err.Error(), "first:") || strings.Contains(err.Error(), "last:") {
+		t.Errorf("expected only the failed stages to be reported, got %v", err)
+	}
+}
+
+// blockedWaitGroup returns a WaitGroup that's waited on until the test ends.
+func blockedWaitGroup(t *testing.T) *sync.WaitGroup {
+	t.Helper()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	t.Cleanup(wg.Done)
+	return &wg
+}
+
+// lockedBuffer is a bytes.Buffer safe for concurrent writes, e.g. by many sensors' loggers.
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// TestSequence_Run_InFlightData verifies shutting down while sensors are blocked sending to a full data channel,
+// and slow consumers are still draining it, completes without any send on the closed channel.
+func TestSequence_Run_InFlightData(t *testing.T) {
+	t.Parallel()
+
+	logs := &lockedBuffer{}
+	logger := slog.New(slog.NewTextHandler(logs, nil))
+
+	// A small buffer and slow consumers keep the sensors blocked sending, with readings in flight.
+	dataCh := make(chan model.SensorData, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr := sensor.NewManager(nil, logger)
+	mgr.SetLauncher(ctx, dataCh, time.Millisecond, nil)
+	if err := mgr.Ramp(ctx, 50, 0); err != nil {
+		t.Fatalf("failed to start sensors: %v", err)
+	}
+
+	var consumers sync.WaitGroup
+	var received atomic.Int64
+	for range 2 {
+		consumers.Add(1)
+		go func() {
+			defer consumers.Done()
+			for range dataCh {
+				received.Add(1)
+				time.Sleep(100 * time.Microsecond)
+			}
+		}()
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for received.Load() < 100 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected readings to flow, got %d", received.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	err := shutdown.NewSequence(logger,
+		shutdown.Stage{Name: "wait for sensors", Timeout: 5 * time.Second, Run: func(context.Context) error {
+			if stragglers := mgr.Wait(time.Second); len(stragglers) > 0 {
+				return fmt.Errorf("%d sensors still running", len(stragglers))
+			}
+			return nil
+		}},
+		shutdown.Stage{Name: "close data channel", Timeout: time.Second, Run: func(context.Context) error {
+			close(dataCh)
+			return nil
+		}},
+		shutdown.Stage{Name: "drain consumers", Timeout: 5 * time.Second, Run: shutdown.Wait(&consumers)},
+	).Run()
+	if err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	if strings.Contains(logs.String(), "panicked") {
+		t.Errorf("expected no sensor to panic during shutdown, got logs:\n%s", logs.String())
+	}
+	if ids := mgr.IDs(); len(ids) != 0 {
+		t.Errorf("expected all sensors to have stopped, got %v still running", ids)
+	}
+}
+
+// TestSequence_Run_NoSensors verifies a consumer-only run (zero sensors) keeps the data channel open
+// for an external producer until shutdown, then stops the producer before closing the channel,
+// so every forwarded reading is consumed and nothing is sent on the closed channel.
+func TestSequence_Run_NoSensors(t *testing.T) {
+	t.Parallel()
+
+	dataCh := make(chan model.SensorData, 16)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr := sensor.NewManager(nil, nil)
+	mgr.SetLauncher(ctx, dataCh, time.Millisecond, nil)
+	if err := mgr.Ramp(ctx, 0, 0); err != nil {
+		t.Fatalf("unexpected error starting no sensors: %v", err)
+	}
+
+	var consumers sync.WaitGroup
+	var received atomic.Int64
+	consumers.Add(1)
+	go func() {
+		defer consumers.Done()
+		for range dataCh {
+			received.Add(1)
+		}
+	}()
+
+	// The ingester feeds the channel, like readings pushed to POST /ingest, until it's closed.
+	ingester := ingest.New(dataCh, nil, nil)
+	var forwarded atomic.Int64
+	producerDone := make(chan error)
+	go func() {
+		for id := 1; ; id++ {
+			n, err := ingester.Ingest(model.SensorData{ID: id, Value: 1})
+			forwarded.Add(int64(n))
+			if errors.Is(err, ingest.ErrClosed) {
+				producerDone <- err
+				return
+			}
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for received.Load() < 50 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected readings to flow with no sensors, got %d", received.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	err := shutdown.NewSequence(nil,
+		shutdown.Stage{Name: "stop producers", Timeout: time.Second, Run: func(context.Context) error {
+			ingester.Close()
+			return nil
+		}},
+		shutdown.Stage{Name: "wait for sensors", Timeout: time.Second, Run: func(context.Context) error {
+			if stragglers := mgr.Wait(time.Second); len(stragglers) > 0 {
+				return fmt.Errorf("%d sensors still running", len(stragglers))
+			}
+			return nil
+		}},
+		shutdown.Stage{Name: "close data channel", Timeout: time.Second, Run: func(context.Context) error {
+			close(dataCh)
+			return nil
+		}},
+		shutdown.Stage{Name: "drain consumers", Timeout: time.Second, Run: shutdown.Wait(&consumers)},
+	).Run()
+	if err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	select {
+	case <-producerDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected the producer to stop once the ingester was closed")
+	}
+	if got, want := received.Load(), forwarded.Load(); got != want {
+		t.Errorf("expected all %d forwarded readings to be consumed, got %d", want, got)
+	}
+}
@@ -0,0 +1,98 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// ErrQueueFull is returned by AsyncSink.Write when the record is dropped because the sink's queue is full.
+var ErrQueueFull = errors.New("sink queue full")
+
+// AsyncSink wraps a Sink with a bounded queue, written by its own goroutine,
+// so a slow sink fills its queue and drops records rather than stalling upstream.
+//
+// Write only enqueues: errors from the wrapped sink are logged, not returned.
+type AsyncSink struct {
+	name    string
+	next    Sink
+	queue   chan model.SensorData
+	done    chan struct{}
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+
+	mu     sync.RWMutex // Guards closed, so Write never sends on a closed queue.
+	closed bool
+}
+
+// NewAsyncSink wraps next with a queue holding up to queueSize records, and starts writing them to next.
+// name identifies the sink in logs and in the dropped records metric.
+func NewAsyncSink(name string, next Sink, queueSize int, m *metrics.Metrics, l *slog.Logger) *AsyncSink {
+	if l == nil {
+		l = slog.Default() // Fallback to default logger if nil logger provided.
+	}
+
+	s := &AsyncSink{
+		name:    name,
+		next:    next,
+		queue:   make(chan model.SensorData, queueSize),
+		done:    make(chan struct{}),
+		metrics: m,
+		logger:  l.With("component", "async_sink", "sink", name),
+	}
+	go s.run()
+	return s
+}
+
+// run writes queued records to the wrapped sink until the queue is closed and drained.
+func (s *AsyncSink) run() {
+	defer close(s.done)
+
+	for data := range s.queue {
+		// The record was accepted when it was enqueued, so it's written regardless of the caller's context.
+		if err := s.next.Write(context.Background(), data); err != nil {
+			s.logger.Warn("Failed to write record to sink", "sensor_id", data.ID, "error", err)
+		}
+	}
+}
+
+// Write enqueues data without blocking.
+// If the queue is full, data is dropped (and counted) and ErrQueueFull is returned.
+func (s *AsyncSink) Write(_ context.Context, data model.SensorData) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.closed {
+		return fmt.Errorf("sink %s is closed", s.name)
+	}
+
+	select {
+	case s.queue <- data:
+		return nil
+	default:
+		if s.metrics != nil {
+			s.metrics.SinkDropped.WithLabelValues(s.name).Inc()
+		}
+		return ErrQueueFull
+	}
+}
+
+// Close stops accepting records, waits for the queued ones to be written, then closes the wrapped sink.
+func (s *AsyncSink) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	<-s.done
+	return s.next.Close()
+}
@@ -0,0 +1,155 @@
+package sink
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// DefaultCSVFlushInterval is how often a CSVSink flushes its buffered rows to its file, by default.
+const DefaultCSVFlushInterval = time.Second
+
+// csvHeader is the header row of CSV files, naming the columns of each record's row.
+var csvHeader = []string{"id", "value", "timestamp", "type"}
+
+// CSVSink is a Sink that appends records to a CSV file, for offline analysis,
+// as rows of id, value, timestamp (RFC 3339), and type, under a header row.
+// Rows are buffered, and flushed periodically and on Close.
+// Failed writes are counted by the CSV write errors metric; failed periodic flushes are also logged.
+type CSVSink struct {
+	mu   sync.Mutex
+	file *os.File
+	w    *csv.Writer
+
+	stop    chan struct{}
+	stopped chan struct{}
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+}
+
+// NewCSVSink opens (or creates) the CSV file at path for appending, writing the header row if the file is empty,
+// and returns a CSVSink writing to it, which flushes every flushInterval (DefaultCSVFlushInterval if not positive).
+// It fails fast if the file's directory doesn't exist or isn't writable (see checkWritableDir).
+func NewCSVSink(path string, flushInterval time.Duration, m *metrics.Metrics, l *slog.Logger) (*CSVSink, error) {
+	if l == nil {
+		l = slog.Default() // Fallback to default logger if nil logger provided.
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultCSVFlushInterval
+	}
+
+	if err := checkWritableDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to stat CSV file: %w", err)
+	}
+
+	s := &CSVSink{
+		file:    f,
+		w:       csv.NewWriter(f),
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+		metrics: m,
+		logger:  l.With("component", "csv_sink", "path", path),
+	}
+
+	// Appending to an existing file continues under its header.
+	if info.Size() == 0 {
+		if err := s.w.Write(csvHeader); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to write CSV header: %w", err)
+		}
+	}
+
+	go s.flushLoop(flushInterval)
+	return s, nil
+}
+
+// flushLoop flushes buffered rows every interval, until Close is called.
+func (s *CSVSink) flushLoop(interval time.Duration) {
+	defer close(s.stopped)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			err := s.flush()
+			s.mu.Unlock()
+			if err != nil {
+				s.logger.Warn("Failed to flush CSV file", "error", err)
+			}
+		}
+	}
+}
+
+// Write buffers data as a CSV row.
+func (s *CSVSink) Write(_ context.Context, data model.SensorData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	row := []string{
+		strconv.Itoa(data.ID),
+		strconv.FormatFloat(data.Value, 'g', -1, 64),
+		data.Timestamp.Format(time.RFC3339Nano),
+		data.Type,
+	}
+	if err := s.w.Write(row); err != nil {
+		s.countError()
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// flush writes buffered rows to the file. The caller must hold mu.
+func (s *CSVSink) flush() error {
+	s.w.Flush()
+	if err := s.w.Error(); err != nil {
+		s.countError()
+		return fmt.Errorf("failed to flush CSV file: %w", err)
+	}
+	return nil
+}
+
+// countError counts a failed write.
+func (s *CSVSink) countError() {
+	if s.metrics != nil {
+		s.metrics.CSVWriteErrors.Inc()
+	}
+}
+
+// Close stops the periodic flushes, flushes buffered rows, and closes the underlying file.
+func (s *CSVSink) Close() error {
+	close(s.stop)
+	<-s.stopped
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
@@ -0,0 +1,99 @@
+package sink
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// FileSink is a Sink that appends records to a file as newline-delimited JSON.
+// Writes are buffered, so Close must be called to guarantee all records reach the file.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+}
+
+// NewFileSink opens (or creates) the file at path for appending and returns a FileSink writing to it.
+// It fails fast if the file's directory doesn't exist or isn't writable (see checkWritableDir).
+func NewFileSink(path string) (*FileSink, error) {
+	if err := checkWritableDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sink file: %w", err)
+	}
+
+	buf := bufio.NewWriter(f)
+	return &FileSink{
+		file: f,
+		buf:  buf,
+		enc:  json.NewEncoder(buf),
+	}, nil
+}
+
+// checkWritableDir verifies records can be written to dir, by creating and removing a temporary file in it.
+// Sinks check this on construction, so a bad output directory is reported at startup rather than mid-run.
+func checkWritableDir(dir string) error {
+	info, err := os.Stat(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("sink directory %q does not exist", dir)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check sink directory %q: %w", dir, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("sink directory %q is not a directory", dir)
+	}
+
+	f, err := os.CreateTemp(dir, ".sink-preflight-*")
+	if err != nil {
+		return fmt.Errorf("sink directory %q is not writable: %w", dir, err)
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
+
+// Write encodes data as a single JSON line.
+func (s *FileSink) Write(_ context.Context, data model.SensorData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.enc.Encode(data); err != nil {
+		return fmt.Errorf("failed to write record: %w", err)
+	}
+	return nil
+}
+
+// Flush writes the buffered records to the file.
+func (s *FileSink) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to flush sink file: %w", err)
+	}
+	return nil
+}
+
+// Close flushes buffered records and closes the underlying file.
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.buf.Flush(); err != nil {
+		s.file.Close()
+		return fmt.Errorf("failed to flush sink file: %w", err)
+	}
+	return s.file.Close()
+}
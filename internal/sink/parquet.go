@@ -0,0 +1,214 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// DefaultParquetRowGroupSize is how many records a ParquetSink buffers into each row group, by default.
+const DefaultParquetRowGroupSize = 10_000
+
+// ErrSinkClosed is returned by writes to a sink that has been closed.
+var ErrSinkClosed = errors.New("sink closed")
+
+// ParquetOptions configures a ParquetSink.
+type ParquetOptions struct {
+	// RowGroupSize is how many records are buffered, then written as a row group (DefaultParquetRowGroupSize if not positive).
+	RowGroupSize int
+	// RollRecords, when positive, rolls over to a new file once the current one holds this many records.
+	RollRecords int
+	// RollInterval, when positive, rolls over to a new file once the current one is this old.
+	RollInterval time.Duration
+}
+
+// ParquetSink is a Sink that writes records to Parquet files, for analytics, in columns derived from model.SensorData.
+// Records are buffered and written in row groups, to files named after the sink's path, the time the sink was opened,
+// and a sequence number (e.g. data-20250101T120000-0001.parquet), rolling over to a new file as configured.
+//
+// A Parquet file is only readable once its footer is written, when it's closed: on rolling over, on Close,
+// and when the context the sink was opened with is done, so a run that doesn't finish shutting down
+// still leaves readable files. Records written after that go to a new file.
+// Failed writes are counted by the Parquet write errors metric; failed rollovers are also logged.
+type ParquetSink struct {
+	mu      sync.Mutex
+	prefix  string // The path of the files, up to their sequence number.
+	ext     string
+	opts    ParquetOptions
+	seq     int
+	file    *os.File
+	w       *parquet.GenericWriter[model.SensorData]
+	rows    []model.SensorData // Buffered for the next row group.
+	records int                // Written to the current file, including those buffered.
+	roll    *time.Timer        // Rolls the current file over once it's RollInterval old.
+	closed  bool
+
+	stop    func() bool // Stops closing the current file once the context is done.
+	metrics *metrics.Metrics
+	logger  *slog.Logger
+}
+
+// NewParquetSink returns a ParquetSink writing to files named after path (e.g. data.parquet), configured by opts.
+// The current file is closed once ctx is done. Files are created as records arrive, so an idle sink leaves none.
+// It fails fast if the files' directory doesn't exist or isn't writable (see checkWritableDir).
+func NewParquetSink(ctx context.Context, path string, opts ParquetOptions, m *metrics.Metrics, l *slog.Logger) (*ParquetSink, error) {
+	if l == nil {
+		l = slog.Default() // Fallback to default logger if nil logger provided.
+	}
+	if opts.RowGroupSize <= 0 {
+		opts.RowGroupSize = DefaultParquetRowGroupSize
+	}
+
+	if err := checkWritableDir(filepath.Dir(path)); err != nil {
+		return nil, err
+	}
+
+	ext := filepath.Ext(path)
+	s := &ParquetSink{
+		prefix:  fmt.Sprintf("%s-%s-", strings.TrimSuffix(path, ext), time.Now().Format("20060102T150405")),
+		ext:     ext,
+		opts:    opts,
+		rows:    make([]model.SensorData, 0, opts.RowGroupSize),
+		metrics: m,
+		logger:  l.With("component", "parquet_sink", "path", path),
+	}
+	s.stop = context.AfterFunc(ctx, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if err := s.closeFile(); err != nil {
+			s.logger.Warn("Failed to close Parquet file", "error", err)
+		}
+	})
+	return s, nil
+}
+
+// Write buffers data, writing the buffered records as a row group once there are RowGroupSize of them,
+// and rolling over to a new file once the current one holds RollRecords.
+func (s *ParquetSink) Write(_ context.Context, data model.SensorData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return ErrSinkClosed
+	}
+	if s.file == nil {
+		if err := s.openFile(); err != nil {
+			s.countError()
+			return err
+		}
+	}
+
+	s.rows = append(s.rows, data)
+	s.records++
+	if len(s.rows) >= s.opts.RowGroupSize {
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+	if s.opts.RollRecords > 0 && s.records >= s.opts.RollRecords {
+		return s.closeFile()
+	}
+	return nil
+}
+
+// openFile creates the next file, and starts rolling it over after RollInterval. The caller must hold mu.
+func (s *ParquetSink) openFile() error {
+	s.seq++
+	path := fmt.Sprintf("%s%04d%s", s.prefix, s.seq, s.ext)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create Parquet file: %w", err)
+	}
+
+	s.file = f
+	s.w = parquet.NewGenericWriter[model.SensorData](f)
+	s.records = 0
+	if s.opts.RollInterval > 0 {
+		seq := s.seq
+		s.roll = time.AfterFunc(s.opts.RollInterval, func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+
+			if s.seq != seq { // The file was already closed, and another one may have been opened since.
+				return
+			}
+			if err := s.closeFile(); err != nil {
+				s.logger.Warn("Failed to roll Parquet file over", "error", err)
+			}
+		})
+	}
+	return nil
+}
+
+// flush writes the buffered records to the current file as a row group. The caller must hold mu.
+func (s *ParquetSink) flush() error {
+	if len(s.rows) == 0 {
+		return nil
+	}
+
+	defer func() { s.rows = s.rows[:0] }()
+	if _, err := s.w.Write(s.rows); err != nil {
+		s.countError()
+		return fmt.Errorf("failed to write records: %w", err)
+	}
+	if err := s.w.Flush(); err != nil {
+		s.countError()
+		return fmt.Errorf("failed to write row group: %w", err)
+	}
+	return nil
+}
+
+// closeFile writes the buffered records and the footer to the current file, if any, and closes it.
+// The next write opens a new file. The caller must hold mu.
+func (s *ParquetSink) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	if s.roll != nil {
+		s.roll.Stop()
+		s.roll = nil
+	}
+
+	err := s.flush()
+	if err == nil {
+		if err = s.w.Close(); err != nil {
+			s.countError()
+			err = fmt.Errorf("failed to write Parquet footer: %w", err)
+		}
+	}
+	if closeErr := s.file.Close(); closeErr != nil && err == nil {
+		err = fmt.Errorf("failed to close Parquet file: %w", closeErr)
+	}
+	s.file, s.w = nil, nil
+	return err
+}
+
+// countError counts a failed write.
+func (s *ParquetSink) countError() {
+	if s.metrics != nil {
+		s.metrics.ParquetWriteErrors.Inc()
+	}
+}
+
+// Close writes the buffered records and closes the current file, after which writes fail with ErrSinkClosed.
+func (s *ParquetSink) Close() error {
+	s.stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	return s.closeFile()
+}
@@ -0,0 +1,90 @@
+// Package sink provides destinations that sensor data can be archived to.
+// Sinks decouple how readings are stored from how they are produced or transported.
+package sink
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+// Sink is a destination for sensor data.
+type Sink interface {
+	// Write stores a single SensorData record.
+	Write(ctx context.Context, data model.SensorData) error
+	// Close flushes any buffered records and releases the sink's resources.
+	Close() error
+}
+
+// Flusher is implemented by sinks that buffer writes, to push the buffered records to their destination
+// (e.g. from memory to the file), for callers that must know a record is stored before acknowledging it.
+type Flusher interface {
+	Flush() error
+}
+
+// Tap writes every reading received on in to s and forwards it to out, unchanged.
+// It returns once in is closed, closing out, so it can sit between the sensors and their consumers.
+// Failed writes are logged (except records dropped by a full AsyncSink, which are counted instead)
+// and don't hold readings back.
+func Tap(in <-chan model.SensorData, out chan<- model.SensorData, s Sink, l *slog.Logger) {
+	defer close(out)
+
+	if l == nil {
+		l = slog.Default()
+	}
+	logger := l.With("component", "sink_tap")
+
+	for data := range in {
+		if err := s.Write(context.Background(), data); err != nil && !errors.Is(err, ErrQueueFull) {
+			logger.Warn("Failed to write record to sink", "sensor_id", data.ID, "error", err)
+		}
+		out <- data
+	}
+}
+
+// MemorySink is a Sink that keeps all written records in memory.
+// It is safe for concurrent use and is mostly useful in tests.
+type MemorySink struct {
+	mu      sync.Mutex
+	records []model.SensorData
+}
+
+// NewMemorySink creates and returns a new, empty MemorySink.
+func NewMemorySink() *MemorySink {
+	return &MemorySink{}
+}
+
+// Write appends data to the sink's records.
+func (s *MemorySink) Write(_ context.Context, data model.SensorData) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records = append(s.records, data)
+	return nil
+}
+
+// Records returns a copy of all records written to the sink, in write order.
+func (s *MemorySink) Records() []model.SensorData {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make([]model.SensorData, len(s.records))
+	copy(records, s.records)
+	return records
+}
+
+// Len returns the number of records written to the sink.
+func (s *MemorySink) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.records)
+}
+
+// Close is a no-op for a MemorySink.
+func (s *MemorySink) Close() error {
+	return nil
+}
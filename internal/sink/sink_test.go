@@ -0,0 +1,592 @@
+// Package sink_test contains tests for the sink package.
+package sink_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/metrics"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+	"github.com/allthepins/iot-sensor-network-simulator/internal/sink"
+)
+
+// TestMemorySink_Write verifies that a MemorySink keeps written records in order.
+func TestMemorySink_Write(t *testing.T) {
+	t.Parallel()
+
+	s := sink.NewMemorySink()
+	for i := 1; i <= 3; i++ {
+		if err := s.Write(context.Background(), model.SensorData{ID: i}); err != nil {
+			t.Fatalf("unexpected error writing record: %v", err)
+		}
+	}
+
+	records := s.Records()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	for i, r := range records {
+		if r.ID != i+1 {
+			t.Errorf("expected record %d to have ID %d, got %d", i, i+1, r.ID)
+		}
+	}
+}
+
+// TestFileSink_Write verifies that a FileSink writes one JSON record per line,
+// which reach the file once flushed.
+func TestFileSink_Write(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.ndjson")
+	s, err := sink.NewFileSink(path)
+	if err != nil {
+		t.Fatalf("NewFileSink returned error: %v", err)
+	}
+
+	want := []model.SensorData{
+		{ID: 1, Value: 0.25, Timestamp: time.Unix(1, 0).UTC()},
+		{ID: 2, Value: 0.75, Timestamp: time.Unix(2, 0).UTC()},
+	}
+	for _, d := range want {
+		if err := s.Write(context.Background(), d); err != nil {
+			t.Fatalf("unexpected error writing record: %v", err)
+		}
+	}
+	if err := s.Flush(); err != nil {
+		t.Fatalf("unexpected error flushing sink: %v", err)
+	}
+	if contents, _ := os.ReadFile(path); strings.Count(string(contents), "\n") != len(want) {
+		t.Errorf("expected %d lines in the file once flushed, got %q", len(want), contents)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing sink: %v", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open sink file: %v", err)
+	}
+	defer f.Close()
+
+	var got []model.SensorData
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var d model.SensorData
+		if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+			t.Fatalf("failed to decode line %q: %v", scanner.Text(), err)
+		}
+		got = append(got, d)
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].Value != want[i].Value || !got[i].Timestamp.Equal(want[i].Timestamp) {
+			t.Errorf("record %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+// TestNewFileSink_UnwritableDirectory verifies a FileSink can't be created in a missing or read-only directory,
+// and that the error names the directory.
+func TestNewFileSink_UnwritableDirectory(t *testing.T) {
+	t.Parallel()
+
+	t.Run("missing", func(t *testing.T) {
+		t.Parallel()
+
+		dir := filepath.Join(t.TempDir(), "missing")
+		_, err := sink.NewFileSink(filepath.Join(dir, "data.ndjson"))
+		if err == nil || !strings.Contains(err.Error(), dir) {
+			t.Fatalf("expected an error naming %q, got %v", dir, err)
+		}
+	})
+
+	t.Run("read-only", func(t *testing.T) {
+		t.Parallel()
+
+		if os.Geteuid() == 0 {
+			t.Skip("directory permissions aren't enforced for root")
+		}
+
+		dir := t.TempDir()
+		if err := os.Chmod(dir, 0o555); err != nil {
+			t.Fatalf("failed to make directory read-only: %v", err)
+		}
+		t.Cleanup(func() { os.Chmod(dir, 0o755) })
+
+		_, err := sink.NewFileSink(filepath.Join(dir, "data.ndjson"))
+		if err == nil || !strings.Contains(err.Error(), "not writable") {
+			t.Fatalf("expected a not writable error, got %v", err)
+		}
+	})
+}
+
+// TestSocketSink_Write_TCP verifies that a SocketSink sends records as JSON lines over TCP.
+func TestSocketSink_Write_TCP(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	received := make(chan []model.SensorData, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		defer conn.Close()
+
+		var records []model.SensorData
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			var d model.SensorData
+			if err := json.Unmarshal(scanner.Bytes(), &d); err != nil {
+				break
+			}
+			records = append(records, d)
+		}
+		received <- records
+	}()
+
+	s, err := sink.NewSocketSink("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("NewSocketSink returned error: %v", err)
+	}
+
+	want := []model.SensorData{
+		{ID: 1, Value: 0.25, Timestamp: time.Unix(1, 0).UTC()},
+		{ID: 2, Value: 0.75, Timestamp: time.Unix(2, 0).UTC()},
+	}
+	for _, d := range want {
+		if err := s.Write(context.Background(), d); err != nil {
+			t.Fatalf("unexpected error writing record: %v", err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing sink: %v", err)
+	}
+
+	select {
+	case got := <-received:
+		if len(got) != len(want) {
+			t.Fatalf("expected %d records, got %d", len(want), len(got))
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID || got[i].Value != want[i].Value || !got[i].Timestamp.Equal(want[i].Timestamp) {
+				t.Errorf("record %d: expected %+v, got %+v", i, want[i], got[i])
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for records")
+	}
+}
+
+// TestNewSocketSink_UnsupportedNetwork verifies networks other than TCP and UDP are rejected.
+func TestNewSocketSink_UnsupportedNetwork(t *testing.T) {
+	t.Parallel()
+
+	if _, err := sink.NewSocketSink("unix", "/tmp/collector.sock"); err == nil {
+		t.Error("expected an error for an unsupported network, got nil")
+	}
+}
+
+// TestSocketSink_Write_StalledPeer verifies a write to a TCP peer that stops reading gives up once its context is done,
+// rather than blocking forever.
+func TestSocketSink_Write_StalledPeer(t *testing.T) {
+	t.Parallel()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer ln.Close()
+
+	// Accept the connection, and never read from it.
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	s, err := sink.NewSocketSink("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("NewSocketSink returned error: %v", err)
+	}
+	defer s.Close()
+	defer func() {
+		if conn := <-accepted; conn != nil {
+			conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			// Writes succeed until the connection's buffers fill up, then block.
+			if err := s.Write(ctx, model.SensorData{ID: 1, Value: 0.5, Timestamp: time.Unix(1, 0).UTC()}); err != nil {
+				failed <- err
+				return
+			}
+		}
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-failed:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the write to fail with context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for the write to give up")
+	}
+}
+
+// TestParseSocketAddr verifies socket sink addresses are split into their network and address, and malformed ones rejected.
+func TestParseSocketAddr(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		in          string
+		wantNetwork string
+		wantAddr    string
+		wantErr     bool
+	}{
+		"tcp":          {in: "tcp://collector:5000", wantNetwork: "tcp", wantAddr: "collector:5000"},
+		"udp":          {in: "udp://127.0.0.1:514", wantNetwork: "udp", wantAddr: "127.0.0.1:514"},
+		"no network":   {in: "collector:5000", wantErr: true},
+		"unsupported":  {in: "unix:///tmp/collector.sock", wantErr: true},
+		"missing port": {in: "tcp://collector", wantErr: true},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			network, addr, err := sink.ParseSocketAddr(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("expected an error for %q, got nil", tc.in)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if network != tc.wantNetwork || addr != tc.wantAddr {
+				t.Errorf("expected %s and %s, got %s and %s", tc.wantNetwork, tc.wantAddr, network, addr)
+			}
+		})
+	}
+}
+
+// slowSink is a Sink whose writes block until release is closed.
+type slowSink struct {
+	*sink.MemorySink
+	release chan struct{}
+}
+
+func (s *slowSink) Write(ctx context.Context, data model.SensorData) error {
+	<-s.release
+	return s.MemorySink.Write(ctx, data)
+}
+
+// TestAsyncSink_Write_SlowSink verifies a slow sink doesn't block writers:
+// records past the queue's capacity are dropped and counted, and queued records are written on Close.
+func TestAsyncSink_Write_SlowSink(t *testing.T) {
+	t.Parallel()
+
+	slow := &slowSink{MemorySink: sink.NewMemorySink(), release: make(chan struct{})}
+	m := metrics.NewMetrics(prometheus.NewRegistry(), metrics.ConfigInfo{})
+	s := sink.NewAsyncSink("slow", slow, 2, m, nil)
+
+	// The sink's goroutine takes one record and blocks writing it, and two more fill the queue.
+	// Every write after that must return immediately with ErrQueueFull.
+	const writes = 10
+	done := make(chan int)
+	go func() {
+		dropped := 0
+		for i := 1; i <= writes; i++ {
+			if err := s.Write(context.Background(), model.SensorData{ID: i}); errors.Is(err, sink.ErrQueueFull) {
+				dropped++
+			} else if err != nil {
+				t.Errorf("unexpected error writing record %d: %v", i, err)
+			}
+		}
+		done <- dropped
+	}()
+
+	var dropped int
+	select {
+	case dropped = <-done:
+	case <-time.After(time.Second):
+		t.Fatal("writes blocked on the slow sink")
+	}
+
+	if dropped < writes-3 {
+		t.Errorf("expected at least %d dropped records, got %d", writes-3, dropped)
+	}
+	if got := testutil.ToFloat64(m.SinkDropped.WithLabelValues("slow")); int(got) != dropped {
+		t.Errorf("expected dropped metric %d, got %v", dropped, got)
+	}
+
+	close(slow.release)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if got := slow.Len(); got != writes-dropped {
+		t.Errorf("expected %d records written, got %d", writes-dropped, got)
+	}
+}
+
+// TestCSVSink_Write verifies a CSVSink writes a header and one row per record,
+// flushing periodically, and that reopening the file appends rows without another header.
+func TestCSVSink_Write(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "data.csv")
+	ts := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	s, err := sink.NewCSVSink(path, 5*time.Millisecond, nil, nil)
+	if err != nil {
+		t.Fatalf("NewCSVSink returned error: %v", err)
+	}
+	if err := s.Write(context.Background(), model.SensorData{ID: 1, Value: 0.25, Timestamp: ts, Type: "temperature"}); err != nil {
+		t.Fatalf("unexpected error writing record: %v", err)
+	}
+
+	// The row is flushed periodically, before the sink is closed.
+	wantFlushed := "id,value,timestamp,type\n1,0.25,2025-01-01T12:00:00Z,temperature\n"
+	deadline := time.Now().Add(time.Second)
+	for {
+		contents, _ := os.ReadFile(path)
+		if string(contents) == wantFlushed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the row to be flushed, got %q", contents)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing sink: %v", err)
+	}
+
+	s, err = sink.NewCSVSink(path, time.Hour, nil, nil)
+	if err != nil {
+		t.Fatalf("NewCSVSink returned error reopening the file: %v", err)
+	}
+	if err := s.Write(context.Background(), model.SensorData{ID: 2, Value: 1.5, Timestamp: ts, Type: "humidity"}); err != nil {
+		t.Fatalf("unexpected error writing record: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing sink: %v", err)
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read CSV file: %v", err)
+	}
+	if want := wantFlushed + "2,1.5,2025-01-01T12:00:00Z,humidity\n"; string(contents) != want {
+		t.Errorf("expected the file to contain\n%q, got\n%q", want, contents)
+	}
+}
+
+// readParquet returns the records in the Parquet files matching pattern, in file order, and each file's row group sizes.
+func readParquet(t *testing.T, pattern string) ([][]model.SensorData, [][]int64) {
+	t.Helper()
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatalf("failed to list Parquet files: %v", err)
+	}
+	var records [][]model.SensorData
+	var rowGroups [][]int64
+	for _, path := range paths { // Glob sorts the paths, so the files are in sequence.
+		f, err := os.Open(path)
+		if err != nil {
+			t.Fatalf("failed to open Parquet file: %v", err)
+		}
+		defer f.Close()
+		info, err := f.Stat()
+		if err != nil {
+			t.Fatalf("failed to stat Parquet file: %v", err)
+		}
+		pf, err := parquet.OpenFile(f, info.Size())
+		if err != nil {
+			t.Fatalf("failed to open %s as a Parquet file: %v", path, err)
+		}
+		var sizes []int64
+		for _, rg := range pf.RowGroups() {
+			sizes = append(sizes, rg.NumRows())
+		}
+		rows, err := parquet.Read[model.SensorData](f, info.Size())
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", path, err)
+		}
+		records = append(records, rows)
+		rowGroups = append(rowGroups, sizes)
+	}
+	return records, rowGroups
+}
+
+// TestParquetSink_Write verifies a ParquetSink writes records in row groups of the configured size,
+// rolling over to a new file every RollRecords, and that the records read back unchanged.
+func TestParquetSink_Write(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	s, err := sink.NewParquetSink(context.Background(), filepath.Join(dir, "data.parquet"), sink.ParquetOptions{RowGroupSize: 2, RollRecords: 3}, nil, nil)
+	if err != nil {
+		t.Fatalf("NewParquetSink returned error: %v", err)
+	}
+
+	ts := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var want []model.SensorData
+	for i := 1; i <= 5; i++ {
+		data := model.SensorData{
+			SchemaVersion: model.SchemaVersion,
+			ID:            i,
+			DeviceID:      "dev-" + strconv.Itoa(i),
+			Type:          "temperature",
+			Unit:          "celsius",
+			Value:         20 + float64(i)/4,
+			Timestamp:     ts.Add(time.Duration(i) * time.Millisecond),
+			Tags:          map[string]string{"site": "north"},
+			Latitude:      51.5,
+			Longitude:     -0.12,
+		}
+		want = append(want, data)
+		if err := s.Write(context.Background(), data); err != nil {
+			t.Fatalf("unexpected error writing record %d: %v", i, err)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing sink: %v", err)
+	}
+	if err := s.Write(context.Background(), model.SensorData{ID: 6}); !errors.Is(err, sink.ErrSinkClosed) {
+		t.Errorf("expected ErrSinkClosed writing to a closed sink, got %v", err)
+	}
+
+	records, rowGroups := readParquet(t, filepath.Join(dir, "data-*.parquet"))
+	if got := fmt.Sprint(rowGroups); got != "[[2 1] [2]]" {
+		t.Errorf("expected files of row groups [[2 1] [2]], got %s", got)
+	}
+	var got []model.SensorData
+	for _, rows := range records {
+		got = append(got, rows...)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d records, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if !got[i].Timestamp.Equal(want[i].Timestamp) {
+			t.Errorf("record %d: expected timestamp %v, got %v", i, want[i].Timestamp, got[i].Timestamp)
+		}
+		got[i].Timestamp = want[i].Timestamp
+		if !reflect.DeepEqual(got[i], want[i]) {
+			t.Errorf("record %d: expected %+v, got %+v", i, want[i], got[i])
+		}
+	}
+}
+
+// TestParquetSink_Close_OnCancel verifies a ParquetSink closes its current file once its context is done,
+// and rolls over once the file is RollInterval old, so the files are readable before the sink is closed.
+func TestParquetSink_Close_OnCancel(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		opts   sink.ParquetOptions
+		cancel bool
+	}{
+		"context done":  {opts: sink.ParquetOptions{}, cancel: true},
+		"roll interval": {opts: sink.ParquetOptions{RollInterval: 10 * time.Millisecond}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			s, err := sink.NewParquetSink(ctx, filepath.Join(dir, "data.parquet"), tt.opts, nil, nil)
+			if err != nil {
+				t.Fatalf("NewParquetSink returned error: %v", err)
+			}
+			defer s.Close()
+			for i := 1; i <= 3; i++ {
+				if err := s.Write(context.Background(), model.SensorData{ID: i}); err != nil {
+					t.Fatalf("unexpected error writing record %d: %v", i, err)
+				}
+			}
+			if tt.cancel {
+				cancel()
+			}
+
+			// The file is closed in the background, so wait for its footer to be written.
+			deadline := time.Now().Add(time.Second)
+			for {
+				paths, _ := filepath.Glob(filepath.Join(dir, "data-*.parquet"))
+				if len(paths) == 1 {
+					if f, err := parquet.ReadFile[model.SensorData](paths[0]); err == nil {
+						if len(f) != 3 {
+							t.Errorf("expected 3 records, got %d", len(f))
+						}
+						return
+					}
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("expected a readable Parquet file before the sink was closed, got %v", paths)
+				}
+				time.Sleep(5 * time.Millisecond)
+			}
+		})
+	}
+}
+
+// TestTap verifies every reading is written to the sink and forwarded unchanged, and that out is closed with in.
+func TestTap(t *testing.T) {
+	t.Parallel()
+
+	in := make(chan model.SensorData, 3)
+	out := make(chan model.SensorData, 3)
+	s := sink.NewMemorySink()
+	for i := 1; i <= 3; i++ {
+		in <- model.SensorData{ID: i}
+	}
+	close(in)
+
+	sink.Tap(in, out, s, nil)
+
+	var forwarded []int
+	for data := range out {
+		forwarded = append(forwarded, data.ID)
+	}
+	if len(forwarded) != 3 || s.Len() != 3 {
+		t.Errorf("expected 3 readings forwarded and written, got %v forwarded and %d written", forwarded, s.Len())
+	}
+}
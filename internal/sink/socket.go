@@ -0,0 +1,156 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/allthepins/iot-sensor-network-simulator/internal/model"
+)
+
+const (
+	// socketDialTimeout bounds connecting (or reconnecting) to the socket sink's address.
+	socketDialTimeout = 5 * time.Second
+	// socketWriteTimeout bounds each write, so a peer that stops reading can't block the sink forever.
+	socketWriteTimeout = 5 * time.Second
+	// socketWriteRetries is how many times a failed TCP write is retried on a fresh connection.
+	socketWriteRetries = 3
+	// socketRetryBackoff is how long the first reconnect waits, doubling with each further one.
+	socketRetryBackoff = 100 * time.Millisecond
+)
+
+// SocketSink is a Sink that writes records as newline-delimited JSON over a TCP or UDP connection,
+// e.g. to a legacy collector. Over TCP, a failed write reconnects and retries, backing off between attempts.
+// Over UDP, records are sent fire-and-forget, one datagram per record.
+// Each write gives up after socketWriteTimeout, or sooner if its context is done.
+type SocketSink struct {
+	mu      sync.Mutex
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+// NewSocketSink connects to addr (as "host:port") over network, which is "tcp" or "udp",
+// and returns a SocketSink writing to it.
+func NewSocketSink(network, addr string) (*SocketSink, error) {
+	if network != "tcp" && network != "udp" {
+		return nil, fmt.Errorf("unsupported socket sink network %q", network)
+	}
+
+	s := &SocketSink{network: network, addr: addr}
+	if err := s.dial(context.Background()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ParseSocketAddr splits a socket sink's address, "tcp://host:port" or "udp://host:port",
+// into the network and the address to pass to NewSocketSink.
+func ParseSocketAddr(s string) (network, addr string, err error) {
+	network, addr, ok := strings.Cut(s, "://")
+	if ok && (network == "tcp" || network == "udp") {
+		if _, _, err := net.SplitHostPort(addr); err == nil {
+			return network, addr, nil
+		}
+	}
+	return "", "", fmt.Errorf("socket sink address must be tcp://host:port or udp://host:port, got %q", s)
+}
+
+// dial (re)connects the sink. The caller must hold s.mu, unless the sink is being constructed.
+func (s *SocketSink) dial(ctx context.Context) error {
+	d := net.Dialer{Timeout: socketDialTimeout}
+	conn, err := d.DialContext(ctx, s.network, s.addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect socket sink to %s://%s: %w", s.network, s.addr, err)
+	}
+	s.conn = conn
+	return nil
+}
+
+// Write encodes data as a single JSON line and sends it.
+// Over TCP, a failed write is retried (up to socketWriteRetries times) on a new connection,
+// after a backoff that doubles from socketRetryBackoff.
+func (s *SocketSink) Write(ctx context.Context, data model.SensorData) error {
+	line, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == "udp" {
+		if err := s.send(ctx, line); err != nil {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+		return nil
+	}
+
+	backoff := socketRetryBackoff
+	for attempt := 0; ; attempt++ {
+		if s.conn == nil {
+			err = s.dial(ctx)
+		}
+		if s.conn != nil {
+			if err = s.send(ctx, line); err == nil {
+				return nil
+			}
+			// The connection is broken, or may hold part of the line; drop it so the next attempt reconnects.
+			s.conn.Close()
+			s.conn = nil
+		}
+
+		if attempt >= socketWriteRetries {
+			return fmt.Errorf("failed to write record: %w", err)
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("failed to write record: %w", errors.Join(err, ctx.Err()))
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// send writes line to the current connection, giving up after socketWriteTimeout, at ctx's deadline if sooner,
+// or as soon as ctx is done. The caller must hold s.mu.
+func (s *SocketSink) send(ctx context.Context, line []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	deadline := time.Now().Add(socketWriteTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := s.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	conn := s.conn
+	stop := context.AfterFunc(ctx, func() { conn.SetWriteDeadline(time.Now()) }) // Unblocks the write.
+	defer stop()
+
+	_, err := conn.Write(line)
+	return err
+}
+
+// Close closes the underlying connection.
+func (s *SocketSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}